@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"go-auth-app/config"
 	"go-auth-app/db"
 	"go-auth-app/internal/delivery"
@@ -10,6 +11,9 @@ import (
 	"go-auth-app/internal/usecase"
 	"go-auth-app/pkg"
 	"log"
+	"time"
+	// Profile time zones are validated against the IANA database, which the runtime image lacks
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +30,29 @@ func main() {
 	// Run database migrations
 	db.RunMigrations()
 
+	// Configure JWT issuer, audience and leeway
+	pkg.ConfigureJWT(pkg.JWTConfig{
+		Secret:   []byte(cfg.JWTSecret),
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   time.Duration(cfg.JWTLeeway) * time.Second,
+	})
+
+	// Load asymmetric JWT signing keys unless the shared secret is used
+	if cfg.JWTSigningAlgorithm != "HS256" {
+		keyManager, err := pkg.NewKeyManager(
+			cfg.JWTKeyDir,
+			cfg.JWTSigningAlgorithm,
+			time.Duration(cfg.JWTKeyRotation)*time.Hour,
+			time.Duration(cfg.JWTKeyRetention)*time.Hour,
+		)
+		if err != nil {
+			log.Fatalf("Failed to load JWT signing keys: %v", err)
+		}
+		keyManager.StartRotation(time.Hour)
+		pkg.SetKeyManager(keyManager)
+	}
+
 	// Initialize NATS client
 	natsClient, err := pkg.NewNatsClient(cfg.NatsURL, cfg.NatsReconnect)
 	if err != nil {
@@ -36,23 +63,83 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository()
 	chatRepo := repository.NewChatRepository()
+	refreshTokenRepo := repository.NewRefreshTokenRepository()
+	revocationRepo := repository.NewRevocationRepository()
+	userTokenRepo := repository.NewUserTokenRepository()
+	mfaRepo := repository.NewMFARepository()
+	loginAttemptRepo := repository.NewLoginAttemptRepository()
+	roleRepo := repository.NewRoleRepository()
+	apiKeyRepo := repository.NewAPIKeyRepository()
+	sessionRepo := repository.NewSessionRepository()
+	auditRepo := repository.NewAuditRepository()
+	oidcRepo := repository.NewOIDCRepository()
+	oauthRepo := repository.NewOAuthRepository()
+	webauthnRepo := repository.NewWebAuthnRepository()
+
+	// Initialize mailer
+	var mailer pkg.Mailer
+	if cfg.MailDriver == "smtp" {
+		mailer = pkg.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	} else {
+		mailer = pkg.NewLogMailer(cfg.MailDir, cfg.MailFrom)
+	}
 
 	// Initialize NATS-related components
 	natsService := service.NewNATSService(natsClient)
 	natsUsecase := usecase.NewNATSUsecase(natsClient)
+	revocationService := service.NewRevocationService(natsClient)
+	if err := revocationService.Subscribe(); err != nil {
+		log.Printf("Failed to subscribe to token revocations: %v", err)
+	}
+	defer revocationService.Close()
+	auditService := service.NewAuditService(auditRepo, natsClient, cfg.AuditNATSSubject)
+	auditService.StartWriter()
+	defer auditService.Close()
 
 	// Initialize usecases
-	authUsecase := usecase.NewAuthUsecase(userRepo)
-	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, natsService)
+	authUsecase := usecase.NewAuthUsecase(usecase.AuthDependencies{
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		RevocationRepo:    revocationRepo,
+		RevocationService: revocationService,
+		UserTokenRepo:     userTokenRepo,
+		MFARepo:           mfaRepo,
+		LoginAttemptRepo:  loginAttemptRepo,
+		RoleRepo:          roleRepo,
+		APIKeyRepo:        apiKeyRepo,
+		SessionRepo:       sessionRepo,
+		ChatRepo:          chatRepo,
+		OIDCRepo:          oidcRepo,
+		OAuthRepo:         oauthRepo,
+		WebAuthnRepo:      webauthnRepo,
+		AuditService:      auditService,
+		Mailer:            mailer,
+	}, cfg)
+	authUsecase.StartRevocationSync(time.Minute)
+	authUsecase.StartLoginAttemptCleanup(time.Hour)
+	authUsecase.StartAccountPurge(time.Hour)
+	authUsecase.StartOIDCStateCleanup(time.Hour)
+	authUsecase.StartOAuthCodeCleanup(time.Hour)
+	authUsecase.StartWebAuthnChallengeCleanup(time.Hour)
+	authUsecase.BootstrapAdmins(context.Background(), cfg.BootstrapAdminEmails)
+	chatUsecase := usecase.NewChatUsecase(chatRepo, userRepo, natsService, cfg)
+	if err := chatUsecase.Presence.Start(); err != nil {
+		log.Printf("Failed to share presence with other instances: %v", err)
+	}
+	defer chatUsecase.Presence.Close()
 
 	// Initialize handlers
 	authHandler := delivery.NewAuthHandler(authUsecase)
 	chatHandler := delivery.NewChatHandler(chatUsecase)
-	wsHandler := delivery.NewWebSocketHandler(chatUsecase, natsService)
-	natsHandler := delivery.NewNATSHandler(natsUsecase)
+	wsHandler := delivery.NewWebSocketHandler(chatUsecase, natsService, revocationService)
+	natsHandler := delivery.NewNATSHandler(natsUsecase, auditService)
 
 	// Initialize router
 	router := gin.Default()
+	// Per-IP login throttling relies on the client IP, so only trust forwarded headers from known proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(delivery.ErrorHandlerMiddleware())
 
 	// Setup routes
@@ -3,8 +3,10 @@ package config
 import (
 	"github.com/joho/godotenv"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -16,9 +18,103 @@ type Config struct {
 	DBName        string
 	DBSSLMode     string
 	JWTSecret     string
-	JWTExpiration string
 	NatsURL       string
 	NatsReconnect bool
+	// AccessTokenExpiration is the access token lifetime in minutes
+	AccessTokenExpiration int
+	// RefreshTokenExpiration is the refresh token lifetime in hours
+	RefreshTokenExpiration int
+	// JWTSigningAlgorithm is HS256 (shared secret), RS256 or EdDSA
+	JWTSigningAlgorithm string
+	JWTKeyDir           string
+	// JWTKeyRotation is how often a new signing key is generated, in hours
+	JWTKeyRotation int
+	// JWTKeyRetention is how long a retired key is still served for verification, in hours
+	JWTKeyRetention int
+	// JWTIssuer identifies this deployment in the iss claim
+	JWTIssuer string
+	// JWTAudience lists the audiences written to and required in the aud claim
+	JWTAudience []string
+	// JWTLeeway is the tolerated clock skew in seconds
+	JWTLeeway int
+	// AppBaseURL is used to build links sent by email
+	AppBaseURL string
+	// MailDriver is "log" for local development or "smtp"
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// PasswordResetExpiration is the reset token lifetime in minutes
+	PasswordResetExpiration int
+	// PasswordResetURL is the page emailed reset links open, with the token
+	// appended as ?token=. It defaults to the reset page of this app.
+	PasswordResetURL string
+	// MagicLinkExpiration is the lifetime of emailed sign-in links in minutes
+	MagicLinkExpiration int
+	// MagicLinkURL is the page emailed sign-in links open, with the token
+	// appended as ?token=. It defaults to the confirmation page of this app.
+	MagicLinkURL string
+	// RequireEmailVerification blocks login and chat for unverified accounts
+	RequireEmailVerification bool
+	// EmailVerificationExpiration is the verification token lifetime in hours
+	EmailVerificationExpiration int
+	// EmailVerificationResendInterval is the minimum delay between verification emails in seconds
+	EmailVerificationResendInterval int
+	// MFAIssuer is the account issuer shown in authenticator apps
+	MFAIssuer string
+	// MFATokenExpiration is how long a login may wait for its second factor, in minutes
+	MFATokenExpiration int
+	// LoginMaxAttempts is the number of failed logins per account before it is locked
+	LoginMaxAttempts int
+	// LoginIPMaxAttempts is the number of failed logins per client IP before it is locked
+	LoginIPMaxAttempts int
+	// LoginLockoutDuration is how long a lockout lasts, in minutes
+	LoginLockoutDuration int
+	// LoginBackoffBase and LoginBackoffMax bound the delay between failed logins, in seconds
+	LoginBackoffBase int
+	LoginBackoffMax  int
+	// LoginAttemptWindow is how long failures are remembered without a new one, in minutes
+	LoginAttemptWindow int
+	// TrustedProxies lists the proxies whose X-Forwarded-For header is believed for the client IP
+	TrustedProxies []string
+	// BootstrapAdminEmails are existing accounts promoted to admin on startup
+	BootstrapAdminEmails []string
+	// AccountDeletionGracePeriod is how long a deleted account is kept before it is purged, in days
+	AccountDeletionGracePeriod int
+	// ImpersonationTokenExpiration is the lifetime of tokens administrators use to act as a user, in minutes
+	ImpersonationTokenExpiration int
+	// AuditNATSSubject is the NATS subject audit events are mirrored to; empty disables mirroring
+	AuditNATSSubject string
+	// AuditHMACKey keys the digests that stand in for email addresses in the audit log; it defaults to JWTSecret
+	AuditHMACKey string
+	// OIDCProviders are the external identity providers users can sign in with
+	OIDCProviders []OIDCProviderConfig
+	// OAuthIssuerURL identifies this app as an identity provider to OAuth clients; it defaults to AppBaseURL
+	OAuthIssuerURL string
+	// OAuthCodeExpiration is how long an OAuth client has to redeem an authorization code, in seconds
+	OAuthCodeExpiration int
+	// WebAuthnRPID is the domain passkeys are bound to; it defaults to the host of AppBaseURL
+	WebAuthnRPID string
+	// WebAuthnRPName is the name authenticators show when creating a passkey
+	WebAuthnRPName string
+	// WebAuthnOrigins are the origins passkey ceremonies may run on; they default to the origin of AppBaseURL
+	WebAuthnOrigins []string
+}
+
+// OIDCProviderConfig configures an OpenID Connect identity provider. Providers
+// are listed in OIDC_PROVIDERS and configured with OIDC_<NAME>_* variables.
+type OIDCProviderConfig struct {
+	// Name appears in the login URL, /auth/oidc/<name>/login
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL defaults to APP_BASE_URL/auth/oidc/<name>/callback
+	RedirectURL string
+	Scopes      []string
 }
 
 func LoadEnv() {
@@ -41,7 +137,7 @@ func LoadConfig() *Config {
 		log.Println("Warning: No .env file found, using environment variables")
 	}
 
-	return &Config{
+	cfg := &Config{
 		Port:          Getenv("PORT", "8000"),
 		DBHost:        Getenv("DB_HOST", "localhost"),
 		DBPort:        Getenv("DB_PORT", "5432"),
@@ -50,10 +146,117 @@ func LoadConfig() *Config {
 		DBName:        Getenv("DB_NAME", "go_auth_db"),
 		DBSSLMode:     Getenv("DB_SSLMODE", "disable"),
 		JWTSecret:     Getenv("JWT_SECRET", "default_jwt_secret_key"),
-		JWTExpiration: Getenv("JWT_EXPIRATION_HOURS", "24"),
 		NatsURL:       Getenv("NATS_URL", "nats://localhost:4222"),
 		NatsReconnect: GetenvBool("NATS_RECONNECT", true),
+
+		AccessTokenExpiration:  loadAccessTokenExpiration(),
+		RefreshTokenExpiration: GetenvInt("REFRESH_TOKEN_EXPIRATION_HOURS", 720),
+
+		JWTSigningAlgorithm: Getenv("JWT_SIGNING_ALGORITHM", "HS256"),
+		JWTKeyDir:           Getenv("JWT_KEY_DIR", "keys"),
+		JWTKeyRotation:      GetenvInt("JWT_KEY_ROTATION_HOURS", 720),
+		JWTKeyRetention:     GetenvInt("JWT_KEY_RETENTION_HOURS", 48),
+		JWTIssuer:           Getenv("JWT_ISSUER", "go-auth-app"),
+		JWTAudience:         GetenvList("JWT_AUDIENCE", []string{"go-auth-app"}),
+		JWTLeeway:           GetenvInt("JWT_LEEWAY_SECONDS", 30),
+
+		AppBaseURL:   Getenv("APP_BASE_URL", "http://localhost:8000"),
+		MailDriver:   Getenv("MAIL_DRIVER", "log"),
+		MailFrom:     Getenv("MAIL_FROM", "no-reply@go-auth-app.local"),
+		MailDir:      Getenv("MAIL_DIR", ""),
+		SMTPHost:     Getenv("SMTP_HOST", "localhost"),
+		SMTPPort:     Getenv("SMTP_PORT", "1025"),
+		SMTPUsername: Getenv("SMTP_USERNAME", ""),
+		SMTPPassword: Getenv("SMTP_PASSWORD", ""),
+
+		PasswordResetExpiration: GetenvInt("PASSWORD_RESET_EXPIRATION_MINUTES", 30),
+		MagicLinkExpiration:     GetenvInt("MAGIC_LINK_EXPIRATION_MINUTES", 15),
+
+		RequireEmailVerification:        GetenvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationExpiration:     GetenvInt("EMAIL_VERIFICATION_EXPIRATION_HOURS", 24),
+		EmailVerificationResendInterval: GetenvInt("EMAIL_VERIFICATION_RESEND_SECONDS", 60),
+
+		MFAIssuer:          Getenv("MFA_ISSUER", "go-auth-app"),
+		MFATokenExpiration: GetenvInt("MFA_TOKEN_EXPIRATION_MINUTES", 5),
+
+		LoginMaxAttempts:     GetenvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:   GetenvInt("LOGIN_IP_MAX_ATTEMPTS", 50),
+		LoginLockoutDuration: GetenvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		LoginBackoffBase:     GetenvInt("LOGIN_BACKOFF_BASE_SECONDS", 1),
+		LoginBackoffMax:      GetenvInt("LOGIN_BACKOFF_MAX_SECONDS", 60),
+		LoginAttemptWindow:   GetenvInt("LOGIN_ATTEMPT_WINDOW_MINUTES", 15),
+		TrustedProxies:       GetenvList("TRUSTED_PROXIES", []string{}),
+		BootstrapAdminEmails: GetenvList("BOOTSTRAP_ADMIN_EMAILS", []string{}),
+
+		AccountDeletionGracePeriod:   GetenvInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
+		ImpersonationTokenExpiration: GetenvInt("IMPERSONATION_TOKEN_EXPIRATION_MINUTES", 15),
+
+		AuditNATSSubject: Getenv("AUDIT_NATS_SUBJECT", "audit.events"),
+	}
+
+	cfg.AuditHMACKey = Getenv("AUDIT_HMAC_KEY", cfg.JWTSecret)
+	cfg.PasswordResetURL = Getenv("PASSWORD_RESET_URL", strings.TrimSuffix(cfg.AppBaseURL, "/")+"/password/reset")
+	cfg.MagicLinkURL = Getenv("MAGIC_LINK_URL", strings.TrimSuffix(cfg.AppBaseURL, "/")+"/login/magic/verify")
+	cfg.OIDCProviders = loadOIDCProviders(cfg.AppBaseURL)
+	cfg.OAuthIssuerURL = strings.TrimSuffix(Getenv("OAUTH_ISSUER_URL", cfg.AppBaseURL), "/")
+	cfg.OAuthCodeExpiration = GetenvInt("OAUTH_CODE_EXPIRATION_SECONDS", 60)
+
+	var appHost, appOrigin string
+	if appURL, err := url.Parse(cfg.AppBaseURL); err == nil {
+		appHost, appOrigin = appURL.Hostname(), appURL.Scheme+"://"+appURL.Host
+	}
+	cfg.WebAuthnRPID = Getenv("WEBAUTHN_RP_ID", appHost)
+	cfg.WebAuthnRPName = Getenv("WEBAUTHN_RP_NAME", "go-auth-app")
+	cfg.WebAuthnOrigins = GetenvList("WEBAUTHN_ORIGINS", []string{appOrigin})
+	return cfg
+}
+
+// loadAccessTokenExpiration reads ACCESS_TOKEN_EXPIRATION_MINUTES. Deployments
+// still setting the deprecated JWT_EXPIRATION_HOURS keep their lifetime when the
+// new setting is absent.
+func loadAccessTokenExpiration() int {
+	hours, legacy := os.LookupEnv("JWT_EXPIRATION_HOURS")
+	if !legacy {
+		return GetenvInt("ACCESS_TOKEN_EXPIRATION_MINUTES", 15)
+	}
+
+	if _, exists := os.LookupEnv("ACCESS_TOKEN_EXPIRATION_MINUTES"); exists {
+		log.Println("Warning: JWT_EXPIRATION_HOURS is deprecated and ignored because ACCESS_TOKEN_EXPIRATION_MINUTES is set")
+		return GetenvInt("ACCESS_TOKEN_EXPIRATION_MINUTES", 15)
+	}
+
+	hoursValue, err := strconv.Atoi(hours)
+	if err != nil || hoursValue <= 0 {
+		log.Printf("Warning: ignoring invalid JWT_EXPIRATION_HOURS %q", hours)
+		return 15
 	}
+	log.Println("Warning: JWT_EXPIRATION_HOURS is deprecated, set ACCESS_TOKEN_EXPIRATION_MINUTES instead")
+	return hoursValue * 60
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS, skipping incomplete ones
+func loadOIDCProviders(appBaseURL string) []OIDCProviderConfig {
+	providers := []OIDCProviderConfig{}
+	for _, name := range GetenvList("OIDC_PROVIDERS", []string{}) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       Getenv(prefix+"ISSUER", ""),
+			ClientID:     Getenv(prefix+"CLIENT_ID", ""),
+			ClientSecret: Getenv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  Getenv(prefix+"REDIRECT_URL", strings.TrimSuffix(appBaseURL, "/")+"/auth/oidc/"+name+"/callback"),
+			Scopes:       GetenvList(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("Warning: OIDC provider %s needs %sISSUER and %sCLIENT_ID, skipping it", name, prefix, prefix)
+			continue
+		}
+
+		providers = append(providers, provider)
+	}
+	return providers
 }
 
 func GetenvBool(key string, fallback bool) bool {
@@ -66,3 +269,30 @@ func GetenvBool(key string, fallback bool) bool {
 	}
 	return fallback
 }
+
+func GetenvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fallback
+		}
+		return intValue
+	}
+	return fallback
+}
+
+// GetenvList reads a comma separated list, ignoring empty entries
+func GetenvList(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -61,8 +61,550 @@ func RunMigrations() {
 	CREATE INDEX IF NOT EXISTS idx_snmp_metrics_timestamp ON snmp_metrics (timestamp);
 	`
 
+	refreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		family_id VARCHAR(64) NOT NULL,
+		token_hash CHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	refreshTokensIndex := `
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+	`
+
+	revokedTokensTable := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	userTokenRevocationsTable := `
+	CREATE TABLE IF NOT EXISTS user_token_revocations (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		revoked_before TIMESTAMP NOT NULL
+	);
+	`
+
+	userTokensTable := `
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		purpose VARCHAR(32) NOT NULL,
+		token_hash CHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	userTokensIndex := `
+	CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens (user_id, purpose);
+	`
+
+	// Addresses are matched regardless of case
+	usersEmailLowerIndex := `
+	CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+	`
+
+	// Accounts that existed before verification was introduced count as
+	// verified, otherwise REQUIRE_EMAIL_VERIFICATION would lock all of them
+	// out. The backfill only runs together with adding the column, so later
+	// signups that never verified are left alone.
+	usersEmailVerifiedColumn := `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified_at'
+		) THEN
+			ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+			UPDATE users SET email_verified_at = COALESCE(created_at, NOW());
+		END IF;
+	END
+	$$;
+	`
+
+	userMFATable := `
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		totp_secret TEXT NOT NULL,
+		enabled_at TIMESTAMP,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		failed_attempts INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	// Too many wrong codes lock the second factor until locked_until
+	userMFALockedUntilColumn := `
+	ALTER TABLE user_mfa ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	`
+
+	mfaRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash CHAR(64) NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	mfaRecoveryCodesIndex := `
+	CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+	`
+
+	loginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		scope VARCHAR(16) NOT NULL,
+		key VARCHAR(255) NOT NULL,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP,
+		PRIMARY KEY (scope, key)
+	);
+	`
+
+	rolesTable := `
+	CREATE TABLE IF NOT EXISTS roles (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(50) UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT ''
+	);
+	`
+
+	rolePermissionsTable := `
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		permission VARCHAR(100) NOT NULL,
+		PRIMARY KEY (role_id, permission)
+	);
+	`
+
+	userRolesTable := `
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, role_id)
+	);
+	`
+
+	seedRoles := `
+	INSERT INTO roles (name, description) VALUES
+		('user', 'Regular account'),
+		('operator', 'Can publish to NATS and read metrics'),
+		('admin', 'Full access including user management')
+	ON CONFLICT (name) DO NOTHING;
+	`
+
+	seedRolePermissions := `
+	INSERT INTO role_permissions (role_id, permission)
+	SELECT r.id, p.permission
+	FROM roles r
+	JOIN (VALUES
+		('operator', 'nats:publish'),
+		('operator', 'nats:read'),
+		('operator', 'snmp:simulate'),
+		('admin', 'nats:publish'),
+		('admin', 'nats:read'),
+		('admin', 'snmp:simulate'),
+		('admin', 'users:manage'),
+		('admin', 'audit:read')
+	) AS p(role, permission) ON p.role = r.name
+	ON CONFLICT DO NOTHING;
+	`
+
+	// Accounts created before roles existed become regular users
+	backfillUserRoles := `
+	INSERT INTO user_roles (user_id, role_id)
+	SELECT u.id, r.id
+	FROM users u, roles r
+	WHERE r.name = 'user' AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id)
+	ON CONFLICT DO NOTHING;
+	`
+
+	apiKeysTable := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(16) UNIQUE NOT NULL,
+		key_hash CHAR(64) NOT NULL,
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		last_used_at TIMESTAMP,
+		expires_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	apiKeysUserIndex := `
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+	`
+
+	// A session is one login; its ID is the refresh token family ID
+	sessionsTable := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		device_name VARCHAR(100) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip VARCHAR(45) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	`
+
+	sessionsUserIndex := `
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+	`
+
+	usersProfileColumns := `
+	ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS pending_email VARCHAR(100),
+		ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(500) NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS bio VARCHAR(500) NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
+	`
+
+	usersDeletedAtColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	`
+
+	usersDeletedAtIndex := `
+	CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+	`
+
+	usersDisabledAtColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+	`
+
+	// Purged users' messages and conversations are handed to this account. The
+	// password is not a bcrypt hash, so nobody can log in as it.
+	seedDeletedUser := `
+	INSERT INTO users (name, email, password, deleted_at)
+	VALUES ('Deleted user', 'deleted-user@invalid', '!', CURRENT_TIMESTAMP)
+	ON CONFLICT (email) DO NOTHING;
+	`
+
+	// actor_id and target_user_id have no foreign key, entries must outlive purged accounts
+	auditEventsTable := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		occurred_at TIMESTAMPTZ NOT NULL,
+		action VARCHAR(100) NOT NULL,
+		outcome VARCHAR(20) NOT NULL,
+		actor_id INTEGER,
+		impersonator_id INTEGER,
+		target_user_id INTEGER,
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		details JSONB NOT NULL DEFAULT '{}',
+		prev_hash CHAR(64) NOT NULL,
+		hash CHAR(64) NOT NULL UNIQUE
+	);
+	`
+
+	auditEventsIndexes := `
+	CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events (occurred_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+	`
+
+	// The application may only insert, edits and deletions are refused by the database itself
+	auditEventsAppendOnlyFunction := `
+	CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	`
+
+	auditEventsAppendOnlyTriggers := `
+	DROP TRIGGER IF EXISTS audit_events_no_update_delete ON audit_events;
+	CREATE TRIGGER audit_events_no_update_delete
+		BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+	DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+	CREATE TRIGGER audit_events_no_truncate
+		BEFORE TRUNCATE ON audit_events
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+	`
+
+	// A login redirected to an identity provider, keyed by the hash of its state parameter
+	oidcLoginStatesTable := `
+	CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state_hash VARCHAR(64) PRIMARY KEY,
+		provider VARCHAR(50) NOT NULL,
+		code_verifier VARCHAR(128) NOT NULL,
+		nonce VARCHAR(128) NOT NULL,
+		device_name VARCHAR(100) NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NOT NULL
+	);
+	`
+
+	userIdentitiesTable := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		provider VARCHAR(50) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		email VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (provider, subject)
+	);
+	`
+
+	userIdentitiesUserIndex := `
+	CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+	`
+
+	// Services that sign users in through this app. Public clients have no secret.
+	oauthClientsTable := `
+	CREATE TABLE IF NOT EXISTS oauth_clients (
+		id VARCHAR(64) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		secret_hash CHAR(64),
+		public BOOLEAN NOT NULL DEFAULT FALSE,
+		redirect_uris TEXT[] NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	oauthConsentsTable := `
+	CREATE TABLE IF NOT EXISTS oauth_consents (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+		scopes TEXT[] NOT NULL,
+		granted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, client_id)
+	);
+	`
+
+	oauthAuthorizationCodesTable := `
+	CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+		code_hash CHAR(64) PRIMARY KEY,
+		client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		redirect_uri TEXT NOT NULL,
+		scopes TEXT[] NOT NULL,
+		nonce VARCHAR(255) NOT NULL DEFAULT '',
+		code_challenge VARCHAR(128) NOT NULL DEFAULT '',
+		auth_time TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+	`
+
+	// Passkeys; public_key is the COSE key the authenticator sent at registration
+	webauthnCredentialsTable := `
+	CREATE TABLE IF NOT EXISTS webauthn_credentials (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		credential_id BYTEA NOT NULL UNIQUE,
+		public_key BYTEA NOT NULL,
+		sign_count BIGINT NOT NULL DEFAULT 0,
+		transports TEXT[] NOT NULL DEFAULT '{}',
+		aaguid VARCHAR(36) NOT NULL DEFAULT '',
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMP
+	);
+	`
+
+	webauthnCredentialsUserIndex := `
+	CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+	`
+
+	// A registration or login ceremony in progress, keyed by the hash of its challenge
+	webauthnChallengesTable := `
+	CREATE TABLE IF NOT EXISTS webauthn_challenges (
+		challenge_hash CHAR(64) PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		purpose VARCHAR(20) NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+	`
+
+	// Group chats. Direct conversations keep user1_id and user2_id, groups leave them NULL
+	conversationsGroupColumns := `
+	ALTER TABLE conversations
+		ADD COLUMN IF NOT EXISTS type VARCHAR(10) NOT NULL DEFAULT 'direct',
+		ADD COLUMN IF NOT EXISTS name VARCHAR(100) NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		ALTER COLUMN user1_id DROP NOT NULL,
+		ALTER COLUMN user2_id DROP NOT NULL;
+	`
+
+	conversationMembersTable := `
+	CREATE TABLE IF NOT EXISTS conversation_members (
+		conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role VARCHAR(10) NOT NULL DEFAULT 'member',
+		joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (conversation_id, user_id)
+	);
+	`
+
+	conversationMembersUserIndex := `
+	CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members (user_id);
+	`
+
+	// Both participants of a direct conversation are its members
+	backfillConversationMembers := `
+	INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+	SELECT id, user1_id, updated_at FROM conversations WHERE type = 'direct'
+	UNION
+	SELECT id, user2_id, updated_at FROM conversations WHERE type = 'direct'
+	ON CONFLICT DO NOTHING;
+	`
+
+	// Group messages have no receiver
+	messagesConversationColumn := `
+	ALTER TABLE messages
+		ADD COLUMN IF NOT EXISTS conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+		ALTER COLUMN receiver_id DROP NOT NULL;
+	`
+
+	backfillMessagesConversation := `
+	UPDATE messages m
+	SET conversation_id = c.id
+	FROM conversations c
+	WHERE m.conversation_id IS NULL
+		AND c.type = 'direct'
+		AND ((c.user1_id = m.sender_id AND c.user2_id = m.receiver_id) OR (c.user1_id = m.receiver_id AND c.user2_id = m.sender_id));
+	`
+
+	messagesConversationIndex := `
+	CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id, id);
+	`
+
+	// Read receipts: every member reads a conversation up to a message.
+	// Existing members start out having read everything, otherwise their
+	// whole history would show up as unread. Like the email_verified_at
+	// backfill this only runs together with adding the column.
+	conversationMembersLastReadColumn := `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'conversation_members' AND column_name = 'last_read_message_id'
+		) THEN
+			ALTER TABLE conversation_members ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
+			UPDATE conversation_members m
+			SET last_read_message_id = COALESCE((SELECT MAX(id) FROM messages WHERE conversation_id = m.conversation_id), 0);
+		END IF;
+	END
+	$$;
+	`
+
+	// Presence: when a user was last connected
+	usersLastSeenColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+	`
+
+	// Edited and deleted messages. Deleting for everyone leaves a tombstone
+	// without content, deleting for yourself only hides the message from you.
+	messagesEditColumns := `
+	ALTER TABLE messages
+		ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+	`
+
+	// Earlier versions of edited messages
+	messageRevisionsTable := `
+	CREATE TABLE IF NOT EXISTS message_revisions (
+		id SERIAL PRIMARY KEY,
+		message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		written_at TIMESTAMP NOT NULL,
+		replaced_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_message_revisions_message_id ON message_revisions (message_id, id);
+	`
+
+	messageDeletionsTable := `
+	CREATE TABLE IF NOT EXISTS message_deletions (
+		message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		deleted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (message_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_message_deletions_user_id ON message_deletions (user_id);
+	`
+
 	// Execute migrations
-	migrations := []string{usersTable, messagesTable, conversationsTable, snmpMetricsTable, metricsIndex}
+	migrations := []string{
+		usersTable,
+		messagesTable,
+		conversationsTable,
+		snmpMetricsTable,
+		metricsIndex,
+		refreshTokensTable,
+		refreshTokensIndex,
+		revokedTokensTable,
+		userTokenRevocationsTable,
+		userTokensTable,
+		userTokensIndex,
+		usersEmailLowerIndex,
+		usersEmailVerifiedColumn,
+		userMFATable,
+		userMFALockedUntilColumn,
+		mfaRecoveryCodesTable,
+		mfaRecoveryCodesIndex,
+		loginAttemptsTable,
+		rolesTable,
+		rolePermissionsTable,
+		userRolesTable,
+		seedRoles,
+		seedRolePermissions,
+		backfillUserRoles,
+		apiKeysTable,
+		apiKeysUserIndex,
+		sessionsTable,
+		sessionsUserIndex,
+		usersProfileColumns,
+		usersDeletedAtColumn,
+		usersDeletedAtIndex,
+		seedDeletedUser,
+		usersDisabledAtColumn,
+		auditEventsTable,
+		auditEventsIndexes,
+		auditEventsAppendOnlyFunction,
+		auditEventsAppendOnlyTriggers,
+		oidcLoginStatesTable,
+		userIdentitiesTable,
+		userIdentitiesUserIndex,
+		oauthClientsTable,
+		oauthConsentsTable,
+		oauthAuthorizationCodesTable,
+		webauthnCredentialsTable,
+		webauthnCredentialsUserIndex,
+		webauthnChallengesTable,
+		conversationsGroupColumns,
+		conversationMembersTable,
+		conversationMembersUserIndex,
+		backfillConversationMembers,
+		messagesConversationColumn,
+		backfillMessagesConversation,
+		messagesConversationIndex,
+		conversationMembersLastReadColumn,
+		usersLastSeenColumn,
+		messagesEditColumns,
+		messageRevisionsTable,
+		messageDeletionsTable,
+	}
 
 	for _, migration := range migrations {
 		_, err := DB.Exec(context.Background(), migration)
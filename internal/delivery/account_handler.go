@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-auth-app/internal/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteAccountHandler schedules the caller's account for deletion
+func (h *AuthHandler) DeleteAccountHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password is required", "details": err.Error()})
+		return
+	}
+
+	purgeAt, err := h.AuthUsecase.DeleteAccount(context.Background(), userID, req.CurrentPassword, clientInfo(c))
+	if err != nil {
+		respondAccountChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Your account is scheduled for deletion. Log in again before it is purged to keep it.",
+		"purge_at": purgeAt,
+	})
+}
+
+// ExportAccountHandler downloads the caller's personal data as a ZIP archive,
+// or as a single JSON document with ?format=json
+func (h *AuthHandler) ExportAccountHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	export, err := h.AuthUsecase.ExportAccount(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account", "details": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", `attachment; filename="account-export.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := exportArchive(export)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account", "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="account-export.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// exportArchive packs an account export into a ZIP file with one JSON document per kind of data
+func exportArchive(export *domain.AccountExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"conversations.json", export.Conversations},
+		{"messages.json", export.Messages},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
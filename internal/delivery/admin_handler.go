@@ -0,0 +1,274 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRolesHandler lists the roles and their permissions
+func (h *AuthHandler) GetRolesHandler(c *gin.Context) {
+	roles, err := h.AuthUsecase.GetRoles(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetUserRolesHandler returns the roles and permissions of a user
+func (h *AuthHandler) GetUserRolesHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	access, err := h.AuthUsecase.GetUserAccess(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load roles", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, access)
+}
+
+// AssignRoleHandler grants a role to a user
+func (h *AuthHandler) AssignRoleHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req domain.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required", "details": err.Error()})
+		return
+	}
+
+	err = h.AuthUsecase.AssignRole(context.Background(), adminID, userID, req.Role, clientInfo(c))
+	if errors.Is(err, domain.ErrUnknownRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+// RemoveRoleHandler revokes a role from a user
+func (h *AuthHandler) RemoveRoleHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.AuthUsecase.RemoveRole(context.Background(), adminID, userID, c.Param("role"), clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed"})
+}
+
+// UnlockAccountHandler lifts a login lockout for an account
+func (h *AuthHandler) UnlockAccountHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	var req domain.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required", "details": err.Error()})
+		return
+	}
+
+	if err := h.AuthUsecase.UnlockAccount(context.Background(), req.Email, adminID, clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock account", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// ListUsersHandler lists accounts, filtered by ?q=, ?status= and ?role= and paginated with ?limit= and ?offset=
+func (h *AuthHandler) ListUsersHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	page, err := h.AuthUsecase.ListUsers(context.Background(), domain.UserFilter{
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		Role:   c.Query("role"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if errors.Is(err, domain.ErrInvalidUserStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetUserHandler returns an account with its roles and sessions
+func (h *AuthHandler) GetUserHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	details, err := h.AuthUsecase.GetUserDetails(context.Background(), userID)
+	if err != nil {
+		respondAdminError(c, err, "failed to load user")
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+// DisableUserHandler blocks an account and signs it out everywhere
+func (h *AuthHandler) DisableUserHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	// The reason is optional, an empty body is fine
+	var req domain.DisableUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+
+	if err := h.AuthUsecase.DisableUser(context.Background(), adminID, userID, req.Reason, clientInfo(c)); err != nil {
+		respondAdminError(c, err, "failed to disable user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled"})
+}
+
+// EnableUserHandler lets a disabled account sign in again
+func (h *AuthHandler) EnableUserHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.AuthUsecase.EnableUser(context.Background(), adminID, userID, clientInfo(c)); err != nil {
+		respondAdminError(c, err, "failed to enable user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled"})
+}
+
+// ForcePasswordResetHandler invalidates a user's password and emails them a reset link
+func (h *AuthHandler) ForcePasswordResetHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.AuthUsecase.ForcePasswordReset(context.Background(), adminID, userID, clientInfo(c)); err != nil {
+		respondAdminError(c, err, "failed to reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset, the user has been emailed a link to choose a new one"})
+}
+
+// GetUserSessionsHandler lists the devices a user is signed in on
+func (h *AuthHandler) GetUserSessionsHandler(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	sessions, err := h.AuthUsecase.ListSessions(context.Background(), userID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeUserSessionsHandler signs a user out of one session (/:sid) or of all of them
+func (h *AuthHandler) RevokeUserSessionsHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.AuthUsecase.RevokeUserSessions(context.Background(), adminID, userID, c.Param("sid"), clientInfo(c)); err != nil {
+		respondAdminError(c, err, "failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked"})
+}
+
+// ImpersonateHandler issues a short-lived token for acting as another user
+func (h *AuthHandler) ImpersonateHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	token, err := h.AuthUsecase.Impersonate(context.Background(), adminID, userID, clientInfo(c))
+	if err != nil {
+		respondAdminError(c, err, "failed to impersonate user")
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// respondAdminError maps the errors of the user management endpoints
+func respondAdminError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrSelfAdministration), errors.Is(err, domain.ErrCannotImpersonate):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
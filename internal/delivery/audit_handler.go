@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditEventsHandler lists audit events, newest first. It filters by
+// ?from= and ?to= (RFC 3339), ?actor_id=, ?target_user_id= and ?action=, and
+// pages backwards with ?before_id= and ?limit=.
+func (h *AuthHandler) GetAuditEventsHandler(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.AuthUsecase.QueryAuditEvents(context.Background(), filter)
+	if errors.Is(err, domain.ErrAuditUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit events", "details": err.Error()})
+		return
+	}
+
+	response := gin.H{"events": events}
+	if len(events) > 0 {
+		response["next_before_id"] = events[len(events)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyAuditLogHandler checks the hash chain of the audit log
+func (h *AuthHandler) VerifyAuditLogHandler(c *gin.Context) {
+	result, err := h.AuthUsecase.VerifyAuditLog(context.Background())
+	if errors.Is(err, domain.ErrAuditUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify audit log", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parseAuditFilter reads the audit log filters from the query string
+func parseAuditFilter(c *gin.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{Action: c.Query("action")}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, errors.New(param + " must be an RFC 3339 timestamp")
+			}
+			*target = &t
+		}
+	}
+
+	for param, target := range map[string]*int{"actor_id": &filter.ActorID, "target_user_id": &filter.TargetID, "limit": &filter.Limit} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, errors.New(param + " must be a positive number")
+			}
+			*target = n
+		}
+	}
+
+	if value := c.Query("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 0 {
+			return filter, errors.New("before_id must be a positive number")
+		}
+		filter.BeforeID = id
+	}
+
+	return filter, nil
+}
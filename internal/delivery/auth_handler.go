@@ -2,11 +2,14 @@ package delivery
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"go-auth-app/internal/domain"
 	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,9 +24,9 @@ func NewAuthHandler(authUsecase *usecase.AuthUsecase) *AuthHandler {
 
 // signup-handler
 func (h *AuthHandler) SignupHandler(c *gin.Context) {
-	var user domain.User
+	var req domain.SignupRequest
 
-	if err := c.ShouldBindJSON(&user); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		// Get the error message
 		errorMsg := err.Error()
 
@@ -40,9 +43,9 @@ func (h *AuthHandler) SignupHandler(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("Received User: %+v\n", user)
+	user := domain.User{Name: req.Name, Email: req.Email, Password: req.Password}
 
-	if err := h.AuthUsecase.Signup(context.Background(), &user); err != nil {
+	if err := h.AuthUsecase.Signup(context.Background(), &user, clientInfo(c)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -55,6 +58,8 @@ func (h *AuthHandler) LoginHandler(c *gin.Context) {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"` // ← fixed missing quote
+		// DeviceName is an optional label shown in the session list
+		DeviceName string `json:"device_name"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -80,7 +85,30 @@ func (h *AuthHandler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := h.AuthUsecase.Login(context.Background(), req.Email, req.Password)
+	client := clientInfo(c)
+	client.DeviceName = req.DeviceName
+
+	result, err := h.AuthUsecase.Login(context.Background(), req.Email, req.Password, client)
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many failed login attempts. Please try again later.",
+		})
+		return
+	}
+	if errors.Is(err, domain.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Please verify your email address before logging in.",
+		})
+		return
+	}
+	if errors.Is(err, domain.ErrAccountDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account has been disabled. Please contact support.",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "Authentication failed. Please check your email and password.",
@@ -89,11 +117,80 @@ func (h *AuthHandler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	// MFA users get {"mfa_required": true, "mfa_token": ...} instead of a token pair
+	c.JSON(http.StatusOK, result)
+}
+
+// RefreshHandler exchanges a refresh token for a new access/refresh token pair
+func (h *AuthHandler) RefreshHandler(c *gin.Context) {
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required", "details": err.Error()})
+		return
+	}
+
+	tokens, err := h.AuthUsecase.Refresh(context.Background(), req.RefreshToken, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LogoutHandler revokes the access token used for this request and the optional refresh token
+func (h *AuthHandler) LogoutHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The body is optional, clients that only hold an access token may omit it
+	var req domain.LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	expiresAt, _ := c.Get("token_expires_at")
+	exp, _ := expiresAt.(time.Time)
+
+	if err := h.AuthUsecase.Logout(context.Background(), userID, c.GetString("jti"), c.GetString("session_id"), exp, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAllHandler revokes every token the authenticated user holds on every device
+func (h *AuthHandler) LogoutAllHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.AuthUsecase.LogoutAll(context.Background(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+// JWKSHandler publishes the public keys downstream services use to verify tokens
+func (h *AuthHandler) JWKSHandler(c *gin.Context) {
+	km := pkg.GetKeyManager()
+	if km == nil {
+		c.JSON(http.StatusOK, pkg.JWKSet{Keys: []pkg.JWK{}})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, km.JWKS())
 }
 
 func (h *AuthHandler) ProtectedHandler(c *gin.Context) {
-	userID := c.GetInt("id")
+	userID, _ := currentUserID(c)
 	email := c.GetString("email")
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Protected Data",
@@ -20,7 +20,7 @@ func NewChatHandler(chatUsecase *usecase.ChatUsecase) *ChatHandler {
 	return &ChatHandler{ChatUsecase: chatUsecase}
 }
 
-// SendMessageHandler handles sending a new message
+// SendMessageHandler handles sending a new message to a user or a conversation
 func (h *ChatHandler) SendMessageHandler(c *gin.Context) {
 	// Get sender ID from token
 	senderIDValue, exists := c.Get("user_id")
@@ -48,16 +48,22 @@ func (h *ChatHandler) SendMessageHandler(c *gin.Context) {
 		return
 	}
 
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
 	// Send message
-	message, err := h.ChatUsecase.SendMessage(
-		context.Background(),
-		senderID,
-		req.ReceiverID,
-		req.Content,
-	)
+	var message *domain.Message
+	var err error
+	if req.ConversationID > 0 {
+		message, err = h.ChatUsecase.SendConversationMessage(context.Background(), senderID, req.ConversationID, req.Content)
+	} else {
+		message, err = h.ChatUsecase.SendMessage(context.Background(), senderID, req.ReceiverID, req.Content)
+	}
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondChatError(c, err)
 		return
 	}
 
@@ -0,0 +1,264 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateGroupHandler starts a group conversation owned by the current user
+func (h *ChatHandler) CreateGroupHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	conversation, err := h.ChatUsecase.CreateGroup(context.Background(), userID, req.Name, req.MemberIDs)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": conversation})
+}
+
+// GetConversationHandler returns a conversation of the current user with its members
+func (h *ChatHandler) GetConversationHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := h.ChatUsecase.GetConversation(context.Background(), userID, conversationID)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": conversation})
+}
+
+// RenameGroupHandler renames a group
+func (h *ChatHandler) RenameGroupHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.RenameGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	conversation, err := h.ChatUsecase.RenameGroup(context.Background(), userID, conversationID, req.Name)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": conversation})
+}
+
+// LeaveGroupHandler takes the current user out of a group
+func (h *ChatHandler) LeaveGroupHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.ChatUsecase.LeaveGroup(context.Background(), userID, conversationID); err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You left the group"})
+}
+
+// AddGroupMembersHandler adds users to a group
+func (h *ChatHandler) AddGroupMembersHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.AddGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	members, err := h.ChatUsecase.AddGroupMembers(context.Background(), userID, conversationID, req.UserIDs)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// RemoveGroupMemberHandler takes a member out of a group
+func (h *ChatHandler) RemoveGroupMemberHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.ChatUsecase.RemoveGroupMember(context.Background(), userID, conversationID, memberID); err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// SetGroupMemberRoleHandler changes the role of a group member
+func (h *ChatHandler) SetGroupMemberRoleHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req domain.SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if err := h.ChatUsecase.SetGroupMemberRole(context.Background(), userID, conversationID, memberID, req.Role); err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member role updated"})
+}
+
+// SendConversationMessageHandler sends a message to a conversation
+func (h *ChatHandler) SendConversationMessageHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	message, err := h.ChatUsecase.SendConversationMessage(context.Background(), userID, conversationID, req.Content)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+// GetConversationMessagesByIDHandler retrieves the messages of a conversation
+func (h *ChatHandler) GetConversationMessagesByIDHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		offset = 0
+	}
+
+	messages, err := h.ChatUsecase.GetConversationMessagesByID(context.Background(), userID, conversationID, limit, offset)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": messages})
+}
+
+// MarkConversationReadHandler marks a conversation read up to a message, or
+// up to its latest message when the body is empty
+func (h *ChatHandler) MarkConversationReadHandler(c *gin.Context) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.MarkReadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	receipt, err := h.ChatUsecase.MarkConversationRead(context.Background(), userID, conversationID, req.MessageID)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": receipt})
+}
+
+// conversationParams reads the current user and the conversation ID of the
+// path, responding with an error if either is missing
+func conversationParams(c *gin.Context) (int, int, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid conversation ID"})
+		return 0, 0, false
+	}
+
+	return userID, conversationID, true
+}
+
+// respondChatError maps chat errors to status codes
+func respondChatError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrConversationNotFound), errors.Is(err, domain.ErrNotConversationMember), errors.Is(err, domain.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrConversationForbidden), errors.Is(err, domain.ErrNotMessageSender), errors.Is(err, domain.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EditMessageHandler replaces the content of a message the current user sent
+func (h *ChatHandler) EditMessageHandler(c *gin.Context) {
+	userID, conversationID, messageID, ok := messageParams(c)
+	if !ok {
+		return
+	}
+
+	var req domain.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	message, err := h.ChatUsecase.EditMessage(context.Background(), userID, conversationID, messageID, req.Content)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": message})
+}
+
+// DeleteMessageHandler deletes a message for the current user, or for
+// everyone with ?for=everyone
+func (h *ChatHandler) DeleteMessageHandler(c *gin.Context) {
+	userID, conversationID, messageID, ok := messageParams(c)
+	if !ok {
+		return
+	}
+
+	deletion, err := h.ChatUsecase.DeleteMessage(context.Background(), userID, conversationID, messageID, c.Query("for"))
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deletion})
+}
+
+// GetMessageRevisionsHandler returns the earlier versions of an edited message
+func (h *ChatHandler) GetMessageRevisionsHandler(c *gin.Context) {
+	userID, conversationID, messageID, ok := messageParams(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.ChatUsecase.GetMessageRevisions(context.Background(), userID, conversationID, messageID)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+// messageParams reads the current user and the conversation and message IDs
+// of the path, responding with an error if one is missing
+func messageParams(c *gin.Context) (int, int, int, bool) {
+	userID, conversationID, ok := conversationParams(c)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	messageID, err := strconv.Atoi(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return 0, 0, 0, false
+	}
+
+	return userID, conversationID, messageID, true
+}
@@ -0,0 +1,96 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollTOTPHandler creates a new TOTP secret for the authenticated user
+func (h *AuthHandler) EnrollTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	enrollment, err := h.AuthUsecase.EnrollTOTP(context.Background(), userID)
+	if errors.Is(err, domain.ErrMFAAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start two-factor enrollment", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPHandler enables TOTP with the first code from the authenticator and returns the recovery codes
+func (h *AuthHandler) ConfirmTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required", "details": err.Error()})
+		return
+	}
+
+	codes, err := h.AuthUsecase.ConfirmTOTP(context.Background(), userID, req.Code)
+	switch {
+	case errors.Is(err, domain.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, domain.ErrMFANotEnrolled), errors.Is(err, domain.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store these recovery codes somewhere safe, they will not be shown again.",
+		"recovery_codes": codes,
+	})
+}
+
+// VerifyMFAHandler completes a login with an MFA pending token and a TOTP or recovery code
+func (h *AuthHandler) VerifyMFAHandler(c *gin.Context) {
+	var req domain.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token is required", "details": err.Error()})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	client := clientInfo(c)
+	client.DeviceName = req.DeviceName
+
+	tokens, err := h.AuthUsecase.VerifyMFA(context.Background(), req.MFAToken, req.Code, req.RecoveryCode, client)
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many invalid codes. Please try again later."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
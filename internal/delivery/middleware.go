@@ -1,11 +1,12 @@
 package delivery
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
 
-	"go-auth-app/pkg"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,10 +32,24 @@ func ErrorHandlerMiddleware() gin.HandlerFunc {
 	}
 }
 
-//AuthMiddleware Function validates the JWT token and extracts user information
+//AuthMiddleware Function validates the JWT token or X-API-Key header, rejects revoked credentials and extracts user information
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(authUsecase *usecase.AuthUsecase) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Machine clients authenticate with a personal API key instead of a JWT
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			principal, err := authUsecase.AuthenticateAPIKey(context.Background(), apiKey)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				c.Abort()
+				return
+			}
+
+			setPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -43,7 +58,6 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		fmt.Println(authHeader)
 
 		// Extract token (format: "Bearer <token>")
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
@@ -54,18 +68,138 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Validate token
-		claims, err := pkg.ValidateJWT(tokenString)
+		claims, err := authUsecase.ValidateAccessToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims["user_id"])
-		c.Set("email", claims["email"])
+		principal := &domain.Principal{
+			UserID:      claims.UserID,
+			Email:       claims.Email,
+			Roles:       claims.Roles,
+			Permissions: claims.Permissions,
+			AuthMethod:  domain.AuthMethodJWT,
+			TokenID:     claims.ID,
+			SessionID:   claims.SessionID,
+			IssuedAt:    claims.IssuedAtTime(),
+			ExpiresAt:   claims.ExpiresAtTime(),
+		}
+		// Impersonation tokens are kept out of routes restricted to AuthMethodJWT
+		if claims.Actor != nil {
+			principal.AuthMethod = domain.AuthMethodImpersonation
+			principal.ImpersonatorID = claims.Actor.UserID
+		}
+
+		setPrincipal(c, principal)
+
+		c.Next()
+
+	}
+
+}
+
+// setPrincipal stores the caller in the context, along with the individual
+// keys handlers read, whichever credential was used
+func setPrincipal(c *gin.Context, principal *domain.Principal) {
+	c.Set("principal", principal)
+	c.Set("user_id", principal.UserID)
+	c.Set("email", principal.Email)
+	c.Set("jti", principal.TokenID)
+	c.Set("session_id", principal.SessionID)
+	c.Set("token_expires_at", principal.ExpiresAt)
+	c.Set("roles", principal.Roles)
+	c.Set("permissions", principal.Permissions)
+}
+
+// currentPrincipal returns the caller set by AuthMiddleware
+func currentPrincipal(c *gin.Context) (*domain.Principal, bool) {
+	value, exists := c.Get("principal")
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*domain.Principal)
+	return principal, ok
+}
+
+// RequireScope rejects API keys that were not granted the scope. JWTs are not scoped.
+// It must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok || !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing scope " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAuthMethod restricts a route to one kind of credential, for example
+// so that an API key cannot be used to manage API keys or sign-in settings.
+// It must run after AuthMiddleware.
+func RequireAuthMethod(method string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok || principal.AuthMethod != method {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this endpoint cannot be used with the current credential"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
+// RequirePermission rejects requests whose token does not grant the permission.
+// It must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !contains(c.GetStringSlice("permissions"), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "missing permission " + permission})
+			c.Abort()
+			return
+		}
 		c.Next()
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// currentUserID returns the authenticated user's ID set by AuthMiddleware.
+// JWT claims decode numbers as float64, so both representations are accepted.
+func currentUserID(c *gin.Context) (int, bool) {
+	value, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
 
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
 	}
+}
 
+// clientInfo describes the caller for throttling and audit entries
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	client := domain.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if principal, ok := currentPrincipal(c); ok {
+		client.ImpersonatorID = principal.ImpersonatorID
+	}
+	return client
 }
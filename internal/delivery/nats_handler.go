@@ -1,18 +1,23 @@
 package delivery
 
 import (
+	"context"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
 	"go-auth-app/internal/usecase"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type NATSHandler struct {
-	NATSUsecase *usecase.NATSUsecase
+	NATSUsecase  *usecase.NATSUsecase
+	AuditService *service.AuditService
 }
 
-func NewNATSHandler(natsUsecase *usecase.NATSUsecase) *NATSHandler {
-	return &NATSHandler{NATSUsecase: natsUsecase}
+func NewNATSHandler(natsUsecase *usecase.NATSUsecase, auditService *service.AuditService) *NATSHandler {
+	return &NATSHandler{NATSUsecase: natsUsecase, AuditService: auditService}
 }
 
 func (h *NATSHandler) GetTopicsHandler(c *gin.Context) {
@@ -36,6 +41,10 @@ func (h *NATSHandler) PublishTestHandler(c *gin.Context) {
 	}
 
 	err := h.NATSUsecase.PublishMessage(payload.Topic, payload.Message)
+	h.audit(c, domain.AuditNATSPublish, err, domain.AuditDetails{
+		"topic":        payload.Topic,
+		"message_size": strconv.Itoa(len(payload.Message)),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -46,6 +55,7 @@ func (h *NATSHandler) PublishTestHandler(c *gin.Context) {
 
 func (h *NATSHandler) SimulateSNMPMetricsHandler(c *gin.Context) {
 	metrics, err := h.NATSUsecase.SimulateSNMPMetrics()
+	h.audit(c, domain.AuditSNMPSimulate, err, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -69,3 +79,32 @@ func (h *NATSHandler) GetTopicMetricsHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"topic": topic, "metrics": metrics})
 }
+
+// audit records an operator action against the caller of the request
+func (h *NATSHandler) audit(c *gin.Context, action string, err error, details domain.AuditDetails) {
+	if h.AuditService == nil {
+		return
+	}
+
+	client := clientInfo(c)
+	event := &domain.AuditEvent{
+		Action:         action,
+		Outcome:        domain.AuditOutcomeSuccess,
+		IP:             client.IP,
+		UserAgent:      client.UserAgent,
+		ImpersonatorID: client.ImpersonatorID,
+		Details:        details,
+	}
+	if principal, ok := currentPrincipal(c); ok {
+		event.ActorID = principal.UserID
+	}
+	if err != nil {
+		event.Outcome = domain.AuditOutcomeFailure
+		if event.Details == nil {
+			event.Details = domain.AuditDetails{}
+		}
+		event.Details["error"] = err.Error()
+	}
+
+	h.AuditService.Record(context.Background(), event)
+}
@@ -0,0 +1,412 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeTemplate is the sign-in and consent page of /oauth/authorize. The
+// authorization request travels in hidden fields, so the page keeps no state.
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.ClientName}}</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 3rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .75rem; }
+.error { color: #b00020; }
+.actions { display: flex; gap: .5rem; }
+</style>
+</head>
+<body>
+{{if .Error}}<h1>Sign-in request rejected</h1>
+<p class="error">{{.Error}}</p>
+{{else}}<h1>Sign in to {{.ClientName}}</h1>
+<p>{{.ClientName}} would like to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+{{if .Message}}<p class="error">{{.Message}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Request}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+<label>Authentication code, if two-factor authentication is on <input type="text" name="otp" inputmode="numeric" autocomplete="one-time-code"></label>
+<div class="actions">
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</div>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// scopeDescriptions explain the scopes on the consent page
+var scopeDescriptions = map[string]string{
+	domain.OAuthScopeOpenID:  "Know who you are",
+	domain.OAuthScopeProfile: "See your name, avatar and time zone",
+	domain.OAuthScopeEmail:   "See your email address",
+}
+
+// authorizePage is the data of the sign-in and consent page
+type authorizePage struct {
+	ClientName string
+	Scopes     []string
+	Request    map[string]string
+	Email      string
+	Message    string
+	Error      string
+}
+
+// OAuthAuthorizeHandler shows the page where users sign in to a client and
+// allow it access. Invalid requests are reported to the client's redirect
+// URI, unless the client or redirect URI itself cannot be trusted.
+func (h *AuthHandler) OAuthAuthorizeHandler(c *gin.Context) {
+	var req domain.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		renderAuthorizePage(c, http.StatusBadRequest, authorizePage{Error: "The sign-in request is malformed."})
+		return
+	}
+
+	prompt, err := h.AuthUsecase.PrepareAuthorization(context.Background(), &req)
+	if err != nil {
+		h.respondAuthorizeError(c, &req, err)
+		return
+	}
+
+	renderAuthorizePage(c, http.StatusOK, newAuthorizePage(prompt, &req, "", ""))
+}
+
+// OAuthAuthorizeSubmitHandler handles the sign-in and consent form. On
+// success the browser is sent back to the client with an authorization code.
+func (h *AuthHandler) OAuthAuthorizeSubmitHandler(c *gin.Context) {
+	var decision domain.AuthorizationDecision
+	if err := c.ShouldBind(&decision); err != nil {
+		renderAuthorizePage(c, http.StatusBadRequest, authorizePage{Error: "The sign-in request is malformed."})
+		return
+	}
+
+	code, err := h.AuthUsecase.Authorize(context.Background(), &decision, clientInfo(c))
+	if err == nil {
+		oauthRedirect(c, decision.RedirectURI, url.Values{"code": {code}}, decision.State)
+		return
+	}
+
+	// Sign-in failures show the form again, anything else ends the request
+	status, message := http.StatusUnauthorized, ""
+	var throttled *domain.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		status, message = http.StatusTooManyRequests, "Too many failed sign-in attempts. Please try again later."
+	case errors.Is(err, domain.ErrMFACodeRequired):
+		message = "Enter the code from your authenticator app."
+	case errors.Is(err, domain.ErrInvalidMFACode):
+		message = "The authentication code is incorrect."
+	case errors.Is(err, domain.ErrAccountDisabled):
+		status, message = http.StatusForbidden, "This account has been disabled."
+	case errors.Is(err, domain.ErrEmailNotVerified):
+		status, message = http.StatusForbidden, "Please verify your email address before signing in."
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		message = "Invalid email or password."
+	default:
+		h.respondAuthorizeError(c, &decision.AuthorizationRequest, err)
+		return
+	}
+
+	prompt, err := h.AuthUsecase.PrepareAuthorization(context.Background(), &decision.AuthorizationRequest)
+	if err != nil {
+		h.respondAuthorizeError(c, &decision.AuthorizationRequest, err)
+		return
+	}
+	renderAuthorizePage(c, status, newAuthorizePage(prompt, &decision.AuthorizationRequest, decision.Email, message))
+}
+
+// OAuthTokenHandler redeems authorization codes (RFC 6749 section 4.1.3).
+// Clients authenticate with HTTP basic auth or client_secret in the form.
+func (h *AuthHandler) OAuthTokenHandler(c *gin.Context) {
+	var req domain.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, domain.NewOAuthError(domain.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+
+	basicClientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	tokens, err := h.AuthUsecase.ExchangeAuthorizationCode(context.Background(), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// OAuthIntrospectHandler reports whether a token or API key is active and
+// who it belongs to (RFC 7662). Callers authenticate as a confidential client.
+func (h *AuthHandler) OAuthIntrospectHandler(c *gin.Context) {
+	var req domain.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, domain.NewOAuthError(domain.OAuthErrInvalidRequest, err.Error()))
+		return
+	}
+	basicClientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	introspection, err := h.AuthUsecase.IntrospectToken(context.Background(), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, introspection)
+}
+
+// OAuthUserInfoHandler returns the claims about the user that an OAuth access token's scope allows
+func (h *AuthHandler) OAuthUserInfoHandler(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="userinfo"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": domain.OAuthErrInvalidToken, "error_description": "a bearer token is required"})
+		return
+	}
+
+	info, err := h.AuthUsecase.UserInfo(context.Background(), token)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, info)
+}
+
+// OpenIDConfigurationHandler publishes the discovery document clients are configured from
+func (h *AuthHandler) OpenIDConfigurationHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.AuthUsecase.OpenIDConfiguration())
+}
+
+// CreateOAuthClientHandler registers an OAuth client; the secret is only shown in this response
+func (h *AuthHandler) CreateOAuthClientHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	var req domain.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and at least one redirect URI are required", "details": err.Error()})
+		return
+	}
+
+	created, err := h.AuthUsecase.CreateOAuthClient(context.Background(), adminID, req, clientInfo(c))
+	if errors.Is(err, domain.ErrInvalidRedirectURI) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect URIs must be absolute https URIs without a fragment, or http on localhost"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register OAuth client", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListOAuthClientsHandler lists the registered OAuth clients
+func (h *AuthHandler) ListOAuthClientsHandler(c *gin.Context) {
+	clients, err := h.AuthUsecase.ListOAuthClients(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list OAuth clients", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// DeleteOAuthClientHandler removes an OAuth client
+func (h *AuthHandler) DeleteOAuthClientHandler(c *gin.Context) {
+	adminID, _ := currentUserID(c)
+
+	err := h.AuthUsecase.DeleteOAuthClient(context.Background(), adminID, c.Param("client_id"), clientInfo(c))
+	if errors.Is(err, domain.ErrOAuthClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete OAuth client", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OAuth client deleted"})
+}
+
+// ListOAuthConsentsHandler lists the services the caller allowed to sign them in
+func (h *AuthHandler) ListOAuthConsentsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	consents, err := h.AuthUsecase.ListOAuthConsents(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list consents", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// RevokeOAuthConsentHandler withdraws a service's access to the caller's account
+func (h *AuthHandler) RevokeOAuthConsentHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	err := h.AuthUsecase.RevokeOAuthConsent(context.Background(), userID, c.Param("client_id"), clientInfo(c))
+	if errors.Is(err, domain.ErrOAuthClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "consent not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke consent", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Consent revoked"})
+}
+
+// respondAuthorizeError reports a failed authorization request. Errors meant
+// for the client go to its redirect URI, which PrepareAuthorization has
+// already checked; the rest are shown to the user.
+func (h *AuthHandler) respondAuthorizeError(c *gin.Context, req *domain.AuthorizationRequest, err error) {
+	var oauthErr *domain.OAuthError
+	switch {
+	case errors.As(err, &oauthErr):
+		params := url.Values{"error": {oauthErr.Code}}
+		if oauthErr.Description != "" {
+			params.Set("error_description", oauthErr.Description)
+		}
+		oauthRedirect(c, req.RedirectURI, params, req.State)
+	case errors.Is(err, domain.ErrOAuthClientNotFound):
+		renderAuthorizePage(c, http.StatusBadRequest, authorizePage{Error: "The application asking you to sign in is not registered."})
+	case errors.Is(err, domain.ErrInvalidRedirectURI):
+		renderAuthorizePage(c, http.StatusBadRequest, authorizePage{Error: "The application asked to return to an address it has not registered."})
+	default:
+		log.Printf("OAuth authorization failed: %v", err)
+		renderAuthorizePage(c, http.StatusInternalServerError, authorizePage{Error: "Something went wrong, please try again."})
+	}
+}
+
+// newAuthorizePage fills the sign-in page for a validated request
+func newAuthorizePage(prompt *domain.AuthorizationPrompt, req *domain.AuthorizationRequest, email, message string) authorizePage {
+	scopes := make([]string, 0, len(prompt.Scopes))
+	for _, scope := range prompt.Scopes {
+		scopes = append(scopes, scopeDescriptions[scope])
+	}
+
+	return authorizePage{
+		ClientName: prompt.Client.Name,
+		Scopes:     scopes,
+		Request: map[string]string{
+			"client_id":             req.ClientID,
+			"redirect_uri":          req.RedirectURI,
+			"response_type":         req.ResponseType,
+			"scope":                 req.Scope,
+			"state":                 req.State,
+			"nonce":                 req.Nonce,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": req.CodeChallengeMethod,
+		},
+		Email:   email,
+		Message: message,
+	}
+}
+
+// renderAuthorizePage writes the page with headers that keep it out of caches and frames
+func renderAuthorizePage(c *gin.Context, status int, page authorizePage) {
+	noStore(c)
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := authorizeTemplate.Execute(c.Writer, page); err != nil {
+		log.Printf("Failed to render the authorization page: %v", err)
+	}
+}
+
+// oauthRedirect sends the browser back to a client's redirect URI with the given parameters
+func oauthRedirect(c *gin.Context, redirectURI string, params url.Values, state string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderAuthorizePage(c, http.StatusBadRequest, authorizePage{Error: "The application asked to return to an invalid address."})
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// respondOAuthError writes an error response of the token and userinfo endpoints (RFC 6749 section 5.2)
+func respondOAuthError(c *gin.Context, err error) {
+	var oauthErr *domain.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("OAuth request failed: %v", err)
+		oauthErr = domain.NewOAuthError(domain.OAuthErrServerError, "")
+	}
+
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case domain.OAuthErrInvalidClient:
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	case domain.OAuthErrInvalidToken:
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	case domain.OAuthErrServerError:
+		status = http.StatusInternalServerError
+	}
+
+	body := gin.H{"error": oauthErr.Code}
+	if oauthErr.Description != "" {
+		body["error_description"] = oauthErr.Description
+	}
+
+	noStore(c)
+	c.JSON(status, body)
+}
+
+// basicClientCredentials takes client credentials from HTTP basic auth when
+// present, which are form-encoded (RFC 6749 section 2.3.1)
+func basicClientCredentials(c *gin.Context, clientID, clientSecret *string) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return
+	}
+	*clientID, _ = url.QueryUnescape(id)
+	*clientSecret, _ = url.QueryUnescape(secret)
+}
+
+// noStore keeps tokens and sign-in pages out of caches
+func noStore(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+}
@@ -0,0 +1,59 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCLoginHandler redirects the browser to an identity provider to sign in.
+// An optional ?device_name= labels the session that the login creates.
+func (h *AuthHandler) OIDCLoginHandler(c *gin.Context) {
+	authURL, err := h.AuthUsecase.StartOIDCLogin(context.Background(), c.Param("provider"), c.Query("device_name"))
+	if errors.Is(err, domain.ErrUnknownOIDCProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable", "details": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallbackHandler completes a login when the identity provider redirects
+// back. It responds like /login: a token pair, or an MFA token for MFA users.
+func (h *AuthHandler) OIDCCallbackHandler(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": domain.ErrOIDCLoginFailed.Error(), "details": providerError + ": " + c.Query("error_description")})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	result, err := h.AuthUsecase.CompleteOIDCLogin(context.Background(), c.Param("provider"), code, state, clientInfo(c))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, domain.ErrUnknownOIDCProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidOIDCState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrOIDCLoginFailed):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": domain.ErrOIDCLoginFailed.Error(), "details": err.Error()})
+	case errors.Is(err, domain.ErrOIDCEmailNotVerified), errors.Is(err, domain.ErrEmailNotVerified), errors.Is(err, domain.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrOIDCAccountConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in", "details": err.Error()})
+	}
+}
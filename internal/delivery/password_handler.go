@@ -0,0 +1,165 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// linkTemplate is the page emailed links open. Opening it uses nothing up, so
+// mail scanners that follow links are harmless; the token is only spent when
+// the user submits the form.
+var linkTemplate = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 3rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .75rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Intro}}</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+{{if .AskPassword}}<label>New password <input type="password" name="password" autocomplete="new-password" required></label>
+{{end}}<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// linkPage is the data of the page emailed links open
+type linkPage struct {
+	Title  string
+	Intro  string
+	Action string
+	Token  string
+	Button string
+	// AskPassword adds a field for a new password
+	AskPassword bool
+}
+
+// renderLinkPage writes the page with headers that keep it, and the token in
+// its URL, out of caches, frames and referrers
+func renderLinkPage(c *gin.Context, page linkPage) {
+	noStore(c)
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := linkTemplate.Execute(c.Writer, page); err != nil {
+		log.Printf("Failed to render the %s page: %v", page.Action, err)
+	}
+}
+
+// ForgotPasswordHandler emails a password reset link if the account exists
+func (h *AuthHandler) ForgotPasswordHandler(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required", "details": err.Error()})
+		return
+	}
+
+	if err := h.AuthUsecase.ForgotPassword(context.Background(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process password reset request"})
+		return
+	}
+
+	// Same response whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+// ResetPasswordPageHandler shows the page reset links open when
+// PASSWORD_RESET_URL does not point elsewhere
+func (h *AuthHandler) ResetPasswordPageHandler(c *gin.Context) {
+	renderLinkPage(c, linkPage{
+		Title:       "Choose a new password",
+		Intro:       "Enter a new password for your account. You will be signed out everywhere.",
+		Action:      "/password/reset",
+		Token:       c.Query("token"),
+		Button:      "Reset password",
+		AskPassword: true,
+	})
+}
+
+// ResetPasswordHandler sets a new password using a reset token, sent as JSON
+// or by the form of the reset page
+func (h *AuthHandler) ResetPasswordHandler(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and password are required", "details": err.Error()})
+		return
+	}
+
+	if err := h.AuthUsecase.ResetPassword(context.Background(), req.Token, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+// MagicLinkHandler emails a sign-in link if the account exists
+func (h *AuthHandler) MagicLinkHandler(c *gin.Context) {
+	var req domain.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required", "details": err.Error()})
+		return
+	}
+
+	if err := h.AuthUsecase.RequestMagicLink(context.Background(), req.Email, clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process sign-in link request"})
+		return
+	}
+
+	// Same response whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a sign-in link has been sent"})
+}
+
+// MagicLinkPageHandler shows the page sign-in links open when MAGIC_LINK_URL
+// does not point elsewhere. It only asks for confirmation, because mail
+// scanners open links and would use the token up before the user does.
+func (h *AuthHandler) MagicLinkPageHandler(c *gin.Context) {
+	renderLinkPage(c, linkPage{
+		Title:  "Sign in",
+		Intro:  "Continue to sign in with the link from your email. The link can only be used once.",
+		Action: "/login/magic/verify",
+		Token:  c.Query("token"),
+		Button: "Sign in",
+	})
+}
+
+// VerifyMagicLinkHandler signs in with the token of a sign-in link, sent as
+// JSON or by the form of the link page. It responds like /login.
+func (h *AuthHandler) VerifyMagicLinkHandler(c *gin.Context) {
+	var req domain.VerifyMagicLinkRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required", "details": err.Error()})
+		return
+	}
+
+	client := clientInfo(c)
+	client.DeviceName = req.DeviceName
+
+	result, err := h.AuthUsecase.VerifyMagicLink(context.Background(), req.Token, client)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, domain.ErrInvalidUserToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrEmailNotVerified), errors.Is(err, domain.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in", "details": err.Error()})
+	}
+}
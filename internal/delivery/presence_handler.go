@@ -0,0 +1,60 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPresenceHandler returns whether a contact of the current user is online
+func (h *ChatHandler) GetPresenceHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	presence, err := h.ChatUsecase.GetPresence(context.Background(), userID, id)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"data": presence})
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get presence", "details": err.Error()})
+	}
+}
+
+// GetPresencesHandler returns whether several contacts of the current user are
+// online. Users who are not contacts are left out rather than refused.
+func (h *ChatHandler) GetPresencesHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.PresenceBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "between 1 and 100 user_ids are required", "details": err.Error()})
+		return
+	}
+
+	presences, err := h.ChatUsecase.GetPresences(context.Background(), userID, req.UserIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get presence", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": presences})
+}
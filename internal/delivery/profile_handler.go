@@ -0,0 +1,139 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProfileHandler returns the caller's account
+func (h *AuthHandler) GetProfileHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	user, err := h.AuthUsecase.GetProfile(context.Background(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfileHandler changes the caller's name and profile fields
+func (h *AuthHandler) UpdateProfileHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	user, err := h.AuthUsecase.UpdateProfile(context.Background(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangeEmailHandler sends a confirmation link to the new address
+func (h *AuthHandler) ChangeEmailHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email and the current password are required", "details": err.Error()})
+		return
+	}
+
+	err := h.AuthUsecase.ChangeEmail(context.Background(), userID, req.Email, req.CurrentPassword, clientInfo(c))
+	if err != nil {
+		respondAccountChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new address"})
+}
+
+// ConfirmEmailChangeHandler applies an email change. Like email verification
+// the token may come from the link query string (GET) or a JSON body (POST).
+func (h *AuthHandler) ConfirmEmailChangeHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req domain.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required", "details": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+
+	err := h.AuthUsecase.ConfirmEmailChange(context.Background(), token)
+	if errors.Is(err, domain.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed successfully"})
+}
+
+// ChangePasswordHandler sets a new password and signs the caller out everywhere
+func (h *AuthHandler) ChangePasswordHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and new_password are required", "details": err.Error()})
+		return
+	}
+
+	err := h.AuthUsecase.ChangePassword(context.Background(), userID, req.CurrentPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		respondAccountChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, please log in again"})
+}
+
+// respondAccountChangeError maps the errors of changes that require the current password
+func respondAccountChangeError(c *gin.Context, err error) {
+	var throttled *domain.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts. Please try again later."})
+	case errors.Is(err, domain.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,46 @@
+package delivery
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmailHandler confirms an email address. The token may come from the
+// link query string (GET) or a JSON body (POST).
+func (h *AuthHandler) VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req domain.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required", "details": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+
+	if err := h.AuthUsecase.VerifyEmail(context.Background(), token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationHandler sends a new verification email if the account is still unverified
+func (h *AuthHandler) ResendVerificationHandler(c *gin.Context) {
+	var req domain.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required", "details": err.Error()})
+		return
+	}
+
+	if err := h.AuthUsecase.ResendVerification(context.Background(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resend verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is not verified yet, a new verification email has been sent"})
+}
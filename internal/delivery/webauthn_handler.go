@@ -0,0 +1,155 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BeginPasskeyRegistrationHandler returns the options for navigator.credentials.create
+func (h *AuthHandler) BeginPasskeyRegistrationHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.BeginPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password is required", "details": err.Error()})
+		return
+	}
+
+	options, err := h.AuthUsecase.BeginPasskeyRegistration(context.Background(), userID, req.CurrentPassword, req.Code, clientInfo(c))
+	if err != nil {
+		respondWebAuthnError(c, err, "failed to start passkey registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishPasskeyRegistrationHandler stores the passkey the browser created
+func (h *AuthHandler) FinishPasskeyRegistrationHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req domain.FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid passkey credential", "details": err.Error()})
+		return
+	}
+
+	credential, err := h.AuthUsecase.FinishPasskeyRegistration(context.Background(), userID, &req, clientInfo(c))
+	if err != nil {
+		respondWebAuthnError(c, err, "failed to register passkey")
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// BeginPasskeyLoginHandler returns the options for navigator.credentials.get
+func (h *AuthHandler) BeginPasskeyLoginHandler(c *gin.Context) {
+	options, err := h.AuthUsecase.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		respondWebAuthnError(c, err, "failed to start passkey login")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishPasskeyLoginHandler signs in with a passkey assertion. It responds like /login.
+func (h *AuthHandler) FinishPasskeyLoginHandler(c *gin.Context) {
+	var req domain.FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid passkey assertion", "details": err.Error()})
+		return
+	}
+
+	client := clientInfo(c)
+	client.DeviceName = req.DeviceName
+
+	result, err := h.AuthUsecase.FinishPasskeyLogin(context.Background(), &req, client)
+	if err != nil {
+		respondWebAuthnError(c, err, "failed to sign in")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListPasskeysHandler lists the caller's passkeys
+func (h *AuthHandler) ListPasskeysHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	credentials, err := h.AuthUsecase.ListPasskeys(context.Background(), userID)
+	if err != nil {
+		respondWebAuthnError(c, err, "failed to list passkeys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// DeletePasskeyHandler removes one of the caller's passkeys
+func (h *AuthHandler) DeletePasskeyHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid passkey id"})
+		return
+	}
+
+	if err := h.AuthUsecase.DeletePasskey(context.Background(), userID, id, clientInfo(c)); err != nil {
+		respondWebAuthnError(c, err, "failed to delete passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "passkey deleted"})
+}
+
+// respondWebAuthnError maps passkey errors to status codes
+func respondWebAuthnError(c *gin.Context, err error, message string) {
+	var throttled *domain.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts. Please try again later."})
+	case errors.Is(err, domain.ErrIncorrectPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrMFACodeRequired), errors.Is(err, domain.ErrInvalidMFACode):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrWebAuthnUnavailable):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidPasskey):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": domain.ErrInvalidPasskey.Error(), "details": err.Error()})
+	case errors.Is(err, domain.ErrInvalidWebAuthnChallenge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrPasskeyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrPasskeyNotFound), errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrEmailNotVerified), errors.Is(err, domain.ErrAccountDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
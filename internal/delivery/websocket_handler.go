@@ -20,19 +20,27 @@ import (
 type WebSocketHandler struct {
 	ChatUsecase *usecase.ChatUsecase
 	NatsService *service.NATSService
-	// Track active connections
-	clients    map[int]*pkg.Client
+	// Track active connections, a user may be connected from several devices
+	clients    map[int]map[*pkg.Client]bool
 	clientsMux sync.RWMutex
+	// Connections to tell about presence changes by contact, and the contacts of each connection
+	watchers map[int]map[*pkg.Client]bool
+	contacts map[*pkg.Client][]int
+	// Keeps status updates of a user from overtaking each other
+	presenceMux sync.Mutex
 	// WebSocket upgrader
 	upgrader websocket.Upgrader
 }
 
-// NewWebSocketHandler creates a new instance of WebSocketHandler
-func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase, natsService *service.NATSService) *WebSocketHandler {
-	return &WebSocketHandler{
+// NewWebSocketHandler creates a new instance of WebSocketHandler. Connections
+// are closed when the token or session they were opened with is revoked.
+func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase, natsService *service.NATSService, revocationService *service.RevocationService) *WebSocketHandler {
+	h := &WebSocketHandler{
 		ChatUsecase: chatUsecase,
 		NatsService: natsService,
-		clients:     make(map[int]*pkg.Client),
+		clients:     make(map[int]map[*pkg.Client]bool),
+		watchers:    make(map[int]map[*pkg.Client]bool),
+		contacts:    make(map[*pkg.Client][]int),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -42,6 +50,32 @@ func NewWebSocketHandler(chatUsecase *usecase.ChatUsecase, natsService *service.
 			},
 		},
 	}
+
+	if revocationService != nil {
+		revocationService.OnRevoke(h.closeRevokedClients)
+	}
+
+	if chatUsecase.Presence != nil {
+		chatUsecase.Presence.OnChange(h.forwardPresence)
+	}
+
+	// Group messages, read receipts, typing events and message updates arrive once per instance and go to the members connected here
+	if natsService != nil {
+		if err := natsService.SubscribeToGroupMessages(h.forwardGroupMessage); err != nil {
+			log.Printf("Error subscribing to group messages: %v", err)
+		}
+		if err := natsService.SubscribeToReadReceipts(h.forwardReadReceipt); err != nil {
+			log.Printf("Error subscribing to read receipts: %v", err)
+		}
+		if err := natsService.SubscribeToTypingEvents(h.forwardTypingEvent); err != nil {
+			log.Printf("Error subscribing to typing events: %v", err)
+		}
+		if err := natsService.SubscribeToMessageUpdates(h.forwardMessageUpdate); err != nil {
+			log.Printf("Error subscribing to message updates: %v", err)
+		}
+	}
+
+	return h
 }
 
 // HandleWebSocket upgrades the HTTP connection to WebSocket
@@ -74,40 +108,34 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 
 	// Create client
 	client := pkg.NewClient(conn, userID)
+	if principal, ok := currentPrincipal(c); ok && principal.AuthMethod == domain.AuthMethodJWT {
+		client.TokenID = principal.TokenID
+		client.SessionID = principal.SessionID
+		client.IssuedAt = principal.IssuedAt
+	}
 
 	// Register client
 	h.registerClient(client)
+	h.watchContacts(client)
+	h.updatePresence(client.ID)
 
 	// Subscribe to NATS for this user
 	if h.NatsService != nil {
-		err = h.NatsService.SubscribeToUserMessages(userID, func(senderID, receiverID int, content string) {
+		err = h.NatsService.SubscribeToUserMessages(userID, func(message *domain.Message) {
 			// Only forward messages if they're intended for this user
-			if receiverID == userID {
-				// Convert to a domain message
-				message := &domain.Message{
-					SenderID:   senderID,
-					ReceiverID: receiverID,
-					Content:    content,
-					CreatedAt:  time.Now(),
-				}
-
-				// Marshal message to JSON for WebSocket transport
-				messageData, err := json.Marshal(message)
-				if err != nil {
-					log.Printf("Error marshaling NATS message: %v", err)
-					return
-				}
-
-				// Create WebSocket message
-				wsMessage := pkg.WebSocketMessage{
-					Type: "chat",
-					Data: messageData,
-				}
-
-				// Send to client
-				client.Send <- wsMessage
-				log.Printf("Forwarded NATS message to user %d", userID)
+			if message.ReceiverID != userID {
+				return
+			}
+
+			// Marshal message to JSON for WebSocket transport
+			messageData, err := json.Marshal(message)
+			if err != nil {
+				log.Printf("Error marshaling NATS message: %v", err)
+				return
 			}
+
+			deliver(client, pkg.WebSocketMessage{Type: pkg.TypeChat, Data: messageData})
+			log.Printf("Forwarded NATS message to user %d", userID)
 		})
 
 		if err != nil {
@@ -124,7 +152,10 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 func (h *WebSocketHandler) registerClient(client *pkg.Client) {
 	h.clientsMux.Lock()
 	defer h.clientsMux.Unlock()
-	h.clients[client.ID] = client
+	if h.clients[client.ID] == nil {
+		h.clients[client.ID] = make(map[*pkg.Client]bool)
+	}
+	h.clients[client.ID][client] = true
 	log.Printf("Client connected: %d", client.ID)
 }
 
@@ -132,24 +163,236 @@ func (h *WebSocketHandler) registerClient(client *pkg.Client) {
 func (h *WebSocketHandler) unregisterClient(client *pkg.Client) {
 	h.clientsMux.Lock()
 	defer h.clientsMux.Unlock()
-	if _, ok := h.clients[client.ID]; ok {
-		delete(h.clients, client.ID)
+	if _, ok := h.clients[client.ID][client]; ok {
+		delete(h.clients[client.ID], client)
+		if len(h.clients[client.ID]) == 0 {
+			delete(h.clients, client.ID)
+		}
+		for _, contactID := range h.contacts[client] {
+			delete(h.watchers[contactID], client)
+			if len(h.watchers[contactID]) == 0 {
+				delete(h.watchers, contactID)
+			}
+		}
+		delete(h.contacts, client)
 		client.Conn.Close()
 		log.Printf("Client disconnected: %d", client.ID)
 	}
 }
 
-// getClient gets a client by ID
-func (h *WebSocketHandler) getClient(id int) *pkg.Client {
+// getClients gets every connection of a user
+func (h *WebSocketHandler) getClients(id int) []*pkg.Client {
 	h.clientsMux.RLock()
 	defer h.clientsMux.RUnlock()
-	return h.clients[id]
+	clients := make([]*pkg.Client, 0, len(h.clients[id]))
+	for client := range h.clients[id] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// watchContacts loads the contacts of a new connection so it is told when they
+// come online or go offline. Contacts made while it is open are picked up on
+// the next connection.
+func (h *WebSocketHandler) watchContacts(client *pkg.Client) {
+	if h.ChatUsecase.Presence == nil {
+		return
+	}
+
+	contactIDs, err := h.ChatUsecase.GetContactIDs(context.Background(), client.ID)
+	if err != nil {
+		log.Printf("Error loading contacts of user %d: %v", client.ID, err)
+		return
+	}
+
+	h.clientsMux.Lock()
+	defer h.clientsMux.Unlock()
+	h.contacts[client] = contactIDs
+	for _, contactID := range contactIDs {
+		if h.watchers[contactID] == nil {
+			h.watchers[contactID] = make(map[*pkg.Client]bool)
+		}
+		h.watchers[contactID][client] = true
+	}
+}
+
+// updatePresence works out the status of a user from their connections here:
+// online if one of them is active, away if all are idle, offline without any
+func (h *WebSocketHandler) updatePresence(userID int) {
+	h.presenceMux.Lock()
+	defer h.presenceMux.Unlock()
+
+	status := domain.PresenceOffline
+	h.clientsMux.RLock()
+	for client := range h.clients[userID] {
+		if !client.Away {
+			status = domain.PresenceOnline
+			break
+		}
+		status = domain.PresenceAway
+	}
+	h.clientsMux.RUnlock()
+
+	h.ChatUsecase.SetPresence(userID, status)
+}
+
+// forwardPresence tells the connections of a user's contacts that their status changed
+func (h *WebSocketHandler) forwardPresence(presence domain.Presence) {
+	h.clientsMux.RLock()
+	clients := make([]*pkg.Client, 0, len(h.watchers[presence.UserID]))
+	for client := range h.watchers[presence.UserID] {
+		clients = append(clients, client)
+	}
+	h.clientsMux.RUnlock()
+
+	if len(clients) == 0 {
+		return
+	}
+
+	presenceData, err := json.Marshal(presence)
+	if err != nil {
+		log.Printf("Error marshaling presence: %v", err)
+		return
+	}
+
+	for _, client := range clients {
+		// A connection this far behind can catch up with the presence endpoints
+		select {
+		case client.Send <- pkg.WebSocketMessage{Type: pkg.TypePresence, Data: presenceData}:
+		default:
+		}
+	}
+}
+
+// forwardGroupMessage sends a group message to every connection of a member
+func (h *WebSocketHandler) forwardGroupMessage(memberID int, message *domain.Message) {
+	clients := h.getClients(memberID)
+	if len(clients) == 0 {
+		return
+	}
+
+	messageData, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling NATS message: %v", err)
+		return
+	}
+
+	for _, client := range clients {
+		deliver(client, pkg.WebSocketMessage{Type: pkg.TypeChat, Data: messageData})
+	}
+	log.Printf("Forwarded group message to user %d", memberID)
+}
+
+// deliver queues a frame without waiting. The NATS callbacks that fan out to
+// connections are shared by every user of the instance, so a connection too
+// far behind to take the frame is closed rather than allowed to hold up the
+// others. Its client reconnects and reloads what it missed over REST.
+func deliver(client *pkg.Client, frame pkg.WebSocketMessage) {
+	select {
+	case client.Send <- frame:
+	default:
+		log.Printf("Closing slow connection of user %d", client.ID)
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "connection too slow")
+		client.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		client.Conn.Close()
+	}
+}
+
+// forwardReadReceipt tells every connection of a member how far someone has read
+func (h *WebSocketHandler) forwardReadReceipt(memberID int, receipt *domain.ReadReceipt) {
+	clients := h.getClients(memberID)
+	if len(clients) == 0 {
+		return
+	}
+
+	receiptData, err := json.Marshal(receipt)
+	if err != nil {
+		log.Printf("Error marshaling read receipt: %v", err)
+		return
+	}
+
+	for _, client := range clients {
+		deliver(client, pkg.WebSocketMessage{Type: pkg.TypeRead, Data: receiptData})
+	}
+}
+
+// forwardTypingEvent tells every connection of a member that someone started or stopped typing
+func (h *WebSocketHandler) forwardTypingEvent(memberID int, event *domain.TypingEvent) {
+	clients := h.getClients(memberID)
+	if len(clients) == 0 {
+		return
+	}
+
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling typing event: %v", err)
+		return
+	}
+
+	frameType := pkg.TypeTypingStop
+	if event.Typing {
+		frameType = pkg.TypeTypingStart
+	}
+
+	for _, client := range clients {
+		// Typing indicators are dropped rather than held up behind a slow connection
+		select {
+		case client.Send <- pkg.WebSocketMessage{Type: frameType, Data: eventData}:
+		default:
+		}
+	}
+}
+
+// forwardMessageUpdate tells every connection of a member that a message was edited or deleted
+func (h *WebSocketHandler) forwardMessageUpdate(memberID int, update *service.MessageUpdate) {
+	clients := h.getClients(memberID)
+	if len(clients) == 0 {
+		return
+	}
+
+	frame, err := messageUpdateFrame(update)
+	if err != nil {
+		log.Printf("Error marshaling message update: %v", err)
+		return
+	}
+
+	for _, client := range clients {
+		deliver(client, frame)
+	}
+}
+
+// messageUpdateFrame turns a message update into a message_edited or message_deleted frame
+func messageUpdateFrame(update *service.MessageUpdate) (pkg.WebSocketMessage, error) {
+	if update.Edited != nil {
+		data, err := json.Marshal(update.Edited)
+		return pkg.WebSocketMessage{Type: pkg.TypeMessageEdited, Data: data}, err
+	}
+
+	data, err := json.Marshal(update.Deleted)
+	return pkg.WebSocketMessage{Type: pkg.TypeMessageDeleted, Data: data}, err
+}
+
+// closeRevokedClients disconnects the user's connections that were opened with
+// a token the event revokes. The read loop then unregisters them as usual.
+func (h *WebSocketHandler) closeRevokedClients(event service.RevocationEvent) {
+	for _, client := range h.getClients(event.UserID) {
+		// API key connections are not affected by token revocations
+		if client.TokenID == "" || !event.Revokes(client.TokenID, client.SessionID, client.ID, client.IssuedAt) {
+			continue
+		}
+
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+		client.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+		client.Conn.Close()
+		log.Printf("Closed connection of user %d after revocation", client.ID)
+	}
 }
 
 // handleClientConnection handles a client's WebSocket connection
 func (h *WebSocketHandler) handleClientConnection(client *pkg.Client) {
 	defer func() {
 		h.unregisterClient(client)
+		h.updatePresence(client.ID)
 	}()
 
 	// Keep the connection alive with ping/pong
@@ -179,8 +422,19 @@ func (h *WebSocketHandler) handleClientConnection(client *pkg.Client) {
 		}
 
 		// Process message based on type
-		if wsMessage.Type == "chat" {
+		switch wsMessage.Type {
+		case pkg.TypeChat:
 			h.handleChatMessage(client, wsMessage.Data)
+		case pkg.TypeRead:
+			h.handleReadMessage(client, wsMessage.Data)
+		case pkg.TypeTypingStart, pkg.TypeTypingStop:
+			h.handleTypingMessage(client, wsMessage.Type, wsMessage.Data)
+		case pkg.TypePresence:
+			h.handlePresenceMessage(client, wsMessage.Data)
+		case pkg.TypeEdit:
+			h.handleEditMessage(client, wsMessage.Data)
+		case pkg.TypeDelete:
+			h.handleDeleteMessage(client, wsMessage.Data)
 		}
 	}
 }
@@ -197,6 +451,110 @@ func (h *WebSocketHandler) handleMessages(client *pkg.Client) {
 	}
 }
 
+// sendError sends an error frame to a client
+func sendError(client *pkg.Client, err error) {
+	errorData, _ := json.Marshal(pkg.ErrorMessage{Message: err.Error()})
+	client.Send <- pkg.WebSocketMessage{Type: pkg.TypeError, Data: errorData}
+}
+
+// handleReadMessage marks a conversation read. The client gets the resulting
+// receipt back, the other members get it over NATS.
+func (h *WebSocketHandler) handleReadMessage(client *pkg.Client, data json.RawMessage) {
+	var req domain.MarkReadRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("Error parsing read message: %v", err)
+		return
+	}
+
+	receipt, err := h.ChatUsecase.MarkConversationRead(context.Background(), client.ID, req.ConversationID, req.MessageID)
+	if err != nil {
+		sendError(client, err)
+		return
+	}
+
+	receiptData, _ := json.Marshal(receipt)
+	client.Send <- pkg.WebSocketMessage{Type: pkg.TypeRead, Data: receiptData}
+}
+
+// handleTypingMessage starts or stops the client's typing indicator. There is
+// no confirmation, only errors are answered.
+func (h *WebSocketHandler) handleTypingMessage(client *pkg.Client, frameType string, data json.RawMessage) {
+	var req domain.TypingRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("Error parsing typing message: %v", err)
+		return
+	}
+
+	if frameType == pkg.TypeTypingStop {
+		h.ChatUsecase.StopTyping(client.ID, req.ConversationID)
+		return
+	}
+
+	if err := h.ChatUsecase.StartTyping(context.Background(), client.ID, req.ConversationID); err != nil {
+		sendError(client, err)
+	}
+}
+
+// handlePresenceMessage marks the client idle or active again. There is no
+// confirmation, only errors are answered.
+func (h *WebSocketHandler) handlePresenceMessage(client *pkg.Client, data json.RawMessage) {
+	var req domain.SetPresenceRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("Error parsing presence message: %v", err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		sendError(client, err)
+		return
+	}
+
+	h.clientsMux.Lock()
+	client.Away = req.Status == domain.PresenceAway
+	h.clientsMux.Unlock()
+
+	h.updatePresence(client.ID)
+}
+
+// handleEditMessage edits a message of the client. The client gets the edited
+// message back, the other members get it over NATS.
+func (h *WebSocketHandler) handleEditMessage(client *pkg.Client, data json.RawMessage) {
+	var req domain.EditMessageRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("Error parsing edit message: %v", err)
+		return
+	}
+
+	message, err := h.ChatUsecase.EditMessage(context.Background(), client.ID, req.ConversationID, req.MessageID, req.Content)
+	if err != nil {
+		sendError(client, err)
+		return
+	}
+
+	frame, _ := messageUpdateFrame(&service.MessageUpdate{Edited: message})
+	client.Send <- frame
+}
+
+// handleDeleteMessage deletes a message for the client or for everyone. The
+// client gets the deletion back, the other members get deletions for
+// everyone over NATS.
+func (h *WebSocketHandler) handleDeleteMessage(client *pkg.Client, data json.RawMessage) {
+	var req domain.DeleteMessageRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Printf("Error parsing delete message: %v", err)
+		return
+	}
+
+	deletion, err := h.ChatUsecase.DeleteMessage(context.Background(), client.ID, req.ConversationID, req.MessageID, req.For)
+	if err != nil {
+		sendError(client, err)
+		return
+	}
+
+	frame, _ := messageUpdateFrame(&service.MessageUpdate{Deleted: deletion})
+	client.Send <- frame
+}
+
 // handleChatMessage processes a chat message
 func (h *WebSocketHandler) handleChatMessage(client *pkg.Client, data json.RawMessage) {
 	var msgReq domain.MessageRequest
@@ -206,12 +564,13 @@ func (h *WebSocketHandler) handleChatMessage(client *pkg.Client, data json.RawMe
 	}
 
 	// Store message in database and publish to NATS
-	message, err := h.ChatUsecase.SendMessage(
-		context.Background(),
-		client.ID,
-		msgReq.ReceiverID,
-		msgReq.Content,
-	)
+	var message *domain.Message
+	err := msgReq.Validate()
+	if err == nil && msgReq.ConversationID > 0 {
+		message, err = h.ChatUsecase.SendConversationMessage(context.Background(), client.ID, msgReq.ConversationID, msgReq.Content)
+	} else if err == nil {
+		message, err = h.ChatUsecase.SendMessage(context.Background(), client.ID, msgReq.ReceiverID, msgReq.Content)
+	}
 	if err != nil {
 		log.Printf("Error saving message: %v", err)
 		// Send error message back to sender
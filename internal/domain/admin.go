@@ -0,0 +1,66 @@
+package domain
+
+import "errors"
+
+// Account states an administrator can filter the user list by
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	UserStatusDeleted  = "deleted"
+)
+
+// Pagination limits of the admin user list
+const (
+	DefaultUserPageSize = 20
+	MaxUserPageSize     = 100
+)
+
+var (
+	// ErrInvalidUserStatus is returned when filtering the user list by an unknown status
+	ErrInvalidUserStatus = errors.New("status must be active, disabled or deleted")
+	// ErrSelfAdministration is returned when an administrator targets their own account
+	ErrSelfAdministration = errors.New("administrators cannot do this to their own account")
+	// ErrCannotImpersonate is returned for accounts that may not be impersonated
+	ErrCannotImpersonate = errors.New("this account cannot be impersonated")
+)
+
+// UserFilter selects a page of the admin user list
+type UserFilter struct {
+	// Query matches email addresses and names, case insensitively
+	Query  string
+	Status string
+	Role   string
+	Limit  int
+	Offset int
+}
+
+// UserPage is one page of the admin user list
+type UserPage struct {
+	Users  []*User `json:"users"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// UserDetails is what an administrator sees about a single account
+type UserDetails struct {
+	User        *User      `json:"user"`
+	Roles       []string   `json:"roles"`
+	Permissions []string   `json:"permissions"`
+	Sessions    []*Session `json:"sessions"`
+}
+
+// ImpersonationToken is a short-lived access token for acting as another
+// user. It has no refresh token and cannot be used for account management.
+type ImpersonationToken struct {
+	AccessToken    string `json:"token"`
+	TokenType      string `json:"token_type"`
+	ExpiresIn      int64  `json:"expires_in"`
+	UserID         int    `json:"user_id"`
+	ImpersonatorID int    `json:"impersonator_id"`
+}
+
+// DisableUserRequest optionally records why an account was disabled
+type DisableUserRequest struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so leaked keys are easy to recognise and scan for
+const APIKeyPrefix = "gaa"
+
+// ScopeChat grants access to the chat API. The other scopes are permission names.
+const ScopeChat = "chat"
+
+var (
+	// ErrInvalidAPIKey is returned for unknown, malformed, expired or revoked keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidScope is returned when a key asks for a scope its owner does not have
+	ErrInvalidScope = errors.New("invalid API key scope")
+)
+
+// APIKey is a long-lived credential for scripts and devices. Only a hash of the
+// secret is stored; the prefix identifies the key without revealing it.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the key can still be used
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreateAPIKeyRequest names a new key and lists its scopes
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"`
+}
+
+// CreatedAPIKey is returned once on creation; Key is never shown again
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -2,31 +2,223 @@ package domain
 
 import (
 	"errors"
+	"strings"
 	"time"
 )
 
-// Message represents a chat message between users
+// Kinds of conversation
+const (
+	ConversationTypeDirect = "direct"
+	ConversationTypeGroup  = "group"
+)
+
+// Roles of a conversation member. Owners and admins manage the members of a
+// group, only the owner hands out roles.
+const (
+	ConversationRoleOwner  = "owner"
+	ConversationRoleAdmin  = "admin"
+	ConversationRoleMember = "member"
+)
+
+// Who a message is deleted for. Deleting for everyone is up to the sender and
+// leaves a tombstone, any member can hide a message from themselves.
+const (
+	DeleteForMe       = "me"
+	DeleteForEveryone = "everyone"
+)
+
+// MaxGroupMembers caps the size of a group, the creator included
+const MaxGroupMembers = 256
+
+var (
+	// ErrConversationNotFound is returned when a conversation does not exist or the user is not a member
+	ErrConversationNotFound = errors.New("conversation not found")
+	// ErrNotConversationMember is returned when the user acted on is not a member of the conversation
+	ErrNotConversationMember = errors.New("user is not a member of this conversation")
+	// ErrNotGroupConversation is returned when managing the members of a direct conversation
+	ErrNotGroupConversation = errors.New("conversation is not a group")
+	// ErrConversationForbidden is returned when a member's role does not allow the change
+	ErrConversationForbidden = errors.New("your role in this conversation does not allow this")
+	// ErrGroupTooLarge is returned when a group would exceed MaxGroupMembers
+	ErrGroupTooLarge = errors.New("group has too many members")
+	// ErrMessageNotFound is returned when a message does not exist in the conversation
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrTypingThrottled is returned when a client sends typing events faster than allowed
+	ErrTypingThrottled = errors.New("too many typing events, slow down")
+	// ErrInvalidGroupName is returned for an empty or overlong group name
+	ErrInvalidGroupName = errors.New("group name must be between 1 and 100 characters")
+	// ErrNotMessageSender is returned when someone other than the sender edits or deletes a message for everyone
+	ErrNotMessageSender = errors.New("only the sender can change this message")
+	// ErrMessageDeleted is returned when editing a message that was deleted for everyone
+	ErrMessageDeleted = errors.New("message has been deleted")
+	// ErrInvalidDeleteScope is returned when a message is deleted for someone other than "me" or "everyone"
+	ErrInvalidDeleteScope = errors.New(`messages are deleted for "me" or "everyone"`)
+)
+
+// Message represents a chat message in a conversation. ReceiverID is only set
+// for direct messages. A message deleted for everyone is a tombstone: it keeps
+// its place in the conversation but has no content.
 type Message struct {
+	ID             int        `json:"id"`
+	ConversationID int        `json:"conversation_id,omitempty"`
+	SenderID       int        `json:"sender_id"`
+	ReceiverID     int        `json:"receiver_id,omitempty"`
+	Content        string     `json:"content"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EditedAt       *time.Time `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// IsDeleted reports whether the message was deleted for everyone
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// MessageRevision is an earlier version of an edited message, written at
+// WrittenAt and replaced by the next one at ReplacedAt
+type MessageRevision struct {
 	ID         int       `json:"id"`
-	SenderID   int       `json:"sender_id"`
-	ReceiverID int       `json:"receiver_id"`
+	MessageID  int       `json:"message_id"`
 	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
+	WrittenAt  time.Time `json:"written_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// MessageDeletion tells who a message was deleted for
+type MessageDeletion struct {
+	ConversationID int       `json:"conversation_id"`
+	MessageID      int       `json:"message_id"`
+	For            string    `json:"for"`
+	DeletedAt      time.Time `json:"deleted_at"`
 }
 
-// Conversation represents a chat conversation between two users
+// Conversation represents a chat conversation, either between two users or a
+// named group. User1ID and User2ID are only set for direct conversations.
 type Conversation struct {
-	ID          int       `json:"id"`
-	User1ID     int       `json:"user1_id"`
-	User2ID     int       `json:"user2_id"`
-	LastMessage string    `json:"last_message"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int                   `json:"id"`
+	Type        string                `json:"type"`
+	Name        string                `json:"name,omitempty"`
+	User1ID     int                   `json:"user1_id,omitempty"`
+	User2ID     int                   `json:"user2_id,omitempty"`
+	CreatedBy   int                   `json:"created_by,omitempty"`
+	LastMessage string                `json:"last_message"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+	Members     []*ConversationMember `json:"members,omitempty"`
+	// LastReadMessageID and UnreadCount are those of the user listing their conversations
+	LastReadMessageID int `json:"last_read_message_id"`
+	UnreadCount       int `json:"unread_count"`
+}
+
+// IsGroup reports whether the conversation is a group
+func (c *Conversation) IsGroup() bool {
+	return c.Type == ConversationTypeGroup
+}
+
+// ConversationMember is a user's membership of a conversation. The member has
+// read every message up to LastReadMessageID.
+type ConversationMember struct {
+	ConversationID    int       `json:"conversation_id"`
+	UserID            int       `json:"user_id"`
+	Role              string    `json:"role"`
+	JoinedAt          time.Time `json:"joined_at"`
+	LastReadMessageID int       `json:"last_read_message_id"`
+}
+
+// CanManageMembers reports whether the member may rename the group and add or remove members
+func (m *ConversationMember) CanManageMembers() bool {
+	return m.Role == ConversationRoleOwner || m.Role == ConversationRoleAdmin
 }
 
-// MessageRequest is used for receiving message data from clients
+// MessageRequest is used for receiving message data from clients. A message
+// goes either to a user, in their direct conversation, or to a conversation.
 type MessageRequest struct {
-	ReceiverID int    `json:"receiver_id" binding:"required"`
-	Content    string `json:"content" binding:"required"`
+	ReceiverID     int    `json:"receiver_id"`
+	ConversationID int    `json:"conversation_id"`
+	Content        string `json:"content" binding:"required"`
+}
+
+// Validate checks that the request names exactly one recipient
+func (r *MessageRequest) Validate() error {
+	if (r.ReceiverID > 0) == (r.ConversationID > 0) {
+		return errors.New("either receiver_id or conversation_id is required")
+	}
+	return nil
+}
+
+// ReadReceipt tells the members of a conversation how far a user has read
+type ReadReceipt struct {
+	ConversationID int       `json:"conversation_id"`
+	UserID         int       `json:"user_id"`
+	MessageID      int       `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// MarkReadRequest is the payload for marking a conversation read. Without a
+// message ID it is read up to its latest message.
+type MarkReadRequest struct {
+	ConversationID int `json:"conversation_id"`
+	MessageID      int `json:"message_id"`
+}
+
+// EditMessageRequest is the payload for editing a message. Over REST the
+// conversation and message come from the path.
+type EditMessageRequest struct {
+	ConversationID int    `json:"conversation_id"`
+	MessageID      int    `json:"message_id"`
+	Content        string `json:"content" binding:"required"`
+}
+
+// DeleteMessageRequest is the payload of the delete frame, For defaults to DeleteForMe
+type DeleteMessageRequest struct {
+	ConversationID int    `json:"conversation_id"`
+	MessageID      int    `json:"message_id"`
+	For            string `json:"for"`
+}
+
+// TypingEvent tells the members of a conversation that someone started or
+// stopped typing. Typing events are never stored.
+type TypingEvent struct {
+	ConversationID int  `json:"conversation_id"`
+	UserID         int  `json:"user_id"`
+	Typing         bool `json:"typing"`
+	// ExpiresAt is when to hide the indicator if no further event arrives
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TypingRequest is the payload of the typing_start and typing_stop frames
+type TypingRequest struct {
+	ConversationID int `json:"conversation_id"`
+}
+
+// CreateGroupRequest is the payload for starting a group conversation
+type CreateGroupRequest struct {
+	Name      string `json:"name" binding:"required"`
+	MemberIDs []int  `json:"member_ids"`
+}
+
+// RenameGroupRequest is the payload for renaming a group
+type RenameGroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddGroupMembersRequest is the payload for adding users to a group
+type AddGroupMembersRequest struct {
+	UserIDs []int `json:"user_ids" binding:"required,min=1"`
+}
+
+// SetMemberRoleRequest is the payload for changing a member's role. Making
+// someone the owner hands the group over, the previous owner becomes an admin.
+type SetMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// NormalizeGroupName trims a group name and checks its length
+func NormalizeGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return "", ErrInvalidGroupName
+	}
+	return name, nil
 }
 
 // ValidateMessage validates the message content
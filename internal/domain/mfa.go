@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidMFACode is returned for wrong, reused or expired second-factor codes
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already has TOTP enabled
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnrolled is returned when confirming without a pending enrollment
+	ErrMFANotEnrolled = errors.New("no pending two-factor enrollment")
+)
+
+// UserMFA holds a user's TOTP enrollment
+type UserMFA struct {
+	UserID         int        `json:"user_id"`
+	TOTPSecret     string     `json:"-"`
+	EnabledAt      *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// IsEnabled reports whether the enrollment was confirmed with a valid code
+func (m *UserMFA) IsEnabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
+
+// LockoutRemaining reports how long codes are still refused after too many
+// wrong ones, or zero when the user may try again
+func (m *UserMFA) LockoutRemaining(now time.Time, maxAttempts int) time.Duration {
+	if m == nil || m.FailedAttempts < maxAttempts || m.LockedUntil == nil || !now.Before(*m.LockedUntil) {
+		return 0
+	}
+	return m.LockedUntil.Sub(now)
+}
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"otpauth_url"`
+}
+
+// LoginResult is either a token pair or, for MFA users, a short-lived token
+// that must be exchanged at /mfa/verify together with a second factor
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// ConfirmTOTPRequest carries the first code from a newly enrolled authenticator
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAVerifyRequest exchanges an MFA pending token and a TOTP or recovery code for a token pair
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	// DeviceName labels the session, as on /login
+	DeviceName string `json:"device_name"`
+}
@@ -0,0 +1,248 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Scopes other services can request when users sign in to them through this app
+const (
+	OAuthScopeOpenID  = "openid"
+	OAuthScopeProfile = "profile"
+	OAuthScopeEmail   = "email"
+)
+
+// OAuthScopes are the scopes this app grants, in the order they are shown on the consent screen
+var OAuthScopes = []string{OAuthScopeOpenID, OAuthScopeProfile, OAuthScopeEmail}
+
+// Error codes of RFC 6749 and OpenID Connect Core
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrLoginRequired           = "login_required"
+	OAuthErrInvalidToken            = "invalid_token"
+	OAuthErrServerError             = "server_error"
+)
+
+var (
+	// ErrOAuthClientNotFound is returned for unknown client IDs
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	// ErrInvalidRedirectURI is returned for redirect URIs that are not registered or may not be registered
+	ErrInvalidRedirectURI = errors.New("invalid redirect URI")
+	// ErrMFACodeRequired is returned when a user with two-factor authentication signs in without a code
+	ErrMFACodeRequired = errors.New("authentication code is required")
+)
+
+// OAuthError is an error reported to an OAuth client using the standard error codes
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// NewOAuthError creates an OAuthError
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthClient is a service registered to sign users in through this app.
+// Confidential clients authenticate with a secret, of which only a hash is
+// stored; public clients, such as single page apps, must use PKCE instead.
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"-"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// HasRedirectURI reports whether uri is registered; redirect URIs must match exactly
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateOAuthClientRequest registers a client
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1"`
+	Public       bool     `json:"public"`
+}
+
+// CreatedOAuthClient is returned once on registration; the secret is never shown again
+type CreatedOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthConsent records the scopes a user allowed a client, so they are not asked again
+type OAuthConsent struct {
+	UserID     int       `json:"user_id"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// Covers reports whether the consent includes every scope
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, granted := range c.Scopes {
+			if granted == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizationCode is a single-use code handed to a client's redirect URI.
+// Only a hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
+
+// AuthorizationRequest is an /oauth/authorize request of a client
+type AuthorizationRequest struct {
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	ResponseType        string `form:"response_type"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+}
+
+// Scopes splits the space separated scope parameter
+func (r *AuthorizationRequest) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// AuthorizationDecision is the submitted sign-in and consent form of an authorization request
+type AuthorizationDecision struct {
+	AuthorizationRequest
+	Email        string `form:"email"`
+	Password     string `form:"password"`
+	OTP          string `form:"otp"`
+	RecoveryCode string `form:"recovery_code"`
+	// Decision is "allow" when the user grants the client access
+	Decision string `form:"decision"`
+}
+
+// Allowed reports whether the user granted the client access
+func (d *AuthorizationDecision) Allowed() bool {
+	return d.Decision == "allow"
+}
+
+// AuthorizationPrompt is what the user is shown on the sign-in and consent page
+type AuthorizationPrompt struct {
+	Client *OAuthClient
+	Scopes []string
+}
+
+// TokenRequest is an /oauth/token request
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenResponse is the successful /oauth/token response
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthUserInfo holds the claims about a user that a client may see, by scope
+type OAuthUserInfo struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Zoneinfo      string `json:"zoneinfo,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// Token types reported by token introspection
+const (
+	TokenTypeAccessToken = "access_token"
+	TokenTypeAPIKey      = "api_key"
+)
+
+// IntrospectionRequest is an /oauth/introspect request (RFC 7662)
+type IntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// TokenIntrospection describes a token to the service that was handed it.
+// Inactive tokens only report active, and revoked when they were revoked
+// before they expired.
+type TokenIntrospection struct {
+	Active    bool     `json:"active"`
+	Revoked   bool     `json:"revoked,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	// The fields below extend RFC 7662 with what this app's own middleware checks
+	UserID      int                 `json:"user_id,omitempty"`
+	SessionID   string              `json:"sid,omitempty"`
+	Roles       []string            `json:"roles,omitempty"`
+	Permissions []string            `json:"permissions,omitempty"`
+	Actor       *IntrospectionActor `json:"act,omitempty"`
+}
+
+// IntrospectionActor names the administrator behind an impersonation token (RFC 8693)
+type IntrospectionActor struct {
+	Subject string `json:"sub"`
+	UserID  int    `json:"user_id"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUnknownOIDCProvider is returned for providers that are not configured
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	// ErrInvalidOIDCState is returned when a callback does not match a login started here
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrOIDCEmailNotVerified is returned when the provider does not vouch for the email of a new identity
+	ErrOIDCEmailNotVerified = errors.New("the identity provider has not verified this email address")
+	// ErrOIDCLoginFailed is returned when the identity provider did not confirm who signed in
+	ErrOIDCLoginFailed = errors.New("sign in at the identity provider failed")
+	// ErrOIDCAccountConflict is returned when the email belongs to a local account whose address was never verified
+	ErrOIDCAccountConflict = errors.New("an unverified account already uses this email address")
+)
+
+// OIDCLoginState remembers a login redirected to an identity provider until
+// the user comes back to the callback. The state itself is only stored hashed.
+type OIDCLoginState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	DeviceName   string
+	ExpiresAt    time.Time
+}
+
+// UserIdentity links an account at an external identity provider to a user
+type UserIdentity struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
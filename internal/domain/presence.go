@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Presence statuses. A user is online while one of their connections is
+// active, away while all of them are idle and offline without connections.
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// MaxPresenceBatch caps the users looked up by one batch presence request
+const MaxPresenceBatch = 100
+
+// ErrInvalidPresenceStatus is returned when a client sets a status other than online or away
+var ErrInvalidPresenceStatus = errors.New("presence status must be online or away")
+
+// Presence is whether a user is connected. LastSeenAt is only set for offline
+// users who have been connected before.
+type Presence struct {
+	UserID     int        `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// PresenceBatchRequest is the payload for looking up the presence of several users
+type PresenceBatchRequest struct {
+	UserIDs []int `json:"user_ids" binding:"required,min=1,max=100"`
+}
+
+// SetPresenceRequest is the payload of the presence frame a client sends when
+// the user goes idle or comes back
+type SetPresenceRequest struct {
+	Status string `json:"status"`
+}
+
+// Validate checks that the status is one a client may set
+func (r *SetPresenceRequest) Validate() error {
+	if r.Status != PresenceOnline && r.Status != PresenceAway {
+		return ErrInvalidPresenceStatus
+	}
+	return nil
+}
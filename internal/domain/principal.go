@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// Ways a request can be authenticated
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+	// AuthMethodImpersonation is a JWT an administrator obtained to act as another user
+	AuthMethodImpersonation = "impersonation"
+)
+
+// Principal is the authenticated caller of a request, whichever credential was used
+type Principal struct {
+	UserID      int
+	Email       string
+	Roles       []string
+	Permissions []string
+	// Scopes restricts API keys; it is nil for JWTs, which are not scoped
+	Scopes     []string
+	AuthMethod string
+	// TokenID, SessionID, IssuedAt and ExpiresAt describe the JWT, APIKeyID the API key
+	TokenID   string
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	APIKeyID  int
+	// ImpersonatorID is the administrator acting as the user, if any
+	ImpersonatorID int
+}
+
+// HasScope reports whether the credential may be used for a scope
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "errors"
+
+// Built-in roles seeded by the migrations
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Permissions checked by RequirePermission
+const (
+	PermissionNATSPublish  = "nats:publish"
+	PermissionNATSRead     = "nats:read"
+	PermissionSNMPSimulate = "snmp:simulate"
+	PermissionUsersManage  = "users:manage"
+	PermissionAuditRead    = "audit:read"
+)
+
+// AdminPermissions guard the administration routes, which need a signed-in
+// user; API keys cannot be granted them
+var AdminPermissions = []string{PermissionUsersManage, PermissionAuditRead}
+
+// ErrUnknownRole is returned when assigning a role that does not exist
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is a named set of permissions
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserAccess is the set of roles and permissions granted to a user
+type UserAccess struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// AssignRoleRequest names a role to grant or revoke
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
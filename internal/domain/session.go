@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionNotFound is returned when a session does not exist, belongs to
+// someone else or was already revoked
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is one signed-in device. Its ID is the refresh token family ID, so
+// every token issued from the same login belongs to the same session.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Current marks the session the listing request was made from
+	Current bool `json:"current"`
+}
+
+// RevokedSession invalidates the access tokens of a session until they would have expired anyway
+type RevokedSession struct {
+	SessionID string    `json:"session_id"`
+	UserID    int       `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
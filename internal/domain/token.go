@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, all sessions in this family were revoked")
+)
+
+// RefreshToken is the persisted form of an opaque refresh token.
+// Tokens issued from the same login share a FamilyID so that the whole
+// chain can be revoked when a rotated token is replayed.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TokenPair is returned to clients after a successful login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// RefreshRequest is used for receiving refresh token data from clients
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RevokedToken is an access token that was revoked before its natural expiry
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	UserID    int       `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// UserRevocation invalidates every access token a user was issued before RevokedBefore
+type UserRevocation struct {
+	UserID        int       `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// LogoutRequest optionally carries the refresh token of the session being closed
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -3,16 +3,113 @@ package domain
 import (
 	"errors"
 	// "fmt"
+	"net/url"
 	"regexp"
 	"time"
 )
 
+// ErrEmailNotVerified is returned when an unverified account tries to log in or chat
+var ErrEmailNotVerified = errors.New("email address has not been verified")
+
+var (
+	// ErrInvalidCredentials is returned when a login names an unknown account or the wrong password
+	ErrInvalidCredentials = errors.New("invalid Email or password")
+	// ErrIncorrectPassword is returned when the current password given to change account details is wrong
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrEmailTaken is returned when another account already uses an email address
+	ErrEmailTaken = errors.New("email address is already in use")
+	// ErrUserNotFound is returned when an account does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAccountDisabled is returned when a disabled account tries to sign in
+	ErrAccountDisabled = errors.New("account has been disabled")
+	// ErrAccountDeleted is returned for the placeholder account that deleted users' messages are moved to
+	ErrAccountDeleted = errors.New("account has been deleted")
+)
+
+// DeletedUserEmail identifies the placeholder account that takes over the
+// messages and conversations of purged users, so the foreign keys of the
+// chat tables stay intact. It cannot log in.
+const DeletedUserEmail = "deleted-user@invalid"
+
+// Limits of the profile fields, matching the users table
+const (
+	MaxNameLength      = 100
+	MaxAvatarURLLength = 500
+	MaxBioLength       = 500
+)
+
+// User is an account. The password hash is never serialized.
 type User struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name" binding:"required"`
-	Email     string    `json:"email" binding:"required,email"`
-	Password  string    `json:"password" binding:"required,min=10"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// PendingEmail is the new address of an email change until it is verified
+	PendingEmail *string   `json:"pending_email,omitempty"`
+	AvatarURL    string    `json:"avatar_url"`
+	Bio          string    `json:"bio"`
+	Timezone     string    `json:"timezone"`
+	CreatedAt    time.Time `json:"created_at"`
+	// DeletedAt is set while the account waits out the deletion grace period
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// DisabledAt is set while an administrator has disabled the account
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// SignupRequest is used for creating an account
+type SignupRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=10"`
+}
+
+// UpdateProfileRequest changes the profile fields that are present in the body
+type UpdateProfileRequest struct {
+	Name      *string `json:"name"`
+	AvatarURL *string `json:"avatar_url"`
+	Bio       *string `json:"bio"`
+	Timezone  *string `json:"timezone"`
+}
+
+// ChangeEmailRequest starts an email change, which takes effect once the new address is verified
+type ChangeEmailRequest struct {
+	Email           string `json:"email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// ChangePasswordRequest sets a new password for a signed-in user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// DeleteAccountRequest schedules the caller's account for deletion
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// AccountExport is the personal data handed out by GET /me/export
+type AccountExport struct {
+	ExportedAt    time.Time       `json:"exported_at"`
+	Profile       *User           `json:"profile"`
+	Conversations []*Conversation `json:"conversations"`
+	Messages      []*Message      `json:"messages"`
+}
+
+// IsDeleted reports whether the account is scheduled for deletion
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// IsDisabled reports whether an administrator disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// IsEmailVerified reports whether the user confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 func isValidEmail(email string) bool {
@@ -27,14 +124,51 @@ func (u *User) Validate() error {
 	if u.Email == "" {
 		return errors.New("Email is required")
 	}
-	if u.Password == "" {
-		return errors.New("Password is required")
-	}
-	if len(u.Password) <= 10 {
-		return errors.New("Password cannot be less than 10 characters!")
+	if err := ValidatePassword(u.Password); err != nil {
+		return err
 	}
 	if !isValidEmail(u.Email) {
 		return errors.New("The email is not valid!")
 	}
 	return nil
 }
+
+// ValidateProfile checks the editable profile fields
+func (u *User) ValidateProfile() error {
+	if u.Name == "" {
+		return errors.New("Name is required")
+	}
+	if len(u.Name) > MaxNameLength {
+		return errors.New("Name is too long")
+	}
+	if len(u.Bio) > MaxBioLength {
+		return errors.New("Bio is too long")
+	}
+	if u.AvatarURL != "" {
+		if len(u.AvatarURL) > MaxAvatarURLLength {
+			return errors.New("Avatar URL is too long")
+		}
+		parsed, err := url.Parse(u.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("Avatar URL must be an http or https URL")
+		}
+	}
+	if u.Timezone != "" {
+		// "Local" would resolve to the server's zone rather than the user's
+		if _, err := time.LoadLocation(u.Timezone); err != nil || u.Timezone == "Local" {
+			return errors.New("Timezone must be an IANA time zone such as Europe/Berlin")
+		}
+	}
+	return nil
+}
+
+// ValidatePassword applies the password policy used at signup and on password changes
+func ValidatePassword(password string) error {
+	if password == "" {
+		return errors.New("Password is required")
+	}
+	if len(password) <= 10 {
+		return errors.New("Password cannot be less than 10 characters!")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Purposes of single-use tokens sent to users
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// MagicLinkIdentityProvider marks logins through an emailed link in the audit log
+const MagicLinkIdentityProvider = "magic_link"
+
+// ErrInvalidUserToken is returned for unknown, used or expired single-use tokens
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// UserToken is a hashed, single-use, time-limited token emailed to a user
+type UserToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ForgotPasswordRequest is used for requesting a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest is used for setting a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// VerifyEmailRequest carries an email verification token
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest is used for requesting a new verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkRequest asks for a sign-in link by email
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyMagicLinkRequest exchanges a sign-in link's token for a login
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+	// DeviceName labels the session, as on /login
+	DeviceName string `json:"device_name" form:"device_name"`
+}
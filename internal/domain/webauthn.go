@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Purposes of a WebAuthn challenge
+const (
+	WebAuthnPurposeRegister = "register"
+	WebAuthnPurposeLogin    = "login"
+)
+
+// PasskeyIdentityProvider marks passkey logins in the audit log
+const PasskeyIdentityProvider = "webauthn"
+
+// WebAuthnTransports are the transport hints browsers report for an authenticator
+var WebAuthnTransports = []string{"ble", "hybrid", "internal", "nfc", "smart-card", "usb"}
+
+var (
+	// ErrWebAuthnUnavailable is returned when no relying party is configured
+	ErrWebAuthnUnavailable = errors.New("passkeys are not available")
+	// ErrInvalidWebAuthnChallenge is returned when a ceremony does not answer a challenge issued here
+	ErrInvalidWebAuthnChallenge = errors.New("invalid or expired passkey challenge")
+	// ErrInvalidPasskey is returned when a passkey response cannot be verified
+	ErrInvalidPasskey = errors.New("passkey verification failed")
+	// ErrPasskeyExists is returned when registering a credential that is already stored
+	ErrPasskeyExists = errors.New("passkey is already registered")
+	// ErrPasskeyNotFound is returned when a passkey does not exist or belongs to someone else
+	ErrPasskeyNotFound = errors.New("passkey not found")
+)
+
+// Base64URL is binary data that travels in JSON as unpadded base64url, the
+// encoding WebAuthn uses for challenges, credential IDs and responses
+type Base64URL []byte
+
+// MarshalJSON encodes the data as unpadded base64url
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes base64url, with or without padding
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return errors.New("invalid base64url data")
+	}
+	*b = decoded
+	return nil
+}
+
+// WebAuthnCredential is a passkey registered to a user. The public key is the
+// COSE key from registration; the sign count detects cloned authenticators.
+type WebAuthnCredential struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	CredentialID Base64URL  `json:"credential_id"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    uint32     `json:"-"`
+	Transports   []string   `json:"transports"`
+	AAGUID       string     `json:"aaguid"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge is a challenge handed to the browser, stored hashed until
+// the ceremony finishes. Login challenges have no user: the passkey tells who signs in.
+type WebAuthnChallenge struct {
+	ChallengeHash string
+	UserID        int
+	Purpose       string
+	ExpiresAt     time.Time
+}
+
+// WebAuthnRelyingParty names this app to the authenticator
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUserEntity is the account a new passkey is created for
+type WebAuthnUserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+// WebAuthnCredentialParameter is an accepted credential algorithm
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor refers to an existing credential
+type WebAuthnCredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+// WebAuthnAuthenticatorSelection states what the new credential must support
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// PublicKeyCredentialCreationOptions are passed to navigator.credentials.create,
+// in the JSON form PublicKeyCredential.parseCreationOptionsFromJSON reads
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions are passed to navigator.credentials.get,
+// in the JSON form PublicKeyCredential.parseRequestOptionsFromJSON reads
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// BeginPasskeyRegistrationRequest re-authenticates the user before a passkey
+// is added, since a passkey signs in without the password or a TOTP code
+type BeginPasskeyRegistrationRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	// Code is the TOTP code, required when two-factor authentication is enabled
+	Code string `json:"code"`
+}
+
+// FinishPasskeyRegistrationRequest is the JSON of the credential the browser
+// created, as returned by PublicKeyCredential.toJSON, and a name for it
+type FinishPasskeyRegistrationRequest struct {
+	RawID    Base64URL `json:"rawId" binding:"required"`
+	Type     string    `json:"type" binding:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" binding:"required"`
+		AttestationObject Base64URL `json:"attestationObject" binding:"required"`
+		Transports        []string  `json:"transports"`
+	} `json:"response"`
+	Name string `json:"name" binding:"max=100"`
+}
+
+// FinishPasskeyLoginRequest is the JSON of the assertion the browser made,
+// as returned by PublicKeyCredential.toJSON
+type FinishPasskeyLoginRequest struct {
+	RawID    Base64URL `json:"rawId" binding:"required"`
+	Type     string    `json:"type" binding:"required,eq=public-key"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData Base64URL `json:"authenticatorData" binding:"required"`
+		Signature         Base64URL `json:"signature" binding:"required"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+	// DeviceName labels the session, as on /login
+	DeviceName string `json:"device_name"`
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKeyRepository defines the interface for API key storage
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	ListByUser(ctx context.Context, userID int) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id, userID int) (bool, error)
+	TouchLastUsed(ctx context.Context, id int) error
+}
+
+// apiKeyRepo implements APIKeyRepository
+type apiKeyRepo struct{}
+
+// NewAPIKeyRepository creates a new instance of apiKeyRepo
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepo{}
+}
+
+// Create stores a new key
+func (r *apiKeyRepo) Create(ctx context.Context, key *domain.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := db.DB.QueryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.ExpiresAt, now).Scan(&key.ID)
+	if err != nil {
+		return err
+	}
+
+	key.CreatedAt = now
+	return nil
+}
+
+// GetByPrefix looks up a key by its public prefix, or returns nil when there is none
+func (r *apiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM api_keys
+		WHERE prefix = $1
+	`
+
+	key, err := scanAPIKey(db.DB.QueryRow(ctx, query, prefix))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return key, err
+}
+
+// ListByUser returns the user's keys, newest first
+func (r *apiKeyRepo) ListByUser(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*domain.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke disables one of the user's keys, reporting false if it does not exist or was already revoked
+func (r *apiKeyRepo) Revoke(ctx context.Context, id, userID int) (bool, error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// TouchLastUsed records key usage at most once a minute to keep writes off the hot path
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, id int) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+
+	now := time.Now()
+	_, err := db.DB.Exec(ctx, query, now, id, now.Add(-time.Minute))
+	return err
+}
+
+// scanAPIKey reads one api_keys row
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	key := &domain.APIKey{}
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.LastUsedAt,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
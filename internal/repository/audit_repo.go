@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// auditChainLockKey is the advisory lock that serialises appends to the hash
+// chain across all instances, so two entries never share a predecessor
+const auditChainLockKey = 7041975310
+
+// auditColumns are the columns read into a domain.AuditEvent
+const auditColumns = `id, occurred_at, action, outcome, COALESCE(actor_id, 0), COALESCE(impersonator_id, 0), COALESCE(target_user_id, 0), ip, user_agent, details, prev_hash, hash`
+
+// AuditRepository defines the interface for the audit log
+type AuditRepository interface {
+	Append(ctx context.Context, event *domain.AuditEvent) error
+	AppendBatch(ctx context.Context, events []*domain.AuditEvent) error
+	Query(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error)
+	ListAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditEvent, error)
+}
+
+// auditRepo implements AuditRepository
+type auditRepo struct{}
+
+// NewAuditRepository creates a new instance of auditRepo
+func NewAuditRepository() AuditRepository {
+	return &auditRepo{}
+}
+
+// Append links the event to the latest entry and stores it, filling in
+// PrevHash, Hash and ID
+func (r *auditRepo) Append(ctx context.Context, event *domain.AuditEvent) error {
+	return r.AppendBatch(ctx, []*domain.AuditEvent{event})
+}
+
+// AppendBatch stores events in order at the end of the chain in a single
+// transaction, so the chain lock is taken once for the whole batch
+func (r *auditRepo) AppendBatch(ctx context.Context, events []*domain.AuditEvent) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(auditChainLockKey)); err != nil {
+		return err
+	}
+
+	var prevHash string
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		prevHash = domain.AuditGenesisHash
+	} else if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_events (occurred_at, action, outcome, actor_id, impersonator_id, target_user_id, ip, user_agent, details, prev_hash, hash)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, 0), $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+
+	for _, event := range events {
+		if event.Details == nil {
+			event.Details = domain.AuditDetails{}
+		}
+		event.PrevHash = prevHash
+		event.Hash = event.ComputeHash()
+
+		err = tx.QueryRow(ctx, query,
+			event.OccurredAt,
+			event.Action,
+			event.Outcome,
+			event.ActorID,
+			event.ImpersonatorID,
+			event.TargetUserID,
+			event.IP,
+			event.UserAgent,
+			event.Details,
+			event.PrevHash,
+			event.Hash,
+		).Scan(&event.ID)
+		if err != nil {
+			return err
+		}
+		prevHash = event.Hash
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Query returns the events matching the filter, newest first
+func (r *auditRepo) Query(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.From != nil {
+		add("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("occurred_at < $%d", *filter.To)
+	}
+	if filter.ActorID != 0 {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		add("target_user_id = $%d", filter.TargetID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.BeforeID != 0 {
+		add("id < $%d", filter.BeforeID)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`SELECT `+auditColumns+` FROM audit_events WHERE %s ORDER BY id DESC LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	return queryAuditEvents(ctx, query, args...)
+}
+
+// ListAfter returns up to limit events following afterID in chain order
+func (r *auditRepo) ListAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditEvent, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2`
+	return queryAuditEvents(ctx, query, afterID, limit)
+}
+
+// queryAuditEvents runs a query selecting auditColumns
+func queryAuditEvents(ctx context.Context, query string, args ...interface{}) ([]*domain.AuditEvent, error) {
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.AuditEvent{}
+	for rows.Next() {
+		event := &domain.AuditEvent{}
+		err := rows.Scan(
+			&event.ID,
+			&event.OccurredAt,
+			&event.Action,
+			&event.Outcome,
+			&event.ActorID,
+			&event.ImpersonatorID,
+			&event.TargetUserID,
+			&event.IP,
+			&event.UserAgent,
+			&event.Details,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
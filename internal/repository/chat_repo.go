@@ -2,18 +2,41 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"go-auth-app/db"
 	"go-auth-app/internal/domain"
 	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // ChatRepository defines the interface for chat-related operations
 type ChatRepository interface {
 	SaveMessage(ctx context.Context, message *domain.Message) error
 	GetMessagesByConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]*domain.Message, error)
+	GetMessagesByConversationID(ctx context.Context, conversationID, userID int, limit, offset int) ([]*domain.Message, error)
 	GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error)
+	GetConversation(ctx context.Context, id int) (*domain.Conversation, error)
 	GetConversationsByUserID(ctx context.Context, userID int) ([]*domain.Conversation, error)
 	UpdateConversation(ctx context.Context, conversationID int, lastMessage string) error
+	GetMessagesByUserID(ctx context.Context, userID int) ([]*domain.Message, error)
+	CreateGroup(ctx context.Context, conversation *domain.Conversation, memberIDs []int) error
+	RenameConversation(ctx context.Context, id int, name string) error
+	DeleteConversation(ctx context.Context, id int) error
+	GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error)
+	ListMembers(ctx context.Context, conversationID int) ([]*domain.ConversationMember, error)
+	AddMembers(ctx context.Context, conversationID int, userIDs []int) ([]*domain.ConversationMember, error)
+	RemoveMember(ctx context.Context, conversationID, userID int) (bool, error)
+	SetMemberRole(ctx context.Context, conversationID, userID int, role string) (bool, error)
+	TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID int) error
+	GetMessage(ctx context.Context, id int) (*domain.Message, error)
+	GetLatestMessageID(ctx context.Context, conversationID int) (int, error)
+	MarkRead(ctx context.Context, conversationID, userID, messageID int) (bool, error)
+	EditMessage(ctx context.Context, id int, content string, editedAt time.Time) (*domain.Message, error)
+	DeleteMessage(ctx context.Context, id int, deletedAt time.Time) (*domain.Message, error)
+	HideMessage(ctx context.Context, id, userID int, hiddenAt time.Time) error
+	GetMessageRevisions(ctx context.Context, messageID int) ([]*domain.MessageRevision, error)
+	GetContactIDs(ctx context.Context, userID int) ([]int, error)
 }
 
 // chatRepo implements ChatRepository
@@ -24,11 +47,113 @@ func NewChatRepository() ChatRepository {
 	return &chatRepo{}
 }
 
+const conversationColumns = `c.id, c.type, c.name, COALESCE(c.user1_id, 0), COALESCE(c.user2_id, 0), COALESCE(c.created_by, 0), COALESCE(c.last_message, ''), c.updated_at`
+
+const messageColumns = `id, COALESCE(conversation_id, 0), sender_id, COALESCE(receiver_id, 0), content, created_at, edited_at, deleted_at`
+
+// refreshLastMessage sets the preview of a conversation to its latest message that was not deleted
+const refreshLastMessage = `
+	UPDATE conversations c
+	SET last_message = COALESCE((
+		SELECT m.content FROM messages m
+		WHERE m.conversation_id = c.id AND m.deleted_at IS NULL
+		ORDER BY m.id DESC
+		LIMIT 1
+	), '')
+	WHERE c.id = $1
+`
+
+// notHiddenFrom filters out the messages a user deleted for themselves, given
+// the placeholder of their ID
+func notHiddenFrom(userID string) string {
+	return `NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = messages.id AND d.user_id = ` + userID + `)`
+}
+
+// scanConversation reads a row selected with conversationColumns
+func scanConversation(row pgx.Row) (*domain.Conversation, error) {
+	conversation := &domain.Conversation{}
+	err := row.Scan(
+		&conversation.ID,
+		&conversation.Type,
+		&conversation.Name,
+		&conversation.User1ID,
+		&conversation.User2ID,
+		&conversation.CreatedBy,
+		&conversation.LastMessage,
+		&conversation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// scanMessage reads a row selected with messageColumns
+func scanMessage(row pgx.Row) (*domain.Message, error) {
+	msg := &domain.Message{}
+	err := row.Scan(
+		&msg.ID,
+		&msg.ConversationID,
+		&msg.SenderID,
+		&msg.ReceiverID,
+		&msg.Content,
+		&msg.CreatedAt,
+		&msg.EditedAt,
+		&msg.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// scanMessages reads the rows of a query selecting messageColumns
+func scanMessages(rows pgx.Rows) ([]*domain.Message, error) {
+	defer rows.Close()
+
+	messages := []*domain.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+const memberColumns = `conversation_id, user_id, role, joined_at, last_read_message_id`
+
+// scanMembers reads the rows of a query selecting memberColumns
+func scanMembers(rows pgx.Rows) ([]*domain.ConversationMember, error) {
+	defer rows.Close()
+
+	members := []*domain.ConversationMember{}
+	for rows.Next() {
+		member := &domain.ConversationMember{}
+		if err := rows.Scan(&member.ConversationID, &member.UserID, &member.Role, &member.JoinedAt, &member.LastReadMessageID); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
 // SaveMessage stores a new message in the database
 func (r *chatRepo) SaveMessage(ctx context.Context, message *domain.Message) error {
 	query := `
-		INSERT INTO messages (sender_id, receiver_id, content, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO messages (conversation_id, sender_id, receiver_id, content, created_at)
+		VALUES (NULLIF($1, 0), $2, NULLIF($3, 0), $4, $5)
 		RETURNING id
 	`
 
@@ -36,6 +161,7 @@ func (r *chatRepo) SaveMessage(ctx context.Context, message *domain.Message) err
 	err := db.DB.QueryRow(
 		ctx,
 		query,
+		message.ConversationID,
 		message.SenderID,
 		message.ReceiverID,
 		message.Content,
@@ -51,12 +177,14 @@ func (r *chatRepo) SaveMessage(ctx context.Context, message *domain.Message) err
 	return nil
 }
 
-// GetMessagesByConversation retrieves messages between two users with pagination
+// GetMessagesByConversation retrieves messages between two users with
+// pagination, but those user1ID deleted for themselves
 func (r *chatRepo) GetMessagesByConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]*domain.Message, error) {
 	query := `
-		SELECT id, sender_id, receiver_id, content, created_at
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)
+		WHERE ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1))
+			AND ` + notHiddenFrom("$1") + `
 		ORDER BY created_at DESC
 		LIMIT $3 OFFSET $4
 	`
@@ -65,87 +193,107 @@ func (r *chatRepo) GetMessagesByConversation(ctx context.Context, user1ID, user2
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	messages := []*domain.Message{}
-	for rows.Next() {
-		msg := &domain.Message{}
-		err := rows.Scan(
-			&msg.ID,
-			&msg.SenderID,
-			&msg.ReceiverID,
-			&msg.Content,
-			&msg.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		messages = append(messages, msg)
-	}
+	return scanMessages(rows)
+}
 
-	if err = rows.Err(); err != nil {
+// GetMessagesByConversationID retrieves the messages of a conversation as the
+// user sees them, newest first
+func (r *chatRepo) GetMessagesByConversationID(ctx context.Context, conversationID, userID int, limit, offset int) ([]*domain.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = $1 AND ` + notHiddenFrom("$2") + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID, userID, limit, offset)
+	if err != nil {
 		return nil, err
 	}
 
-	return messages, nil
+	return scanMessages(rows)
 }
 
-// GetOrCreateConversation gets an existing conversation or creates a new one
+// GetOrCreateConversation gets the direct conversation of two users or creates it
 func (r *chatRepo) GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error) {
 	// First try to get existing conversation
 	query := `
-		SELECT id, user1_id, user2_id, last_message, updated_at
-		FROM conversations
-		WHERE (user1_id = $1 AND user2_id = $2) OR (user1_id = $2 AND user2_id = $1)
+		SELECT ` + conversationColumns + `
+		FROM conversations c
+		WHERE c.type = 'direct' AND ((c.user1_id = $1 AND c.user2_id = $2) OR (c.user1_id = $2 AND c.user2_id = $1))
 	`
 
-	conversation := &domain.Conversation{}
-	err := db.DB.QueryRow(ctx, query, user1ID, user2ID).Scan(
-		&conversation.ID,
-		&conversation.User1ID,
-		&conversation.User2ID,
-		&conversation.LastMessage,
-		&conversation.UpdatedAt,
-	)
+	conversation, err := scanConversation(db.DB.QueryRow(ctx, query, user1ID, user2ID))
+	if err == nil {
+		return conversation, nil
+	}
 
+	// If not found, create a new conversation with both users as members
+	tx, err := db.DB.Begin(ctx)
 	if err != nil {
-		// If not found, create a new conversation
-		createQuery := `
-			INSERT INTO conversations (user1_id, user2_id, last_message, updated_at)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id
-		`
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
-		now := time.Now()
-		err = db.DB.QueryRow(
-			ctx,
-			createQuery,
-			user1ID,
-			user2ID,
-			"",
-			now,
-		).Scan(&conversation.ID)
+	now := time.Now()
+	conversation = &domain.Conversation{
+		Type:      domain.ConversationTypeDirect,
+		User1ID:   user1ID,
+		User2ID:   user2ID,
+		UpdatedAt: now,
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	createQuery := `
+		INSERT INTO conversations (type, user1_id, user2_id, last_message, updated_at)
+		VALUES ($1, $2, $3, '', $4)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, createQuery, conversation.Type, user1ID, user2ID, now).Scan(&conversation.ID); err != nil {
+		return nil, err
+	}
 
-		conversation.User1ID = user1ID
-		conversation.User2ID = user2ID
-		conversation.LastMessage = ""
-		conversation.UpdatedAt = now
+	membersQuery := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at)
+		SELECT $1::int, unnest($2::int[]), $3::varchar, $4::timestamp
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, membersQuery, conversation.ID, []int{user1ID, user2ID}, domain.ConversationRoleMember, now); err != nil {
+		return nil, err
+	}
+
+	return conversation, tx.Commit(ctx)
+}
+
+// GetConversation returns a conversation, or nil if it does not exist
+func (r *chatRepo) GetConversation(ctx context.Context, id int) (*domain.Conversation, error) {
+	query := `SELECT ` + conversationColumns + ` FROM conversations c WHERE c.id = $1`
+
+	conversation, err := scanConversation(db.DB.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return conversation, nil
 }
 
-// GetConversationsByUserID retrieves all conversations for a user
+// GetConversationsByUserID retrieves all conversations a user is a member of,
+// with how far they have read and how many messages from others they have not
 func (r *chatRepo) GetConversationsByUserID(ctx context.Context, userID int) ([]*domain.Conversation, error) {
 	query := `
-		SELECT id, user1_id, user2_id, last_message, updated_at
-		FROM conversations
-		WHERE user1_id = $1 OR user2_id = $1
-		ORDER BY updated_at DESC
+		SELECT ` + conversationColumns + `, m.last_read_message_id, (
+			SELECT COUNT(*) FROM messages msg
+			WHERE msg.conversation_id = c.id AND msg.id > m.last_read_message_id AND msg.sender_id <> m.user_id
+				AND msg.deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = msg.id AND d.user_id = m.user_id)
+		)
+		FROM conversations c
+		JOIN conversation_members m ON m.conversation_id = c.id AND m.user_id = $1
+		ORDER BY c.updated_at DESC
 	`
 
 	rows, err := db.DB.Query(ctx, query, userID)
@@ -159,10 +307,15 @@ func (r *chatRepo) GetConversationsByUserID(ctx context.Context, userID int) ([]
 		conv := &domain.Conversation{}
 		err := rows.Scan(
 			&conv.ID,
+			&conv.Type,
+			&conv.Name,
 			&conv.User1ID,
 			&conv.User2ID,
+			&conv.CreatedBy,
 			&conv.LastMessage,
 			&conv.UpdatedAt,
+			&conv.LastReadMessageID,
+			&conv.UnreadCount,
 		)
 		if err != nil {
 			return nil, err
@@ -188,3 +341,330 @@ func (r *chatRepo) UpdateConversation(ctx context.Context, conversationID int, l
 	_, err := db.DB.Exec(ctx, query, lastMessage, time.Now(), conversationID)
 	return err
 }
+
+// GetMessagesByUserID retrieves every message a user sent or received, oldest first
+func (r *chatRepo) GetMessagesByUserID(ctx context.Context, userID int) ([]*domain.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE sender_id = $1 OR receiver_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMessages(rows)
+}
+
+// CreateGroup stores a group conversation with its creator as owner and the
+// other users as members
+func (r *chatRepo) CreateGroup(ctx context.Context, conversation *domain.Conversation, memberIDs []int) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	query := `
+		INSERT INTO conversations (type, name, created_by, last_message, updated_at)
+		VALUES ($1, $2, $3, '', $4)
+		RETURNING id
+	`
+	if err := tx.QueryRow(ctx, query, domain.ConversationTypeGroup, conversation.Name, conversation.CreatedBy, now).Scan(&conversation.ID); err != nil {
+		return err
+	}
+
+	membersQuery := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at)
+		SELECT $1::int, unnest($2::int[]), $3::varchar, $4::timestamp
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, membersQuery, conversation.ID, []int{conversation.CreatedBy}, domain.ConversationRoleOwner, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, membersQuery, conversation.ID, memberIDs, domain.ConversationRoleMember, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	conversation.Type = domain.ConversationTypeGroup
+	conversation.UpdatedAt = now
+	return nil
+}
+
+// RenameConversation changes the name of a group
+func (r *chatRepo) RenameConversation(ctx context.Context, id int, name string) error {
+	_, err := db.DB.Exec(ctx, `UPDATE conversations SET name = $1 WHERE id = $2`, name, id)
+	return err
+}
+
+// DeleteConversation removes a conversation with its members and messages
+func (r *chatRepo) DeleteConversation(ctx context.Context, id int) error {
+	_, err := db.DB.Exec(ctx, `DELETE FROM conversations WHERE id = $1`, id)
+	return err
+}
+
+// GetMember returns a user's membership of a conversation, or nil if they are not a member
+func (r *chatRepo) GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM conversation_members
+		WHERE conversation_id = $1 AND user_id = $2
+	`
+
+	member := &domain.ConversationMember{}
+	err := db.DB.QueryRow(ctx, query, conversationID, userID).Scan(&member.ConversationID, &member.UserID, &member.Role, &member.JoinedAt, &member.LastReadMessageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// ListMembers returns the members of a conversation, longest-standing first
+func (r *chatRepo) ListMembers(ctx context.Context, conversationID int) ([]*domain.ConversationMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM conversation_members
+		WHERE conversation_id = $1
+		ORDER BY joined_at, user_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMembers(rows)
+}
+
+// AddMembers adds users to a conversation as members and returns the ones
+// that were not members already. New members start at the latest message,
+// so earlier history does not count as unread for them.
+func (r *chatRepo) AddMembers(ctx context.Context, conversationID int, userIDs []int) ([]*domain.ConversationMember, error) {
+	query := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at, last_read_message_id)
+		SELECT $1::int, unnest($2::int[]), $3::varchar, $4::timestamp,
+			COALESCE((SELECT MAX(id) FROM messages WHERE conversation_id = $1), 0)
+		ON CONFLICT DO NOTHING
+		RETURNING ` + memberColumns + `
+	`
+
+	rows, err := db.DB.Query(ctx, query, conversationID, userIDs, domain.ConversationRoleMember, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return scanMembers(rows)
+}
+
+// RemoveMember takes a user out of a conversation. It reports false if they were not a member.
+func (r *chatRepo) RemoveMember(ctx context.Context, conversationID, userID int) (bool, error) {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2`, conversationID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetMemberRole changes a member's role. It reports false if the user is not a member.
+func (r *chatRepo) SetMemberRole(ctx context.Context, conversationID, userID int, role string) (bool, error) {
+	tag, err := db.DB.Exec(ctx, `UPDATE conversation_members SET role = $3 WHERE conversation_id = $1 AND user_id = $2`, conversationID, userID, role)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TransferOwnership makes a member the owner and the previous owner an admin
+func (r *chatRepo) TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID int) error {
+	query := `
+		UPDATE conversation_members
+		SET role = CASE WHEN user_id = $3 THEN $4 ELSE $5 END
+		WHERE conversation_id = $1 AND user_id IN ($2, $3)
+	`
+
+	_, err := db.DB.Exec(ctx, query, conversationID, fromUserID, toUserID, domain.ConversationRoleOwner, domain.ConversationRoleAdmin)
+	return err
+}
+
+// GetMessage returns a message, or nil if it does not exist
+func (r *chatRepo) GetMessage(ctx context.Context, id int) (*domain.Message, error) {
+	message, err := scanMessage(db.DB.QueryRow(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetLatestMessageID returns the ID of the newest message of a conversation, 0 if it has none
+func (r *chatRepo) GetLatestMessageID(ctx context.Context, conversationID int) (int, error) {
+	var id int
+	err := db.DB.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1`, conversationID).Scan(&id)
+	return id, err
+}
+
+// MarkRead moves a member's read cursor forward to a message. It reports false
+// if the member had already read that far.
+func (r *chatRepo) MarkRead(ctx context.Context, conversationID, userID, messageID int) (bool, error) {
+	query := `
+		UPDATE conversation_members
+		SET last_read_message_id = $3
+		WHERE conversation_id = $1 AND user_id = $2 AND last_read_message_id < $3
+	`
+
+	tag, err := db.DB.Exec(ctx, query, conversationID, userID, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetContactIDs returns the users who share a conversation with the user
+func (r *chatRepo) GetContactIDs(ctx context.Context, userID int) ([]int, error) {
+	query := `
+		SELECT DISTINCT o.user_id
+		FROM conversation_members m
+		JOIN conversation_members o ON o.conversation_id = m.conversation_id AND o.user_id <> m.user_id
+		WHERE m.user_id = $1
+		ORDER BY o.user_id
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// EditMessage replaces the content of a message that was not deleted and
+// keeps the previous version as a revision. It returns nil if there is no
+// such message.
+func (r *chatRepo) EditMessage(ctx context.Context, id int, content string, editedAt time.Time) (*domain.Message, error) {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the row keeps concurrent edits from losing a revision
+	var previous string
+	var writtenAt time.Time
+	query := `SELECT content, COALESCE(edited_at, created_at) FROM messages WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(ctx, query, id).Scan(&previous, &writtenAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO message_revisions (message_id, content, written_at, replaced_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, id, previous, writtenAt, editedAt); err != nil {
+		return nil, err
+	}
+
+	message, err := scanMessage(tx.QueryRow(ctx, `UPDATE messages SET content = $2, edited_at = $3 WHERE id = $1 RETURNING `+messageColumns, id, content, editedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, refreshLastMessage, message.ConversationID); err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit(ctx)
+}
+
+// DeleteMessage turns a message into a tombstone for everyone. Its content
+// and revisions are dropped and the conversation preview falls back to the
+// message before it. It returns nil if the message was already deleted.
+func (r *chatRepo) DeleteMessage(ctx context.Context, id int, deletedAt time.Time) (*domain.Message, error) {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE messages SET content = '', deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING ` + messageColumns
+	message, err := scanMessage(tx.QueryRow(ctx, query, id, deletedAt))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM message_revisions WHERE message_id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, refreshLastMessage, message.ConversationID); err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit(ctx)
+}
+
+// HideMessage deletes a message for one user only
+func (r *chatRepo) HideMessage(ctx context.Context, id, userID int, hiddenAt time.Time) error {
+	query := `
+		INSERT INTO message_deletions (message_id, user_id, deleted_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id, user_id) DO NOTHING
+	`
+	_, err := db.DB.Exec(ctx, query, id, userID, hiddenAt)
+	return err
+}
+
+// GetMessageRevisions returns the earlier versions of a message, oldest first
+func (r *chatRepo) GetMessageRevisions(ctx context.Context, messageID int) ([]*domain.MessageRevision, error) {
+	query := `
+		SELECT id, message_id, content, written_at, replaced_at
+		FROM message_revisions
+		WHERE message_id = $1
+		ORDER BY id
+	`
+
+	rows, err := db.DB.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*domain.MessageRevision{}
+	for rows.Next() {
+		revision := &domain.MessageRevision{}
+		if err := rows.Scan(&revision.ID, &revision.MessageID, &revision.Content, &revision.WrittenAt, &revision.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
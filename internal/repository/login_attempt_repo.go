@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoginAttemptRepository defines the interface for failed login counters.
+// Counters live in Postgres so every app instance sees the same state.
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, scope, key string) (*domain.LoginAttempt, error)
+	RecordFailure(ctx context.Context, scope, key string, windowStart time.Time) (*domain.LoginAttempt, error)
+	Lock(ctx context.Context, scope, key string, until time.Time) error
+	Reset(ctx context.Context, scope, key string) error
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// loginAttemptRepo implements LoginAttemptRepository
+type loginAttemptRepo struct{}
+
+// NewLoginAttemptRepository creates a new instance of loginAttemptRepo
+func NewLoginAttemptRepository() LoginAttemptRepository {
+	return &loginAttemptRepo{}
+}
+
+// Get returns the counter for an account or IP, or nil when there were no recent failures
+func (r *loginAttemptRepo) Get(ctx context.Context, scope, key string) (*domain.LoginAttempt, error) {
+	query := `
+		SELECT scope, key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE scope = $1 AND key = $2
+	`
+
+	attempt := &domain.LoginAttempt{}
+	err := db.DB.QueryRow(ctx, query, scope, key).Scan(
+		&attempt.Scope,
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// RecordFailure atomically increments the counter. Failures older than
+// windowStart are forgotten, so the count restarts at one.
+func (r *loginAttemptRepo) RecordFailure(ctx context.Context, scope, key string, windowStart time.Time) (*domain.LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts (scope, key, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET failures = CASE WHEN login_attempts.last_failure_at < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING scope, key, failures, last_failure_at, locked_until
+	`
+
+	attempt := &domain.LoginAttempt{}
+	err := db.DB.QueryRow(ctx, query, scope, key, time.Now(), windowStart).Scan(
+		&attempt.Scope,
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// Lock blocks further attempts until the given time
+func (r *loginAttemptRepo) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $1
+		WHERE scope = $2 AND key = $3
+	`
+
+	_, err := db.DB.Exec(ctx, query, until, scope, key)
+	return err
+}
+
+// Reset clears the counter and any lock
+func (r *loginAttemptRepo) Reset(ctx context.Context, scope, key string) error {
+	_, err := db.DB.Exec(ctx, `DELETE FROM login_attempts WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
+
+// DeleteStale removes counters without recent failures whose lock has expired
+func (r *loginAttemptRepo) DeleteStale(ctx context.Context, before time.Time) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)
+	`
+
+	_, err := db.DB.Exec(ctx, query, before, time.Now())
+	return err
+}
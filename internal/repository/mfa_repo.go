@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MFARepository defines the interface for TOTP enrollment and recovery code operations
+type MFARepository interface {
+	GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error)
+	SavePending(ctx context.Context, userID int, secret string) error
+	Enable(ctx context.Context, userID int, step int64) error
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	RecordFailedAttempt(ctx context.Context, userID, maxAttempts int, lockUntil time.Time) (int, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+}
+
+// mfaRepo implements MFARepository
+type mfaRepo struct{}
+
+// NewMFARepository creates a new instance of mfaRepo
+func NewMFARepository() MFARepository {
+	return &mfaRepo{}
+}
+
+// GetByUserID returns the user's TOTP enrollment, or nil when the user never enrolled
+func (r *mfaRepo) GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error) {
+	query := `
+		SELECT user_id, totp_secret, enabled_at, last_used_step, failed_attempts, locked_until, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	mfa := &domain.UserMFA{}
+	err := db.DB.QueryRow(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.TOTPSecret,
+		&mfa.EnabledAt,
+		&mfa.LastUsedStep,
+		&mfa.FailedAttempts,
+		&mfa.LockedUntil,
+		&mfa.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return mfa, nil
+}
+
+// SavePending stores a new unconfirmed secret, replacing any earlier unconfirmed one
+func (r *mfaRepo) SavePending(ctx context.Context, userID int, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, totp_secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, created_at = EXCLUDED.created_at, last_used_step = 0, failed_attempts = 0, locked_until = NULL
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	_, err := db.DB.Exec(ctx, query, userID, secret, time.Now())
+	return err
+}
+
+// Enable confirms the enrollment and records the step of the confirming code
+func (r *mfaRepo) Enable(ctx context.Context, userID int, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET enabled_at = $1, last_used_step = $2, failed_attempts = 0, locked_until = NULL
+		WHERE user_id = $3
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), step, userID)
+	return err
+}
+
+// UseStep records a successfully used time step. It reports false when the
+// step (or a later one) was already used, which rejects replayed codes.
+func (r *mfaRepo) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $1, failed_attempts = 0, locked_until = NULL
+		WHERE user_id = $2 AND last_used_step < $1
+	`
+
+	tag, err := db.DB.Exec(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// RecordFailedAttempt increments and returns the number of consecutive failed
+// codes. From maxAttempts on, every failure locks codes out until lockUntil.
+func (r *mfaRepo) RecordFailedAttempt(ctx context.Context, userID, maxAttempts int, lockUntil time.Time) (int, error) {
+	query := `
+		UPDATE user_mfa
+		SET failed_attempts = failed_attempts + 1,
+			locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE user_id = $1
+		RETURNING failed_attempts
+	`
+
+	var attempts int
+	err := db.DB.QueryRow(ctx, query, userID, maxAttempts, lockUntil).Scan(&attempts)
+	return attempts, err
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores a new set
+func (r *mfaRepo) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseRecoveryCode consumes a recovery code, reporting false if it is unknown or already used
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
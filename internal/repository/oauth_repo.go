@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OAuthRepository defines the interface for the storage of the OAuth provider
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *domain.OAuthClient) error
+	GetClient(ctx context.Context, id string) (*domain.OAuthClient, error)
+	ListClients(ctx context.Context) ([]*domain.OAuthClient, error)
+	DeleteClient(ctx context.Context, id string) (bool, error)
+	GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error
+	ListConsents(ctx context.Context, userID int) ([]*domain.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID int, clientID string) (bool, error)
+	CreateCode(ctx context.Context, code *domain.AuthorizationCode) error
+	ConsumeCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error)
+	DeleteExpiredCodes(ctx context.Context, before time.Time) error
+}
+
+// oauthRepo implements OAuthRepository
+type oauthRepo struct{}
+
+// NewOAuthRepository creates a new instance of oauthRepo
+func NewOAuthRepository() OAuthRepository {
+	return &oauthRepo{}
+}
+
+// CreateClient registers a client
+func (r *oauthRepo) CreateClient(ctx context.Context, client *domain.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (id, name, secret_hash, public, redirect_uris)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING created_at
+	`
+
+	return db.DB.QueryRow(ctx, query, client.ID, client.Name, client.SecretHash, client.Public, client.RedirectURIs).Scan(&client.CreatedAt)
+}
+
+// GetClient looks up a client, or returns nil when there is none
+func (r *oauthRepo) GetClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	query := `SELECT id, name, COALESCE(secret_hash, ''), public, redirect_uris, created_at FROM oauth_clients WHERE id = $1`
+
+	client, err := scanOAuthClient(db.DB.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return client, err
+}
+
+// ListClients returns every registered client, oldest first
+func (r *oauthRepo) ListClients(ctx context.Context) ([]*domain.OAuthClient, error) {
+	query := `SELECT id, name, COALESCE(secret_hash, ''), public, redirect_uris, created_at FROM oauth_clients ORDER BY created_at, id`
+
+	rows, err := db.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*domain.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// DeleteClient removes a client with its consents and pending codes
+func (r *oauthRepo) DeleteClient(ctx context.Context, id string) (bool, error) {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetConsent returns what a user allowed a client, or nil when they were never asked
+func (r *oauthRepo) GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error) {
+	query := `
+		SELECT oc.user_id, oc.client_id, c.name, oc.scopes, oc.granted_at
+		FROM oauth_consents oc
+		JOIN oauth_clients c ON c.id = oc.client_id
+		WHERE oc.user_id = $1 AND oc.client_id = $2
+	`
+
+	consent, err := scanOAuthConsent(db.DB.QueryRow(ctx, query, userID, clientID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return consent, err
+}
+
+// SaveConsent records the scopes a user allowed, adding to what they allowed before
+func (r *oauthRepo) SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes)),
+			granted_at = EXCLUDED.granted_at
+	`
+
+	consent.GrantedAt = time.Now()
+	_, err := db.DB.Exec(ctx, query, consent.UserID, consent.ClientID, consent.Scopes, consent.GrantedAt)
+	return err
+}
+
+// ListConsents returns the clients a user has allowed, most recent first
+func (r *oauthRepo) ListConsents(ctx context.Context, userID int) ([]*domain.OAuthConsent, error) {
+	query := `
+		SELECT oc.user_id, oc.client_id, c.name, oc.scopes, oc.granted_at
+		FROM oauth_consents oc
+		JOIN oauth_clients c ON c.id = oc.client_id
+		WHERE oc.user_id = $1
+		ORDER BY oc.granted_at DESC
+	`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []*domain.OAuthConsent{}
+	for rows.Next() {
+		consent, err := scanOAuthConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
+
+// DeleteConsent withdraws what a user allowed a client
+func (r *oauthRepo) DeleteConsent(ctx context.Context, userID int, clientID string) (bool, error) {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// CreateCode stores an authorization code
+func (r *oauthRepo) CreateCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := db.DB.Exec(ctx, query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scopes,
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.ExpiresAt,
+	)
+	return err
+}
+
+// ConsumeCode removes and returns an authorization code, so each one is
+// redeemed at most once. It returns nil when there is none.
+func (r *oauthRepo) ConsumeCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	query := `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, auth_time, expires_at
+	`
+
+	var code domain.AuthorizationCode
+	err := db.DB.QueryRow(ctx, query, codeHash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scopes,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.AuthTime,
+		&code.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// DeleteExpiredCodes removes codes that were never redeemed
+func (r *oauthRepo) DeleteExpiredCodes(ctx context.Context, before time.Time) error {
+	_, err := db.DB.Exec(ctx, `DELETE FROM oauth_authorization_codes WHERE expires_at < $1`, before)
+	return err
+}
+
+// scanOAuthClient reads a client row
+func scanOAuthClient(row pgx.Row) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	err := row.Scan(&client.ID, &client.Name, &client.SecretHash, &client.Public, &client.RedirectURIs, &client.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// scanOAuthConsent reads a consent row joined with the client name
+func scanOAuthConsent(row pgx.Row) (*domain.OAuthConsent, error) {
+	var consent domain.OAuthConsent
+	err := row.Scan(&consent.UserID, &consent.ClientID, &consent.ClientName, &consent.Scopes, &consent.GrantedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
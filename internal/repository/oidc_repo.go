@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OIDCRepository defines the interface for external identity provider logins
+type OIDCRepository interface {
+	CreateState(ctx context.Context, state *domain.OIDCLoginState) error
+	ConsumeState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error)
+	DeleteExpiredStates(ctx context.Context, before time.Time) error
+	GetIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error
+	TouchIdentity(ctx context.Context, id int, email string) error
+}
+
+// oidcRepo implements OIDCRepository
+type oidcRepo struct{}
+
+// NewOIDCRepository creates a new instance of oidcRepo
+func NewOIDCRepository() OIDCRepository {
+	return &oidcRepo{}
+}
+
+// CreateState stores a login that was redirected to an identity provider
+func (r *oidcRepo) CreateState(ctx context.Context, state *domain.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, device_name, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := db.DB.Exec(ctx, query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce, state.DeviceName, state.ExpiresAt)
+	return err
+}
+
+// ConsumeState removes and returns a login state, so each one is used at most
+// once. It returns nil when there is none.
+func (r *oidcRepo) ConsumeState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1
+		RETURNING state_hash, provider, code_verifier, nonce, device_name, expires_at
+	`
+
+	var state domain.OIDCLoginState
+	err := db.DB.QueryRow(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.CodeVerifier,
+		&state.Nonce,
+		&state.DeviceName,
+		&state.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// DeleteExpiredStates removes logins that were abandoned at the identity provider
+func (r *oidcRepo) DeleteExpiredStates(ctx context.Context, before time.Time) error {
+	_, err := db.DB.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < $1`, before)
+	return err
+}
+
+// GetIdentity looks up a linked identity, or returns nil when there is none
+func (r *oidcRepo) GetIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity domain.UserIdentity
+	err := db.DB.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// CreateIdentity links an external identity to a user
+func (r *oidcRepo) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_login_at
+	`
+
+	return db.DB.QueryRow(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt, &identity.LastLoginAt)
+}
+
+// TouchIdentity records a login through a linked identity and the email the provider sent with it
+func (r *oidcRepo) TouchIdentity(ctx context.Context, id int, email string) error {
+	query := `UPDATE user_identities SET email = $1, last_login_at = $2 WHERE id = $3`
+	_, err := db.DB.Exec(ctx, query, email, time.Now(), id)
+	return err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+)
+
+// RevocationRepository defines the interface for access token revocation storage
+type RevocationRepository interface {
+	RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	RevokeAllForUser(ctx context.Context, userID int, before time.Time) error
+	GetRevokedTokens(ctx context.Context) ([]*domain.RevokedToken, error)
+	GetUserRevocations(ctx context.Context, since time.Time) ([]*domain.UserRevocation, error)
+	DeleteExpired(ctx context.Context, userRevocationCutoff time.Time) error
+}
+
+// revocationRepo implements RevocationRepository
+type revocationRepo struct{}
+
+// NewRevocationRepository creates a new instance of revocationRepo
+func NewRevocationRepository() RevocationRepository {
+	return &revocationRepo{}
+}
+
+// RevokeToken records a single revoked access token until it would have expired anyway
+func (r *revocationRepo) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	_, err := db.DB.Exec(ctx, query, jti, userID, expiresAt, time.Now())
+	return err
+}
+
+// RevokeAllForUser invalidates every access token issued to the user before the given time
+func (r *revocationRepo) RevokeAllForUser(ctx context.Context, userID int, before time.Time) error {
+	query := `
+		INSERT INTO user_token_revocations (user_id, revoked_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before
+	`
+
+	_, err := db.DB.Exec(ctx, query, userID, before)
+	return err
+}
+
+// GetRevokedTokens returns all revoked tokens that have not expired yet
+func (r *revocationRepo) GetRevokedTokens(ctx context.Context) ([]*domain.RevokedToken, error) {
+	query := `
+		SELECT jti, user_id, expires_at, revoked_at
+		FROM revoked_tokens
+		WHERE expires_at > $1
+	`
+
+	rows, err := db.DB.Query(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*domain.RevokedToken{}
+	for rows.Next() {
+		token := &domain.RevokedToken{}
+		if err := rows.Scan(&token.JTI, &token.UserID, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// GetUserRevocations returns the per-user revocations recorded after since
+func (r *revocationRepo) GetUserRevocations(ctx context.Context, since time.Time) ([]*domain.UserRevocation, error) {
+	query := `
+		SELECT user_id, revoked_before
+		FROM user_token_revocations
+		WHERE revoked_before > $1
+	`
+
+	rows, err := db.DB.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revocations := []*domain.UserRevocation{}
+	for rows.Next() {
+		revocation := &domain.UserRevocation{}
+		if err := rows.Scan(&revocation.UserID, &revocation.RevokedBefore); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, revocation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+// DeleteExpired removes revocations that can no longer match a valid token
+func (r *revocationRepo) DeleteExpired(ctx context.Context, userRevocationCutoff time.Time) error {
+	if _, err := db.DB.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= $1`, time.Now()); err != nil {
+		return err
+	}
+
+	_, err := db.DB.Exec(ctx, `DELETE FROM user_token_revocations WHERE revoked_before <= $1`, userRevocationCutoff)
+	return err
+}
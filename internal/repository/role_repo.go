@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+)
+
+// RoleRepository defines the interface for role and permission storage
+type RoleRepository interface {
+	GetUserAccess(ctx context.Context, userID int) (*domain.UserAccess, error)
+	AssignRole(ctx context.Context, userID int, role string) error
+	RemoveRole(ctx context.Context, userID int, role string) error
+	GetRoles(ctx context.Context) ([]*domain.Role, error)
+}
+
+// roleRepo implements RoleRepository
+type roleRepo struct{}
+
+// NewRoleRepository creates a new instance of roleRepo
+func NewRoleRepository() RoleRepository {
+	return &roleRepo{}
+}
+
+// GetUserAccess returns the user's role names and the union of their permissions
+func (r *roleRepo) GetUserAccess(ctx context.Context, userID int) (*domain.UserAccess, error) {
+	access := &domain.UserAccess{Roles: []string{}, Permissions: []string{}}
+
+	rows, err := db.DB.Query(ctx, `
+		SELECT r.name
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		access.Roles = append(access.Roles, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	permissionRows, err := db.DB.Query(ctx, `
+		SELECT DISTINCT rp.permission
+		FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY rp.permission
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer permissionRows.Close()
+
+	for permissionRows.Next() {
+		var permission string
+		if err := permissionRows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		access.Permissions = append(access.Permissions, permission)
+	}
+
+	return access, permissionRows.Err()
+}
+
+// AssignRole grants a role by name, returning ErrUnknownRole when it does not exist
+func (r *roleRepo) AssignRole(ctx context.Context, userID int, role string) error {
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+
+	tag, err := db.DB.Exec(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		// Either the role is unknown or the user already has it
+		var exists bool
+		if err := db.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return domain.ErrUnknownRole
+		}
+	}
+
+	return nil
+}
+
+// RemoveRole revokes a role by name
+func (r *roleRepo) RemoveRole(ctx context.Context, userID int, role string) error {
+	query := `
+		DELETE FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+	`
+
+	_, err := db.DB.Exec(ctx, query, userID, role)
+	return err
+}
+
+// GetRoles lists every role with its permissions
+func (r *roleRepo) GetRoles(ctx context.Context) ([]*domain.Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		GROUP BY r.id
+		ORDER BY r.id
+	`
+
+	rows, err := db.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*domain.Role
+	for rows.Next() {
+		role := &domain.Role{}
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SessionRepository defines the interface for login session storage
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id string) (*domain.Session, error)
+	ListActiveByUser(ctx context.Context, userID int) ([]*domain.Session, error)
+	Touch(ctx context.Context, id, ip string) error
+	Revoke(ctx context.Context, id string, userID int) (bool, error)
+	RevokeAllForUser(ctx context.Context, userID int) error
+	ListRevokedSince(ctx context.Context, since time.Time) ([]*domain.Session, error)
+}
+
+// sessionRepo implements SessionRepository
+type sessionRepo struct{}
+
+// NewSessionRepository creates a new instance of sessionRepo
+func NewSessionRepository() SessionRepository {
+	return &sessionRepo{}
+}
+
+// Create stores a new session
+func (r *sessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device_name, user_agent, ip, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`
+
+	now := time.Now()
+	_, err := db.DB.Exec(ctx, query, session.ID, session.UserID, session.DeviceName, session.UserAgent, session.IP, now)
+	if err != nil {
+		return err
+	}
+
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	return nil
+}
+
+// GetByID looks up a session, or returns nil when there is none
+func (r *sessionRepo) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_name, user_agent, ip, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	session, err := scanSession(db.DB.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return session, err
+}
+
+// ListActiveByUser returns the user's sessions that were not revoked, most recently used first
+func (r *sessionRepo) ListActiveByUser(ctx context.Context, userID int) ([]*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_name, user_agent, ip, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`
+
+	return querySessions(ctx, query, userID)
+}
+
+// Touch records that the session was just used from the given IP
+func (r *sessionRepo) Touch(ctx context.Context, id, ip string) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = $1, ip = $2
+		WHERE id = $3
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), ip, id)
+	return err
+}
+
+// Revoke ends one of the user's sessions, reporting false if it does not exist or was already revoked
+func (r *sessionRepo) Revoke(ctx context.Context, id string, userID int) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevokeAllForUser ends every open session of a user
+func (r *sessionRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), userID)
+	return err
+}
+
+// ListRevokedSince returns the sessions revoked after since
+func (r *sessionRepo) ListRevokedSince(ctx context.Context, since time.Time) ([]*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_name, user_agent, ip, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE revoked_at > $1
+	`
+
+	return querySessions(ctx, query, since)
+}
+
+// querySessions runs a query returning session rows
+func querySessions(ctx context.Context, query string, args ...interface{}) ([]*domain.Session, error) {
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// scanSession reads one sessions row
+func scanSession(row pgx.Row) (*domain.Session, error) {
+	session := &domain.Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+)
+
+// RefreshTokenRepository defines the interface for refresh token operations
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *domain.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	MarkUsed(ctx context.Context, id int) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int) error
+}
+
+// refreshTokenRepo implements RefreshTokenRepository
+type refreshTokenRepo struct{}
+
+// NewRefreshTokenRepository creates a new instance of refreshTokenRepo
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &refreshTokenRepo{}
+}
+
+// Create stores a new refresh token hash
+func (r *refreshTokenRepo) Create(ctx context.Context, token *domain.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := db.DB.QueryRow(
+		ctx,
+		query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+		now,
+	).Scan(&token.ID)
+
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = now
+
+	return nil
+}
+
+// GetByHash looks up a refresh token by the hash of its opaque value
+func (r *refreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	token := &domain.RefreshToken{}
+	err := db.DB.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed flags a token as rotated. It reports false when the token had
+// already been used, which lets concurrent refreshes detect reuse atomically.
+func (r *refreshTokenRepo) MarkUsed(ctx context.Context, id int) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevokeFamily revokes every token that descends from the same login
+func (r *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), familyID)
+	return err
+}
+
+// RevokeAllForUser revokes every outstanding refresh token of a user
+func (r *refreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), userID)
+	return err
+}
@@ -2,15 +2,41 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-auth-app/db"
 	"go-auth-app/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the Postgres error code for a duplicate key
+const uniqueViolation = "23505"
+
+// userColumns are the columns read into a domain.User
+const userColumns = `id, name, email, password, email_verified_at, pending_email, avatar_url, bio, timezone, created_at, deleted_at, disabled_at`
+
 // UserRepository defines the interface for user operations
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetByID(ctx context.Context, id int) (*domain.User, error)
+	UpdatePassword(ctx context.Context, id int, hashedPassword string) error
+	MarkEmailVerified(ctx context.Context, id int) error
+	UpdateProfile(ctx context.Context, user *domain.User) error
+	SetPendingEmail(ctx context.Context, id int, email string) error
+	ApplyPendingEmail(ctx context.Context, id int, email string) error
+	MarkDeleted(ctx context.Context, id int, deletedAt time.Time) error
+	Restore(ctx context.Context, id int) error
+	ListDeletedBefore(ctx context.Context, before time.Time) ([]int, error)
+	Purge(ctx context.Context, id int, deletedBefore time.Time) (bool, error)
+	List(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int, error)
+	SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error
+	TouchLastSeen(ctx context.Context, ids []int, seenAt time.Time) error
+	GetLastSeen(ctx context.Context, ids []int) (map[int]time.Time, error)
 }
 
 // userRepo implements UserRepository
@@ -22,30 +48,326 @@ func NewUserRepository() UserRepository {
 }
 
 func (r *userRepo) Create(ctx context.Context, user *domain.User) error {
-	query := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3)`
-	_, err := db.DB.Exec(ctx, query, user.Name, user.Email, user.Password)
-	return err
+	query := `INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, created_at`
+	return db.DB.QueryRow(ctx, query, user.Name, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt)
 }
 
+// GetByEmail looks up an account by email address regardless of case, or
+// returns nil when there is none
 func (r *userRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE email = $1`
-	row := db.DB.QueryRow(ctx, query, email)
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1) ORDER BY id LIMIT 1`
+	user, err := scanUser(db.DB.QueryRow(ctx, query, email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
 
-	var user domain.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+func (r *userRepo) GetByID(ctx context.Context, id int) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(db.DB.QueryRow(ctx, query, id))
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+	_, err := db.DB.Exec(ctx, query, hashedPassword, id)
+	return err
+}
+
+func (r *userRepo) MarkEmailVerified(ctx context.Context, id int) error {
+	query := `UPDATE users SET email_verified_at = $1 WHERE id = $2 AND email_verified_at IS NULL`
+	_, err := db.DB.Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+// UpdateProfile saves the editable profile fields
+func (r *userRepo) UpdateProfile(ctx context.Context, user *domain.User) error {
+	query := `UPDATE users SET name = $1, avatar_url = $2, bio = $3, timezone = $4 WHERE id = $5`
+	_, err := db.DB.Exec(ctx, query, user.Name, user.AvatarURL, user.Bio, user.Timezone, user.ID)
+	return err
+}
+
+// SetPendingEmail remembers the address an email change is waiting to verify
+func (r *userRepo) SetPendingEmail(ctx context.Context, id int, email string) error {
+	query := `UPDATE users SET pending_email = $1 WHERE id = $2`
+	_, err := db.DB.Exec(ctx, query, email, id)
+	return err
+}
+
+// ApplyPendingEmail switches the account to its verified pending address. It
+// fails with ErrEmailTaken if another account claimed the address meanwhile.
+func (r *userRepo) ApplyPendingEmail(ctx context.Context, id int, email string) error {
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL, email_verified_at = $1
+		WHERE id = $2 AND pending_email = $3
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id, email)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return domain.ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrInvalidUserToken
+	}
+
+	return nil
+}
+
+// MarkDeleted starts the deletion grace period of an account
+func (r *userRepo) MarkDeleted(ctx context.Context, id int, deletedAt time.Time) error {
+	query := `UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	_, err := db.DB.Exec(ctx, query, deletedAt, id)
+	return err
+}
+
+// Restore cancels a pending deletion
+func (r *userRepo) Restore(ctx context.Context, id int) error {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND email <> $2`
+	_, err := db.DB.Exec(ctx, query, id, domain.DeletedUserEmail)
+	return err
+}
+
+// ListDeletedBefore returns the accounts whose grace period started before the given time
+func (r *userRepo) ListDeletedBefore(ctx context.Context, before time.Time) ([]int, error) {
+	query := `SELECT id FROM users WHERE deleted_at < $1 AND email <> $2 ORDER BY deleted_at`
+
+	rows, err := db.DB.Query(ctx, query, before, domain.DeletedUserEmail)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
 
-func (r *userRepo) GetByID(ctx context.Context, id int) (*domain.User, error) {
-	query := `SELECT id, name, email, password, created_at FROM users WHERE id = $1`
-	row := db.DB.QueryRow(ctx, query, id)
+// Purge removes an account for good. Its messages and conversations are handed
+// to the deleted user placeholder so the other participants keep their history,
+// and its groups to another member. Everything else, group memberships included,
+// goes with the users row through ON DELETE CASCADE. It reports false without
+// changes if the account was restored in the meantime.
+func (r *userRepo) Purge(ctx context.Context, id int, deletedBefore time.Time) (bool, error) {
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the row keeps a concurrent login from restoring the account halfway through
+	var userID int
+	err = tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 AND deleted_at < $2 AND email <> $3 FOR UPDATE`, id, deletedBefore, domain.DeletedUserEmail).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var placeholderID int
+	if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE email = $1`, domain.DeletedUserEmail).Scan(&placeholderID); err != nil {
+		return false, err
+	}
+
+	// counterpart is the other participant of a conversation of the purged user
+	const counterpart = `CASE WHEN c.user1_id = $1 THEN c.user2_id ELSE c.user1_id END`
+
+	statements := []string{
+		// Messages only between deleted users would have nobody left to read them
+		`DELETE FROM messages WHERE (sender_id = $1 AND receiver_id IN ($1, $2)) OR (sender_id = $2 AND receiver_id = $1)`,
+		`UPDATE messages SET sender_id = $2 WHERE sender_id = $1`,
+		`UPDATE messages SET receiver_id = $2 WHERE receiver_id = $1`,
+		`DELETE FROM conversations c WHERE (c.user1_id = $1 OR c.user2_id = $1) AND ` + counterpart + ` IN ($1, $2)`,
+		// A participant who already talks to the placeholder keeps one conversation with the latest message
+		`UPDATE conversations e
+		SET last_message = c.last_message, updated_at = c.updated_at
+		FROM conversations c
+		WHERE (c.user1_id = $1 OR c.user2_id = $1)
+			AND ((e.user1_id = $2 AND e.user2_id = ` + counterpart + `) OR (e.user2_id = $2 AND e.user1_id = ` + counterpart + `))
+			AND c.updated_at > e.updated_at`,
+		// Their messages follow it
+		`UPDATE messages m
+		SET conversation_id = e.id
+		FROM conversations c, conversations e
+		WHERE m.conversation_id = c.id
+			AND (c.user1_id = $1 OR c.user2_id = $1)
+			AND ((e.user1_id = $2 AND e.user2_id = ` + counterpart + `) OR (e.user2_id = $2 AND e.user1_id = ` + counterpart + `))`,
+		`DELETE FROM conversations c
+		WHERE (c.user1_id = $1 OR c.user2_id = $1)
+			AND EXISTS (
+				SELECT 1 FROM conversations e
+				WHERE (e.user1_id = $2 AND e.user2_id = ` + counterpart + `) OR (e.user2_id = $2 AND e.user1_id = ` + counterpart + `)
+			)`,
+		`UPDATE conversations SET user1_id = $2 WHERE user1_id = $1`,
+		`UPDATE conversations SET user2_id = $2 WHERE user2_id = $1`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, userID, placeholderID); err != nil {
+			return false, err
+		}
+	}
+
+	groupStatements := []string{
+		// Groups the user owned pass to their longest-standing admin, or member if there is none
+		`UPDATE conversation_members m
+		SET role = 'owner'
+		FROM (
+			SELECT DISTINCT ON (o.conversation_id) o.conversation_id, o.user_id
+			FROM conversation_members o
+			JOIN conversation_members p ON p.conversation_id = o.conversation_id AND p.user_id = $1 AND p.role = 'owner'
+			WHERE o.user_id <> $1
+			ORDER BY o.conversation_id, o.role = 'admin' DESC, o.joined_at, o.user_id
+		) h
+		WHERE m.conversation_id = h.conversation_id AND m.user_id = h.user_id`,
+		// Groups nobody else is left in go with their messages
+		`DELETE FROM conversations c
+		WHERE c.type = 'group'
+			AND EXISTS (SELECT 1 FROM conversation_members m WHERE m.conversation_id = c.id AND m.user_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM conversation_members m WHERE m.conversation_id = c.id AND m.user_id <> $1)`,
+	}
+
+	for _, statement := range groupStatements {
+		if _, err := tx.Exec(ctx, statement, userID); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// List returns one page of the accounts matching the filter, newest first,
+// and the number of matching accounts. The deleted user placeholder is never listed.
+func (r *userRepo) List(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int, error) {
+	conditions := []string{"email <> $1"}
+	args := []interface{}{domain.DeletedUserEmail}
+
+	if filter.Query != "" {
+		// The query is matched literally, LIKE wildcards typed by the admin are escaped
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query) + "%"
+		args = append(args, pattern)
+		conditions = append(conditions, fmt.Sprintf("(email ILIKE $%d OR name ILIKE $%d)", len(args), len(args)))
+	}
+
+	switch filter.Status {
+	case domain.UserStatusActive:
+		conditions = append(conditions, "disabled_at IS NULL AND deleted_at IS NULL")
+	case domain.UserStatusDisabled:
+		conditions = append(conditions, "disabled_at IS NOT NULL")
+	case domain.UserStatusDeleted:
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	}
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM user_roles ur JOIN roles ro ON ro.id = ur.role_id
+			WHERE ur.user_id = users.id AND ro.name = $%d
+		)`, len(args)))
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := db.DB.QueryRow(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT `+userColumns+` FROM users`+where+` ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetDisabled disables an account, or enables it again when disabledAt is nil
+func (r *userRepo) SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error {
+	query := `UPDATE users SET disabled_at = $1 WHERE id = $2`
+	_, err := db.DB.Exec(ctx, query, disabledAt, id)
+	return err
+}
+
+// TouchLastSeen records that the users were connected at seenAt
+func (r *userRepo) TouchLastSeen(ctx context.Context, ids []int, seenAt time.Time) error {
+	query := `UPDATE users SET last_seen_at = $2 WHERE id = ANY($1::int[]) AND (last_seen_at IS NULL OR last_seen_at < $2)`
+	_, err := db.DB.Exec(ctx, query, ids, seenAt)
+	return err
+}
+
+// GetLastSeen returns when the users were last connected. Users who never were are left out.
+func (r *userRepo) GetLastSeen(ctx context.Context, ids []int) (map[int]time.Time, error) {
+	query := `SELECT id, last_seen_at FROM users WHERE id = ANY($1::int[]) AND last_seen_at IS NOT NULL`
+
+	rows, err := db.DB.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lastSeen := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var seenAt time.Time
+		if err := rows.Scan(&id, &seenAt); err != nil {
+			return nil, err
+		}
+		lastSeen[id] = seenAt
+	}
+
+	return lastSeen, rows.Err()
+}
 
+// scanUser reads one users row selected with userColumns
+func scanUser(row pgx.Row) (*domain.User, error) {
 	var user domain.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password,
+		&user.EmailVerifiedAt,
+		&user.PendingEmail,
+		&user.AvatarURL,
+		&user.Bio,
+		&user.Timezone,
+		&user.CreatedAt,
+		&user.DeletedAt,
+		&user.DisabledAt,
+	)
 	if err != nil {
 		return nil, err
 	}
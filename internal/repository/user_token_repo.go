@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+)
+
+// UserTokenRepository defines the interface for single-use user token operations
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *domain.UserToken) error
+	GetByHash(ctx context.Context, tokenHash, purpose string) (*domain.UserToken, error)
+	MarkUsed(ctx context.Context, id int) (bool, error)
+	InvalidateForUser(ctx context.Context, userID int, purpose string) error
+	GetLatestForUser(ctx context.Context, userID int, purpose string) (*domain.UserToken, error)
+}
+
+// userTokenRepo implements UserTokenRepository
+type userTokenRepo struct{}
+
+// NewUserTokenRepository creates a new instance of userTokenRepo
+func NewUserTokenRepository() UserTokenRepository {
+	return &userTokenRepo{}
+}
+
+// Create stores the hash of a new single-use token
+func (r *userTokenRepo) Create(ctx context.Context, token *domain.UserToken) error {
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := db.DB.QueryRow(
+		ctx,
+		query,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.ExpiresAt,
+		now,
+	).Scan(&token.ID)
+
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = now
+
+	return nil
+}
+
+// GetByHash looks up a token by its hash and purpose
+func (r *userTokenRepo) GetByHash(ctx context.Context, tokenHash, purpose string) (*domain.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2
+	`
+
+	token := &domain.UserToken{}
+	err := db.DB.QueryRow(ctx, query, tokenHash, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkUsed consumes a token. It reports false when the token was already used or has expired.
+func (r *userTokenRepo) MarkUsed(ctx context.Context, id int) (bool, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL AND expires_at > $1
+	`
+
+	tag, err := db.DB.Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token of a purpose for a user
+func (r *userTokenRepo) InvalidateForUser(ctx context.Context, userID int, purpose string) error {
+	query := `
+		UPDATE user_tokens
+		SET used_at = $1
+		WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL
+	`
+
+	_, err := db.DB.Exec(ctx, query, time.Now(), userID, purpose)
+	return err
+}
+
+// GetLatestForUser returns the most recently issued token of a purpose for a user
+func (r *userTokenRepo) GetLatestForUser(ctx context.Context, userID int, purpose string) (*domain.UserToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	token := &domain.UserToken{}
+	err := db.DB.QueryRow(ctx, query, userID, purpose).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
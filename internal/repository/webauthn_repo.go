@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/db"
+	"go-auth-app/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WebAuthnRepository defines the interface for passkeys and their ceremonies
+type WebAuthnRepository interface {
+	CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error)
+	DeleteExpiredChallenges(ctx context.Context, before time.Time) error
+	CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error
+	GetCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID int) ([]*domain.WebAuthnCredential, error)
+	UpdateSignCount(ctx context.Context, id int, signCount uint32) error
+	DeleteCredential(ctx context.Context, id, userID int) (bool, error)
+	DeleteCredentialsForUser(ctx context.Context, userID int) (int, error)
+}
+
+// webauthnRepo implements WebAuthnRepository
+type webauthnRepo struct{}
+
+// NewWebAuthnRepository creates a new instance of webauthnRepo
+func NewWebAuthnRepository() WebAuthnRepository {
+	return &webauthnRepo{}
+}
+
+const webauthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, transports, aaguid, name, created_at, last_used_at`
+
+// CreateChallenge stores a challenge handed to the browser
+func (r *webauthnRepo) CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	query := `
+		INSERT INTO webauthn_challenges (challenge_hash, user_id, purpose, expires_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4)
+	`
+
+	_, err := db.DB.Exec(ctx, query, challenge.ChallengeHash, challenge.UserID, challenge.Purpose, challenge.ExpiresAt)
+	return err
+}
+
+// ConsumeChallenge removes and returns a challenge, so each one answers at most
+// one ceremony. It returns nil when there is none.
+func (r *webauthnRepo) ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error) {
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE challenge_hash = $1
+		RETURNING challenge_hash, COALESCE(user_id, 0), purpose, expires_at
+	`
+
+	var challenge domain.WebAuthnChallenge
+	err := db.DB.QueryRow(ctx, query, challengeHash).Scan(
+		&challenge.ChallengeHash,
+		&challenge.UserID,
+		&challenge.Purpose,
+		&challenge.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// DeleteExpiredChallenges removes ceremonies the browser never finished
+func (r *webauthnRepo) DeleteExpiredChallenges(ctx context.Context, before time.Time) error {
+	_, err := db.DB.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < $1`, before)
+	return err
+}
+
+// CreateCredential stores a newly registered passkey
+func (r *webauthnRepo) CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (user_id, credential_id, public_key, sign_count, transports, aaguid, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	return db.DB.QueryRow(ctx, query,
+		credential.UserID,
+		[]byte(credential.CredentialID),
+		credential.PublicKey,
+		int64(credential.SignCount),
+		credential.Transports,
+		credential.AAGUID,
+		credential.Name,
+	).Scan(&credential.ID, &credential.CreatedAt)
+}
+
+// GetCredential looks up a passkey by the ID the authenticator gave it, or returns nil when there is none
+func (r *webauthnRepo) GetCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE credential_id = $1`
+
+	credential, err := scanWebAuthnCredential(db.DB.QueryRow(ctx, query, credentialID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return credential, err
+}
+
+// ListCredentials returns a user's passkeys, oldest first
+func (r *webauthnRepo) ListCredentials(ctx context.Context, userID int) ([]*domain.WebAuthnCredential, error) {
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at, id`
+
+	rows, err := db.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []*domain.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+// UpdateSignCount records a login with a passkey and the counter it reported
+func (r *webauthnRepo) UpdateSignCount(ctx context.Context, id int, signCount uint32) error {
+	query := `UPDATE webauthn_credentials SET sign_count = $1, last_used_at = $2 WHERE id = $3`
+	_, err := db.DB.Exec(ctx, query, int64(signCount), time.Now(), id)
+	return err
+}
+
+// DeleteCredential removes one of the user's passkeys and reports whether it existed
+func (r *webauthnRepo) DeleteCredential(ctx context.Context, id, userID int) (bool, error) {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteCredentialsForUser removes all of the user's passkeys and returns how many there were
+func (r *webauthnRepo) DeleteCredentialsForUser(ctx context.Context, userID int) (int, error) {
+	tag, err := db.DB.Exec(ctx, `DELETE FROM webauthn_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanWebAuthnCredential reads a row selected with webauthnCredentialColumns
+func scanWebAuthnCredential(row pgx.Row) (*domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	var credentialID []byte
+	var signCount int64
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credentialID,
+		&credential.PublicKey,
+		&signCount,
+		&credential.Transports,
+		&credential.AAGUID,
+		&credential.Name,
+		&credential.CreatedAt,
+		&credential.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.CredentialID = credentialID
+	credential.SignCount = uint32(signCount)
+	return &credential, nil
+}
@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
 )
 
 func SetupRoutes(
@@ -15,28 +18,162 @@ func SetupRoutes(
 	// Existing routes remain the same
 	router.POST("/signup", authHandler.SignupHandler)
 	router.POST("/login", authHandler.LoginHandler)
+	router.POST("/token/refresh", authHandler.RefreshHandler)
+	router.GET("/.well-known/jwks.json", authHandler.JWKSHandler)
+	router.POST("/password/forgot", authHandler.ForgotPasswordHandler)
+	router.GET("/password/reset", authHandler.ResetPasswordPageHandler)
+	router.POST("/password/reset", authHandler.ResetPasswordHandler)
+	router.POST("/login/magic", authHandler.MagicLinkHandler)
+	router.GET("/login/magic/verify", authHandler.MagicLinkPageHandler)
+	router.POST("/login/magic/verify", authHandler.VerifyMagicLinkHandler)
+	router.GET("/verify-email", authHandler.VerifyEmailHandler)
+	router.POST("/verify-email", authHandler.VerifyEmailHandler)
+	router.POST("/verify-email/resend", authHandler.ResendVerificationHandler)
+	router.GET("/verify-email/change", authHandler.ConfirmEmailChangeHandler)
+	router.POST("/verify-email/change", authHandler.ConfirmEmailChangeHandler)
+	router.POST("/mfa/verify", authHandler.VerifyMFAHandler)
+	router.GET("/auth/oidc/:provider/login", authHandler.OIDCLoginHandler)
+	router.GET("/auth/oidc/:provider/callback", authHandler.OIDCCallbackHandler)
+
+	// OpenID Connect provider for our other services. Its ID tokens are signed
+	// with an asymmetric key, so it is not served with the shared HS256 secret.
+	if authHandler.AuthUsecase.OAuthProviderAvailable() {
+		router.GET("/.well-known/openid-configuration", authHandler.OpenIDConfigurationHandler)
+		router.GET("/oauth/authorize", authHandler.OAuthAuthorizeHandler)
+		router.POST("/oauth/authorize", authHandler.OAuthAuthorizeSubmitHandler)
+		router.POST("/oauth/token", authHandler.OAuthTokenHandler)
+		router.GET("/oauth/userinfo", authHandler.OAuthUserInfoHandler)
+		router.POST("/oauth/userinfo", authHandler.OAuthUserInfoHandler)
+	} else {
+		log.Println("Warning: OpenID Connect provider disabled, it needs JWT_SIGNING_ALGORITHM set to RS256 or EdDSA")
+	}
+	router.POST("/oauth/introspect", authHandler.OAuthIntrospectHandler)
+
+	authMiddleware := delivery.AuthMiddleware(authHandler.AuthUsecase)
+
+	// Account management needs a signed-in user, API keys are refused
+	jwtOnly := delivery.RequireAuthMethod(domain.AuthMethodJWT)
+
+	router.GET("/protected", authMiddleware, authHandler.ProtectedHandler)
+	router.POST("/logout", authMiddleware, jwtOnly, authHandler.LogoutHandler)
+	router.POST("/logout-all", authMiddleware, jwtOnly, authHandler.LogoutAllHandler)
+	router.POST("/mfa/totp/enroll", authMiddleware, jwtOnly, authHandler.EnrollTOTPHandler)
+	router.POST("/mfa/totp/confirm", authMiddleware, jwtOnly, authHandler.ConfirmTOTPHandler)
+
+	apiKeys := router.Group("/api-keys")
+	apiKeys.Use(authMiddleware, jwtOnly)
+	{
+		apiKeys.POST("", authHandler.CreateAPIKeyHandler)
+		apiKeys.GET("", authHandler.ListAPIKeysHandler)
+		apiKeys.DELETE("/:id", authHandler.RevokeAPIKeyHandler)
+	}
+
+	me := router.Group("/me")
+	me.Use(authMiddleware)
+	{
+		me.GET("", authHandler.GetProfileHandler)
+		me.PATCH("", jwtOnly, authHandler.UpdateProfileHandler)
+		me.POST("/email", jwtOnly, authHandler.ChangeEmailHandler)
+		me.PUT("/password", jwtOnly, authHandler.ChangePasswordHandler)
+		me.DELETE("", jwtOnly, authHandler.DeleteAccountHandler)
+		me.GET("/export", jwtOnly, authHandler.ExportAccountHandler)
+		me.GET("/oauth/consents", jwtOnly, authHandler.ListOAuthConsentsHandler)
+		me.DELETE("/oauth/consents/:client_id", jwtOnly, authHandler.RevokeOAuthConsentHandler)
+	}
+
+	// Passkeys are added by a signed-in user and then sign in on their own
+	webauthn := router.Group("/webauthn")
+	{
+		webauthn.POST("/register/begin", authMiddleware, jwtOnly, authHandler.BeginPasskeyRegistrationHandler)
+		webauthn.POST("/register/finish", authMiddleware, jwtOnly, authHandler.FinishPasskeyRegistrationHandler)
+		webauthn.POST("/login/begin", authHandler.BeginPasskeyLoginHandler)
+		webauthn.POST("/login/finish", authHandler.FinishPasskeyLoginHandler)
+		webauthn.GET("/credentials", authMiddleware, jwtOnly, authHandler.ListPasskeysHandler)
+		webauthn.DELETE("/credentials/:id", authMiddleware, jwtOnly, authHandler.DeletePasskeyHandler)
+	}
+
+	sessions := router.Group("/sessions")
+	sessions.Use(authMiddleware, jwtOnly)
+	{
+		sessions.GET("", authHandler.ListSessionsHandler)
+		sessions.DELETE("/:id", authHandler.RevokeSessionHandler)
+	}
 
 	// Chat routes (existing)
 	chat := router.Group("/chat")
-	chat.Use(delivery.AuthMiddleware())
+	chat.Use(authMiddleware, delivery.RequireScope(domain.ScopeChat))
 	{
 		chat.POST("/messages", chatHandler.SendMessageHandler)
 		chat.GET("/messages/:user_id", chatHandler.GetConversationMessagesHandler)
 		chat.GET("/conversations", chatHandler.GetUserConversationsHandler)
+		chat.GET("/conversations/:id", chatHandler.GetConversationHandler)
+		chat.POST("/conversations/:id/messages", chatHandler.SendConversationMessageHandler)
+		chat.GET("/conversations/:id/messages", chatHandler.GetConversationMessagesByIDHandler)
+		chat.PATCH("/conversations/:id/messages/:message_id", chatHandler.EditMessageHandler)
+		chat.DELETE("/conversations/:id/messages/:message_id", chatHandler.DeleteMessageHandler)
+		chat.GET("/conversations/:id/messages/:message_id/revisions", chatHandler.GetMessageRevisionsHandler)
+		chat.POST("/conversations/:id/read", chatHandler.MarkConversationReadHandler)
+		chat.POST("/groups", chatHandler.CreateGroupHandler)
+		chat.PATCH("/groups/:id", chatHandler.RenameGroupHandler)
+		chat.POST("/groups/:id/leave", chatHandler.LeaveGroupHandler)
+		chat.POST("/groups/:id/members", chatHandler.AddGroupMembersHandler)
+		chat.DELETE("/groups/:id/members/:user_id", chatHandler.RemoveGroupMemberHandler)
+		chat.PUT("/groups/:id/members/:user_id/role", chatHandler.SetGroupMemberRoleHandler)
 		chat.GET("/ws", wsHandler.HandleWebSocket)
 	}
 
-	// NATS and SNMP routes
+	// Presence is shown to chat contacts
+	users := router.Group("/users")
+	users.Use(authMiddleware, delivery.RequireScope(domain.ScopeChat))
+	{
+		users.POST("/presence", chatHandler.GetPresencesHandler)
+		users.GET("/:id/presence", chatHandler.GetPresenceHandler)
+	}
+
+	// NATS and SNMP routes are restricted to operators and admins
 	nats := router.Group("/nats")
+	nats.Use(authMiddleware)
 	{
-		nats.GET("/topics", natsHandler.GetTopicsHandler)
-		nats.POST("/publish", natsHandler.PublishTestHandler)
+		nats.GET("/topics", delivery.RequirePermission(domain.PermissionNATSRead), natsHandler.GetTopicsHandler)
+		nats.POST("/publish", delivery.RequirePermission(domain.PermissionNATSPublish), natsHandler.PublishTestHandler)
 		// New endpoint to subscribe and get metrics from a specific topic
-		nats.GET("/subscribe/:topic", natsHandler.GetTopicMetricsHandler)
+		nats.GET("/subscribe/:topic", delivery.RequirePermission(domain.PermissionNATSRead), natsHandler.GetTopicMetricsHandler)
 	}
 
 	snmp := router.Group("/snmp")
+	snmp.Use(authMiddleware)
+	{
+		snmp.GET("/metrics", delivery.RequirePermission(domain.PermissionSNMPSimulate), natsHandler.SimulateSNMPMetricsHandler)
+	}
+
+	// Administration needs a signed-in administrator, API keys are refused
+	admin := router.Group("/admin")
+	admin.Use(authMiddleware, jwtOnly, delivery.RequirePermission(domain.PermissionUsersManage))
+	{
+		admin.GET("/roles", authHandler.GetRolesHandler)
+		admin.GET("/users/:id/roles", authHandler.GetUserRolesHandler)
+		admin.POST("/users/:id/roles", authHandler.AssignRoleHandler)
+		admin.DELETE("/users/:id/roles/:role", authHandler.RemoveRoleHandler)
+		admin.POST("/users/unlock", authHandler.UnlockAccountHandler)
+		admin.GET("/users", authHandler.ListUsersHandler)
+		admin.GET("/users/:id", authHandler.GetUserHandler)
+		admin.POST("/users/:id/disable", authHandler.DisableUserHandler)
+		admin.POST("/users/:id/enable", authHandler.EnableUserHandler)
+		admin.POST("/users/:id/password-reset", authHandler.ForcePasswordResetHandler)
+		admin.GET("/users/:id/sessions", authHandler.GetUserSessionsHandler)
+		admin.DELETE("/users/:id/sessions", authHandler.RevokeUserSessionsHandler)
+		admin.DELETE("/users/:id/sessions/:sid", authHandler.RevokeUserSessionsHandler)
+		admin.POST("/users/:id/impersonate", authHandler.ImpersonateHandler)
+		admin.GET("/oauth/clients", authHandler.ListOAuthClientsHandler)
+		admin.POST("/oauth/clients", authHandler.CreateOAuthClientHandler)
+		admin.DELETE("/oauth/clients/:client_id", authHandler.DeleteOAuthClientHandler)
+	}
+
+	// The audit log has its own permission so it can be granted to auditors
+	audit := router.Group("/admin/audit")
+	audit.Use(authMiddleware, jwtOnly, delivery.RequirePermission(domain.PermissionAuditRead))
 	{
-		snmp.GET("/metrics", natsHandler.SimulateSNMPMetricsHandler)
+		audit.GET("", authHandler.GetAuditEventsHandler)
+		audit.GET("/verify", authHandler.VerifyAuditLogHandler)
 	}
 }
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/repository"
+	"go-auth-app/pkg"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// auditVerifyBatch is how many entries are read at a time when verifying the chain
+	auditVerifyBatch = 1000
+	// auditQueueSize is how many events may wait for the background writer
+	auditQueueSize = 4096
+	// auditWriteBatch is the most events stored under one chain lock
+	auditWriteBatch = 256
+)
+
+// AuditService writes security relevant events to the append-only audit log
+// and mirrors them to NATS for external consumers. Postgres is the record;
+// the NATS copy is best effort.
+type AuditService struct {
+	Repo    repository.AuditRepository
+	Client  *pkg.NatsClient
+	Subject string
+
+	queue  chan *domain.AuditEvent
+	stop   chan struct{}
+	done   chan struct{}
+	closed atomic.Bool
+}
+
+// NewAuditService creates a new audit service. An empty subject disables mirroring.
+// Events are stored synchronously until StartWriter is called.
+func NewAuditService(repo repository.AuditRepository, client *pkg.NatsClient, subject string) *AuditService {
+	return &AuditService{
+		Repo:    repo,
+		Client:  client,
+		Subject: subject,
+	}
+}
+
+// StartWriter moves appends off the request path. Every append takes the
+// chain lock shared by all instances, so requests would otherwise queue
+// behind each other during a burst of logins; the writer stores whatever has
+// piled up in one transaction instead.
+func (s *AuditService) StartWriter() {
+	s.queue = make(chan *domain.AuditEvent, auditQueueSize)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		for {
+			select {
+			case event := <-s.queue:
+				s.store(s.collect(event))
+			case <-s.stop:
+				for {
+					select {
+					case event := <-s.queue:
+						s.store(s.collect(event))
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Close stores the events still queued and stops the writer
+func (s *AuditService) Close() {
+	if s.queue == nil || !s.closed.CompareAndSwap(false, true) {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Record appends an event to the audit log. With the writer running the event
+// is queued, and only stored synchronously when the queue is full. Events that
+// cannot be stored are still written to the application log so they are not
+// lost silently.
+func (s *AuditService) Record(ctx context.Context, event *domain.AuditEvent) error {
+	// Postgres keeps microseconds, the hash must be computed over what is stored
+	event.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	if event.Outcome == "" {
+		event.Outcome = domain.AuditOutcomeSuccess
+	}
+
+	if s.queue != nil && !s.closed.Load() {
+		select {
+		case s.queue <- event:
+			return nil
+		default:
+		}
+	}
+
+	if err := s.Repo.Append(ctx, event); err != nil {
+		log.Printf("Failed to store audit event %s for user %d: %v", event.Action, event.ActorID, err)
+		return err
+	}
+
+	s.mirror(event)
+	return nil
+}
+
+// collect gathers the queued events following first into one batch
+func (s *AuditService) collect(first *domain.AuditEvent) []*domain.AuditEvent {
+	batch := []*domain.AuditEvent{first}
+	for len(batch) < auditWriteBatch {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// store appends a batch from the writer and mirrors it once it is stored
+func (s *AuditService) store(batch []*domain.AuditEvent) {
+	if err := s.Repo.AppendBatch(context.Background(), batch); err != nil {
+		for _, event := range batch {
+			log.Printf("Failed to store audit event %s for user %d: %v", event.Action, event.ActorID, err)
+		}
+		return
+	}
+
+	for _, event := range batch {
+		s.mirror(event)
+	}
+}
+
+// Query returns a page of audit events, newest first
+func (s *AuditService) Query(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultAuditPageSize
+	}
+	if filter.Limit > domain.MaxAuditPageSize {
+		filter.Limit = domain.MaxAuditPageSize
+	}
+
+	return s.Repo.Query(ctx, filter)
+}
+
+// Verify walks the whole chain and reports the first entry that was altered,
+// removed or inserted out of order
+func (s *AuditService) Verify(ctx context.Context) (*domain.AuditVerification, error) {
+	result := &domain.AuditVerification{Valid: true}
+	prevHash := domain.AuditGenesisHash
+	var lastID int64
+
+	for {
+		events, err := s.Repo.ListAfter(ctx, lastID, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			if event.PrevHash != prevHash || event.ComputeHash() != event.Hash {
+				result.Valid = false
+				result.BrokenAtID = event.ID
+				return result, nil
+			}
+			prevHash = event.Hash
+			lastID = event.ID
+			result.Checked++
+		}
+
+		if len(events) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// mirror publishes a stored event to NATS
+func (s *AuditService) mirror(event *domain.AuditEvent) {
+	if s.Subject == "" || s.Client == nil || !s.Client.IsConnected() {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal audit event %d: %v", event.ID, err)
+		return
+	}
+
+	if err := s.Client.Publish(s.Subject, data); err != nil {
+		log.Printf("Failed to mirror audit event %d to NATS: %v", event.ID, err)
+	}
+}
@@ -18,13 +18,54 @@ type NATSService struct {
 	Subscriptions map[string]*nats.Subscription
 }
 
-// NATSMessagePayload is the structure of messages published over NATS
+// GroupChatSubjects matches the subjects of every group conversation
+const GroupChatSubjects = "chat.group.*"
+
+// ReadReceiptSubjects matches the read receipt subjects of every conversation
+const ReadReceiptSubjects = "chat.receipts.*"
+
+// ReadReceiptPayload is a read receipt published over NATS with the members to deliver it to
+type ReadReceiptPayload struct {
+	domain.ReadReceipt
+	MemberIDs []int `json:"member_ids"`
+}
+
+// TypingSubjects matches the typing subjects of every conversation
+const TypingSubjects = "chat.typing.*"
+
+// TypingEventPayload is a typing event published over NATS with the members to deliver it to
+type TypingEventPayload struct {
+	domain.TypingEvent
+	MemberIDs []int `json:"member_ids"`
+}
+
+// MessageUpdateSubjects matches the message update subjects of every conversation
+const MessageUpdateSubjects = "chat.updates.*"
+
+// MessageUpdate is an edit or a deletion for everyone of a message, only one of them is set
+type MessageUpdate struct {
+	Edited  *domain.Message         `json:"edited,omitempty"`
+	Deleted *domain.MessageDeletion `json:"deleted,omitempty"`
+}
+
+// MessageUpdatePayload is a message update published over NATS with the members to deliver it to
+type MessageUpdatePayload struct {
+	MessageUpdate
+	SenderID  int   `json:"sender_id"`
+	MemberIDs []int `json:"member_ids"`
+}
+
+// NATSMessagePayload is the structure of messages published over NATS. Group
+// messages carry the members to deliver to, so instances need no database
+// lookup to fan them out.
 type NATSMessagePayload struct {
-	ID         int    `json:"id"`
-	SenderID   int    `json:"sender_id"`
-	ReceiverID int    `json:"receiver_id"`
-	Content    string `json:"content"`
-	Timestamp  int64  `json:"timestamp"`
+	ID             int    `json:"id"`
+	ConversationID int    `json:"conversation_id,omitempty"`
+	SenderID       int    `json:"sender_id"`
+	ReceiverID     int    `json:"receiver_id,omitempty"`
+	MemberIDs      []int  `json:"member_ids,omitempty"`
+	Content        string `json:"content"`
+	Timestamp      int64  `json:"timestamp"`
 }
 
 // NewNATSService creates a new NATS service
@@ -44,6 +85,11 @@ func (s *NATSService) GetPrivateChatSubject(user1ID, user2ID int) string {
 	return fmt.Sprintf("chat.private.%d.%d", user1ID, user2ID)
 }
 
+// GetGroupChatSubject returns the subject of a group conversation
+func (s *NATSService) GetGroupChatSubject(conversationID int) string {
+	return fmt.Sprintf("chat.group.%d", conversationID)
+}
+
 // PublishChatMessage publishes a chat message to NATS
 func (s *NATSService) PublishChatMessage(message *domain.Message) error {
 	if !s.Client.IsConnected() {
@@ -52,11 +98,12 @@ func (s *NATSService) PublishChatMessage(message *domain.Message) error {
 
 	// Create payload
 	payload := NATSMessagePayload{
-		ID:         message.ID,
-		SenderID:   message.SenderID,
-		ReceiverID: message.ReceiverID,
-		Content:    message.Content,
-		Timestamp:  message.CreatedAt.Unix(),
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		ReceiverID:     message.ReceiverID,
+		Content:        message.Content,
+		Timestamp:      message.CreatedAt.Unix(),
 	}
 
 	// Marshal to JSON
@@ -78,8 +125,230 @@ func (s *NATSService) PublishChatMessage(message *domain.Message) error {
 	return nil
 }
 
-// SubscribeToUserMessages subscribes to all messages for a specific user
-func (s *NATSService) SubscribeToUserMessages(userID int, callback func(senderID, receiverID int, content string)) error {
+// PublishGroupMessage publishes a message of a group conversation for its members
+func (s *NATSService) PublishGroupMessage(message *domain.Message, memberIDs []int) error {
+	if !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	payload := NATSMessagePayload{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		MemberIDs:      memberIDs,
+		Content:        message.Content,
+		Timestamp:      message.CreatedAt.Unix(),
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %v", err)
+	}
+
+	subject := s.GetGroupChatSubject(message.ConversationID)
+	if err := s.Client.Publish(subject, data); err != nil {
+		return fmt.Errorf("failed to publish message: %v", err)
+	}
+
+	log.Printf("Published message to subject: %s", subject)
+	return nil
+}
+
+// SubscribeToGroupMessages subscribes once per instance to every group
+// conversation and calls deliver for each member of a received message but
+// its sender. The callback decides whether the member is connected here.
+func (s *NATSService) SubscribeToGroupMessages(deliver func(memberID int, message *domain.Message)) error {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(GroupChatSubjects, func(msg *nats.Msg) {
+		var payload NATSMessagePayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			log.Printf("Failed to unmarshal message payload: %v", err)
+			return
+		}
+
+		message := &domain.Message{
+			ID:             payload.ID,
+			ConversationID: payload.ConversationID,
+			SenderID:       payload.SenderID,
+			Content:        payload.Content,
+			CreatedAt:      time.Unix(payload.Timestamp, 0),
+		}
+
+		for _, memberID := range payload.MemberIDs {
+			if memberID != payload.SenderID {
+				deliver(memberID, message)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", GroupChatSubjects, err)
+	}
+	s.Subscriptions["group_messages"] = sub
+
+	log.Printf("Subscribed to %s", GroupChatSubjects)
+	return nil
+}
+
+// GetReadReceiptSubject returns the read receipt subject of a conversation
+func (s *NATSService) GetReadReceiptSubject(conversationID int) string {
+	return fmt.Sprintf("chat.receipts.%d", conversationID)
+}
+
+// PublishReadReceipt publishes how far a user has read a conversation for its members
+func (s *NATSService) PublishReadReceipt(receipt *domain.ReadReceipt, memberIDs []int) error {
+	if !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	data, err := json.Marshal(ReadReceiptPayload{ReadReceipt: *receipt, MemberIDs: memberIDs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal read receipt: %v", err)
+	}
+
+	if err := s.Client.Publish(s.GetReadReceiptSubject(receipt.ConversationID), data); err != nil {
+		return fmt.Errorf("failed to publish read receipt: %v", err)
+	}
+	return nil
+}
+
+// SubscribeToReadReceipts subscribes once per instance to the read receipts of
+// every conversation and calls deliver for each member but the reader
+func (s *NATSService) SubscribeToReadReceipts(deliver func(memberID int, receipt *domain.ReadReceipt)) error {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(ReadReceiptSubjects, func(msg *nats.Msg) {
+		var payload ReadReceiptPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			log.Printf("Failed to unmarshal read receipt: %v", err)
+			return
+		}
+
+		for _, memberID := range payload.MemberIDs {
+			if memberID != payload.UserID {
+				deliver(memberID, &payload.ReadReceipt)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", ReadReceiptSubjects, err)
+	}
+	s.Subscriptions["read_receipts"] = sub
+
+	log.Printf("Subscribed to %s", ReadReceiptSubjects)
+	return nil
+}
+
+// GetTypingSubject returns the typing subject of a conversation
+func (s *NATSService) GetTypingSubject(conversationID int) string {
+	return fmt.Sprintf("chat.typing.%d", conversationID)
+}
+
+// PublishTypingEvent publishes a typing event for the members of a conversation
+func (s *NATSService) PublishTypingEvent(event *domain.TypingEvent, memberIDs []int) error {
+	if !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	data, err := json.Marshal(TypingEventPayload{TypingEvent: *event, MemberIDs: memberIDs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal typing event: %v", err)
+	}
+
+	if err := s.Client.Publish(s.GetTypingSubject(event.ConversationID), data); err != nil {
+		return fmt.Errorf("failed to publish typing event: %v", err)
+	}
+	return nil
+}
+
+// SubscribeToTypingEvents subscribes once per instance to the typing events of
+// every conversation and calls deliver for each member but the typist
+func (s *NATSService) SubscribeToTypingEvents(deliver func(memberID int, event *domain.TypingEvent)) error {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(TypingSubjects, func(msg *nats.Msg) {
+		var payload TypingEventPayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			log.Printf("Failed to unmarshal typing event: %v", err)
+			return
+		}
+
+		for _, memberID := range payload.MemberIDs {
+			if memberID != payload.UserID {
+				deliver(memberID, &payload.TypingEvent)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", TypingSubjects, err)
+	}
+	s.Subscriptions["typing_events"] = sub
+
+	log.Printf("Subscribed to %s", TypingSubjects)
+	return nil
+}
+
+// GetMessageUpdateSubject returns the message update subject of a conversation
+func (s *NATSService) GetMessageUpdateSubject(conversationID int) string {
+	return fmt.Sprintf("chat.updates.%d", conversationID)
+}
+
+// PublishMessageUpdate publishes that the sender edited or deleted a message for the members of its conversation
+func (s *NATSService) PublishMessageUpdate(conversationID, senderID int, update *MessageUpdate, memberIDs []int) error {
+	if !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	data, err := json.Marshal(MessageUpdatePayload{MessageUpdate: *update, SenderID: senderID, MemberIDs: memberIDs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message update: %v", err)
+	}
+
+	if err := s.Client.Publish(s.GetMessageUpdateSubject(conversationID), data); err != nil {
+		return fmt.Errorf("failed to publish message update: %v", err)
+	}
+	return nil
+}
+
+// SubscribeToMessageUpdates subscribes once per instance to the message
+// updates of every conversation and calls deliver for each member but the sender
+func (s *NATSService) SubscribeToMessageUpdates(deliver func(memberID int, update *MessageUpdate)) error {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(MessageUpdateSubjects, func(msg *nats.Msg) {
+		var payload MessageUpdatePayload
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			log.Printf("Failed to unmarshal message update: %v", err)
+			return
+		}
+
+		for _, memberID := range payload.MemberIDs {
+			if memberID != payload.SenderID {
+				deliver(memberID, &payload.MessageUpdate)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", MessageUpdateSubjects, err)
+	}
+	s.Subscriptions["message_updates"] = sub
+
+	log.Printf("Subscribed to %s", MessageUpdateSubjects)
+	return nil
+}
+
+// SubscribeToUserMessages subscribes to all direct messages of a specific
+// user. The callback gets the stored message, including its ID and
+// conversation, so clients can mark it read and match later edits to it.
+func (s *NATSService) SubscribeToUserMessages(userID int, callback func(message *domain.Message)) error {
 	if !s.Client.IsConnected() {
 		return fmt.Errorf("not connected to NATS")
 	}
@@ -118,7 +387,7 @@ func (s *NATSService) SubscribeToUserMessages(userID int, callback func(senderID
 }
 
 // handleChatMessage processes NATS messages for chat
-func (s *NATSService) handleChatMessage(msg *nats.Msg, userID int, callback func(senderID, receiverID int, content string)) {
+func (s *NATSService) handleChatMessage(msg *nats.Msg, userID int, callback func(message *domain.Message)) {
 	// Parse subject to identify participants
 	parts := strings.Split(msg.Subject, ".")
 	if len(parts) != 4 {
@@ -139,8 +408,14 @@ func (s *NATSService) handleChatMessage(msg *nats.Msg, userID int, callback func
 		return
 	}
 
-	// Call the callback with the message data
-	callback(payload.SenderID, payload.ReceiverID, payload.Content)
+	callback(&domain.Message{
+		ID:             payload.ID,
+		ConversationID: payload.ConversationID,
+		SenderID:       payload.SenderID,
+		ReceiverID:     payload.ReceiverID,
+		Content:        payload.Content,
+		CreatedAt:      time.Unix(payload.Timestamp, 0),
+	})
 }
 
 // UnsubscribeAll unsubscribes from all subscriptions
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/repository"
+	"go-auth-app/pkg"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// PresenceSubject is the NATS subject every app instance publishes its presence heartbeats on
+const PresenceSubject = "presence.heartbeat"
+
+// PresenceHeartbeat is what an instance tells the others about the users
+// connected to it. A full heartbeat lists all of them and replaces whatever
+// the instance said before; a partial one only lists users whose status
+// changed, PresenceOffline for those who left.
+type PresenceHeartbeat struct {
+	InstanceID string         `json:"instance_id"`
+	Full       bool           `json:"full"`
+	Users      map[int]string `json:"users"`
+}
+
+// PresenceService tracks who is online across all app instances. Each instance
+// publishes a partial heartbeat when the status of a user connected to it
+// changes and a full one every Interval. An instance that has not been heard
+// of for TTL is presumed gone along with its users. Postgres keeps when users
+// were last seen: it is saved every Interval while they are connected and once
+// more when they disconnect.
+type PresenceService struct {
+	UserRepo   repository.UserRepository
+	Client     *pkg.NatsClient
+	InstanceID string
+	Interval   time.Duration
+	TTL        time.Duration
+
+	// local holds the users connected here, instances what the others reported
+	local        map[int]string
+	instances    map[string]*presenceInstance
+	status       map[int]string
+	listeners    []func(presence domain.Presence)
+	mutex        sync.Mutex
+	subscription *nats.Subscription
+	done         chan struct{}
+}
+
+// presenceInstance is the last word of another instance on its users
+type presenceInstance struct {
+	users  map[int]string
+	seenAt time.Time
+}
+
+// NewPresenceService creates a presence service for this instance
+func NewPresenceService(userRepo repository.UserRepository, client *pkg.NatsClient) *PresenceService {
+	instanceID, _ := pkg.GenerateSecureToken(12)
+	return &PresenceService{
+		UserRepo:   userRepo,
+		Client:     client,
+		InstanceID: instanceID,
+		Interval:   15 * time.Second,
+		TTL:        45 * time.Second,
+		local:      make(map[int]string),
+		instances:  make(map[string]*presenceInstance),
+		status:     make(map[int]string),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start sends the heartbeats of this instance and listens to those of the
+// others. Without NATS the service still tracks the users connected here, and
+// the error says so.
+func (s *PresenceService) Start() error {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case now := <-ticker.C:
+				s.heartbeat(now)
+			}
+		}
+	}()
+
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(PresenceSubject, func(msg *nats.Msg) {
+		var heartbeat PresenceHeartbeat
+		if err := json.Unmarshal(msg.Data, &heartbeat); err != nil {
+			log.Printf("Failed to unmarshal presence heartbeat: %v", err)
+			return
+		}
+		s.Apply(heartbeat)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", PresenceSubject, err)
+	}
+
+	s.mutex.Lock()
+	s.subscription = sub
+	s.mutex.Unlock()
+	log.Printf("Subscribed to %s", PresenceSubject)
+
+	// Announcing ourselves makes the others answer with their users right away
+	s.publish(true, s.localUsers())
+	return nil
+}
+
+// Close stops the heartbeats and tells the other instances that the users
+// connected here are gone
+func (s *PresenceService) Close() {
+	close(s.done)
+
+	s.mutex.Lock()
+	users := s.local
+	s.local = make(map[int]string)
+	subscription := s.subscription
+	s.subscription = nil
+	s.mutex.Unlock()
+
+	if subscription != nil {
+		if err := subscription.Unsubscribe(); err != nil {
+			log.Printf("Failed to unsubscribe from %s: %v", PresenceSubject, err)
+		}
+	}
+
+	s.touch(presenceUserIDs(users), time.Now())
+	s.publish(true, map[int]string{})
+}
+
+// OnChange registers a function called whenever the status of a user changes,
+// here or on another instance. It must not block.
+func (s *PresenceService) OnChange(listener func(presence domain.Presence)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetLocal sets the status of a user on this instance, PresenceOffline once
+// their last connection here has closed
+func (s *PresenceService) SetLocal(userID int, status string) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	previous, connected := s.local[userID]
+	if previous == status || (!connected && status == domain.PresenceOffline) {
+		s.mutex.Unlock()
+		return
+	}
+	if status == domain.PresenceOffline {
+		delete(s.local, userID)
+	} else {
+		s.local[userID] = status
+	}
+	changes := s.recompute(now, userID)
+	s.mutex.Unlock()
+
+	if status == domain.PresenceOffline {
+		s.touch([]int{userID}, now)
+	}
+	s.publish(false, map[int]string{userID: status})
+	s.notify(changes)
+}
+
+// Apply takes in a heartbeat received from another instance. Hearing from an
+// instance for the first time, it answers with the users connected here.
+func (s *PresenceService) Apply(heartbeat PresenceHeartbeat) {
+	if heartbeat.InstanceID == s.InstanceID {
+		return
+	}
+	now := time.Now()
+
+	s.mutex.Lock()
+	instance, known := s.instances[heartbeat.InstanceID]
+	if !known {
+		instance = &presenceInstance{users: make(map[int]string)}
+		s.instances[heartbeat.InstanceID] = instance
+	}
+	instance.seenAt = now
+
+	var changed []int
+	if heartbeat.Full {
+		for userID := range instance.users {
+			if _, ok := heartbeat.Users[userID]; !ok {
+				changed = append(changed, userID)
+			}
+		}
+		instance.users = make(map[int]string, len(heartbeat.Users))
+	}
+	for userID, status := range heartbeat.Users {
+		changed = append(changed, userID)
+		if status == domain.PresenceOffline {
+			delete(instance.users, userID)
+		} else {
+			instance.users[userID] = status
+		}
+	}
+	changes := s.recompute(now, changed...)
+	s.mutex.Unlock()
+
+	if !known {
+		s.publish(true, s.localUsers())
+	}
+	s.notify(changes)
+}
+
+// Get returns the presence of the users, with when offline users were last seen
+func (s *PresenceService) Get(ctx context.Context, userIDs []int) ([]*domain.Presence, error) {
+	presences := make([]*domain.Presence, 0, len(userIDs))
+	var offline []int
+
+	s.mutex.Lock()
+	for _, userID := range userIDs {
+		status, ok := s.status[userID]
+		if !ok {
+			status = domain.PresenceOffline
+			offline = append(offline, userID)
+		}
+		presences = append(presences, &domain.Presence{UserID: userID, Status: status})
+	}
+	s.mutex.Unlock()
+
+	if len(offline) == 0 || s.UserRepo == nil {
+		return presences, nil
+	}
+
+	lastSeen, err := s.UserRepo.GetLastSeen(ctx, offline)
+	if err != nil {
+		return nil, err
+	}
+	for _, presence := range presences {
+		if seenAt, ok := lastSeen[presence.UserID]; ok && presence.Status == domain.PresenceOffline {
+			presence.LastSeenAt = &seenAt
+		}
+	}
+
+	return presences, nil
+}
+
+// heartbeat publishes the users connected here, saves that they were seen
+// and drops the instances that went quiet
+func (s *PresenceService) heartbeat(now time.Time) {
+	var changes []domain.Presence
+
+	s.mutex.Lock()
+	for id, instance := range s.instances {
+		if now.Sub(instance.seenAt) > s.TTL {
+			delete(s.instances, id)
+			changes = append(changes, s.recompute(instance.seenAt, presenceUserIDs(instance.users)...)...)
+		}
+	}
+	users := s.copyLocal()
+	s.mutex.Unlock()
+
+	s.publish(true, users)
+	s.touch(presenceUserIDs(users), now)
+	s.notify(changes)
+}
+
+// recompute works out the status of the users from every instance and returns
+// those that changed. Users going offline were last seen at seenAt. The
+// caller holds the mutex.
+func (s *PresenceService) recompute(seenAt time.Time, userIDs ...int) []domain.Presence {
+	var changes []domain.Presence
+	for _, userID := range userIDs {
+		status := s.local[userID]
+		for _, instance := range s.instances {
+			if presenceRank(instance.users[userID]) > presenceRank(status) {
+				status = instance.users[userID]
+			}
+		}
+
+		previous, ok := s.status[userID]
+		if !ok {
+			previous = domain.PresenceOffline
+		}
+		if status == "" {
+			status = domain.PresenceOffline
+		}
+		if status == previous {
+			continue
+		}
+
+		presence := domain.Presence{UserID: userID, Status: status}
+		if status == domain.PresenceOffline {
+			delete(s.status, userID)
+			lastSeen := seenAt
+			presence.LastSeenAt = &lastSeen
+		} else {
+			s.status[userID] = status
+		}
+		changes = append(changes, presence)
+	}
+	return changes
+}
+
+// notify passes status changes to the listeners
+func (s *PresenceService) notify(changes []domain.Presence) {
+	if len(changes) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	listeners := s.listeners
+	s.mutex.Unlock()
+
+	for _, presence := range changes {
+		for _, listener := range listeners {
+			listener(presence)
+		}
+	}
+}
+
+// publish sends a heartbeat to the other instances, if there are any
+func (s *PresenceService) publish(full bool, users map[int]string) {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return
+	}
+
+	data, err := json.Marshal(PresenceHeartbeat{InstanceID: s.InstanceID, Full: full, Users: users})
+	if err != nil {
+		log.Printf("Failed to marshal presence heartbeat: %v", err)
+		return
+	}
+
+	if err := s.Client.Publish(PresenceSubject, data); err != nil {
+		log.Printf("Failed to publish presence heartbeat: %v", err)
+	}
+}
+
+// touch saves that the users were seen
+func (s *PresenceService) touch(userIDs []int, seenAt time.Time) {
+	if len(userIDs) == 0 || s.UserRepo == nil {
+		return
+	}
+
+	if err := s.UserRepo.TouchLastSeen(context.Background(), userIDs, seenAt); err != nil {
+		log.Printf("Failed to save last seen time: %v", err)
+	}
+}
+
+// localUsers returns a copy of the users connected here
+func (s *PresenceService) localUsers() map[int]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.copyLocal()
+}
+
+// copyLocal returns a copy of the users connected here. The caller holds the mutex.
+func (s *PresenceService) copyLocal() map[int]string {
+	users := make(map[int]string, len(s.local))
+	for userID, status := range s.local {
+		users[userID] = status
+	}
+	return users
+}
+
+// presenceRank orders statuses so the most present connection wins
+func presenceRank(status string) int {
+	switch status {
+	case domain.PresenceOnline:
+		return 2
+	case domain.PresenceAway:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// presenceUserIDs returns the users of a status map
+func presenceUserIDs(users map[int]string) []int {
+	ids := make([]int, 0, len(users))
+	for userID := range users {
+		ids = append(ids, userID)
+	}
+	return ids
+}
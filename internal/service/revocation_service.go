@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// RevocationSubject is the NATS subject used to broadcast revocations to every app instance
+const RevocationSubject = "auth.revocations"
+
+// RevocationService keeps an in-memory view of revoked access tokens that is
+// shared between app instances over NATS. Postgres remains the source of truth;
+// the cache only saves a database round trip on every authenticated request.
+type RevocationService struct {
+	Client *pkg.NatsClient
+
+	tokens       map[string]time.Time
+	users        map[int]time.Time
+	sessions     map[string]time.Time
+	listeners    []func(RevocationEvent)
+	mutex        sync.RWMutex
+	subscription *nats.Subscription
+}
+
+// RevocationEvent is the payload broadcast on RevocationSubject. RevokedBefore
+// is in Unix milliseconds, the precision of token issue times.
+type RevocationEvent struct {
+	JTI           string `json:"jti,omitempty"`
+	SessionID     string `json:"sid,omitempty"`
+	UserID        int    `json:"user_id"`
+	ExpiresAt     int64  `json:"expires_at,omitempty"`
+	RevokedBefore int64  `json:"revoked_before_ms,omitempty"`
+}
+
+// Revokes reports whether the event invalidates a token with the given ID, session, owner and issue time
+func (e RevocationEvent) Revokes(jti, sessionID string, userID int, issuedAt time.Time) bool {
+	if e.JTI != "" && e.JTI == jti {
+		return true
+	}
+	if e.SessionID != "" && e.SessionID == sessionID {
+		return true
+	}
+	return e.RevokedBefore > 0 && e.UserID == userID && !issuedAt.After(time.UnixMilli(e.RevokedBefore))
+}
+
+// NewRevocationService creates a new revocation service
+func NewRevocationService(client *pkg.NatsClient) *RevocationService {
+	return &RevocationService{
+		Client:   client,
+		tokens:   make(map[string]time.Time),
+		users:    make(map[int]time.Time),
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// Subscribe starts listening for revocations published by other instances
+func (s *RevocationService) Subscribe() error {
+	if s.Client == nil || !s.Client.IsConnected() {
+		return fmt.Errorf("not connected to NATS")
+	}
+
+	sub, err := s.Client.Subscribe(RevocationSubject, func(msg *nats.Msg) {
+		var event RevocationEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Printf("Failed to unmarshal revocation event: %v", err)
+			return
+		}
+		s.apply(event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to subject %s: %v", RevocationSubject, err)
+	}
+
+	s.subscription = sub
+	log.Printf("Subscribed to %s", RevocationSubject)
+	return nil
+}
+
+// RevokeToken revokes a single token locally and announces it to other instances
+func (s *RevocationService) RevokeToken(jti string, userID int, expiresAt time.Time) {
+	s.broadcast(RevocationEvent{JTI: jti, UserID: userID, ExpiresAt: expiresAt.Unix()})
+}
+
+// RevokeUser revokes every token issued to a user up to the given millisecond
+func (s *RevocationService) RevokeUser(userID int, before time.Time) {
+	s.broadcast(RevocationEvent{UserID: userID, RevokedBefore: before.UnixMilli()})
+}
+
+// RevokeSession revokes every token of a login session. The entry is kept
+// until expiresAt, when the last access token of the session has expired.
+func (s *RevocationService) RevokeSession(sessionID string, userID int, expiresAt time.Time) {
+	s.broadcast(RevocationEvent{SessionID: sessionID, UserID: userID, ExpiresAt: expiresAt.Unix()})
+}
+
+// OnRevoke registers a function called for every revocation, local or received
+// from another instance, so long-lived connections can be closed
+func (s *RevocationService) OnRevoke(listener func(RevocationEvent)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Load replaces the cached view with the state read from the database
+func (s *RevocationService) Load(tokens []*domain.RevokedToken, users []*domain.UserRevocation, sessions []*domain.RevokedSession) {
+	now := time.Now()
+	tokenMap := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		tokenMap[token.JTI] = token.ExpiresAt
+	}
+
+	userMap := make(map[int]time.Time, len(users))
+	for _, user := range users {
+		userMap[user.UserID] = user.RevokedBefore
+	}
+
+	sessionMap := make(map[string]time.Time, len(sessions))
+	for _, session := range sessions {
+		sessionMap[session.SessionID] = session.ExpiresAt
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Keep entries received over NATS that the database snapshot may predate
+	for jti, exp := range s.tokens {
+		if _, ok := tokenMap[jti]; !ok && now.Before(exp) {
+			tokenMap[jti] = exp
+		}
+	}
+	for userID, before := range s.users {
+		if existing, ok := userMap[userID]; !ok || before.After(existing) {
+			userMap[userID] = before
+		}
+	}
+	for sessionID, exp := range s.sessions {
+		if _, ok := sessionMap[sessionID]; !ok && now.Before(exp) {
+			sessionMap[sessionID] = exp
+		}
+	}
+
+	s.tokens = tokenMap
+	s.users = userMap
+	s.sessions = sessionMap
+}
+
+// IsRevoked reports whether a token with the given ID, session, owner and issue time has been revoked
+func (s *RevocationService) IsRevoked(jti, sessionID string, userID int, issuedAt time.Time) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if jti != "" {
+		if _, ok := s.tokens[jti]; ok {
+			return true
+		}
+	}
+
+	if sessionID != "" {
+		if _, ok := s.sessions[sessionID]; ok {
+			return true
+		}
+	}
+
+	if before, ok := s.users[userID]; ok && !issuedAt.After(before) {
+		return true
+	}
+
+	return false
+}
+
+// Close stops listening for revocation events
+func (s *RevocationService) Close() {
+	if s.subscription != nil {
+		if err := s.subscription.Unsubscribe(); err != nil {
+			log.Printf("Failed to unsubscribe from %s: %v", RevocationSubject, err)
+		}
+		s.subscription = nil
+	}
+}
+
+// broadcast applies an event locally and publishes it for the other instances
+func (s *RevocationService) broadcast(event RevocationEvent) {
+	s.apply(event)
+
+	if s.Client == nil || !s.Client.IsConnected() {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal revocation event: %v", err)
+		return
+	}
+
+	if err := s.Client.Publish(RevocationSubject, data); err != nil {
+		log.Printf("Failed to publish revocation event: %v", err)
+	}
+}
+
+// apply records an event in the in-memory view and notifies the listeners
+func (s *RevocationService) apply(event RevocationEvent) {
+	s.mutex.Lock()
+
+	if event.JTI != "" {
+		s.tokens[event.JTI] = time.Unix(event.ExpiresAt, 0)
+	}
+
+	if event.SessionID != "" {
+		s.sessions[event.SessionID] = time.Unix(event.ExpiresAt, 0)
+	}
+
+	if event.RevokedBefore > 0 {
+		before := time.UnixMilli(event.RevokedBefore)
+		if existing, ok := s.users[event.UserID]; !ok || before.After(existing) {
+			s.users[event.UserID] = before
+		}
+	}
+
+	listeners := s.listeners
+	s.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
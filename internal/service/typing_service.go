@@ -0,0 +1,192 @@
+package service
+
+import (
+	"go-auth-app/internal/domain"
+	"log"
+	"sync"
+	"time"
+)
+
+// TypingPublisher sends typing events to every app instance, NATSService in production
+type TypingPublisher interface {
+	PublishTypingEvent(event *domain.TypingEvent, memberIDs []int) error
+}
+
+// TypingService tracks who is typing in which conversation on this instance.
+// A typing_start is published when a user starts typing and repeated at most
+// every KeepAlive while they go on; typing_stop is published when they stop,
+// or by the service itself once Timeout passes without a new typing_start, so
+// indicators do not hang when a client never says it stopped. A user can show
+// at most MaxStarts new indicators per StartWindow, which bounds what a
+// misbehaving client puts on the bus.
+type TypingService struct {
+	Publisher   TypingPublisher
+	Timeout     time.Duration
+	KeepAlive   time.Duration
+	MaxStarts   int
+	StartWindow time.Duration
+
+	active map[typingKey]*typingState
+	starts map[int][]time.Time
+	mutex  sync.Mutex
+}
+
+// typingKey identifies a user typing in a conversation
+type typingKey struct {
+	userID         int
+	conversationID int
+}
+
+// typingState is an indicator shown to the other members
+type typingState struct {
+	memberIDs   []int
+	publishedAt time.Time
+	timer       *time.Timer
+}
+
+// NewTypingService creates a typing service that publishes with the given publisher
+func NewTypingService(publisher TypingPublisher) *TypingService {
+	return &TypingService{
+		Publisher:   publisher,
+		Timeout:     6 * time.Second,
+		KeepAlive:   3 * time.Second,
+		MaxStarts:   20,
+		StartWindow: time.Minute,
+		active:      make(map[typingKey]*typingState),
+		starts:      make(map[int][]time.Time),
+	}
+}
+
+// Refresh extends the indicator of a user already typing in a conversation.
+// It reports false if they are not, and the caller must Start it.
+func (s *TypingService) Refresh(userID, conversationID int) bool {
+	key := typingKey{userID, conversationID}
+
+	s.mutex.Lock()
+	state, ok := s.active[key]
+	if !ok {
+		s.mutex.Unlock()
+		return false
+	}
+
+	state.timer.Reset(s.Timeout)
+	keepAlive := time.Since(state.publishedAt) >= s.KeepAlive
+	if keepAlive {
+		state.publishedAt = time.Now()
+	}
+	memberIDs := state.memberIDs
+	s.mutex.Unlock()
+
+	if keepAlive {
+		s.publish(key, true, memberIDs)
+	}
+	return true
+}
+
+// Start shows that a user is typing in a conversation to its members. It
+// returns domain.ErrTypingThrottled when the user starts typing too often.
+func (s *TypingService) Start(userID, conversationID int, memberIDs []int) error {
+	if s.Refresh(userID, conversationID) {
+		return nil
+	}
+
+	key := typingKey{userID, conversationID}
+	now := time.Now()
+
+	s.mutex.Lock()
+	if !s.allowStart(userID, now) {
+		s.mutex.Unlock()
+		return domain.ErrTypingThrottled
+	}
+
+	state := &typingState{memberIDs: memberIDs, publishedAt: now}
+	state.timer = time.AfterFunc(s.Timeout, func() { s.expire(key, state) })
+	s.active[key] = state
+	s.mutex.Unlock()
+
+	s.publish(key, true, memberIDs)
+	return nil
+}
+
+// Stop hides the indicator of a user typing in a conversation. Users who are
+// not typing there are ignored.
+func (s *TypingService) Stop(userID, conversationID int) {
+	key := typingKey{userID, conversationID}
+
+	s.mutex.Lock()
+	state, ok := s.active[key]
+	if ok {
+		state.timer.Stop()
+		delete(s.active, key)
+	}
+	s.mutex.Unlock()
+
+	if ok {
+		s.publish(key, false, state.memberIDs)
+	}
+}
+
+// expire stops an indicator whose client went quiet, unless it was replaced in the meantime
+func (s *TypingService) expire(key typingKey, state *typingState) {
+	s.mutex.Lock()
+	if s.active[key] != state {
+		s.mutex.Unlock()
+		return
+	}
+	delete(s.active, key)
+	s.mutex.Unlock()
+
+	s.publish(key, false, state.memberIDs)
+}
+
+// Throttled reports whether the user has started typing MaxStarts times in
+// the last StartWindow, so a new Start would be refused
+func (s *TypingService) Throttled(userID int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.recentStarts(userID, time.Now())) >= s.MaxStarts
+}
+
+// allowStart records a start for the user if they are within MaxStarts per
+// StartWindow. The caller holds the mutex.
+func (s *TypingService) allowStart(userID int, now time.Time) bool {
+	recent := s.recentStarts(userID, now)
+	if len(recent) >= s.MaxStarts {
+		return false
+	}
+
+	s.starts[userID] = append(recent, now)
+	return true
+}
+
+// recentStarts drops the user's starts older than StartWindow and returns the
+// others. The caller holds the mutex.
+func (s *TypingService) recentStarts(userID int, now time.Time) []time.Time {
+	recent := s.starts[userID][:0]
+	for _, at := range s.starts[userID] {
+		if now.Sub(at) < s.StartWindow {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) == 0 {
+		delete(s.starts, userID)
+		return nil
+	}
+
+	s.starts[userID] = recent
+	return recent
+}
+
+// publish sends a typing event to the members of the conversation
+func (s *TypingService) publish(key typingKey, typing bool, memberIDs []int) {
+	event := &domain.TypingEvent{ConversationID: key.conversationID, UserID: key.userID, Typing: typing}
+	if typing {
+		expiresAt := time.Now().Add(s.Timeout)
+		event.ExpiresAt = &expiresAt
+	}
+
+	if err := s.Publisher.PublishTypingEvent(event, memberIDs); err != nil {
+		log.Printf("Failed to publish typing event: %v", err)
+	}
+}
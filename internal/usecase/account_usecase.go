@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"time"
+)
+
+// DeleteAccount schedules the caller's account for deletion. It is signed out
+// everywhere at once, but the data is only purged after the grace period;
+// logging in before then restores the account. It returns when the purge is due.
+func (uc *AuthUsecase) DeleteAccount(ctx context.Context, userID int, currentPassword string, client domain.ClientInfo) (time.Time, error) {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := uc.verifyCurrentPassword(ctx, user, currentPassword, client); err != nil {
+		return time.Time{}, err
+	}
+
+	deletedAt := time.Now()
+	if err := uc.UserRepo.MarkDeleted(ctx, user.ID, deletedAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := uc.LogoutAll(ctx, user.ID); err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := deletedAt.Add(uc.AccountDeletionGrace)
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditDeletionRequested, ActorID: user.ID, Details: domain.AuditDetails{"purge_at": purgeAt.Format(time.RFC3339)}}, client)
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour account and all of its data will be deleted on %s.\nIf you change your mind, simply log in before then to keep your account.\n",
+			user.Name, purgeAt.Format("January 2, 2006"),
+		),
+	})
+
+	return purgeAt, nil
+}
+
+// ExportAccount collects the caller's personal data: the profile and every
+// conversation and message they took part in
+func (uc *AuthUsecase) ExportAccount(ctx context.Context, userID int) (*domain.AccountExport, error) {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &domain.AccountExport{
+		ExportedAt:    time.Now().UTC(),
+		Profile:       user,
+		Conversations: []*domain.Conversation{},
+		Messages:      []*domain.Message{},
+	}
+
+	if uc.ChatRepo != nil {
+		if export.Conversations, err = uc.ChatRepo.GetConversationsByUserID(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if export.Messages, err = uc.ChatRepo.GetMessagesByUserID(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+// PurgeDeletedAccounts permanently removes the accounts whose grace period is over
+func (uc *AuthUsecase) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-uc.AccountDeletionGrace)
+
+	ids, err := uc.UserRepo.ListDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		ok, err := uc.UserRepo.Purge(ctx, id, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("purge user %d: %w", id, err)
+		}
+		if ok {
+			purged++
+			uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditUserPurged, TargetUserID: id}, domain.ClientInfo{})
+		}
+	}
+
+	return purged, nil
+}
+
+// StartAccountPurge periodically purges accounts whose deletion grace period is over
+func (uc *AuthUsecase) StartAccountPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := uc.PurgeDeletedAccounts(context.Background()); err != nil {
+				log.Printf("Failed to purge deleted accounts: %v", err)
+			}
+		}
+	}()
+}
+
+// restoreAccount cancels the pending deletion of an account that signed in again
+func (uc *AuthUsecase) restoreAccount(ctx context.Context, user *domain.User, client domain.ClientInfo) error {
+	// The placeholder that holds purged users' messages is deleted for good
+	if user.Email == domain.DeletedUserEmail {
+		return domain.ErrAccountDeleted
+	}
+
+	if err := uc.UserRepo.Restore(ctx, user.ID); err != nil {
+		return err
+	}
+	user.DeletedAt = nil
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditDeletionCancelled, ActorID: user.ID}, client)
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your account deletion was cancelled",
+		Body:    fmt.Sprintf("Hi %s,\n\nYou logged in again, so your account will not be deleted.\nIf this was not you, change your password immediately.\n", user.Name),
+	})
+
+	return nil
+}
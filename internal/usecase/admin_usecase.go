@@ -0,0 +1,248 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ListUsers returns a page of accounts for administrators
+func (uc *AuthUsecase) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error) {
+	switch filter.Status {
+	case "", domain.UserStatusActive, domain.UserStatusDisabled, domain.UserStatusDeleted:
+	default:
+		return nil, domain.ErrInvalidUserStatus
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Limit <= 0 {
+		filter.Limit = domain.DefaultUserPageSize
+	}
+	if filter.Limit > domain.MaxUserPageSize {
+		filter.Limit = domain.MaxUserPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	users, total, err := uc.UserRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.UserPage{Users: users, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// GetUserDetails returns an account with its roles and active sessions
+func (uc *AuthUsecase) GetUserDetails(ctx context.Context, userID int) (*domain.UserDetails, error) {
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &domain.UserDetails{User: user, Roles: []string{}, Permissions: []string{}, Sessions: []*domain.Session{}}
+
+	if uc.RoleRepo != nil {
+		access, err := uc.RoleRepo.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		details.Roles = access.Roles
+		details.Permissions = access.Permissions
+	}
+
+	if uc.SessionRepo != nil {
+		if details.Sessions, err = uc.ListSessions(ctx, user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return details, nil
+}
+
+// DisableUser blocks an account from signing in and signs it out everywhere
+func (uc *AuthUsecase) DisableUser(ctx context.Context, adminID, userID int, reason string, client domain.ClientInfo) error {
+	if adminID == userID {
+		return domain.ErrSelfAdministration
+	}
+
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := uc.UserRepo.SetDisabled(ctx, user.ID, &now); err != nil {
+		return err
+	}
+
+	if err := uc.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditUserDisabled, ActorID: adminID, TargetUserID: user.ID, Details: domain.AuditDetails{"reason": reason}}, client)
+	return nil
+}
+
+// EnableUser lets a disabled account sign in again
+func (uc *AuthUsecase) EnableUser(ctx context.Context, adminID, userID int, client domain.ClientInfo) error {
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.UserRepo.SetDisabled(ctx, user.ID, nil); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditUserEnabled, ActorID: adminID, TargetUserID: user.ID}, client)
+	return nil
+}
+
+// ForcePasswordReset replaces the user's password with a random one, signs
+// them out everywhere and emails a reset link, for accounts whose password
+// is believed to be compromised
+func (uc *AuthUsecase) ForcePasswordReset(ctx context.Context, adminID, userID int, client domain.ClientInfo) error {
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	random, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := uc.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	removedPasskeys, err := uc.removePasskeys(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	token, err := uc.createPasswordResetToken(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Please choose a new password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nAn administrator has reset your password and signed you out everywhere.%s Use the link below to choose a new one. It expires in %d minutes.\n\n%s\n\nYou can request a new link from the login page at any time.\n",
+			user.Name, passkeysRemovedNotice(removedPasskeys), int(uc.PasswordResetTTL.Minutes()), uc.emailLink(uc.PasswordResetURL, "/password/reset", token),
+		),
+	})
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditPasswordResetForced, ActorID: adminID, TargetUserID: user.ID}, client)
+	return nil
+}
+
+// RevokeUserSessions signs a user out of one session, or out of all of them when sessionID is empty
+func (uc *AuthUsecase) RevokeUserSessions(ctx context.Context, adminID, userID int, sessionID string, client domain.ClientInfo) error {
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if sessionID == "" {
+		if err := uc.LogoutAll(ctx, user.ID); err != nil {
+			return err
+		}
+		uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditSessionRevokeAll, ActorID: adminID, TargetUserID: user.ID}, client)
+		return nil
+	}
+
+	revoked, err := uc.endSession(ctx, user.ID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrSessionNotFound
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditSessionRevoke, ActorID: adminID, TargetUserID: user.ID, Details: domain.AuditDetails{"session_id": sessionID}}, client)
+	return nil
+}
+
+// Impersonate issues a short-lived access token that acts as another user, for
+// support staff reproducing a problem. The token names the administrator in
+// its act claim, has no refresh token or session, and is refused by the
+// account management endpoints. Other administrators cannot be impersonated.
+func (uc *AuthUsecase) Impersonate(ctx context.Context, adminID, userID int, client domain.ClientInfo) (*domain.ImpersonationToken, error) {
+	if adminID == userID {
+		return nil, domain.ErrSelfAdministration
+	}
+
+	user, err := uc.adminTarget(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() || user.IsDeleted() {
+		return nil, domain.ErrCannotImpersonate
+	}
+
+	claims := pkg.NewClaims(user.ID, user.Email, uc.ImpersonationTTL)
+	claims.Actor = &pkg.Actor{Subject: strconv.Itoa(adminID), UserID: adminID}
+
+	if uc.RoleRepo != nil {
+		access, err := uc.RoleRepo.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, permission := range access.Permissions {
+			if permission == domain.PermissionUsersManage {
+				return nil, domain.ErrCannotImpersonate
+			}
+		}
+		claims.Roles = access.Roles
+		claims.Permissions = access.Permissions
+	}
+
+	accessToken, err := pkg.SignClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditImpersonate,
+		ActorID:      adminID,
+		TargetUserID: user.ID,
+		Details:      domain.AuditDetails{"jti": claims.ID, "expires_at": claims.ExpiresAtTime().Format(time.RFC3339)},
+	}, client)
+
+	return &domain.ImpersonationToken{
+		AccessToken:    accessToken,
+		TokenType:      "Bearer",
+		ExpiresIn:      int64(uc.ImpersonationTTL.Seconds()),
+		UserID:         user.ID,
+		ImpersonatorID: adminID,
+	}, nil
+}
+
+// adminTarget loads the account an administrator acts on. The deleted user
+// placeholder is not a real account and cannot be managed.
+func (uc *AuthUsecase) adminTarget(ctx context.Context, userID int) (*domain.User, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil || user == nil || user.Email == domain.DeletedUserEmail {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefixBytes is the size of the random key prefix. Prefixes are
+// unique, and at 8 bytes a collision is not a practical concern.
+const apiKeyPrefixBytes = 8
+
+// CreateAPIKey issues a new key for the user. Scopes are limited to chat and
+// the permissions the user holds right now; the raw key is only returned here.
+func (uc *AuthUsecase) CreateAPIKey(ctx context.Context, userID int, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	scopes, err := uc.validateScopes(ctx, userID, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+
+	secret, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rawKey := domain.APIKeyPrefix + "_" + prefix + "_" + secret
+
+	key := &domain.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: pkg.HashToken(rawKey),
+		Scopes:  scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := uc.APIKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatedAPIKey{APIKey: key, Key: rawKey}, nil
+}
+
+// ListAPIKeys returns the user's keys without their secrets
+func (uc *AuthUsecase) ListAPIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	return uc.APIKeyRepo.ListByUser(ctx, userID)
+}
+
+// RevokeAPIKey disables one of the user's keys immediately
+func (uc *AuthUsecase) RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	revoked, err := uc.APIKeyRepo.Revoke(ctx, keyID, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves an X-API-Key header to a principal. The key's
+// permissions are its scopes intersected with what the owner holds today, so
+// removing a role also narrows every key the user created earlier.
+func (uc *AuthUsecase) AuthenticateAPIKey(ctx context.Context, rawKey string) (*domain.Principal, error) {
+	key, err := uc.lookupAPIKey(ctx, rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.apiKeyPrincipal(ctx, key)
+}
+
+// lookupAPIKey finds the key a raw API key belongs to, whether or not it can still be used
+func (uc *AuthUsecase) lookupAPIKey(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	if uc.APIKeyRepo == nil {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	parts := strings.SplitN(rawKey, "_", 3)
+	if len(parts) != 3 || parts[0] != domain.APIKeyPrefix {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	key, err := uc.APIKeyRepo.GetByPrefix(ctx, parts[1])
+	if err != nil || key == nil {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(pkg.HashToken(rawKey))) != 1 {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+// apiKeyPrincipal checks that a key and its owner are active and resolves what the key may do
+func (uc *AuthUsecase) apiKeyPrincipal(ctx context.Context, key *domain.APIKey) (*domain.Principal, error) {
+	if !key.IsActive(time.Now()) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, key.UserID)
+	if err != nil || user == nil || user.IsDeleted() || user.IsDisabled() {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if uc.RequireEmailVerification && !user.IsEmailVerified() {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	// A nil scope list would mean unrestricted, a key always has an explicit list
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	principal := &domain.Principal{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       []string{},
+		Permissions: []string{},
+		Scopes:      scopes,
+		AuthMethod:  domain.AuthMethodAPIKey,
+		APIKeyID:    key.ID,
+	}
+
+	if uc.RoleRepo != nil {
+		access, err := uc.RoleRepo.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		principal.Roles = access.Roles
+		for _, permission := range access.Permissions {
+			if principal.HasScope(permission) {
+				principal.Permissions = append(principal.Permissions, permission)
+			}
+		}
+	}
+
+	if err := uc.APIKeyRepo.TouchLastUsed(ctx, key.ID); err != nil {
+		log.Printf("Failed to record API key %d usage: %v", key.ID, err)
+	}
+
+	return principal, nil
+}
+
+// validateScopes removes duplicates and rejects scopes the user could not use,
+// which include the administration permissions
+func (uc *AuthUsecase) validateScopes(ctx context.Context, userID int, requested []string) ([]string, error) {
+	allowed := map[string]bool{domain.ScopeChat: true}
+	if uc.RoleRepo != nil {
+		access, err := uc.RoleRepo.GetUserAccess(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, permission := range access.Permissions {
+			allowed[permission] = true
+		}
+	}
+	for _, permission := range domain.AdminPermissions {
+		delete(allowed, permission)
+	}
+
+	seen := make(map[string]bool, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if !allowed[scope] {
+			return nil, fmt.Errorf("%w: %s", domain.ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, nil
+}
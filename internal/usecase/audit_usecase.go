@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+)
+
+// QueryAuditEvents returns a page of the audit log, newest first
+func (uc *AuthUsecase) QueryAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	if uc.AuditService == nil {
+		return nil, domain.ErrAuditUnavailable
+	}
+	return uc.AuditService.Query(ctx, filter)
+}
+
+// VerifyAuditLog checks that no audit entry was altered or removed since it was written
+func (uc *AuthUsecase) VerifyAuditLog(ctx context.Context) (*domain.AuditVerification, error) {
+	if uc.AuditService == nil {
+		return nil, domain.ErrAuditUnavailable
+	}
+	return uc.AuditService.Verify(ctx)
+}
@@ -3,28 +3,218 @@ package usecase
 import (
 	"context"
 	"errors"
+	"go-auth-app/config"
 	"go-auth-app/internal/domain"
 	"go-auth-app/internal/repository"
+	"go-auth-app/internal/service"
 	"go-auth-app/pkg"
+	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	defaultAccessTokenTTL   = 15 * time.Minute
+	defaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	defaultPasswordResetTTL = 30 * time.Minute
+	defaultMagicLinkTTL     = 15 * time.Minute
+	// Email verification defaults
+	defaultEmailVerificationTTL = 24 * time.Hour
+	defaultVerificationResend   = time.Minute
+	// Two-factor defaults
+	defaultMFAIssuer   = "go-auth-app"
+	defaultMFATokenTTL = 5 * time.Minute
+	// Login throttling defaults
+	defaultLoginMaxAttempts     = 5
+	defaultLoginIPMaxAttempts   = 50
+	defaultLoginLockoutDuration = 15 * time.Minute
+	defaultLoginBackoffBase     = time.Second
+	defaultLoginBackoffMax      = time.Minute
+	defaultLoginAttemptWindow   = 15 * time.Minute
+	// Account deletion defaults
+	defaultAccountDeletionGrace = 30 * 24 * time.Hour
+	// Administration defaults
+	defaultImpersonationTTL = 15 * time.Minute
+	// External login defaults
+	defaultOIDCStateTTL = 10 * time.Minute
+	// OAuth provider defaults
+	defaultOAuthCodeTTL = time.Minute
+	// Passkey defaults
+	defaultWebAuthnChallengeTTL = 5 * time.Minute
+)
+
+// AuthDependencies are the repositories and services an AuthUsecase works
+// with. Features whose dependency is left nil are disabled or skipped.
+type AuthDependencies struct {
+	UserRepo          repository.UserRepository
+	RefreshTokenRepo  repository.RefreshTokenRepository
+	RevocationRepo    repository.RevocationRepository
+	RevocationService *service.RevocationService
+	UserTokenRepo     repository.UserTokenRepository
+	MFARepo           repository.MFARepository
+	LoginAttemptRepo  repository.LoginAttemptRepository
+	RoleRepo          repository.RoleRepository
+	APIKeyRepo        repository.APIKeyRepository
+	SessionRepo       repository.SessionRepository
+	ChatRepo          repository.ChatRepository
+	OIDCRepo          repository.OIDCRepository
+	OAuthRepo         repository.OAuthRepository
+	WebAuthnRepo      repository.WebAuthnRepository
+	AuditService      *service.AuditService
+	Mailer            pkg.Mailer
+}
+
 type AuthUsecase struct {
-	UserRepo repository.UserRepository
+	AuthDependencies
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+	PasswordResetTTL time.Duration
+	MagicLinkTTL     time.Duration
+	AppBaseURL       string
+	PasswordResetURL string
+	MagicLinkURL     string
+	// Email verification settings
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	VerificationResend       time.Duration
+	// Two-factor settings
+	MFAIssuer   string
+	MFATokenTTL time.Duration
+	// Login throttling settings
+	LoginMaxAttempts     int
+	LoginIPMaxAttempts   int
+	LoginLockoutDuration time.Duration
+	LoginBackoffBase     time.Duration
+	LoginBackoffMax      time.Duration
+	LoginAttemptWindow   time.Duration
+	// AccountDeletionGrace is how long a deleted account can still be restored by logging in
+	AccountDeletionGrace time.Duration
+	// ImpersonationTTL is the lifetime of the tokens administrators use to act as a user
+	ImpersonationTTL time.Duration
+	// OIDCProviders are the external identity providers, by name
+	OIDCProviders map[string]*pkg.OIDCProvider
+	// OIDCStateTTL is how long a user may take to sign in at an identity provider
+	OIDCStateTTL time.Duration
+	// OAuthIssuer is the issuer of the ID tokens this app hands to OAuth clients
+	OAuthIssuer string
+	// OAuthCodeTTL is how long an OAuth client has to redeem an authorization code
+	OAuthCodeTTL time.Duration
+	// WebAuthn verifies passkey ceremonies; nil when no relying party is configured
+	WebAuthn *pkg.RelyingParty
+	// WebAuthnChallengeTTL is how long a browser has to finish a passkey ceremony
+	WebAuthnChallengeTTL time.Duration
+	// AuditHMACKey keys the digests written to the audit log instead of email addresses
+	AuditHMACKey []byte
 }
 
-func NewAuthUsecase(userRepo repository.UserRepository) *AuthUsecase {
-	return &AuthUsecase{UserRepo: userRepo}
+// NewAuthUsecase applies cfg over the defaults; a nil cfg keeps them all
+func NewAuthUsecase(deps AuthDependencies, cfg *config.Config) *AuthUsecase {
+	uc := &AuthUsecase{
+		AuthDependencies: deps,
+		AccessTokenTTL:   defaultAccessTokenTTL,
+		RefreshTokenTTL:  defaultRefreshTokenTTL,
+		PasswordResetTTL: defaultPasswordResetTTL,
+		MagicLinkTTL:     defaultMagicLinkTTL,
+
+		EmailVerificationTTL: defaultEmailVerificationTTL,
+		VerificationResend:   defaultVerificationResend,
+		MFAIssuer:            defaultMFAIssuer,
+		MFATokenTTL:          defaultMFATokenTTL,
+		LoginMaxAttempts:     defaultLoginMaxAttempts,
+		LoginIPMaxAttempts:   defaultLoginIPMaxAttempts,
+		LoginLockoutDuration: defaultLoginLockoutDuration,
+		LoginBackoffBase:     defaultLoginBackoffBase,
+		LoginBackoffMax:      defaultLoginBackoffMax,
+		LoginAttemptWindow:   defaultLoginAttemptWindow,
+		AccountDeletionGrace: defaultAccountDeletionGrace,
+		ImpersonationTTL:     defaultImpersonationTTL,
+		OIDCProviders:        map[string]*pkg.OIDCProvider{},
+		OIDCStateTTL:         defaultOIDCStateTTL,
+		OAuthCodeTTL:         defaultOAuthCodeTTL,
+		WebAuthnChallengeTTL: defaultWebAuthnChallengeTTL,
+	}
+
+	if cfg != nil {
+		if cfg.AccessTokenExpiration > 0 {
+			uc.AccessTokenTTL = time.Duration(cfg.AccessTokenExpiration) * time.Minute
+		}
+		if cfg.RefreshTokenExpiration > 0 {
+			uc.RefreshTokenTTL = time.Duration(cfg.RefreshTokenExpiration) * time.Hour
+		}
+		if cfg.PasswordResetExpiration > 0 {
+			uc.PasswordResetTTL = time.Duration(cfg.PasswordResetExpiration) * time.Minute
+		}
+		if cfg.MagicLinkExpiration > 0 {
+			uc.MagicLinkTTL = time.Duration(cfg.MagicLinkExpiration) * time.Minute
+		}
+		if cfg.EmailVerificationExpiration > 0 {
+			uc.EmailVerificationTTL = time.Duration(cfg.EmailVerificationExpiration) * time.Hour
+		}
+		if cfg.EmailVerificationResendInterval > 0 {
+			uc.VerificationResend = time.Duration(cfg.EmailVerificationResendInterval) * time.Second
+		}
+		if cfg.MFAIssuer != "" {
+			uc.MFAIssuer = cfg.MFAIssuer
+		}
+		if cfg.MFATokenExpiration > 0 {
+			uc.MFATokenTTL = time.Duration(cfg.MFATokenExpiration) * time.Minute
+		}
+		if cfg.LoginMaxAttempts > 0 {
+			uc.LoginMaxAttempts = cfg.LoginMaxAttempts
+		}
+		if cfg.LoginIPMaxAttempts > 0 {
+			uc.LoginIPMaxAttempts = cfg.LoginIPMaxAttempts
+		}
+		if cfg.LoginLockoutDuration > 0 {
+			uc.LoginLockoutDuration = time.Duration(cfg.LoginLockoutDuration) * time.Minute
+		}
+		if cfg.LoginBackoffBase > 0 {
+			uc.LoginBackoffBase = time.Duration(cfg.LoginBackoffBase) * time.Second
+		}
+		if cfg.LoginBackoffMax > 0 {
+			uc.LoginBackoffMax = time.Duration(cfg.LoginBackoffMax) * time.Second
+		}
+		if cfg.LoginAttemptWindow > 0 {
+			uc.LoginAttemptWindow = time.Duration(cfg.LoginAttemptWindow) * time.Minute
+		}
+		if cfg.AccountDeletionGracePeriod > 0 {
+			uc.AccountDeletionGrace = time.Duration(cfg.AccountDeletionGracePeriod) * 24 * time.Hour
+		}
+		if cfg.ImpersonationTokenExpiration > 0 {
+			uc.ImpersonationTTL = time.Duration(cfg.ImpersonationTokenExpiration) * time.Minute
+		}
+		for _, provider := range cfg.OIDCProviders {
+			uc.OIDCProviders[provider.Name] = pkg.NewOIDCProvider(provider.Name, provider.Issuer, provider.ClientID, provider.ClientSecret, provider.RedirectURL, provider.Scopes)
+		}
+		if cfg.OAuthCodeExpiration > 0 {
+			uc.OAuthCodeTTL = time.Duration(cfg.OAuthCodeExpiration) * time.Second
+		}
+		uc.OAuthIssuer = cfg.OAuthIssuerURL
+		if cfg.WebAuthnRPID != "" && len(cfg.WebAuthnOrigins) > 0 {
+			uc.WebAuthn = pkg.NewRelyingParty(cfg.WebAuthnRPID, cfg.WebAuthnRPName, cfg.WebAuthnOrigins)
+		}
+		uc.AppBaseURL = cfg.AppBaseURL
+		uc.PasswordResetURL = cfg.PasswordResetURL
+		uc.MagicLinkURL = cfg.MagicLinkURL
+		uc.AuditHMACKey = []byte(cfg.AuditHMACKey)
+		uc.RequireEmailVerification = cfg.RequireEmailVerification
+	}
+
+	return uc
 }
 
 // Signup-handler
-func (uc *AuthUsecase) Signup(ctx context.Context, user *domain.User) error {
+func (uc *AuthUsecase) Signup(ctx context.Context, user *domain.User, client domain.ClientInfo) error {
+	user.Email = normalizeEmail(user.Email)
 	if err := user.Validate(); err != nil {
 		return err
 	}
 
-	existingUser, _ := uc.UserRepo.GetByEmail(ctx, user.Email)
+	existingUser, err := uc.UserRepo.GetByEmail(ctx, user.Email)
+	if err != nil {
+		return err
+	}
 	if existingUser != nil {
 		return errors.New("user already exists")
 	}
@@ -35,26 +225,433 @@ func (uc *AuthUsecase) Signup(ctx context.Context, user *domain.User) error {
 	}
 	user.Password = string(hashedPassword)
 
-	return uc.UserRepo.Create(ctx, user)
+	if err := uc.UserRepo.Create(ctx, user); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditSignup, ActorID: user.ID}, client)
+	uc.assignDefaultRole(ctx, user.ID)
+
+	// The account exists even if the email cannot be sent, the user can ask for a resend
+	if err := uc.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	return nil
 }
 
-// Login-authenticates a user and returns a JWT token
-func (uc *AuthUsecase) Login(ctx context.Context, email, password string) (string, error) {
+// Login-authenticates a user and returns an access/refresh token pair, or an
+// MFA pending token when the user has two-factor authentication enabled.
+// Failed attempts are throttled per account and per client IP.
+func (uc *AuthUsecase) Login(ctx context.Context, email, password string, client domain.ClientInfo) (*domain.LoginResult, error) {
+	user, err := uc.authenticatePassword(ctx, email, password, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.completeLogin(ctx, user, client)
+}
+
+// authenticatePassword checks the first factor of a login, throttling and
+// auditing failures. Disabled accounts are refused even with the right password.
+func (uc *AuthUsecase) authenticatePassword(ctx context.Context, email, password string, client domain.ClientInfo) (*domain.User, error) {
+	if err := uc.checkLoginThrottle(ctx, email, client); err != nil {
+		return nil, err
+	}
+
 	user, err := uc.UserRepo.GetByEmail(ctx, email)
 
-	if err != nil {
-		return "", errors.New("invalid Email or password")
+	if err != nil || user == nil {
+		uc.recordLoginFailure(ctx, email, client)
+		uc.auditLoginFailure(ctx, email, 0, "unknown_account", client)
+		return nil, domain.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("Invalid Email or password")
+		uc.recordLoginFailure(ctx, email, client)
+		uc.auditLoginFailure(ctx, email, user.ID, "incorrect_password", client)
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	uc.resetLoginFailures(ctx, email)
+
+	if user.IsDisabled() {
+		uc.auditLoginFailure(ctx, email, user.ID, "account_disabled", client)
+		return nil, domain.ErrAccountDisabled
+	}
+
+	return user, nil
+}
+
+// completeLogin finishes signing in a user whose first factor was accepted:
+// it asks for the second factor when one is enrolled, or starts a session
+func (uc *AuthUsecase) completeLogin(ctx context.Context, user *domain.User, client domain.ClientInfo) (*domain.LoginResult, error) {
+	if uc.RequireEmailVerification && !user.IsEmailVerified() {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	mfaRequired, err := uc.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaRequired {
+		mfaToken, err := uc.issueMFAToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := uc.startSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LoginResult{TokenPair: tokens}, nil
+}
+
+// Refresh rotates a refresh token: the presented token is marked as used and a
+// new pair is issued in the same family. Presenting a token that was already
+// rotated revokes the entire family, since one of the two holders is an attacker.
+func (uc *AuthUsecase) Refresh(ctx context.Context, refreshToken string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	stored, err := uc.RefreshTokenRepo.GetByHash(ctx, pkg.HashToken(refreshToken))
+	if err != nil || stored == nil {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		return nil, uc.revokeReusedFamily(ctx, stored, client)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	rotated, err := uc.RefreshTokenRepo.MarkUsed(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request rotated this token between our read and write
+		return nil, uc.revokeReusedFamily(ctx, stored, client)
 	}
 
-	token, err := pkg.GenerateJWT(user.ID, user.Email)
+	user, err := uc.UserRepo.GetByID(ctx, stored.UserID)
+	if err != nil || user == nil || user.IsDisabled() {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	uc.touchSession(ctx, stored.FamilyID, client)
+
+	return uc.issueTokens(ctx, user, stored.FamilyID)
+}
+
+// ValidateAccessToken verifies a JWT and rejects tokens that were revoked by logout
+func (uc *AuthUsecase) ValidateAccessToken(tokenString string) (*pkg.Claims, error) {
+	claims, err := pkg.ValidateJWT(tokenString)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
+	// Restricted tokens, such as MFA pending tokens, never grant API access
+	if claims.Purpose != "" {
+		return nil, errors.New("token cannot be used for API access")
+	}
+
+	if uc.isRevoked(claims) {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return claims, nil
+}
+
+// Logout revokes the presented access token and ends its session. A refresh
+// token, when given, has its family revoked as well.
+func (uc *AuthUsecase) Logout(ctx context.Context, userID int, jti, sessionID string, expiresAt time.Time, refreshToken string) error {
+	if jti == "" {
+		return errors.New("token has no identifier")
+	}
+
+	if err := uc.revokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	if sessionID != "" {
+		if _, err := uc.endSession(ctx, userID, sessionID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		stored, err := uc.RefreshTokenRepo.GetByHash(ctx, pkg.HashToken(refreshToken))
+		if err == nil && stored != nil && stored.UserID == userID {
+			return uc.RefreshTokenRepo.RevokeFamily(ctx, stored.FamilyID)
+		}
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every access and refresh token the user currently holds and ends all sessions
+func (uc *AuthUsecase) LogoutAll(ctx context.Context, userID int) error {
+	if err := uc.revokeAccessTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	if uc.SessionRepo != nil {
+		if err := uc.SessionRepo.RevokeAllForUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return uc.RefreshTokenRepo.RevokeAllForUser(ctx, userID)
+}
+
+// SyncRevocations reloads the revocation cache from the database and prunes expired entries
+func (uc *AuthUsecase) SyncRevocations(ctx context.Context) error {
+	if uc.RevocationRepo == nil || uc.RevocationService == nil {
+		return nil
+	}
+
+	// A user-wide revocation only matters while tokens issued before it can still be valid
+	cutoff := time.Now().Add(-uc.accessTokenLifetime())
+	if err := uc.RevocationRepo.DeleteExpired(ctx, cutoff); err != nil {
+		return err
+	}
+
+	tokens, err := uc.RevocationRepo.GetRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := uc.RevocationRepo.GetUserRevocations(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := uc.revokedSessions(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	uc.RevocationService.Load(tokens, users, sessions)
+	return nil
+}
+
+// StartRevocationSync periodically resynchronises the revocation cache so that
+// instances which missed a NATS broadcast still converge on the database state
+func (uc *AuthUsecase) StartRevocationSync(interval time.Duration) {
+	if err := uc.SyncRevocations(context.Background()); err != nil {
+		log.Printf("Failed to load token revocations: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := uc.SyncRevocations(context.Background()); err != nil {
+				log.Printf("Failed to sync token revocations: %v", err)
+			}
+		}
+	}()
+}
+
+// audit records a security relevant event in the audit log. Actions taken
+// through an impersonation token name the administrator behind them. Without
+// an audit service, as in tests, events only go to the application log.
+func (uc *AuthUsecase) audit(ctx context.Context, event *domain.AuditEvent, client domain.ClientInfo) {
+	event.IP = client.IP
+	event.UserAgent = client.UserAgent
+	event.ImpersonatorID = client.ImpersonatorID
+
+	if uc.AuditService == nil {
+		log.Printf("AUDIT action=%s outcome=%s actor_id=%d target_user_id=%d impersonator_id=%d ip=%s user_agent=%q details=%v",
+			event.Action, event.Outcome, event.ActorID, event.TargetUserID, event.ImpersonatorID, event.IP, event.UserAgent, event.Details)
+		return
+	}
+
+	// Record logs events it could not store, the action itself has already happened
+	_ = uc.AuditService.Record(ctx, event)
+}
+
+// isRevoked reports whether a token was revoked individually, with its session
+// or by a user-wide logout. Impersonation tokens also die with a user-wide
+// logout of the administrator who obtained them.
+func (uc *AuthUsecase) isRevoked(claims *pkg.Claims) bool {
+	if uc.RevocationService == nil {
+		return false
+	}
+
+	if uc.RevocationService.IsRevoked(claims.ID, claims.SessionID, claims.UserID, claims.IssuedAtTime()) {
+		return true
+	}
+
+	return claims.Actor != nil && uc.RevocationService.IsRevoked("", "", claims.Actor.UserID, claims.IssuedAtTime())
+}
+
+// revokeToken persists a single token revocation and broadcasts it to the other instances
+func (uc *AuthUsecase) revokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	if err := uc.RevocationRepo.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+	if uc.RevocationService != nil {
+		uc.RevocationService.RevokeToken(jti, userID, expiresAt)
+	}
+	return nil
+}
+
+// accessTokenLifetime is the longest an access token of any kind stays valid.
+// Impersonation tokens may outlive regular ones, so revocations are kept until
+// either kind issued before them has expired.
+func (uc *AuthUsecase) accessTokenLifetime() time.Duration {
+	if uc.ImpersonationTTL > uc.AccessTokenTTL {
+		return uc.ImpersonationTTL
+	}
+	return uc.AccessTokenTTL
+}
+
+// revokeAccessTokens invalidates every access token issued to the user so far.
+// The cutoff has the millisecond precision of token issue times, so tokens
+// issued right after it keep working.
+func (uc *AuthUsecase) revokeAccessTokens(ctx context.Context, userID int) error {
+	now := time.Now().Truncate(time.Millisecond)
+
+	if err := uc.RevocationRepo.RevokeAllForUser(ctx, userID, now); err != nil {
+		return err
+	}
+	if uc.RevocationService != nil {
+		uc.RevocationService.RevokeUser(userID, now)
+	}
+
+	return nil
+}
+
+// startSession records a new session for the client and issues the first
+// token pair of its refresh token family. Signing in to an account that is
+// scheduled for deletion cancels the deletion.
+func (uc *AuthUsecase) startSession(ctx context.Context, user *domain.User, client domain.ClientInfo) (*domain.TokenPair, error) {
+	if user.IsDisabled() {
+		return nil, domain.ErrAccountDisabled
+	}
+
+	if user.IsDeleted() {
+		if err := uc.restoreAccount(ctx, user, client); err != nil {
+			return nil, err
+		}
+	}
+
+	familyID, err := pkg.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.createSession(ctx, familyID, user.ID, client); err != nil {
+		return nil, err
+	}
+
+	tokens, err := uc.issueTokens(ctx, user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := domain.AuditDetails{"session_id": familyID}
+	if client.IdentityProvider != "" {
+		details["provider"] = client.IdentityProvider
+	}
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: user.ID, Details: details}, client)
+	return tokens, nil
+}
+
+// assignDefaultRole gives a new account the user role. A missing role only
+// withholds permissions, the migrations backfill it on the next start.
+func (uc *AuthUsecase) assignDefaultRole(ctx context.Context, userID int) {
+	if uc.RoleRepo == nil {
+		return
+	}
+	if err := uc.RoleRepo.AssignRole(ctx, userID, domain.RoleUser); err != nil {
+		log.Printf("Failed to assign the default role to user %d: %v", userID, err)
+	}
+}
+
+// auditLoginFailure records a rejected login. A digest of the email is kept
+// even for unknown accounts, to spot credential stuffing.
+func (uc *AuthUsecase) auditLoginFailure(ctx context.Context, email string, userID int, reason string, client domain.ClientInfo) {
+	details := domain.AuditDetails{"reason": reason}
+	if email != "" {
+		details["email_hmac"] = uc.auditEmail(email)
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditLoginFailure,
+		Outcome:      domain.AuditOutcomeFailure,
+		TargetUserID: userID,
+		Details:      details,
+	}, client)
+}
+
+// auditEmail stands in for an email address in the audit log. Entries can
+// never be changed, so a plain address would outlive the erasure of the
+// account; the keyed digest still lets the same address be recognised.
+func (uc *AuthUsecase) auditEmail(email string) string {
+	return pkg.KeyedHash(uc.AuditHMACKey, normalizeEmail(email))
+}
+
+// revokeReusedFamily revokes every token in the family of a replayed refresh token
+func (uc *AuthUsecase) revokeReusedFamily(ctx context.Context, token *domain.RefreshToken, client domain.ClientInfo) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking family %s", token.UserID, token.FamilyID)
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditRefreshTokenReuse,
+		Outcome:      domain.AuditOutcomeFailure,
+		TargetUserID: token.UserID,
+		Details:      domain.AuditDetails{"session_id": token.FamilyID},
+	}, client)
+	if err := uc.RefreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return domain.ErrRefreshTokenReused
+}
+
+// issueTokens signs a short-lived access token and stores a new refresh token in the given family
+func (uc *AuthUsecase) issueTokens(ctx context.Context, user *domain.User, familyID string) (*domain.TokenPair, error) {
+	claims := pkg.NewClaims(user.ID, user.Email, uc.AccessTokenTTL)
+	claims.SessionID = familyID
+	if uc.RoleRepo != nil {
+		access, err := uc.RoleRepo.GetUserAccess(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		claims.Roles = access.Roles
+		claims.Permissions = access.Permissions
+	}
+
+	accessToken, err := pkg.SignClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.RefreshTokenRepo.Create(ctx, &domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: pkg.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(uc.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return &domain.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(uc.AccessTokenTTL.Seconds()),
+	}, nil
 }
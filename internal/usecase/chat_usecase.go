@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"errors"
+	"go-auth-app/config"
 	"go-auth-app/internal/domain"
 	"go-auth-app/internal/repository"
 	"go-auth-app/internal/service"
@@ -14,6 +15,12 @@ type ChatUsecase struct {
 	ChatRepo    repository.ChatRepository
 	UserRepo    repository.UserRepository
 	NatsService *service.NATSService
+	// Typing tracks typing indicators, nil without NATS
+	Typing *service.TypingService
+	// Presence tracks who is online, nil without NATS
+	Presence *service.PresenceService
+	// RequireVerifiedEmail rejects messages from unverified senders
+	RequireVerifiedEmail bool
 }
 
 // NewChatUsecase creates a new instance of ChatUsecase
@@ -21,12 +28,24 @@ func NewChatUsecase(
 	chatRepo repository.ChatRepository,
 	userRepo repository.UserRepository,
 	natsService *service.NATSService,
+	cfg *config.Config,
 ) *ChatUsecase {
-	return &ChatUsecase{
+	uc := &ChatUsecase{
 		ChatRepo:    chatRepo,
 		UserRepo:    userRepo,
 		NatsService: natsService,
 	}
+
+	if natsService != nil {
+		uc.Typing = service.NewTypingService(natsService)
+		uc.Presence = service.NewPresenceService(userRepo, natsService.Client)
+	}
+
+	if cfg != nil {
+		uc.RequireVerifiedEmail = cfg.RequireEmailVerification
+	}
+
+	return uc
 }
 
 // SendMessage sends a message from one user to another
@@ -36,21 +55,33 @@ func (uc *ChatUsecase) SendMessage(ctx context.Context, senderID int, receiverID
 		return nil, err
 	}
 
+	if err := uc.checkSender(ctx, senderID); err != nil {
+		return nil, err
+	}
+
 	// Check if receiver exists
 	receiver, err := uc.UserRepo.GetByID(ctx, receiverID)
 	if err != nil {
 		return nil, errors.New("receiver not found")
 	}
 
-	if receiver == nil {
+	// Accounts being deleted, and the placeholder of purged ones, cannot be written to
+	if receiver == nil || receiver.IsDeleted() {
 		return nil, errors.New("receiver not found")
 	}
 
+	// The message is filed under the direct conversation of the two users
+	conversation, err := uc.ChatRepo.GetOrCreateConversation(ctx, senderID, receiverID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create message object
 	message := &domain.Message{
-		SenderID:   senderID,
-		ReceiverID: receiverID,
-		Content:    content,
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		ReceiverID:     receiverID,
+		Content:        content,
 	}
 
 	// Save message to database
@@ -59,17 +90,14 @@ func (uc *ChatUsecase) SendMessage(ctx context.Context, senderID int, receiverID
 		return nil, err
 	}
 
-	// Update conversation
-	conversation, err := uc.ChatRepo.GetOrCreateConversation(ctx, senderID, receiverID)
-	if err != nil {
-		return nil, err
-	}
-
 	err = uc.ChatRepo.UpdateConversation(ctx, conversation.ID, content)
 	if err != nil {
 		return nil, err
 	}
 
+	// Sending the message ends the typing indicator
+	uc.StopTyping(senderID, conversation.ID)
+
 	// Publish message to NATS
 	if uc.NatsService != nil {
 		err = uc.NatsService.PublishChatMessage(message)
@@ -82,6 +110,22 @@ func (uc *ChatUsecase) SendMessage(ctx context.Context, senderID int, receiverID
 	return message, nil
 }
 
+// checkSender rejects unverified senders when the email must be verified to chat
+func (uc *ChatUsecase) checkSender(ctx context.Context, senderID int) error {
+	if !uc.RequireVerifiedEmail {
+		return nil
+	}
+
+	sender, err := uc.UserRepo.GetByID(ctx, senderID)
+	if err != nil || sender == nil {
+		return errors.New("sender not found")
+	}
+	if !sender.IsEmailVerified() {
+		return domain.ErrEmailNotVerified
+	}
+	return nil
+}
+
 // GetConversationMessages retrieves messages between two users
 func (uc *ChatUsecase) GetConversationMessages(ctx context.Context, user1ID int, user2ID int, limit int, offset int) ([]*domain.Message, error) {
 	// Check if user2 exists
@@ -90,7 +134,19 @@ func (uc *ChatUsecase) GetConversationMessages(ctx context.Context, user1ID int,
 		return nil, errors.New("user not found")
 	}
 
-	// Set default pagination values
+	limit, offset = messagePage(limit, offset)
+
+	// Get messages
+	messages, err := uc.ChatRepo.GetMessagesByConversation(ctx, user1ID, user2ID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// messagePage applies the default and maximum page size of message listings
+func messagePage(limit, offset int) (int, int) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -100,14 +156,7 @@ func (uc *ChatUsecase) GetConversationMessages(ctx context.Context, user1ID int,
 	if offset < 0 {
 		offset = 0
 	}
-
-	// Get messages
-	messages, err := uc.ChatRepo.GetMessagesByConversation(ctx, user1ID, user2ID, limit, offset)
-	if err != nil {
-		return nil, err
-	}
-
-	return messages, nil
+	return limit, offset
 }
 
 // GetUserConversations retrieves all conversations for a user
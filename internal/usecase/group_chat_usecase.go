@@ -0,0 +1,353 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"log"
+)
+
+// CreateGroup starts a group conversation owned by its creator, with the
+// given users as members
+func (uc *ChatUsecase) CreateGroup(ctx context.Context, ownerID int, name string, memberIDs []int) (*domain.Conversation, error) {
+	name, err := domain.NormalizeGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.checkSender(ctx, ownerID); err != nil {
+		return nil, err
+	}
+
+	memberIDs, err = uc.newMemberIDs(ctx, memberIDs, map[int]bool{ownerID: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(memberIDs)+1 > domain.MaxGroupMembers {
+		return nil, domain.ErrGroupTooLarge
+	}
+
+	conversation := &domain.Conversation{Name: name, CreatedBy: ownerID}
+	if err := uc.ChatRepo.CreateGroup(ctx, conversation, memberIDs); err != nil {
+		return nil, err
+	}
+
+	if conversation.Members, err = uc.ChatRepo.ListMembers(ctx, conversation.ID); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// GetConversation returns a conversation of the user with its members
+func (uc *ChatUsecase) GetConversation(ctx context.Context, userID, conversationID int) (*domain.Conversation, error) {
+	conversation, _, err := uc.membership(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.Members, err = uc.ChatRepo.ListMembers(ctx, conversationID); err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// RenameGroup changes the name of a group. Owners and admins may rename it.
+func (uc *ChatUsecase) RenameGroup(ctx context.Context, userID, conversationID int, name string) (*domain.Conversation, error) {
+	name, err := domain.NormalizeGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	conversation, _, err := uc.groupManager(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.ChatRepo.RenameConversation(ctx, conversationID, name); err != nil {
+		return nil, err
+	}
+
+	conversation.Name = name
+	return conversation, nil
+}
+
+// AddGroupMembers adds users to a group and returns the new memberships.
+// Owners and admins may add members; users who already belong are skipped.
+func (uc *ChatUsecase) AddGroupMembers(ctx context.Context, userID, conversationID int, userIDs []int) ([]*domain.ConversationMember, error) {
+	if _, _, err := uc.groupManager(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[int]bool, len(members))
+	for _, member := range members {
+		existing[member.UserID] = true
+	}
+
+	userIDs, err = uc.newMemberIDs(ctx, userIDs, existing)
+	if err != nil {
+		return nil, err
+	}
+	if len(members)+len(userIDs) > domain.MaxGroupMembers {
+		return nil, domain.ErrGroupTooLarge
+	}
+	if len(userIDs) == 0 {
+		return []*domain.ConversationMember{}, nil
+	}
+
+	return uc.ChatRepo.AddMembers(ctx, conversationID, userIDs)
+}
+
+// RemoveGroupMember takes a member out of a group. The owner may remove
+// anyone, admins only plain members. Removing yourself is leaving.
+func (uc *ChatUsecase) RemoveGroupMember(ctx context.Context, userID, conversationID, memberID int) error {
+	if memberID == userID {
+		return uc.LeaveGroup(ctx, userID, conversationID)
+	}
+
+	_, actor, err := uc.groupManager(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	target, err := uc.ChatRepo.GetMember(ctx, conversationID, memberID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return domain.ErrNotConversationMember
+	}
+
+	if target.Role == domain.ConversationRoleOwner || (target.Role == domain.ConversationRoleAdmin && actor.Role != domain.ConversationRoleOwner) {
+		return domain.ErrConversationForbidden
+	}
+
+	_, err = uc.ChatRepo.RemoveMember(ctx, conversationID, memberID)
+	return err
+}
+
+// LeaveGroup takes the user out of a group. An owner who leaves hands the
+// group to the longest-standing admin, or member if there is none, and the
+// group is deleted when its last member leaves.
+func (uc *ChatUsecase) LeaveGroup(ctx context.Context, userID, conversationID int) error {
+	conversation, member, err := uc.membership(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !conversation.IsGroup() {
+		return domain.ErrNotGroupConversation
+	}
+
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	var successor *domain.ConversationMember
+	for _, other := range members {
+		if other.UserID == userID {
+			continue
+		}
+		if successor == nil || (other.Role == domain.ConversationRoleAdmin && successor.Role != domain.ConversationRoleAdmin) {
+			successor = other
+		}
+	}
+
+	if successor == nil {
+		return uc.ChatRepo.DeleteConversation(ctx, conversationID)
+	}
+
+	if member.Role == domain.ConversationRoleOwner {
+		if err := uc.ChatRepo.TransferOwnership(ctx, conversationID, userID, successor.UserID); err != nil {
+			return err
+		}
+	}
+
+	_, err = uc.ChatRepo.RemoveMember(ctx, conversationID, userID)
+	return err
+}
+
+// SetGroupMemberRole changes the role of a member. Only the owner hands out
+// roles; making someone else the owner hands the group over.
+func (uc *ChatUsecase) SetGroupMemberRole(ctx context.Context, userID, conversationID, memberID int, role string) error {
+	conversation, actor, err := uc.membership(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !conversation.IsGroup() {
+		return domain.ErrNotGroupConversation
+	}
+
+	// The owner steps down by handing the group to someone else
+	if actor.Role != domain.ConversationRoleOwner || memberID == userID {
+		return domain.ErrConversationForbidden
+	}
+
+	target, err := uc.ChatRepo.GetMember(ctx, conversationID, memberID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return domain.ErrNotConversationMember
+	}
+
+	if role == domain.ConversationRoleOwner {
+		return uc.ChatRepo.TransferOwnership(ctx, conversationID, userID, memberID)
+	}
+
+	_, err = uc.ChatRepo.SetMemberRole(ctx, conversationID, memberID, role)
+	return err
+}
+
+// SendConversationMessage sends a message to a conversation of the sender.
+// Messages to a direct conversation are sent as to the other participant.
+func (uc *ChatUsecase) SendConversationMessage(ctx context.Context, senderID, conversationID int, content string) (*domain.Message, error) {
+	if err := domain.ValidateMessage(content); err != nil {
+		return nil, err
+	}
+
+	conversation, _, err := uc.membership(ctx, conversationID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conversation.IsGroup() {
+		receiverID := conversation.User1ID
+		if receiverID == senderID {
+			receiverID = conversation.User2ID
+		}
+		return uc.SendMessage(ctx, senderID, receiverID, content)
+	}
+
+	if err := uc.checkSender(ctx, senderID); err != nil {
+		return nil, err
+	}
+
+	message := &domain.Message{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        content,
+	}
+
+	if err := uc.ChatRepo.SaveMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	if err := uc.ChatRepo.UpdateConversation(ctx, conversationID, content); err != nil {
+		return nil, err
+	}
+
+	// Sending the message ends the typing indicator
+	uc.StopTyping(senderID, conversationID)
+
+	// Publish message to NATS for every member's connections
+	if uc.NatsService != nil {
+		memberIDs, err := uc.memberIDs(ctx, conversationID)
+		if err != nil {
+			log.Printf("Failed to list members of conversation %d: %v", conversationID, err)
+			return message, nil
+		}
+
+		if err := uc.NatsService.PublishGroupMessage(message, memberIDs); err != nil {
+			// Log error but don't fail the operation
+			log.Printf("Failed to publish message to NATS: %v", err)
+		}
+	}
+
+	return message, nil
+}
+
+// GetConversationMessagesByID retrieves the messages of a conversation of the
+// user, newest first. Messages they deleted for themselves are left out.
+func (uc *ChatUsecase) GetConversationMessagesByID(ctx context.Context, userID, conversationID, limit, offset int) ([]*domain.Message, error) {
+	if _, _, err := uc.membership(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	limit, offset = messagePage(limit, offset)
+	return uc.ChatRepo.GetMessagesByConversationID(ctx, conversationID, userID, limit, offset)
+}
+
+// membership returns a conversation and the user's membership of it. Users
+// who are not members are told the conversation does not exist.
+func (uc *ChatUsecase) membership(ctx context.Context, conversationID, userID int) (*domain.Conversation, *domain.ConversationMember, error) {
+	member, err := uc.ChatRepo.GetMember(ctx, conversationID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member == nil {
+		return nil, nil, domain.ErrConversationNotFound
+	}
+
+	conversation, err := uc.ChatRepo.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if conversation == nil {
+		return nil, nil, domain.ErrConversationNotFound
+	}
+
+	return conversation, member, nil
+}
+
+// memberIDs returns the user IDs of the members of a conversation
+func (uc *ChatUsecase) memberIDs(ctx context.Context, conversationID int) ([]int, error) {
+	members, err := uc.ChatRepo.ListMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+	return ids, nil
+}
+
+// groupManager is membership for changes only owners and admins of a group may make
+func (uc *ChatUsecase) groupManager(ctx context.Context, conversationID, userID int) (*domain.Conversation, *domain.ConversationMember, error) {
+	conversation, member, err := uc.membership(ctx, conversationID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !conversation.IsGroup() {
+		return nil, nil, domain.ErrNotGroupConversation
+	}
+	if !member.CanManageMembers() {
+		return nil, nil, domain.ErrConversationForbidden
+	}
+	return conversation, member, nil
+}
+
+// newMemberIDs removes duplicates and the skipped users from the IDs and
+// checks that the remaining accounts exist and can be written to
+func (uc *ChatUsecase) newMemberIDs(ctx context.Context, userIDs []int, skip map[int]bool) ([]int, error) {
+	seen := make(map[int]bool, len(userIDs))
+	ids := make([]int, 0, len(userIDs))
+
+	for _, id := range userIDs {
+		if skip[id] || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		// Stop looking users up once the group could not hold them anyway
+		if len(ids) >= domain.MaxGroupMembers {
+			return nil, domain.ErrGroupTooLarge
+		}
+
+		user, err := uc.UserRepo.GetByID(ctx, id)
+		if err != nil || user == nil || user.IsDeleted() {
+			return nil, fmt.Errorf("%w: %d", domain.ErrUserNotFound, id)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
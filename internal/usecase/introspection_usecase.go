@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"strconv"
+	"strings"
+)
+
+// IntrospectToken tells a service whether a token it was handed, a JWT or
+// an API key, is active and who it belongs to (RFC 7662), so the service can
+// check callers without holding the signing keys. Only confidential OAuth
+// clients may ask; client authentication failures are an *domain.OAuthError.
+func (uc *AuthUsecase) IntrospectToken(ctx context.Context, req *domain.IntrospectionRequest) (*domain.TokenIntrospection, error) {
+	oauthClient, err := uc.authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if oauthClient.Public {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidClient, "public clients cannot introspect tokens")
+	}
+
+	if req.Token == "" {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidRequest, "token is required")
+	}
+
+	// API keys are recognisable by their prefix, the hint is not needed
+	if strings.HasPrefix(req.Token, domain.APIKeyPrefix+"_") {
+		return uc.introspectAPIKey(ctx, req.Token)
+	}
+	return uc.introspectJWT(ctx, req.Token)
+}
+
+// introspectJWT describes an access token, including tokens issued to OAuth clients
+func (uc *AuthUsecase) introspectJWT(ctx context.Context, token string) (*domain.TokenIntrospection, error) {
+	inactive := &domain.TokenIntrospection{Active: false}
+
+	claims, err := pkg.ValidateJWT(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	// MFA pending tokens only prove half a login
+	if claims.Purpose != "" && claims.Purpose != pkg.TokenPurposeOAuth {
+		return inactive, nil
+	}
+
+	if uc.isRevoked(claims) {
+		return &domain.TokenIntrospection{Active: false, Revoked: true}, nil
+	}
+
+	if claims.Purpose == pkg.TokenPurposeOAuth {
+		if uc.OAuthRepo == nil {
+			return inactive, nil
+		}
+		consent, err := uc.OAuthRepo.GetConsent(ctx, claims.UserID, claims.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if consent == nil || !consent.Covers(strings.Fields(claims.Scope)) {
+			return &domain.TokenIntrospection{Active: false, Revoked: true}, nil
+		}
+	}
+
+	introspection := &domain.TokenIntrospection{
+		Active:      true,
+		TokenType:   domain.TokenTypeAccessToken,
+		Scope:       claims.Scope,
+		ClientID:    claims.ClientID,
+		Username:    claims.Email,
+		Subject:     claims.Subject,
+		Audience:    claims.Audience,
+		Issuer:      claims.Issuer,
+		TokenID:     claims.ID,
+		ExpiresAt:   claims.ExpiresAt,
+		IssuedAt:    claims.IssuedAt,
+		NotBefore:   claims.NotBefore,
+		UserID:      claims.UserID,
+		SessionID:   claims.SessionID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}
+	if claims.Actor != nil {
+		introspection.Actor = &domain.IntrospectionActor{Subject: claims.Actor.Subject, UserID: claims.Actor.UserID}
+	}
+
+	return introspection, nil
+}
+
+// introspectAPIKey describes an API key with the permissions it grants today
+func (uc *AuthUsecase) introspectAPIKey(ctx context.Context, rawKey string) (*domain.TokenIntrospection, error) {
+	inactive := &domain.TokenIntrospection{Active: false}
+
+	key, err := uc.lookupAPIKey(ctx, rawKey)
+	if err != nil {
+		return inactive, nil
+	}
+	if key.RevokedAt != nil {
+		return &domain.TokenIntrospection{Active: false, Revoked: true}, nil
+	}
+
+	principal, err := uc.apiKeyPrincipal(ctx, key)
+	if errors.Is(err, domain.ErrInvalidAPIKey) || errors.Is(err, domain.ErrEmailNotVerified) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	introspection := &domain.TokenIntrospection{
+		Active:      true,
+		TokenType:   domain.TokenTypeAPIKey,
+		Scope:       strings.Join(principal.Scopes, " "),
+		Username:    principal.Email,
+		Subject:     strconv.Itoa(principal.UserID),
+		TokenID:     strconv.Itoa(key.ID),
+		IssuedAt:    key.CreatedAt.Unix(),
+		UserID:      principal.UserID,
+		Roles:       principal.Roles,
+		Permissions: principal.Permissions,
+	}
+	if key.ExpiresAt != nil {
+		introspection.ExpiresAt = key.ExpiresAt.Unix()
+	}
+
+	return introspection, nil
+}
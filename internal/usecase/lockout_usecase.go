@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"log"
+	"strings"
+	"time"
+)
+
+// throttleKey identifies one failed login counter
+type throttleKey struct {
+	scope string
+	key   string
+	limit int
+}
+
+// UnlockAccount lifts an account lockout before it expires. It is meant for
+// administrators helping a user who locked themselves out.
+func (uc *AuthUsecase) UnlockAccount(ctx context.Context, email string, adminID int, client domain.ClientInfo) error {
+	if uc.LoginAttemptRepo == nil {
+		return nil
+	}
+
+	if err := uc.LoginAttemptRepo.Reset(ctx, domain.LoginScopeAccount, normalizeEmail(email)); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditLoginUnlock, ActorID: adminID, Details: domain.AuditDetails{"email_hmac": uc.auditEmail(email)}}, client)
+	return nil
+}
+
+// StartLoginAttemptCleanup periodically removes counters that no longer affect logins
+func (uc *AuthUsecase) StartLoginAttemptCleanup(interval time.Duration) {
+	if uc.LoginAttemptRepo == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := uc.LoginAttemptRepo.DeleteStale(context.Background(), time.Now().Add(-uc.LoginAttemptWindow)); err != nil {
+				log.Printf("Failed to clean up login attempts: %v", err)
+			}
+		}
+	}()
+}
+
+// checkLoginThrottle rejects the attempt while the account or the client IP is backed off or locked.
+// It runs before the password is checked so a locked account gives nothing away.
+func (uc *AuthUsecase) checkLoginThrottle(ctx context.Context, email string, client domain.ClientInfo) error {
+	if uc.LoginAttemptRepo == nil {
+		return nil
+	}
+
+	now := time.Now()
+	for _, k := range uc.throttleKeys(email, client) {
+		attempt, err := uc.LoginAttemptRepo.Get(ctx, k.scope, k.key)
+		if err != nil {
+			return err
+		}
+
+		if attempt != nil && attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			return &domain.LoginThrottledError{RetryAfter: attempt.LockedUntil.Sub(now).Round(time.Second)}
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed login for the account and the client IP.
+// Each failure delays the next attempt exponentially, and reaching the limit
+// locks the account or IP for the lockout duration.
+func (uc *AuthUsecase) recordLoginFailure(ctx context.Context, email string, client domain.ClientInfo) {
+	if uc.LoginAttemptRepo == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, k := range uc.throttleKeys(email, client) {
+		attempt, err := uc.LoginAttemptRepo.RecordFailure(ctx, k.scope, k.key, now.Add(-uc.LoginAttemptWindow))
+		if err != nil {
+			log.Printf("Failed to record login failure for %s %s: %v", k.scope, k.key, err)
+			continue
+		}
+
+		var delay time.Duration
+		if k.limit > 0 && attempt.Failures >= k.limit {
+			delay = uc.LoginLockoutDuration
+			details := domain.AuditDetails{k.scope: k.key}
+			if k.scope == domain.LoginScopeAccount {
+				details = domain.AuditDetails{"email_hmac": uc.auditEmail(k.key)}
+			}
+			uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditLoginLockout, Details: details}, client)
+		} else {
+			delay = uc.loginBackoff(attempt.Failures)
+		}
+
+		if delay <= 0 {
+			continue
+		}
+		if err := uc.LoginAttemptRepo.Lock(ctx, k.scope, k.key, now.Add(delay)); err != nil {
+			log.Printf("Failed to lock %s %s: %v", k.scope, k.key, err)
+		}
+	}
+}
+
+// resetLoginFailures clears the account counter after a successful login. The
+// IP counter is left to expire, otherwise an attacker could reset it by logging
+// into an account of their own between guesses.
+func (uc *AuthUsecase) resetLoginFailures(ctx context.Context, email string) {
+	if uc.LoginAttemptRepo == nil {
+		return
+	}
+
+	if err := uc.LoginAttemptRepo.Reset(ctx, domain.LoginScopeAccount, normalizeEmail(email)); err != nil {
+		log.Printf("Failed to reset login failures for %s: %v", email, err)
+	}
+}
+
+// loginBackoff returns the delay after the given number of consecutive failures
+func (uc *AuthUsecase) loginBackoff(failures int) time.Duration {
+	if uc.LoginBackoffBase <= 0 || failures <= 0 {
+		return 0
+	}
+
+	delay := uc.LoginBackoffBase
+	for i := 1; i < failures && delay < uc.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	if uc.LoginBackoffMax > 0 && delay > uc.LoginBackoffMax {
+		delay = uc.LoginBackoffMax
+	}
+
+	return delay
+}
+
+// throttleKeys lists the counters that apply to a login attempt
+func (uc *AuthUsecase) throttleKeys(email string, client domain.ClientInfo) []throttleKey {
+	keys := []throttleKey{{scope: domain.LoginScopeAccount, key: normalizeEmail(email), limit: uc.LoginMaxAttempts}}
+	if client.IP != "" {
+		keys = append(keys, throttleKey{scope: domain.LoginScopeIP, key: client.IP, limit: uc.LoginIPMaxAttempts})
+	}
+	return keys
+}
+
+// normalizeEmail makes counters independent of how the address was typed
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
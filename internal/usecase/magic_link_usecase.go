@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"time"
+)
+
+// RequestMagicLink emails a single-use sign-in link. Like ForgotPassword it
+// never reveals whether the address is registered, and requests inside the
+// verification resend interval are dropped silently so the endpoint cannot
+// flood an inbox. Only the most recent link stays valid.
+func (uc *AuthUsecase) RequestMagicLink(ctx context.Context, email string, client domain.ClientInfo) error {
+	user, err := uc.UserRepo.GetByEmail(ctx, normalizeEmail(email))
+	if err != nil || user == nil || user.IsDisabled() {
+		return nil
+	}
+
+	latest, err := uc.UserTokenRepo.GetLatestForUser(ctx, user.ID, domain.TokenPurposeMagicLink)
+	if err == nil && latest != nil && time.Since(latest.CreatedAt) < uc.VerificationResend {
+		log.Printf("Throttled sign-in link for user %d", user.ID)
+		return nil
+	}
+
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposeMagicLink); err != nil {
+		return err
+	}
+
+	token, err := uc.createUserToken(ctx, user.ID, domain.TokenPurposeMagicLink, uc.MagicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to sign in. It expires in %d minutes and can only be used once.\n\n%s\n\nThe link was requested from %s. If this was not you, you can ignore this email.\n",
+			user.Name, int(uc.MagicLinkTTL.Minutes()), uc.emailLink(uc.MagicLinkURL, "/login/magic/verify", token), client.IP,
+		),
+	})
+
+	return nil
+}
+
+// VerifyMagicLink signs in with the token of a sign-in link. The link proves
+// the user controls the address, so an unverified email becomes verified. It
+// only replaces the password: users with two-factor authentication still get
+// an MFA token, as on /login.
+func (uc *AuthUsecase) VerifyMagicLink(ctx context.Context, token string, client domain.ClientInfo) (*domain.LoginResult, error) {
+	client.IdentityProvider = domain.MagicLinkIdentityProvider
+
+	stored, err := uc.consumeUserToken(ctx, token, domain.TokenPurposeMagicLink)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, stored.UserID)
+	if err != nil || user == nil {
+		return nil, domain.ErrInvalidUserToken
+	}
+
+	if user.IsDisabled() {
+		uc.auditLoginFailure(ctx, user.Email, user.ID, "account_disabled", client)
+		return nil, domain.ErrAccountDisabled
+	}
+
+	if !user.IsEmailVerified() {
+		if err := uc.UserRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return uc.completeLogin(ctx, user, client)
+}
@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"log"
+	"time"
+)
+
+// EditMessage replaces the content of a message. Only its sender can edit it,
+// and the previous version is kept as a revision. The other members are told
+// over NATS.
+func (uc *ChatUsecase) EditMessage(ctx context.Context, userID, conversationID, messageID int, content string) (*domain.Message, error) {
+	if err := domain.ValidateMessage(content); err != nil {
+		return nil, err
+	}
+
+	message, err := uc.conversationMessage(ctx, userID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, domain.ErrNotMessageSender
+	}
+	if message.IsDeleted() {
+		return nil, domain.ErrMessageDeleted
+	}
+
+	// Saving the same text again would only add an empty revision
+	if message.Content == content {
+		return message, nil
+	}
+
+	edited, err := uc.ChatRepo.EditMessage(ctx, messageID, content, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if edited == nil {
+		return nil, domain.ErrMessageDeleted
+	}
+
+	uc.publishMessageUpdate(ctx, conversationID, userID, &service.MessageUpdate{Edited: edited})
+	return edited, nil
+}
+
+// DeleteMessage deletes a message for the user only, or for everyone. Any
+// member can hide a message from themselves; deleting for everyone is up to
+// the sender, leaves a tombstone in place of the message and drops its
+// revisions. Deleting a message twice is not an error.
+func (uc *ChatUsecase) DeleteMessage(ctx context.Context, userID, conversationID, messageID int, scope string) (*domain.MessageDeletion, error) {
+	if scope == "" {
+		scope = domain.DeleteForMe
+	}
+	if scope != domain.DeleteForMe && scope != domain.DeleteForEveryone {
+		return nil, domain.ErrInvalidDeleteScope
+	}
+
+	message, err := uc.conversationMessage(ctx, userID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	deletion := &domain.MessageDeletion{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		For:            scope,
+		DeletedAt:      time.Now(),
+	}
+
+	if scope == domain.DeleteForMe {
+		if err := uc.ChatRepo.HideMessage(ctx, messageID, userID, deletion.DeletedAt); err != nil {
+			return nil, err
+		}
+		return deletion, nil
+	}
+
+	if message.SenderID != userID {
+		return nil, domain.ErrNotMessageSender
+	}
+	if message.IsDeleted() {
+		deletion.DeletedAt = *message.DeletedAt
+		return deletion, nil
+	}
+
+	deleted, err := uc.ChatRepo.DeleteMessage(ctx, messageID, deletion.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deleted == nil {
+		// Another device of the sender deleted it in the meantime
+		return deletion, nil
+	}
+
+	uc.publishMessageUpdate(ctx, conversationID, userID, &service.MessageUpdate{Deleted: deletion})
+	return deletion, nil
+}
+
+// GetMessageRevisions returns the earlier versions of a message, oldest first
+func (uc *ChatUsecase) GetMessageRevisions(ctx context.Context, userID, conversationID, messageID int) ([]*domain.MessageRevision, error) {
+	if _, err := uc.conversationMessage(ctx, userID, conversationID, messageID); err != nil {
+		return nil, err
+	}
+
+	return uc.ChatRepo.GetMessageRevisions(ctx, messageID)
+}
+
+// conversationMessage returns a message of a conversation the user is a member of
+func (uc *ChatUsecase) conversationMessage(ctx context.Context, userID, conversationID, messageID int) (*domain.Message, error) {
+	if _, _, err := uc.membership(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	message, err := uc.ChatRepo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.ConversationID != conversationID {
+		return nil, domain.ErrMessageNotFound
+	}
+
+	return message, nil
+}
+
+// publishMessageUpdate tells the members of a conversation that the sender edited or deleted a message
+func (uc *ChatUsecase) publishMessageUpdate(ctx context.Context, conversationID, senderID int, update *service.MessageUpdate) {
+	if uc.NatsService == nil {
+		return
+	}
+
+	memberIDs, err := uc.memberIDs(ctx, conversationID)
+	if err != nil {
+		log.Printf("Failed to list members of conversation %d: %v", conversationID, err)
+		return
+	}
+
+	if err := uc.NatsService.PublishMessageUpdate(conversationID, senderID, update, memberIDs); err != nil {
+		// Log error but don't fail the operation
+		log.Printf("Failed to publish message update to NATS: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// recoveryCodeCount is how many single-use recovery codes are issued on enrollment
+	recoveryCodeCount = 10
+	// maxMFAAttempts is how many wrong codes in a row lock the user's second factor
+	maxMFAAttempts = 5
+	// totpSkew accepts codes from one step before and after the current one
+	totpSkew = 1
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP starts a TOTP enrollment. The secret only becomes active once
+// ConfirmTOTP receives a valid code, so an abandoned enrollment never locks the user out.
+func (uc *AuthUsecase) EnrollTOTP(ctx context.Context, userID int) (*domain.TOTPEnrollment, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.IsEnabled() {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.SavePending(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &domain.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: pkg.TOTPProvisioningURI(uc.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery
+// codes. They are only stored hashed, so this is the only time they are shown.
+func (uc *AuthUsecase) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, domain.ErrMFANotEnrolled
+	}
+	if mfa.IsEnabled() {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := pkg.ValidateTOTP(mfa.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, domain.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := uc.MFARepo.Enable(ctx, userID, step); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyMFA completes a login by exchanging an MFA pending token and a TOTP or
+// recovery code for a token pair. The pending token is single use, and once
+// wrong codes lock the second factor it is revoked so the password has to be
+// entered again.
+func (uc *AuthUsecase) VerifyMFA(ctx context.Context, mfaToken, code, recoveryCode string, client domain.ClientInfo) (*domain.TokenPair, error) {
+	claims, err := pkg.ValidateJWT(mfaToken)
+	if err != nil || claims.Purpose != pkg.TokenPurposeMFA || uc.isRevoked(claims) {
+		return nil, errors.New("invalid or expired MFA token")
+	}
+
+	mfa, err := uc.MFARepo.GetByUserID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.IsEnabled() {
+		return nil, errors.New("invalid or expired MFA token")
+	}
+
+	if err := uc.verifySecondFactor(ctx, mfa, code, recoveryCode, client); err != nil {
+		if errors.Is(err, domain.ErrLoginThrottled) {
+			if err := uc.revokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAtTime()); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if err := uc.revokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAtTime()); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, claims.UserID)
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	return uc.startSession(ctx, user, client)
+}
+
+// verifySecondFactor checks a TOTP or recovery code for an enrolled user. The
+// failure count is per user rather than per login, so new pending tokens do
+// not buy new guesses: after maxMFAAttempts wrong codes every further one
+// locks the second factor for the lockout duration, and while it is locked
+// even a correct code is refused.
+func (uc *AuthUsecase) verifySecondFactor(ctx context.Context, mfa *domain.UserMFA, code, recoveryCode string, client domain.ClientInfo) error {
+	now := time.Now()
+	if remaining := mfa.LockoutRemaining(now, maxMFAAttempts); remaining > 0 {
+		return &domain.LoginThrottledError{RetryAfter: remaining.Round(time.Second)}
+	}
+
+	verified, err := uc.checkSecondFactor(ctx, mfa, code, recoveryCode)
+	if err != nil {
+		return err
+	}
+	if verified {
+		return nil
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditLoginMFAFailure, Outcome: domain.AuditOutcomeFailure, TargetUserID: mfa.UserID}, client)
+
+	attempts, err := uc.MFARepo.RecordFailedAttempt(ctx, mfa.UserID, maxMFAAttempts, now.Add(uc.LoginLockoutDuration))
+	if err != nil {
+		return err
+	}
+	if attempts >= maxMFAAttempts {
+		log.Printf("Too many invalid MFA codes for user %d, locking the second factor", mfa.UserID)
+		return &domain.LoginThrottledError{RetryAfter: uc.LoginLockoutDuration}
+	}
+
+	return domain.ErrInvalidMFACode
+}
+
+// checkSecondFactor verifies a TOTP code, rejecting replays of an already
+// used time step, or consumes a recovery code
+func (uc *AuthUsecase) checkSecondFactor(ctx context.Context, mfa *domain.UserMFA, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := pkg.ValidateTOTP(mfa.TOTPSecret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		return uc.MFARepo.UseStep(ctx, mfa.UserID, step)
+	}
+
+	if recoveryCode != "" {
+		used, err := uc.MFARepo.UseRecoveryCode(ctx, mfa.UserID, hashRecoveryCode(recoveryCode))
+		if err == nil && used {
+			log.Printf("User %d signed in with a recovery code", mfa.UserID)
+		}
+		return used, err
+	}
+
+	return false, nil
+}
+
+// mfaEnabled reports whether the user must present a second factor to log in
+func (uc *AuthUsecase) mfaEnabled(ctx context.Context, userID int) (bool, error) {
+	if uc.MFARepo == nil {
+		return false, nil
+	}
+
+	mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return mfa.IsEnabled(), nil
+}
+
+// issueMFAToken signs the short-lived token that proves the password step of a login
+func (uc *AuthUsecase) issueMFAToken(user *domain.User) (string, error) {
+	claims := pkg.NewClaims(user.ID, user.Email, uc.MFATokenTTL)
+	claims.Purpose = pkg.TokenPurposeMFA
+	return pkg.SignClaims(claims)
+}
+
+// generateRecoveryCodes returns n codes formatted as xxxxx-xxxxx together with their hashes
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalises case and separators before hashing so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return pkg.HashToken(normalized)
+}
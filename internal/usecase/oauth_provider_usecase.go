@@ -0,0 +1,518 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateOAuthClient registers a service that signs its users in through this
+// app. Confidential clients get a secret, which is only returned here.
+func (uc *AuthUsecase) CreateOAuthClient(ctx context.Context, adminID int, req domain.CreateOAuthClientRequest, client domain.ClientInfo) (*domain.CreatedOAuthClient, error) {
+	if uc.OAuthRepo == nil {
+		return nil, domain.ErrOAuthClientNotFound
+	}
+
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return nil, domain.ErrInvalidRedirectURI
+		}
+	}
+
+	clientID, err := pkg.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthClient := &domain.OAuthClient{
+		ID:           clientID,
+		Name:         strings.TrimSpace(req.Name),
+		Public:       req.Public,
+		RedirectURIs: req.RedirectURIs,
+	}
+
+	var secret string
+	if !req.Public {
+		if secret, err = pkg.GenerateSecureToken(32); err != nil {
+			return nil, err
+		}
+		oauthClient.SecretHash = pkg.HashToken(secret)
+	}
+
+	if err := uc.OAuthRepo.CreateClient(ctx, oauthClient); err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:  domain.AuditOAuthClientCreate,
+		ActorID: adminID,
+		Details: domain.AuditDetails{"client_id": oauthClient.ID, "name": oauthClient.Name},
+	}, client)
+
+	return &domain.CreatedOAuthClient{OAuthClient: oauthClient, ClientSecret: secret}, nil
+}
+
+// ListOAuthClients returns the registered clients without their secrets
+func (uc *AuthUsecase) ListOAuthClients(ctx context.Context) ([]*domain.OAuthClient, error) {
+	if uc.OAuthRepo == nil {
+		return []*domain.OAuthClient{}, nil
+	}
+	return uc.OAuthRepo.ListClients(ctx)
+}
+
+// DeleteOAuthClient removes a client. Tokens it already holds stop working at
+// /oauth/userinfo since the consents are removed with it.
+func (uc *AuthUsecase) DeleteOAuthClient(ctx context.Context, adminID int, clientID string, client domain.ClientInfo) error {
+	if uc.OAuthRepo == nil {
+		return domain.ErrOAuthClientNotFound
+	}
+
+	deleted, err := uc.OAuthRepo.DeleteClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrOAuthClientNotFound
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:  domain.AuditOAuthClientDelete,
+		ActorID: adminID,
+		Details: domain.AuditDetails{"client_id": clientID},
+	}, client)
+	return nil
+}
+
+// PrepareAuthorization validates an authorization request before the user is
+// asked to sign in. An unknown client or redirect URI returns a plain error,
+// since the user must not be redirected to an unverified address; any other
+// problem is an *domain.OAuthError to report to the client's redirect URI.
+func (uc *AuthUsecase) PrepareAuthorization(ctx context.Context, req *domain.AuthorizationRequest) (*domain.AuthorizationPrompt, error) {
+	oauthClient, err := uc.oauthClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !oauthClient.HasRedirectURI(req.RedirectURI) {
+		return nil, domain.ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != "code" {
+		return nil, domain.NewOAuthError(domain.OAuthErrUnsupportedResponseType, "only the authorization code flow is supported")
+	}
+
+	scopes, err := normalizeOAuthScopes(req.Scopes())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != "S256" {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidRequest, "code_challenge_method must be S256")
+	}
+	if oauthClient.Public && req.CodeChallenge == "" {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidRequest, "public clients must use PKCE")
+	}
+
+	// There is no browser session to sign in silently with
+	if req.Prompt == "none" {
+		return nil, domain.NewOAuthError(domain.OAuthErrLoginRequired, "")
+	}
+
+	return &domain.AuthorizationPrompt{Client: oauthClient, Scopes: scopes}, nil
+}
+
+// Authorize signs the user in with their password, and their second factor
+// when enrolled, records their consent and returns an authorization code for
+// the client. Sign-in failures are counted like failed logins.
+func (uc *AuthUsecase) Authorize(ctx context.Context, decision *domain.AuthorizationDecision, client domain.ClientInfo) (string, error) {
+	prompt, err := uc.PrepareAuthorization(ctx, &decision.AuthorizationRequest)
+	if err != nil {
+		return "", err
+	}
+
+	if !decision.Allowed() {
+		return "", domain.NewOAuthError(domain.OAuthErrAccessDenied, "the user denied access")
+	}
+
+	user, err := uc.authenticatePassword(ctx, decision.Email, decision.Password, client)
+	if err != nil {
+		return "", err
+	}
+
+	if uc.RequireEmailVerification && !user.IsEmailVerified() {
+		return "", domain.ErrEmailNotVerified
+	}
+
+	if err := uc.checkAuthorizationSecondFactor(ctx, user, decision, client); err != nil {
+		return "", err
+	}
+
+	if user.IsDeleted() {
+		if err := uc.restoreAccount(ctx, user, client); err != nil {
+			return "", err
+		}
+	}
+
+	err = uc.OAuthRepo.SaveConsent(ctx, &domain.OAuthConsent{UserID: user.ID, ClientID: prompt.Client.ID, Scopes: prompt.Scopes})
+	if err != nil {
+		return "", err
+	}
+
+	code, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = uc.OAuthRepo.CreateCode(ctx, &domain.AuthorizationCode{
+		CodeHash:      pkg.HashToken(code),
+		ClientID:      prompt.Client.ID,
+		UserID:        user.ID,
+		RedirectURI:   decision.RedirectURI,
+		Scopes:        prompt.Scopes,
+		Nonce:         decision.Nonce,
+		CodeChallenge: decision.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(uc.OAuthCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:  domain.AuditOAuthAuthorize,
+		ActorID: user.ID,
+		Details: domain.AuditDetails{"client_id": prompt.Client.ID, "scope": strings.Join(prompt.Scopes, " ")},
+	}, client)
+
+	return code, nil
+}
+
+// ExchangeAuthorizationCode redeems an authorization code at the token
+// endpoint for an access token and an ID token. Every failure is an
+// *domain.OAuthError.
+func (uc *AuthUsecase) ExchangeAuthorizationCode(ctx context.Context, req *domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	if req.GrantType != "authorization_code" {
+		return nil, domain.NewOAuthError(domain.OAuthErrUnsupportedGrantType, "")
+	}
+
+	oauthClient, err := uc.authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code == "" {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidRequest, "code is required")
+	}
+
+	code, err := uc.OAuthRepo.ConsumeCode(ctx, pkg.HashToken(req.Code))
+	if err != nil {
+		return nil, domain.NewOAuthError(domain.OAuthErrServerError, "")
+	}
+	if code == nil || code.ClientID != oauthClient.ID || time.Now().After(code.ExpiresAt) {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidGrant, "invalid or expired authorization code")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if code.CodeChallenge != "" && subtle.ConstantTimeCompare([]byte(pkg.PKCEChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, code.UserID)
+	if err != nil || user == nil || user.IsDisabled() {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidGrant, "the user can no longer sign in")
+	}
+
+	scope := strings.Join(code.Scopes, " ")
+
+	claims := pkg.NewClaims(user.ID, user.Email, uc.AccessTokenTTL)
+	claims.Audience = append(pkg.Audience{oauthClient.ID}, claims.Audience...)
+	claims.Purpose = pkg.TokenPurposeOAuth
+	claims.ClientID = oauthClient.ID
+	claims.Scope = scope
+	if !hasScope(code.Scopes, domain.OAuthScopeEmail) {
+		claims.Email = ""
+	}
+
+	accessToken, err := pkg.SignClaims(claims)
+	if err != nil {
+		return nil, domain.NewOAuthError(domain.OAuthErrServerError, "")
+	}
+
+	info := oauthUserInfo(user, code.Scopes)
+	now := time.Now()
+	idToken, err := pkg.SignIDToken(&pkg.IDTokenClaims{
+		Issuer:          uc.OAuthIssuer,
+		Subject:         info.Subject,
+		Audience:        pkg.Audience{oauthClient.ID},
+		AuthorizedParty: oauthClient.ID,
+		ExpiresAt:       now.Add(uc.AccessTokenTTL).Unix(),
+		IssuedAt:        now.Unix(),
+		Nonce:           code.Nonce,
+		AuthTime:        code.AuthTime.Unix(),
+		Name:            info.Name,
+		Picture:         info.Picture,
+		Zoneinfo:        info.Zoneinfo,
+		Email:           info.Email,
+		EmailVerified:   info.EmailVerified != nil && *info.EmailVerified,
+	})
+	if err != nil {
+		log.Printf("Failed to sign ID token for client %s: %v", oauthClient.ID, err)
+		return nil, domain.NewOAuthError(domain.OAuthErrServerError, "")
+	}
+
+	return &domain.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(uc.AccessTokenTTL.Seconds()),
+		IDToken:     idToken,
+		Scope:       scope,
+	}, nil
+}
+
+// UserInfo returns the claims an OAuth access token's scope allows. Tokens
+// stop working when the user withdraws consent or the client is deleted.
+func (uc *AuthUsecase) UserInfo(ctx context.Context, accessToken string) (*domain.OAuthUserInfo, error) {
+	invalid := domain.NewOAuthError(domain.OAuthErrInvalidToken, "invalid or expired access token")
+
+	claims, err := pkg.ValidateJWT(accessToken)
+	if err != nil || claims.Purpose != pkg.TokenPurposeOAuth || uc.isRevoked(claims) || uc.OAuthRepo == nil {
+		return nil, invalid
+	}
+
+	consent, err := uc.OAuthRepo.GetConsent(ctx, claims.UserID, claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := strings.Fields(claims.Scope)
+	if consent == nil || !consent.Covers(scopes) {
+		return nil, invalid
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, claims.UserID)
+	if err != nil || user == nil || user.IsDisabled() {
+		return nil, invalid
+	}
+
+	return oauthUserInfo(user, scopes), nil
+}
+
+// OAuthProviderAvailable reports whether ID tokens can be issued. Clients
+// verify them with the keys published in the JWKS, which the shared HS256
+// secret cannot be.
+func (uc *AuthUsecase) OAuthProviderAvailable() bool {
+	return pkg.GetKeyManager() != nil
+}
+
+// OpenIDConfiguration is the discovery document of this app as a provider
+func (uc *AuthUsecase) OpenIDConfiguration() *pkg.OIDCDiscovery {
+	algorithms := []string{}
+	if km := pkg.GetKeyManager(); km != nil {
+		if key := km.ActiveKey(); key != nil {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	return &pkg.OIDCDiscovery{
+		Issuer:                            uc.OAuthIssuer,
+		AuthorizationEndpoint:             uc.OAuthIssuer + "/oauth/authorize",
+		TokenEndpoint:                     uc.OAuthIssuer + "/oauth/token",
+		UserinfoEndpoint:                  uc.OAuthIssuer + "/oauth/userinfo",
+		JWKSURI:                           uc.OAuthIssuer + "/.well-known/jwks.json",
+		ScopesSupported:                   domain.OAuthScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "name", "picture", "zoneinfo", "email", "email_verified", "auth_time", "nonce"},
+		IntrospectionEndpoint:             uc.OAuthIssuer + "/oauth/introspect",
+		IntrospectionEndpointAuthMethods:  []string{"client_secret_basic", "client_secret_post"},
+	}
+}
+
+// ListOAuthConsents returns the clients the user allowed to sign them in
+func (uc *AuthUsecase) ListOAuthConsents(ctx context.Context, userID int) ([]*domain.OAuthConsent, error) {
+	if uc.OAuthRepo == nil {
+		return []*domain.OAuthConsent{}, nil
+	}
+	return uc.OAuthRepo.ListConsents(ctx, userID)
+}
+
+// RevokeOAuthConsent withdraws a client's access; the user is asked again on the next sign-in
+func (uc *AuthUsecase) RevokeOAuthConsent(ctx context.Context, userID int, clientID string, client domain.ClientInfo) error {
+	if uc.OAuthRepo == nil {
+		return domain.ErrOAuthClientNotFound
+	}
+
+	deleted, err := uc.OAuthRepo.DeleteConsent(ctx, userID, clientID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrOAuthClientNotFound
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:  domain.AuditOAuthConsentRevoke,
+		ActorID: userID,
+		Details: domain.AuditDetails{"client_id": clientID},
+	}, client)
+	return nil
+}
+
+// StartOAuthCodeCleanup periodically removes authorization codes that were never redeemed
+func (uc *AuthUsecase) StartOAuthCodeCleanup(interval time.Duration) {
+	if uc.OAuthRepo == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := uc.OAuthRepo.DeleteExpiredCodes(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to clean up OAuth authorization codes: %v", err)
+			}
+		}
+	}()
+}
+
+// checkAuthorizationSecondFactor asks users with two-factor authentication for
+// their code on the sign-in form, there is no pending token to come back with
+func (uc *AuthUsecase) checkAuthorizationSecondFactor(ctx context.Context, user *domain.User, decision *domain.AuthorizationDecision, client domain.ClientInfo) error {
+	required, err := uc.mfaEnabled(ctx, user.ID)
+	if err != nil || !required {
+		return err
+	}
+
+	if decision.OTP == "" && decision.RecoveryCode == "" {
+		return domain.ErrMFACodeRequired
+	}
+
+	mfa, err := uc.MFARepo.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.verifySecondFactor(ctx, mfa, decision.OTP, decision.RecoveryCode, client)
+	if errors.Is(err, domain.ErrInvalidMFACode) {
+		uc.recordLoginFailure(ctx, decision.Email, client)
+	}
+	return err
+}
+
+// oauthClient looks up a registered client
+func (uc *AuthUsecase) oauthClient(ctx context.Context, clientID string) (*domain.OAuthClient, error) {
+	if uc.OAuthRepo == nil || clientID == "" {
+		return nil, domain.ErrOAuthClientNotFound
+	}
+
+	oauthClient, err := uc.OAuthRepo.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if oauthClient == nil {
+		return nil, domain.ErrOAuthClientNotFound
+	}
+	return oauthClient, nil
+}
+
+// authenticateOAuthClient checks the credentials a client presents at the
+// token endpoint. Public clients have no secret and rely on PKCE instead.
+func (uc *AuthUsecase) authenticateOAuthClient(ctx context.Context, clientID, secret string) (*domain.OAuthClient, error) {
+	invalid := domain.NewOAuthError(domain.OAuthErrInvalidClient, "client authentication failed")
+
+	oauthClient, err := uc.oauthClient(ctx, clientID)
+	if errors.Is(err, domain.ErrOAuthClientNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, domain.NewOAuthError(domain.OAuthErrServerError, "")
+	}
+
+	if oauthClient.Public {
+		return oauthClient, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(pkg.HashToken(secret)), []byte(oauthClient.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	return oauthClient, nil
+}
+
+// oauthUserInfo returns the claims about the user that the scopes allow
+func oauthUserInfo(user *domain.User, scopes []string) *domain.OAuthUserInfo {
+	info := &domain.OAuthUserInfo{Subject: strconv.Itoa(user.ID)}
+
+	if hasScope(scopes, domain.OAuthScopeProfile) {
+		info.Name = user.Name
+		info.Picture = user.AvatarURL
+		info.Zoneinfo = user.Timezone
+	}
+	if hasScope(scopes, domain.OAuthScopeEmail) {
+		verified := user.IsEmailVerified()
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+
+	return info
+}
+
+// normalizeOAuthScopes checks requested scopes and returns them without
+// duplicates, in the order of domain.OAuthScopes. ID tokens are the point of
+// signing in through this app, so openid is required.
+func normalizeOAuthScopes(requested []string) ([]string, error) {
+	for _, scope := range requested {
+		if !hasScope(domain.OAuthScopes, scope) {
+			return nil, domain.NewOAuthError(domain.OAuthErrInvalidScope, "unknown scope "+scope)
+		}
+	}
+	if !hasScope(requested, domain.OAuthScopeOpenID) {
+		return nil, domain.NewOAuthError(domain.OAuthErrInvalidScope, "the openid scope is required")
+	}
+
+	scopes := []string{}
+	for _, scope := range domain.OAuthScopes {
+		if hasScope(requested, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// hasScope reports whether scope is in the list
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validRedirectURI allows absolute https URIs without a fragment. Plain http
+// is only allowed on the loopback interface, for local development.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,202 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"strings"
+	"time"
+)
+
+// unusablePassword is stored for accounts created through an identity
+// provider. It is not a bcrypt hash, so no password matches it until the user
+// sets one with a password reset.
+const unusablePassword = "!"
+
+// StartOIDCLogin begins a login at an identity provider using the
+// authorization code flow with PKCE. It returns the URL to send the browser to.
+func (uc *AuthUsecase) StartOIDCLogin(ctx context.Context, providerName, deviceName string) (string, error) {
+	provider, ok := uc.OIDCProviders[providerName]
+	if !ok || uc.OIDCRepo == nil {
+		return "", domain.ErrUnknownOIDCProvider
+	}
+
+	state, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := pkg.GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, pkg.PKCEChallenge(codeVerifier))
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.OIDCRepo.CreateState(ctx, &domain.OIDCLoginState{
+		StateHash:    pkg.HashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		DeviceName:   normalizeDeviceName(deviceName),
+		ExpiresAt:    time.Now().Add(uc.OIDCStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin handles the user coming back from an identity provider.
+// The external identity signs in the account it is linked to; an unknown
+// identity is linked to the account with the same verified email, or a new
+// account is created for it. The result is the same as a password login.
+func (uc *AuthUsecase) CompleteOIDCLogin(ctx context.Context, providerName, code, state string, client domain.ClientInfo) (*domain.LoginResult, error) {
+	provider, ok := uc.OIDCProviders[providerName]
+	if !ok || uc.OIDCRepo == nil {
+		return nil, domain.ErrUnknownOIDCProvider
+	}
+
+	stored, err := uc.OIDCRepo.ConsumeState(ctx, pkg.HashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.Provider != provider.Name || time.Now().After(stored.ExpiresAt) {
+		return nil, domain.ErrInvalidOIDCState
+	}
+
+	client.DeviceName = stored.DeviceName
+	client.IdentityProvider = provider.Name
+
+	tokens, err := provider.Exchange(ctx, code, stored.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrOIDCLoginFailed, err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, stored.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrOIDCLoginFailed, err)
+	}
+
+	user, err := uc.oidcUser(ctx, provider.Name, claims, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsDisabled() {
+		uc.auditLoginFailure(ctx, user.Email, user.ID, "account_disabled", client)
+		return nil, domain.ErrAccountDisabled
+	}
+
+	return uc.completeLogin(ctx, user, client)
+}
+
+// StartOIDCStateCleanup periodically removes logins abandoned at an identity provider
+func (uc *AuthUsecase) StartOIDCStateCleanup(interval time.Duration) {
+	if uc.OIDCRepo == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := uc.OIDCRepo.DeleteExpiredStates(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to clean up OIDC login states: %v", err)
+			}
+		}
+	}()
+}
+
+// oidcUser finds or creates the account an external identity signs in to
+func (uc *AuthUsecase) oidcUser(ctx context.Context, providerName string, claims *pkg.IDTokenClaims, client domain.ClientInfo) (*domain.User, error) {
+	email := normalizeEmail(claims.Email)
+
+	identity, err := uc.OIDCRepo.GetIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := uc.UserRepo.GetByID(ctx, identity.UserID)
+		if err != nil || user == nil {
+			return nil, domain.ErrUserNotFound
+		}
+		if err := uc.OIDCRepo.TouchIdentity(ctx, identity.ID, email); err != nil {
+			log.Printf("Failed to update identity %d: %v", identity.ID, err)
+		}
+		return user, nil
+	}
+
+	// Linking by email is only safe when the provider vouches for the address
+	if email == "" || !claims.EmailVerified {
+		return nil, domain.ErrOIDCEmailNotVerified
+	}
+
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		// Whoever registered an unverified address may not own it, linking would
+		// let them keep a password into the real owner's account
+		if !user.IsEmailVerified() {
+			return nil, domain.ErrOIDCAccountConflict
+		}
+	} else {
+		if user, err = uc.createOIDCUser(ctx, providerName, claims.Name, email, client); err != nil {
+			return nil, err
+		}
+	}
+
+	identity = &domain.UserIdentity{UserID: user.ID, Provider: providerName, Subject: claims.Subject, Email: email}
+	if err := uc.OIDCRepo.CreateIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditIdentityLinked,
+		ActorID:      user.ID,
+		TargetUserID: user.ID,
+		Details:      domain.AuditDetails{"provider": providerName, "subject": claims.Subject},
+	}, client)
+
+	return user, nil
+}
+
+// createOIDCUser creates an account for someone signing in through an
+// identity provider for the first time. The provider verified the email, so
+// no verification email is sent.
+func (uc *AuthUsecase) createOIDCUser(ctx context.Context, providerName, name, email string, client domain.ClientInfo) (*domain.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	if runes := []rune(name); len(runes) > domain.MaxNameLength {
+		name = string(runes[:domain.MaxNameLength])
+	}
+
+	user := &domain.User{Name: name, Email: email, Password: unusablePassword}
+	if err := uc.UserRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := uc.UserRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditSignup, ActorID: user.ID, Details: domain.AuditDetails{"provider": providerName}}, client)
+	uc.assignDefaultRole(ctx, user.ID)
+
+	return user, nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPassword emails a single-use reset link. It succeeds silently for
+// unknown addresses so the endpoint cannot be used to enumerate accounts.
+func (uc *AuthUsecase) ForgotPassword(ctx context.Context, email string) error {
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err != nil || user == nil {
+		return nil
+	}
+
+	token, err := uc.createPasswordResetToken(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request a password reset you can ignore this email.\n",
+			user.Name, int(uc.PasswordResetTTL.Minutes()), uc.emailLink(uc.PasswordResetURL, "/password/reset", token),
+		),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (uc *AuthUsecase) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if err := domain.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	stored, err := uc.consumeUserToken(ctx, token, domain.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, stored.UserID)
+	if err != nil || user == nil {
+		return domain.ErrInvalidUserToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must lose access as well
+	if err := uc.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	removedPasskeys, err := uc.removePasskeys(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditPasswordReset, ActorID: user.ID}, domain.ClientInfo{})
+
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposePasswordReset); err != nil {
+		log.Printf("Failed to invalidate reset tokens for user %d: %v", user.ID, err)
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour password was just changed and all of your sessions were signed out.%s\nIf this was not you, reset your password immediately.\n", user.Name, passkeysRemovedNotice(removedPasskeys)),
+	})
+
+	return nil
+}
+
+// passkeysRemovedNotice tells the user in a reset email that their passkeys have to be added again
+func passkeysRemovedNotice(removed int) string {
+	if removed == 0 {
+		return ""
+	}
+	return " Your passkeys were removed as well, add them again once you are signed in."
+}
+
+// createPasswordResetToken issues a reset token. Only the most recent reset link stays valid.
+func (uc *AuthUsecase) createPasswordResetToken(ctx context.Context, userID int) (string, error) {
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, userID, domain.TokenPurposePasswordReset); err != nil {
+		return "", err
+	}
+
+	return uc.createUserToken(ctx, userID, domain.TokenPurposePasswordReset, uc.PasswordResetTTL)
+}
+
+// createUserToken stores the hash of a new single-use token and returns the raw value
+func (uc *AuthUsecase) createUserToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	token, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.UserTokenRepo.Create(ctx, &domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: pkg.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken validates a single-use token and marks it as used
+func (uc *AuthUsecase) consumeUserToken(ctx context.Context, token, purpose string) (*domain.UserToken, error) {
+	stored, err := uc.UserTokenRepo.GetByHash(ctx, pkg.HashToken(token), purpose)
+	if err != nil || stored == nil {
+		return nil, domain.ErrInvalidUserToken
+	}
+
+	if stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, domain.ErrInvalidUserToken
+	}
+
+	consumed, err := uc.UserTokenRepo.MarkUsed(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, domain.ErrInvalidUserToken
+	}
+
+	return stored, nil
+}
+
+// emailLink appends a token to the page an emailed link opens, falling back
+// to the given path of this app when no page is configured
+func (uc *AuthUsecase) emailLink(page, fallbackPath, token string) string {
+	if page == "" {
+		page = strings.TrimSuffix(uc.AppBaseURL, "/") + fallbackPath
+	}
+
+	link, err := url.Parse(page)
+	if err != nil {
+		return page + "?token=" + url.QueryEscape(token)
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// sendEmail delivers mail in the background so response times do not reveal whether an account exists
+func (uc *AuthUsecase) sendEmail(email pkg.Email) {
+	if uc.Mailer == nil {
+		log.Printf("No mailer configured, dropping email to %s", email.To)
+		return
+	}
+
+	go func() {
+		if err := uc.Mailer.Send(context.Background(), email); err != nil {
+			log.Printf("Failed to send email to %s: %v", email.To, err)
+		}
+	}()
+}
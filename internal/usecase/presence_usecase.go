@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+)
+
+// GetPresence returns whether a user is online. Users only see the presence
+// of people they share a conversation with, and their own; anyone else is
+// reported as not found.
+func (uc *ChatUsecase) GetPresence(ctx context.Context, viewerID, userID int) (*domain.Presence, error) {
+	presences, err := uc.GetPresences(ctx, viewerID, []int{userID})
+	if err != nil {
+		return nil, err
+	}
+	if len(presences) == 0 {
+		return nil, domain.ErrUserNotFound
+	}
+	return presences[0], nil
+}
+
+// GetPresences returns the presence of several users in the order asked for.
+// Duplicates and users the viewer may not see are left out.
+func (uc *ChatUsecase) GetPresences(ctx context.Context, viewerID int, userIDs []int) ([]*domain.Presence, error) {
+	contactIDs, err := uc.GetContactIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[int]bool{viewerID: true}
+	for _, id := range contactIDs {
+		visible[id] = true
+	}
+
+	ids := make([]int, 0, len(userIDs))
+	for _, id := range userIDs {
+		if visible[id] {
+			ids = append(ids, id)
+			delete(visible, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return []*domain.Presence{}, nil
+	}
+
+	// Without presence tracking nobody is known to be online
+	if uc.Presence == nil {
+		presences := make([]*domain.Presence, 0, len(ids))
+		for _, id := range ids {
+			presences = append(presences, &domain.Presence{UserID: id, Status: domain.PresenceOffline})
+		}
+		return presences, nil
+	}
+
+	return uc.Presence.Get(ctx, ids)
+}
+
+// GetContactIDs returns the users who share a conversation with the user,
+// those who are told when the user comes online or goes offline
+func (uc *ChatUsecase) GetContactIDs(ctx context.Context, userID int) ([]int, error) {
+	return uc.ChatRepo.GetContactIDs(ctx, userID)
+}
+
+// SetPresence sets the status of the user's connections to this instance,
+// PresenceOffline once the last one has closed
+func (uc *ChatUsecase) SetPresence(userID int, status string) {
+	if uc.Presence != nil {
+		uc.Presence.SetLocal(userID, status)
+	}
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetProfile returns the signed-in user's account
+func (uc *AuthUsecase) GetProfile(ctx context.Context, userID int) (*domain.User, error) {
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// UpdateProfile changes the fields present in the request and leaves the others as they are
+func (uc *AuthUsecase) UpdateProfile(ctx context.Context, userID int, req domain.UpdateProfileRequest) (*domain.User, error) {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.AvatarURL != nil {
+		user.AvatarURL = strings.TrimSpace(*req.AvatarURL)
+	}
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.Timezone != nil {
+		user.Timezone = strings.TrimSpace(*req.Timezone)
+	}
+
+	if err := user.ValidateProfile(); err != nil {
+		return nil, err
+	}
+
+	if err := uc.UserRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangeEmail starts moving the account to a new address. The current address
+// keeps working until the link sent to the new one is opened, so a typo cannot
+// lock the user out.
+func (uc *AuthUsecase) ChangeEmail(ctx context.Context, userID int, newEmail, currentPassword string, client domain.ClientInfo) error {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.verifyCurrentPassword(ctx, user, currentPassword, client); err != nil {
+		return err
+	}
+
+	newEmail = strings.TrimSpace(newEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return errors.New("new email address is the same as the current one")
+	}
+	if existing, _ := uc.UserRepo.GetByEmail(ctx, newEmail); existing != nil {
+		return domain.ErrEmailTaken
+	}
+
+	if err := uc.UserRepo.SetPendingEmail(ctx, user.ID, newEmail); err != nil {
+		return err
+	}
+
+	// Only the link for the latest requested address stays valid
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposeEmailChange); err != nil {
+		return err
+	}
+
+	token, err := uc.createUserToken(ctx, user.ID, domain.TokenPurposeEmailChange, uc.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to start using this address for your account. It expires in %d hours.\n\n%s/verify-email/change?token=%s\n",
+			user.Name, int(uc.EmailVerificationTTL.Hours()), uc.AppBaseURL, token,
+		),
+	})
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nA change of your account email to %s was requested. It takes effect once the new address is confirmed.\nIf this was not you, change your password immediately.\n", user.Name, newEmail),
+	})
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditEmailChangeRequested, ActorID: user.ID, Details: domain.AuditDetails{"new_email_hmac": uc.auditEmail(newEmail)}}, client)
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the new address behind an email change token
+func (uc *AuthUsecase) ConfirmEmailChange(ctx context.Context, token string) error {
+	stored, err := uc.consumeUserToken(ctx, token, domain.TokenPurposeEmailChange)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, stored.UserID)
+	if err != nil || user == nil || user.PendingEmail == nil {
+		return domain.ErrInvalidUserToken
+	}
+
+	if err := uc.UserRepo.ApplyPendingEmail(ctx, user.ID, *user.PendingEmail); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditEmailChanged, ActorID: user.ID, Details: domain.AuditDetails{"old_email_hmac": uc.auditEmail(user.Email), "new_email_hmac": uc.auditEmail(*user.PendingEmail)}}, domain.ClientInfo{})
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one and, like
+// a reset, signs every session out including the one the change was made from
+func (uc *AuthUsecase) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string, client domain.ClientInfo) error {
+	user, err := uc.GetProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.verifyCurrentPassword(ctx, user, currentPassword, client); err != nil {
+		return err
+	}
+
+	if err := domain.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.UserRepo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := uc.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditPasswordChanged, ActorID: user.ID}, client)
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour password was just changed and all of your sessions were signed out.\nIf this was not you, reset your password immediately.\n", user.Name),
+	})
+
+	return nil
+}
+
+// verifyCurrentPassword re-authenticates a signed-in user before a sensitive
+// change. Wrong guesses count towards the login lockout, so a stolen access
+// token cannot be used to brute force the password.
+func (uc *AuthUsecase) verifyCurrentPassword(ctx context.Context, user *domain.User, password string, client domain.ClientInfo) error {
+	if err := uc.checkLoginThrottle(ctx, user.Email, client); err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		uc.recordLoginFailure(ctx, user.Email, client)
+		return domain.ErrIncorrectPassword
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"log"
+	"time"
+)
+
+// MarkConversationRead records that the user has read a conversation up to a
+// message, or up to its latest message when messageID is 0. The cursor only
+// moves forward; the other members are told over NATS when it does. The
+// returned receipt holds the message the user has now read up to.
+func (uc *ChatUsecase) MarkConversationRead(ctx context.Context, userID, conversationID, messageID int) (*domain.ReadReceipt, error) {
+	_, member, err := uc.membership(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageID == 0 {
+		if messageID, err = uc.ChatRepo.GetLatestMessageID(ctx, conversationID); err != nil {
+			return nil, err
+		}
+	} else {
+		message, err := uc.ChatRepo.GetMessage(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil || message.ConversationID != conversationID {
+			return nil, domain.ErrMessageNotFound
+		}
+	}
+
+	receipt := &domain.ReadReceipt{
+		ConversationID: conversationID,
+		UserID:         userID,
+		MessageID:      member.LastReadMessageID,
+		ReadAt:         time.Now(),
+	}
+
+	if messageID <= member.LastReadMessageID {
+		return receipt, nil
+	}
+
+	advanced, err := uc.ChatRepo.MarkRead(ctx, conversationID, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		// Another device of the user read further in the meantime
+		return receipt, nil
+	}
+	receipt.MessageID = messageID
+
+	if uc.NatsService != nil {
+		memberIDs, err := uc.memberIDs(ctx, conversationID)
+		if err != nil {
+			log.Printf("Failed to list members of conversation %d: %v", conversationID, err)
+			return receipt, nil
+		}
+
+		if err := uc.NatsService.PublishReadReceipt(receipt, memberIDs); err != nil {
+			// Log error but don't fail the operation
+			log.Printf("Failed to publish read receipt to NATS: %v", err)
+		}
+	}
+
+	return receipt, nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-auth-app/internal/domain"
+	"log"
+)
+
+// GetRoles lists the available roles and their permissions
+func (uc *AuthUsecase) GetRoles(ctx context.Context) ([]*domain.Role, error) {
+	return uc.RoleRepo.GetRoles(ctx)
+}
+
+// GetUserAccess returns the roles and permissions of a user
+func (uc *AuthUsecase) GetUserAccess(ctx context.Context, userID int) (*domain.UserAccess, error) {
+	return uc.RoleRepo.GetUserAccess(ctx, userID)
+}
+
+// AssignRole grants a role to a user. Access tokens carry a snapshot of the
+// roles, so the user's current access tokens are revoked; clients pick up the
+// new roles with their next refresh.
+func (uc *AuthUsecase) AssignRole(ctx context.Context, adminID, userID int, role string, client domain.ClientInfo) error {
+	if user, err := uc.UserRepo.GetByID(ctx, userID); err != nil || user == nil {
+		return errors.New("user not found")
+	}
+
+	if err := uc.RoleRepo.AssignRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditRoleAssign, ActorID: adminID, TargetUserID: userID, Details: domain.AuditDetails{"role": role}}, client)
+	return uc.revokeAccessTokens(ctx, userID)
+}
+
+// RemoveRole revokes a role from a user and invalidates the user's current access tokens
+func (uc *AuthUsecase) RemoveRole(ctx context.Context, adminID, userID int, role string, client domain.ClientInfo) error {
+	if err := uc.RoleRepo.RemoveRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditRoleRemove, ActorID: adminID, TargetUserID: userID, Details: domain.AuditDetails{"role": role}}, client)
+	return uc.revokeAccessTokens(ctx, userID)
+}
+
+// BootstrapAdmins grants the admin role to existing accounts with the given
+// emails, so a fresh deployment has someone who can manage roles. Accounts
+// are only promoted once their address is verified, otherwise anyone could
+// sign up with a listed address they do not own and become admin on the
+// next restart. Disabled and deleted accounts are skipped as well.
+func (uc *AuthUsecase) BootstrapAdmins(ctx context.Context, emails []string) {
+	if uc.RoleRepo == nil {
+		return
+	}
+
+	for _, email := range emails {
+		user, err := uc.UserRepo.GetByEmail(ctx, email)
+		if err != nil || user == nil {
+			log.Printf("Admin bootstrap: no account for %s yet", email)
+			continue
+		}
+
+		switch {
+		case !user.IsEmailVerified():
+			log.Printf("Admin bootstrap: skipping %s until the address is verified", email)
+			continue
+		case user.IsDisabled():
+			log.Printf("Admin bootstrap: skipping %s, the account is disabled", email)
+			continue
+		case user.IsDeleted():
+			log.Printf("Admin bootstrap: skipping %s, the account is scheduled for deletion", email)
+			continue
+		}
+
+		if err := uc.RoleRepo.AssignRole(ctx, user.ID, domain.RoleAdmin); err != nil {
+			log.Printf("Admin bootstrap: failed to grant admin to %s: %v", email, err)
+		}
+	}
+}
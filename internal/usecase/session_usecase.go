@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxDeviceNameLength matches the sessions.device_name column
+const maxDeviceNameLength = 100
+
+// ListSessions returns the user's open sessions and marks the one the request was made from
+func (uc *AuthUsecase) ListSessions(ctx context.Context, userID int, currentSessionID string) ([]*domain.Session, error) {
+	if uc.SessionRepo == nil {
+		return []*domain.Session{}, nil
+	}
+
+	sessions, err := uc.SessionRepo.ListActiveByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's devices out. Its refresh tokens stop
+// working, its access tokens are rejected on every instance and open WebSocket
+// connections authenticated by them are closed.
+func (uc *AuthUsecase) RevokeSession(ctx context.Context, userID int, sessionID string, client domain.ClientInfo) error {
+	revoked, err := uc.endSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return domain.ErrSessionNotFound
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{Action: domain.AuditSessionRevoke, ActorID: userID, TargetUserID: userID, Details: domain.AuditDetails{"session_id": sessionID}}, client)
+	return nil
+}
+
+// createSession records where a new login came from
+func (uc *AuthUsecase) createSession(ctx context.Context, sessionID string, userID int, client domain.ClientInfo) error {
+	if uc.SessionRepo == nil {
+		return nil
+	}
+
+	return uc.SessionRepo.Create(ctx, &domain.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		DeviceName: normalizeDeviceName(client.DeviceName),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+	})
+}
+
+// normalizeDeviceName trims a client supplied device label and cuts it to the
+// column length, which counts characters rather than bytes
+func normalizeDeviceName(deviceName string) string {
+	runes := []rune(strings.TrimSpace(deviceName))
+	if len(runes) > maxDeviceNameLength {
+		runes = runes[:maxDeviceNameLength]
+	}
+	return string(runes)
+}
+
+// touchSession records a refresh as session activity. It is not done on every
+// request, so last-seen is accurate to about one access token lifetime.
+func (uc *AuthUsecase) touchSession(ctx context.Context, sessionID string, client domain.ClientInfo) {
+	if uc.SessionRepo == nil {
+		return
+	}
+
+	if err := uc.SessionRepo.Touch(ctx, sessionID, client.IP); err != nil {
+		log.Printf("Failed to update session %s: %v", sessionID, err)
+	}
+}
+
+// endSession revokes a session, its refresh token family and its access tokens.
+// It reports false when the session does not exist or was already revoked.
+func (uc *AuthUsecase) endSession(ctx context.Context, userID int, sessionID string) (bool, error) {
+	if uc.SessionRepo == nil {
+		return false, nil
+	}
+
+	revoked, err := uc.SessionRepo.Revoke(ctx, sessionID, userID)
+	if err != nil || !revoked {
+		return false, err
+	}
+
+	if err := uc.RefreshTokenRepo.RevokeFamily(ctx, sessionID); err != nil {
+		return false, err
+	}
+
+	// The sessions table is the durable record, the cache entry only has to
+	// outlive the session's last access token
+	if uc.RevocationService != nil {
+		uc.RevocationService.RevokeSession(sessionID, userID, time.Now().Add(uc.accessTokenLifetime()))
+	}
+
+	return true, nil
+}
+
+// revokedSessions lists the sessions whose access tokens may still be presented
+func (uc *AuthUsecase) revokedSessions(ctx context.Context, cutoff time.Time) ([]*domain.RevokedSession, error) {
+	if uc.SessionRepo == nil {
+		return nil, nil
+	}
+
+	sessions, err := uc.SessionRepo.ListRevokedSince(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make([]*domain.RevokedSession, 0, len(sessions))
+	for _, session := range sessions {
+		revoked = append(revoked, &domain.RevokedSession{
+			SessionID: session.ID,
+			UserID:    session.UserID,
+			ExpiresAt: session.RevokedAt.Add(uc.accessTokenLifetime()),
+		})
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+	"go-auth-app/internal/domain"
+)
+
+// StartTyping tells the other members of a conversation that the user is
+// typing. Clients repeat it while the user types; the indicator goes away on
+// StopTyping, when the user sends a message, or after a few quiet seconds.
+func (uc *ChatUsecase) StartTyping(ctx context.Context, userID, conversationID int) error {
+	if uc.Typing == nil {
+		return nil
+	}
+
+	// Typing on needs no database lookup
+	if uc.Typing.Refresh(userID, conversationID) {
+		return nil
+	}
+
+	// A client flooding the server is turned away before the lookup
+	if uc.Typing.Throttled(userID) {
+		return domain.ErrTypingThrottled
+	}
+
+	if _, _, err := uc.membership(ctx, conversationID, userID); err != nil {
+		return err
+	}
+
+	memberIDs, err := uc.memberIDs(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	return uc.Typing.Start(userID, conversationID, memberIDs)
+}
+
+// StopTyping hides the user's typing indicator in a conversation
+func (uc *ChatUsecase) StopTyping(userID, conversationID int) {
+	if uc.Typing != nil {
+		uc.Typing.Stop(userID, conversationID)
+	}
+}
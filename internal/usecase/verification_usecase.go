@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"time"
+)
+
+// VerifyEmail marks the account behind a verification token as verified
+func (uc *AuthUsecase) VerifyEmail(ctx context.Context, token string) error {
+	stored, err := uc.consumeUserToken(ctx, token, domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.UserRepo.MarkEmailVerified(ctx, stored.UserID); err != nil {
+		return err
+	}
+
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, stored.UserID, domain.TokenPurposeEmailVerification); err != nil {
+		log.Printf("Failed to invalidate verification tokens for user %d: %v", stored.UserID, err)
+	}
+
+	return nil
+}
+
+// ResendVerification sends a fresh verification email. Like ForgotPassword it
+// never reveals whether the address is registered; requests inside the resend
+// interval are dropped silently.
+func (uc *AuthUsecase) ResendVerification(ctx context.Context, email string) error {
+	user, err := uc.UserRepo.GetByEmail(ctx, email)
+	if err != nil || user == nil || user.IsEmailVerified() {
+		return nil
+	}
+
+	latest, err := uc.UserTokenRepo.GetLatestForUser(ctx, user.ID, domain.TokenPurposeEmailVerification)
+	if err == nil && latest != nil && time.Since(latest.CreatedAt) < uc.VerificationResend {
+		log.Printf("Throttled verification email for user %d", user.ID)
+		return nil
+	}
+
+	if err := uc.UserTokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	return uc.sendVerificationEmail(ctx, user)
+}
+
+// sendVerificationEmail issues a verification token and emails the confirmation link
+func (uc *AuthUsecase) sendVerificationEmail(ctx context.Context, user *domain.User) error {
+	token, err := uc.createUserToken(ctx, user.ID, domain.TokenPurposeEmailVerification, uc.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	uc.sendEmail(pkg.Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %d hours.\n\n%s/verify-email?token=%s\n",
+			user.Name, int(uc.EmailVerificationTTL.Hours()), uc.AppBaseURL, token,
+		),
+	})
+
+	return nil
+}
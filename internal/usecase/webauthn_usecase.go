@@ -0,0 +1,371 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// BeginPasskeyRegistration starts adding a passkey to the user's account. The
+// options ask for a discoverable credential with user verification, so the
+// passkey alone can sign in later; the user's other passkeys are excluded.
+// Because of that the user proves every factor they have again first: an
+// access token alone must not be enough to plant a lasting way in.
+func (uc *AuthUsecase) BeginPasskeyRegistration(ctx context.Context, userID int, currentPassword, code string, client domain.ClientInfo) (*domain.PublicKeyCredentialCreationOptions, error) {
+	if uc.WebAuthn == nil || uc.WebAuthnRepo == nil {
+		return nil, domain.ErrWebAuthnUnavailable
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	if err := uc.verifyCurrentPassword(ctx, user, currentPassword, client); err != nil {
+		return nil, err
+	}
+
+	if uc.MFARepo != nil {
+		mfa, err := uc.MFARepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if mfa.IsEnabled() {
+			if code == "" {
+				return nil, domain.ErrMFACodeRequired
+			}
+			if err := uc.verifySecondFactor(ctx, mfa, code, "", client); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	existing, err := uc.WebAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := uc.createWebAuthnChallenge(ctx, userID, domain.WebAuthnPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]domain.WebAuthnCredentialParameter, 0, len(pkg.WebAuthnAlgorithms))
+	for _, alg := range pkg.WebAuthnAlgorithms {
+		params = append(params, domain.WebAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return &domain.PublicKeyCredentialCreationOptions{
+		Challenge: challenge,
+		RP:        domain.WebAuthnRelyingParty{ID: uc.WebAuthn.ID, Name: uc.WebAuthn.Name},
+		User: domain.WebAuthnUserEntity{
+			ID:          webauthnUserHandle(user.ID),
+			Name:        user.Email,
+			DisplayName: user.Name,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            uc.WebAuthnChallengeTTL.Milliseconds(),
+		ExcludeCredentials: webauthnDescriptors(existing),
+		AuthenticatorSelection: domain.WebAuthnAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the credential the browser created and
+// stores it for the user
+func (uc *AuthUsecase) FinishPasskeyRegistration(ctx context.Context, userID int, req *domain.FinishPasskeyRegistrationRequest, client domain.ClientInfo) (*domain.WebAuthnCredential, error) {
+	if uc.WebAuthn == nil || uc.WebAuthnRepo == nil {
+		return nil, domain.ErrWebAuthnUnavailable
+	}
+
+	clientData, authData, err := uc.WebAuthn.VerifyRegistration(req.Response.ClientDataJSON, req.Response.AttestationObject, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+
+	if err := uc.consumeWebAuthnChallenge(ctx, clientData.Challenge, domain.WebAuthnPurposeRegister, userID); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(authData.CredentialID, req.RawID) {
+		return nil, fmt.Errorf("%w: credential ID does not match the authenticator data", domain.ErrInvalidPasskey)
+	}
+
+	existing, err := uc.WebAuthnRepo.GetCredential(ctx, authData.CredentialID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, domain.ErrPasskeyExists
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential := &domain.WebAuthnCredential{
+		UserID:       userID,
+		CredentialID: authData.CredentialID,
+		PublicKey:    authData.CredentialPublicKey,
+		SignCount:    authData.SignCount,
+		Transports:   webauthnTransports(req.Response.Transports),
+		AAGUID:       formatAAGUID(authData.AAGUID),
+		Name:         name,
+	}
+	if err := uc.WebAuthnRepo.CreateCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditPasskeyRegister,
+		ActorID:      userID,
+		TargetUserID: userID,
+		Details:      domain.AuditDetails{"passkey_id": strconv.Itoa(credential.ID), "name": credential.Name},
+	}, client)
+
+	if user, err := uc.UserRepo.GetByID(ctx, userID); err == nil && user != nil {
+		uc.sendEmail(pkg.Email{
+			To:      user.Email,
+			Subject: "A passkey was added to your account",
+			Body:    fmt.Sprintf("Hi %s,\n\nA passkey named %q was just added to your account. It can sign in without your password.\nIf this was not you, reset your password immediately; that also removes all of your passkeys.\n", user.Name, credential.Name),
+		})
+	}
+
+	return credential, nil
+}
+
+// BeginPasskeyLogin starts a passkey login. No account is named: the browser
+// offers the user's discoverable passkeys, and the chosen one tells who signs in.
+func (uc *AuthUsecase) BeginPasskeyLogin(ctx context.Context) (*domain.PublicKeyCredentialRequestOptions, error) {
+	if uc.WebAuthn == nil || uc.WebAuthnRepo == nil {
+		return nil, domain.ErrWebAuthnUnavailable
+	}
+
+	challenge, err := uc.createWebAuthnChallenge(ctx, 0, domain.WebAuthnPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          uc.WebAuthnChallengeTTL.Milliseconds(),
+		RPID:             uc.WebAuthn.ID,
+		AllowCredentials: []domain.WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishPasskeyLogin verifies an assertion and signs the passkey's owner in.
+// The authenticator verified the user with a PIN or biometrics, so the passkey
+// counts as both factors and no TOTP code is asked for. A sign count that did
+// not increase means the authenticator was cloned, and the login is refused.
+func (uc *AuthUsecase) FinishPasskeyLogin(ctx context.Context, req *domain.FinishPasskeyLoginRequest, client domain.ClientInfo) (*domain.LoginResult, error) {
+	if uc.WebAuthn == nil || uc.WebAuthnRepo == nil {
+		return nil, domain.ErrWebAuthnUnavailable
+	}
+
+	client.IdentityProvider = domain.PasskeyIdentityProvider
+
+	credential, err := uc.WebAuthnRepo.GetCredential(ctx, req.RawID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		uc.auditLoginFailure(ctx, "", 0, "unknown_passkey", client)
+		return nil, domain.ErrInvalidPasskey
+	}
+
+	// The user handle is optional, but when sent it must name the owner
+	if len(req.Response.UserHandle) > 0 && !bytes.Equal(req.Response.UserHandle, webauthnUserHandle(credential.UserID)) {
+		uc.auditLoginFailure(ctx, "", credential.UserID, "passkey_user_mismatch", client)
+		return nil, domain.ErrInvalidPasskey
+	}
+
+	clientData, authData, err := uc.WebAuthn.VerifyAssertion(req.Response.ClientDataJSON, req.Response.AuthenticatorData, req.Response.Signature, credential.PublicKey, true)
+	if err != nil {
+		uc.auditLoginFailure(ctx, "", credential.UserID, "invalid_passkey", client)
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPasskey, err)
+	}
+
+	if err := uc.consumeWebAuthnChallenge(ctx, clientData.Challenge, domain.WebAuthnPurposeLogin, 0); err != nil {
+		return nil, err
+	}
+
+	// Authenticators that keep no counter always report zero
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		log.Printf("Passkey %d of user %d reported sign count %d after %d, it may have been cloned", credential.ID, credential.UserID, authData.SignCount, credential.SignCount)
+		uc.auditLoginFailure(ctx, "", credential.UserID, "passkey_cloned", client)
+		return nil, fmt.Errorf("%w: sign count did not increase", domain.ErrInvalidPasskey)
+	}
+
+	if err := uc.WebAuthnRepo.UpdateSignCount(ctx, credential.ID, authData.SignCount); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.UserRepo.GetByID(ctx, credential.UserID)
+	if err != nil || user == nil {
+		return nil, domain.ErrInvalidPasskey
+	}
+
+	if user.IsDisabled() {
+		uc.auditLoginFailure(ctx, user.Email, user.ID, "account_disabled", client)
+		return nil, domain.ErrAccountDisabled
+	}
+	if uc.RequireEmailVerification && !user.IsEmailVerified() {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	tokens, err := uc.startSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LoginResult{TokenPair: tokens}, nil
+}
+
+// ListPasskeys returns the user's passkeys
+func (uc *AuthUsecase) ListPasskeys(ctx context.Context, userID int) ([]*domain.WebAuthnCredential, error) {
+	if uc.WebAuthnRepo == nil {
+		return nil, domain.ErrWebAuthnUnavailable
+	}
+	return uc.WebAuthnRepo.ListCredentials(ctx, userID)
+}
+
+// DeletePasskey removes one of the user's passkeys, it can no longer sign in
+func (uc *AuthUsecase) DeletePasskey(ctx context.Context, userID, id int, client domain.ClientInfo) error {
+	if uc.WebAuthnRepo == nil {
+		return domain.ErrWebAuthnUnavailable
+	}
+
+	deleted, err := uc.WebAuthnRepo.DeleteCredential(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.ErrPasskeyNotFound
+	}
+
+	uc.audit(ctx, &domain.AuditEvent{
+		Action:       domain.AuditPasskeyDelete,
+		ActorID:      userID,
+		TargetUserID: userID,
+		Details:      domain.AuditDetails{"passkey_id": strconv.Itoa(id)},
+	}, client)
+
+	return nil
+}
+
+// removePasskeys deletes all of the user's passkeys. A password reset calls it
+// because the reset is how a user takes back a compromised account, and a
+// passkey planted by whoever had access would otherwise keep working.
+func (uc *AuthUsecase) removePasskeys(ctx context.Context, userID int) (int, error) {
+	if uc.WebAuthnRepo == nil {
+		return 0, nil
+	}
+
+	removed, err := uc.WebAuthnRepo.DeleteCredentialsForUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if removed > 0 {
+		log.Printf("Removed %d passkeys of user %d after a password reset", removed, userID)
+	}
+
+	return removed, nil
+}
+
+// StartWebAuthnChallengeCleanup periodically removes passkey ceremonies the browser never finished
+func (uc *AuthUsecase) StartWebAuthnChallengeCleanup(interval time.Duration) {
+	if uc.WebAuthnRepo == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := uc.WebAuthnRepo.DeleteExpiredChallenges(context.Background(), time.Now()); err != nil {
+				log.Printf("Failed to clean up passkey challenges: %v", err)
+			}
+		}
+	}()
+}
+
+// createWebAuthnChallenge stores a new challenge and returns it base64url encoded,
+// the form the browser echoes back in the client data
+func (uc *AuthUsecase) createWebAuthnChallenge(ctx context.Context, userID int, purpose string) (string, error) {
+	challenge, err := pkg.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.WebAuthnRepo.CreateChallenge(ctx, &domain.WebAuthnChallenge{
+		ChallengeHash: pkg.HashToken(challenge),
+		UserID:        userID,
+		Purpose:       purpose,
+		ExpiresAt:     time.Now().Add(uc.WebAuthnChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge checks a ceremony answers a challenge issued here
+// for the same purpose and user, and uses the challenge up
+func (uc *AuthUsecase) consumeWebAuthnChallenge(ctx context.Context, challenge, purpose string, userID int) error {
+	stored, err := uc.WebAuthnRepo.ConsumeChallenge(ctx, pkg.HashToken(challenge))
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.Purpose != purpose || stored.UserID != userID || time.Now().After(stored.ExpiresAt) {
+		return domain.ErrInvalidWebAuthnChallenge
+	}
+	return nil
+}
+
+// webauthnUserHandle identifies an account to authenticators. It is returned
+// with discoverable credentials, so it holds nothing but the user ID.
+func webauthnUserHandle(userID int) domain.Base64URL {
+	return domain.Base64URL(strconv.Itoa(userID))
+}
+
+// webauthnDescriptors refers to stored credentials in ceremony options
+func webauthnDescriptors(credentials []*domain.WebAuthnCredential) []domain.WebAuthnCredentialDescriptor {
+	descriptors := make([]domain.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, domain.WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID, Transports: credential.Transports})
+	}
+	return descriptors
+}
+
+// webauthnTransports keeps the known transport hints the browser reported
+func webauthnTransports(transports []string) []string {
+	known := []string{}
+	for _, transport := range transports {
+		if slices.Contains(domain.WebAuthnTransports, transport) && !slices.Contains(known, transport) {
+			known = append(known, transport)
+		}
+	}
+	return known
+}
+
+// formatAAGUID writes an authenticator model ID as a UUID; all zeros means the model was not disclosed
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", aaguid[0:4], aaguid[4:6], aaguid[6:8], aaguid[8:10], aaguid[10:16])
+}
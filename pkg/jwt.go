@@ -1,42 +1,240 @@
 package pkg
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var secretKey = []byte(os.Getenv("JWT_SECRET"))
+// JWTConfig controls how tokens are minted and which tokens are accepted
+type JWTConfig struct {
+	// Secret is the HMAC key used when no asymmetric key manager is configured
+	Secret []byte
+	// Issuer is written to iss and must match on validation when set
+	Issuer string
+	// Audience is written to aud; validation requires at least one shared entry when set
+	Audience []string
+	// Leeway tolerates clock skew between hosts when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+var jwtConfig = JWTConfig{
+	Secret: []byte(os.Getenv("JWT_SECRET")),
+}
+
+// keyManager holds the asymmetric signing keys. When it is nil tokens are
+// signed with the shared HMAC secret.
+var keyManager *KeyManager
+
+// ConfigureJWT replaces the settings used to mint and validate tokens
+func ConfigureJWT(cfg JWTConfig) {
+	jwtConfig = cfg
+}
+
+// SetKeyManager switches token signing and verification to asymmetric keys
+func SetKeyManager(km *KeyManager) {
+	keyManager = km
+}
 
-func GenerateJWT(userID int, email string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+// GetKeyManager returns the configured key manager, or nil when HMAC signing is used
+func GetKeyManager() *KeyManager {
+	return keyManager
+}
+
+// Audience is the aud claim, which may be a single string or an array in JSON
+type Audience []string
+
+// UnmarshalJSON accepts both the string and the array form of aud
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = Audience(list)
+	return nil
 }
 
-func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// Contains reports whether any of the expected audiences is present
+func (a Audience) Contains(expected []string) bool {
+	for _, want := range expected {
+		for _, got := range a {
+			if got == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenPurposeMFA marks a token that only proves the password step of a login
+// and can solely be exchanged at /mfa/verify
+const TokenPurposeMFA = "mfa"
+
+// TokenPurposeOAuth marks an access token issued to an OAuth client, which
+// only grants what its scope allows at /oauth/userinfo
+const TokenPurposeOAuth = "oauth"
+
+// Claims are the JWT claims issued by this service
+type Claims struct {
+	ID        string   `json:"jti,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+	ExpiresAt int64    `json:"exp"`
+	UserID    int      `json:"user_id"`
+	Email     string   `json:"email"`
+	// Roles and Permissions are a snapshot taken when the token was issued
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// Purpose is empty for access tokens; restricted tokens name what they may be used for
+	Purpose string `json:"purpose,omitempty"`
+	// SessionID ties an access token to the login session it was issued for
+	SessionID string `json:"sid,omitempty"`
+	// Actor is set on impersonation tokens and names the administrator acting as the subject (RFC 8693)
+	Actor *Actor `json:"act,omitempty"`
+	// ClientID and Scope are set on tokens issued to OAuth clients
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// IssuedAtMillis refines iat, so that signing out everywhere does not
+	// also revoke tokens issued later in the same second
+	IssuedAtMillis int64 `json:"iat_ms,omitempty"`
+}
+
+// Actor identifies who is acting on behalf of a token's subject
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  int    `json:"user_id"`
+}
+
+// Valid enforces expiry, not-before, issue time, issuer and audience using the configured leeway
+func (c *Claims) Valid() error {
+	cfg := jwtConfig
+	now := time.Now()
+	leeway := int64(cfg.Leeway.Seconds())
+
+	if c.ExpiresAt == 0 || now.Unix() > c.ExpiresAt+leeway {
+		return errors.New("token is expired")
+	}
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore-leeway {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != 0 && now.Unix() < c.IssuedAt-leeway {
+		return errors.New("token used before issued")
+	}
+	if cfg.Issuer != "" && c.Issuer != cfg.Issuer {
+		return errors.New("token has an unexpected issuer")
+	}
+	if len(cfg.Audience) > 0 && !c.Audience.Contains(cfg.Audience) {
+		return errors.New("token has an unexpected audience")
+	}
+
+	return nil
+}
+
+// ExpiresAtTime returns exp as a time.Time
+func (c *Claims) ExpiresAtTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// IssuedAtTime returns the issue time, to the millisecond when the token carries it
+func (c *Claims) IssuedAtTime() time.Time {
+	if c.IssuedAtMillis != 0 {
+		return time.UnixMilli(c.IssuedAtMillis)
+	}
+	return time.Unix(c.IssuedAt, 0)
+}
+
+// NewClaims builds claims for a user with the configured issuer and audience
+func NewClaims(userID int, email string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		Subject:        strconv.Itoa(userID),
+		Issuer:         jwtConfig.Issuer,
+		Audience:       Audience(jwtConfig.Audience),
+		IssuedAt:       now.Unix(),
+		NotBefore:      now.Unix(),
+		ExpiresAt:      now.Add(ttl).Unix(),
+		IssuedAtMillis: now.UnixMilli(),
+		UserID:         userID,
+		Email:          email,
+	}
+}
+
+// SignClaims assigns a token ID when missing and signs the claims
+func SignClaims(claims *Claims) (string, error) {
+	if claims.ID == "" {
+		jti, err := GenerateSecureToken(16)
+		if err != nil {
+			return "", err
 		}
-		return secretKey, nil
-	})
+		claims.ID = jti
+	}
+
+	return signToken(claims)
+}
+
+func ValidateJWT(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid or expired token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, nil
+}
+
+// signToken signs claims with the active asymmetric key, or the HMAC secret when none is configured
+func signToken(claims jwt.Claims) (string, error) {
+	km := keyManager
+	if km == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(jwtConfig.Secret)
+	}
+
+	key := km.ActiveKey()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// verificationKey resolves the key for a parsed token. Once asymmetric keys
+// are configured HMAC tokens are refused, otherwise anyone holding the public
+// key could forge tokens by signing with it as an HMAC secret.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	km := keyManager
+	if km == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtConfig.Secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := km.VerificationKey(kid)
 	if !ok {
-		return nil, errors.New("invalid token claims")
+		return nil, errors.New("unknown signing key")
 	}
 
-	return claims, nil
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.PrivateKey.Public(), nil
 }
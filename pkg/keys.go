@@ -0,0 +1,357 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// keyIDTimeFormat starts every generated kid, so the creation time of a key
+// does not depend on its file
+const keyIDTimeFormat = "20060102T150405.000Z"
+
+// SigningKey is a private key used to sign JWTs, identified by the kid header
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	// RetiredAt is when the next newer key was created and took over
+	// signing; it is zero for the newest key
+	RetiredAt time.Time
+}
+
+// JWK is the JSON Web Key representation of a public verification key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager loads signing keys from a directory, signs with the newest one
+// and keeps older keys around for verification until they are retired.
+// Several instances may share the directory: each one reloads it on every
+// rotation check, so keys generated elsewhere are picked up as well.
+type KeyManager struct {
+	Dir              string
+	Algorithm        string
+	RotationInterval time.Duration
+	Retention        time.Duration
+
+	keys   map[string]*SigningKey
+	active *SigningKey
+	mutex  sync.RWMutex
+}
+
+// NewKeyManager loads the keys in dir, generating a first key when it is empty
+func NewKeyManager(dir, algorithm string, rotationInterval, retention time.Duration) (*KeyManager, error) {
+	if algorithm != "RS256" && algorithm != "EdDSA" {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm: %s", algorithm)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %v", err)
+	}
+
+	km := &KeyManager{
+		Dir:              dir,
+		Algorithm:        algorithm,
+		RotationInterval: rotationInterval,
+		Retention:        retention,
+		keys:             make(map[string]*SigningKey),
+	}
+
+	if err := km.Reload(); err != nil {
+		return nil, err
+	}
+
+	if km.ActiveKey() == nil {
+		if err := km.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return km, nil
+}
+
+// Reload reads every *.pem file in the key directory
+func (km *KeyManager) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(km.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*SigningKey, len(paths))
+	var active *SigningKey
+
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			log.Printf("Skipping JWT key %s: %v", path, err)
+			continue
+		}
+		keys[key.ID] = key
+
+		if key.Algorithm == km.Algorithm && (active == nil || key.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+
+	// Each key retires when its successor is created
+	ordered := make([]*SigningKey, 0, len(keys))
+	for _, key := range keys {
+		ordered = append(ordered, key)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].CreatedAt.Before(ordered[j].CreatedAt) })
+	for i := 1; i < len(ordered); i++ {
+		ordered[i-1].RetiredAt = ordered[i].CreatedAt
+	}
+
+	km.mutex.Lock()
+	km.keys = keys
+	km.active = active
+	km.mutex.Unlock()
+
+	return nil
+}
+
+// Rotate generates a new active key and removes keys past their retention period
+func (km *KeyManager) Rotate() error {
+	key, err := generateSigningKey(km.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(km.Dir, key.ID+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write JWT key: %v", err)
+	}
+
+	log.Printf("Generated new JWT signing key %s (%s)", key.ID, key.Algorithm)
+
+	if err := km.Reload(); err != nil {
+		return err
+	}
+
+	km.pruneRetiredKeys()
+	return nil
+}
+
+// StartRotation checks periodically whether the active key is due for rotation
+func (km *KeyManager) StartRotation(checkInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := km.Reload(); err != nil {
+				log.Printf("Failed to reload JWT keys: %v", err)
+				continue
+			}
+
+			active := km.ActiveKey()
+			if active == nil || time.Since(active.CreatedAt) >= km.RotationInterval {
+				if err := km.Rotate(); err != nil {
+					log.Printf("Failed to rotate JWT signing key: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// ActiveKey returns the key new tokens are signed with
+func (km *KeyManager) ActiveKey() *SigningKey {
+	km.mutex.RLock()
+	defer km.mutex.RUnlock()
+	return km.active
+}
+
+// VerificationKey returns the public key for a kid
+func (km *KeyManager) VerificationKey(kid string) (*SigningKey, bool) {
+	km.mutex.RLock()
+	defer km.mutex.RUnlock()
+	key, ok := km.keys[kid]
+	return key, ok
+}
+
+// JWKS returns every verification key as a JSON Web Key Set
+func (km *KeyManager) JWKS() JWKSet {
+	km.mutex.RLock()
+	defer km.mutex.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range km.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+
+		switch pub := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID > set.Keys[j].KeyID })
+	return set
+}
+
+// SigningMethod returns the jwt-go signing method matching a key's algorithm
+func (k *SigningKey) SigningMethod() jwt.SigningMethod {
+	if k.Algorithm == "EdDSA" {
+		return SigningMethodEd25519
+	}
+	return jwt.SigningMethodRS256
+}
+
+// pruneRetiredKeys deletes keys retired for longer than the retention period.
+// Counting from retirement keeps the key a late rotation replaced, for
+// instance after downtime, verifiable for the whole period.
+func (km *KeyManager) pruneRetiredKeys() {
+	cutoff := time.Now().Add(-km.Retention)
+
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	for kid, key := range km.keys {
+		if key == km.active || key.RetiredAt.IsZero() || key.RetiredAt.After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(km.Dir, kid+".pem")); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove retired JWT key %s: %v", kid, err)
+			continue
+		}
+		delete(km.keys, kid)
+		log.Printf("Removed retired JWT signing key %s", kid)
+	}
+}
+
+// generateSigningKey creates a fresh key pair for the given algorithm
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	suffix, err := GenerateSecureToken(4)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	key := &SigningKey{
+		ID:        now.Format(keyIDTimeFormat) + "-" + suffix,
+		Algorithm: algorithm,
+		CreatedAt: now,
+	}
+
+	switch algorithm {
+	case "RS256":
+		key.PrivateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, key.PrivateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unsupported JWT signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// loadSigningKey parses a PKCS#8 PEM file; the file name (without extension)
+// is the kid. The creation time is taken from the kid rather than the file,
+// whose modification time changes when keys are copied or restored. Keys
+// placed by hand under other names fall back to the modification time.
+func loadSigningKey(path string) (*SigningKey, error) {
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	createdAt, err := keyCreatedAt(id)
+	if err != nil {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, statErr
+		}
+		log.Printf("Warning: %v, using the modification time of %s", err, path)
+		createdAt = info.ModTime().UTC()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:        id,
+		CreatedAt: createdAt,
+	}
+
+	switch privateKey := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm = "RS256"
+		key.PrivateKey = privateKey
+	case ed25519.PrivateKey:
+		key.Algorithm = "EdDSA"
+		key.PrivateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// keyCreatedAt reads the creation time at the start of a kid. The layout has
+// no fraction, which lets time.Parse accept both whole seconds, as in older
+// kids, and the milliseconds of keyIDTimeFormat.
+func keyCreatedAt(kid string) (time.Time, error) {
+	timestamp, _, _ := strings.Cut(kid, "-")
+	createdAt, err := time.Parse("20060102T150405Z", timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("key name %q does not start with its creation time", kid)
+	}
+	return createdAt, nil
+}
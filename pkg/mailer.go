@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Email is an outgoing plain text message
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// LogMailer is meant for local development: it logs every email and, when Dir
+// is set, also writes it to an .eml file so links can be copied from disk.
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+// NewLogMailer creates a mailer that logs messages and optionally stores them in dir
+func NewLogMailer(dir, from string) *LogMailer {
+	return &LogMailer{Dir: dir, From: from}
+}
+
+// Send logs the email and writes it to the mail directory if configured
+func (m *LogMailer) Send(ctx context.Context, email Email) error {
+	log.Printf("Email to %s: %s\n%s", email.To, email.Subject, email.Body)
+
+	if m.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	suffix, err := GenerateSecureToken(4)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405Z"), suffix)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage(m.From, email), 0600)
+}
+
+// SMTPMailer sends emails through an SMTP server such as a local Mailpit instance
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer creates a mailer that relays through the given SMTP server
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Send delivers the email over SMTP, authenticating only when credentials are configured
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := m.Host + ":" + m.Port
+	if err := smtp.SendMail(addr, auth, m.From, []string{email.To}, buildMessage(m.From, email)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+
+	return nil
+}
+
+// buildMessage renders an RFC 5322 message
+func buildMessage(from string, email Email) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + email.To + "\r\n")
+	b.WriteString("Subject: " + email.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,377 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// oidcLeeway tolerates clock skew between this service and an identity provider
+const oidcLeeway = time.Minute
+
+// oidcKeyRefreshInterval limits how often an unknown kid triggers a JWKS download
+const oidcKeyRefreshInterval = time.Minute
+
+// OIDCDiscovery is the subset of /.well-known/openid-configuration used to sign users in
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	// The metadata below is only published by this app as a provider
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported,omitempty"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	// IntrospectionEndpointAuthMethods are the client authentication methods of the introspection endpoint
+	IntrospectionEndpointAuthMethods []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+}
+
+// OIDCTokenResponse is the token endpoint response of an authorization code exchange
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// IDTokenClaims are the ID token claims needed to identify a user
+type IDTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        Audience `json:"aud"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	EmailVerified   bool     `json:"email_verified,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	AuthTime        int64    `json:"auth_time,omitempty"`
+	Picture         string   `json:"picture,omitempty"`
+	Zoneinfo        string   `json:"zoneinfo,omitempty"`
+}
+
+// Valid checks the token lifetime; issuer, audience and nonce are checked by VerifyIDToken
+func (c *IDTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(oidcLeeway)) {
+		return errors.New("ID token has expired")
+	}
+	if c.IssuedAt != 0 && time.Unix(c.IssuedAt, 0).After(now.Add(oidcLeeway)) {
+		return errors.New("ID token was issued in the future")
+	}
+	return nil
+}
+
+// OIDCProvider is an external OpenID Connect identity provider users can sign
+// in with. Discovery and signing keys are fetched on first use and cached.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	discovery   *OIDCDiscovery
+	keys        map[string]interface{}
+	keysFetched time.Time
+	mutex       sync.Mutex
+}
+
+// NewOIDCProvider creates a provider; the issuer must serve the discovery document
+func NewOIDCProvider(name, issuer, clientID, clientSecret, redirectURL string, scopes []string) *OIDCProvider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Discover fetches and caches the provider's discovery document
+func (p *OIDCProvider) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery OIDCDiscovery
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery for %s failed: %w", p.Name, err)
+	}
+
+	// A document for another issuer would let that issuer's tokens in
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("OIDC discovery for %s returned issuer %q", p.Name, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery for %s is missing endpoints", p.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// AuthCodeURL returns the authorization endpoint URL that starts a login,
+// using the authorization code flow with an S256 PKCE challenge
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange trades an authorization code and its PKCE verifier for tokens
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*OIDCTokenResponse, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC token exchange with %s failed with status %d: %s", p.Name, resp.StatusCode, body)
+	}
+
+	var tokens OIDCTokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("OIDC token response from %s has no id_token", p.Name)
+	}
+
+	return &tokens, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, lifetime and nonce of an ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, token)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != p.Issuer {
+		return nil, errors.New("ID token was issued by another provider")
+	}
+	if !claims.Audience.Contains([]string{p.ClientID}) {
+		return nil, errors.New("ID token was issued for another client")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID {
+		return nil, errors.New("ID token was issued for another client")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+
+	return claims, nil
+}
+
+// verificationKey returns the provider key an ID token was signed with. An
+// unknown kid refreshes the key set, so keys rotated by the provider are found.
+func (p *OIDCProvider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *SigningMethodEdDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := p.lookupKey(ctx, kid, false)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if key, err = p.lookupKey(ctx, kid, true); err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+			return key, nil
+		}
+	case ed25519.PublicKey:
+		if _, ok := token.Method.(*SigningMethodEdDSA); ok {
+			return key, nil
+		}
+	}
+	return nil, errors.New("signing method does not match the key")
+}
+
+// lookupKey finds a key by kid, downloading the key set when it is not loaded
+// yet or, with refresh, when it was last downloaded a while ago
+func (p *OIDCProvider) lookupKey(ctx context.Context, kid string, refresh bool) (interface{}, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stale := refresh && time.Since(p.keysFetched) > oidcKeyRefreshInterval
+	if p.keys == nil || stale {
+		var set JWKSet
+		if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+			return nil, fmt.Errorf("failed to load signing keys of %s: %w", p.Name, err)
+		}
+		p.keys = parseJWKSet(set)
+		p.keysFetched = time.Now()
+	}
+
+	// Providers with a single key may leave out the kid
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return p.keys[kid], nil
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *OIDCProvider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", target, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// parseJWKSet converts the signing keys of a JWK set, skipping key types this service cannot verify
+func parseJWKSet(set JWKSet) map[string]interface{} {
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		switch jwk.KeyType {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if jwk.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[jwk.KeyID] = ed25519.PublicKey(x)
+		}
+	}
+	return keys
+}
+
+// PKCEChallenge derives the S256 code challenge of a PKCE code verifier (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SignIDToken signs an ID token this app issues as a provider. Clients verify
+// it with the published JWKS, so it needs the asymmetric signing keys; the
+// shared HMAC secret must never be handed to clients.
+func SignIDToken(claims *IDTokenClaims) (string, error) {
+	if keyManager == nil {
+		return "", errors.New("ID tokens require asymmetric signing keys")
+	}
+	return signToken(claims)
+}
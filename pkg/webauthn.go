@@ -0,0 +1,319 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Flags of the authenticator data
+const (
+	WebAuthnFlagUserPresent  = 0x01
+	WebAuthnFlagUserVerified = 0x04
+	WebAuthnFlagAttestedData = 0x40
+	WebAuthnFlagExtensions   = 0x80
+)
+
+// COSE algorithms of the credential public keys this app accepts
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// WebAuthnAlgorithms are the accepted algorithms in order of preference
+var WebAuthnAlgorithms = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Ceremony types written to the client data by the browser
+const (
+	WebAuthnCeremonyCreate = "webauthn.create"
+	WebAuthnCeremonyGet    = "webauthn.get"
+)
+
+// WebAuthnClientData is the clientDataJSON the browser signs over
+type WebAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AuthenticatorData is the data the authenticator signs (WebAuthn section 6.1).
+// The credential fields are only present when a credential is registered.
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	AAGUID    []byte
+	// CredentialID and CredentialPublicKey, a COSE key, describe a new credential
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+// UserVerified reports whether the authenticator verified the user, with a PIN or biometrics
+func (d *AuthenticatorData) UserVerified() bool {
+	return d.Flags&WebAuthnFlagUserVerified != 0
+}
+
+// RelyingParty verifies WebAuthn ceremonies for this app. ID is the domain
+// credentials are scoped to; Origins are the exact origins pages may run on.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// NewRelyingParty creates a relying party
+func NewRelyingParty(id, name string, origins []string) *RelyingParty {
+	return &RelyingParty{ID: id, Name: name, Origins: origins}
+}
+
+// VerifyRegistration checks a new credential from navigator.credentials.create
+// and returns the client data, whose challenge the caller must check, and the
+// authenticator data holding the credential. Only the "none" attestation is
+// accepted: passkeys are trusted on first use, not by their make and model.
+func (rp *RelyingParty) VerifyRegistration(clientDataJSON, attestationObject []byte, requireUserVerification bool) (*WebAuthnClientData, *AuthenticatorData, error) {
+	clientData, err := rp.verifyClientData(clientDataJSON, WebAuthnCeremonyCreate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoded, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, nil, errors.New("invalid attestation object")
+	}
+
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if format != "none" || len(statement) != 0 {
+		return nil, nil, fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := rp.checkAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, nil, errors.New("authenticator data has no credential")
+	}
+	if _, _, err := parseCOSEKey(authData.CredentialPublicKey); err != nil {
+		return nil, nil, err
+	}
+
+	return clientData, authData, nil
+}
+
+// VerifyAssertion checks a signature from navigator.credentials.get made with
+// a registered credential's COSE public key. The caller checks the challenge
+// and the sign count.
+func (rp *RelyingParty) VerifyAssertion(clientDataJSON, rawAuthData, signature, publicKey []byte, requireUserVerification bool) (*WebAuthnClientData, *AuthenticatorData, error) {
+	clientData, err := rp.verifyClientData(clientDataJSON, WebAuthnCeremonyGet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := rp.checkAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := verifyCOSESignature(publicKey, signed, signature); err != nil {
+		return nil, nil, err
+	}
+
+	return clientData, authData, nil
+}
+
+// ParseAuthenticatorData decodes authenticator data. Extensions are skipped.
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	data := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if data.Flags&WebAuthnFlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		data.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, errors.New("invalid credential ID length")
+		}
+		data.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The COSE key is the only way to tell where it ends
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		data.CredentialPublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if data.Flags&WebAuthnFlagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extensions: %w", err)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("authenticator data has trailing bytes")
+	}
+
+	return data, nil
+}
+
+// COSEAlgorithm returns the algorithm of a COSE public key
+func COSEAlgorithm(coseKey []byte) (int, error) {
+	_, alg, err := parseCOSEKey(coseKey)
+	return alg, err
+}
+
+// verifyClientData parses clientDataJSON and checks the ceremony and origin
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string) (*WebAuthnClientData, error) {
+	var clientData WebAuthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, errors.New("invalid client data")
+	}
+
+	if clientData.Type != ceremony {
+		return nil, fmt.Errorf("unexpected ceremony %q", clientData.Type)
+	}
+	if clientData.CrossOrigin {
+		return nil, errors.New("cross-origin ceremonies are not allowed")
+	}
+
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return &clientData, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected origin %q", clientData.Origin)
+}
+
+// checkAuthenticatorData checks the credential is scoped to this relying party and the user was present
+func (rp *RelyingParty) checkAuthenticatorData(data *AuthenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.RPIDHash, rpIDHash[:]) {
+		return errors.New("credential belongs to another relying party")
+	}
+	if data.Flags&WebAuthnFlagUserPresent == 0 {
+		return errors.New("user presence was not confirmed")
+	}
+	if requireUserVerification && !data.UserVerified() {
+		return errors.New("user verification is required")
+	}
+	return nil
+}
+
+// parseCOSEKey decodes a COSE_Key (RFC 9053) of one of the accepted algorithms
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int, error) {
+	decoded, rest, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid P-256 key")
+		}
+		// ecdh rejects points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, 0, errors.New("invalid P-256 key")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, COSEAlgES256, nil
+
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), COSEAlgEdDSA, nil
+
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA key")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if exponent.Int64() < 3 {
+			return nil, 0, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, COSEAlgRS256, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verifyCOSESignature checks a signature with a COSE public key
+func verifyCOSESignature(coseKey, data, signature []byte) error {
+	publicKey, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature)
+	case COSEAlgEdDSA:
+		valid = ed25519.Verify(publicKey.(ed25519.PublicKey), data, signature)
+	case COSEAlgRS256:
+		digest := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"encoding/json"
 	"github.com/gorilla/websocket"
+	"time"
 )
 
 // WebSocketMessage represents a message sent over WebSocket
@@ -19,6 +20,12 @@ type Client struct {
 	Send chan WebSocketMessage
 	// UserID from authentication
 	ID int
+	// Access token the connection was opened with, empty for API keys
+	TokenID   string
+	SessionID string
+	IssuedAt  time.Time
+	// Away is set while the user is idle on this connection
+	Away bool
 }
 
 // NewClient creates a new WebSocket client
@@ -36,6 +43,15 @@ const (
 	TypeChat          = "chat"
 	TypeChatConfirmed = "chat_confirmed"
 	TypeError         = "error"
+	TypeRead          = "read"
+	TypeTypingStart   = "typing_start"
+	TypeTypingStop    = "typing_stop"
+	TypePresence      = "presence"
+	// Edits and deletions are sent as edit and delete, members are told with message_edited and message_deleted
+	TypeEdit           = "edit"
+	TypeDelete         = "delete"
+	TypeMessageEdited  = "message_edited"
+	TypeMessageDeleted = "message_deleted"
 )
 
 // ChatMessage represents a chat message sent over WebSocket
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+)
+
+// setupAccountTestRouter creates a test router for account deletion and export
+func setupAccountTestRouter() (*gin.Engine, *usecase.AuthUsecase, *MockUserRepository, *MockChatRepository, *MockRevocationRepository, *MockRefreshTokenRepository, *RecordingMailer) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockChatRepo := new(MockChatRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	revocationService := service.NewRevocationService(nil)
+	mailer := NewRecordingMailer()
+
+	router, authUsecase := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+			ChatRepo:          mockChatRepo,
+			Mailer:            mailer,
+		},
+		Config: &config.Config{AccountDeletionGracePeriod: 7},
+	}.newRouter()
+
+	return router, authUsecase, mockUserRepo, mockChatRepo, mockRevocationRepo, mockRefreshTokenRepo, mailer
+}
+
+// TestDeleteAccount tests that deleting requires the password and signs the account out everywhere
+func TestDeleteAccount(t *testing.T) {
+	router, _, mockUserRepo, _, mockRevocationRepo, mockRefreshTokenRepo, mailer := setupAccountTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil)
+	token, _ := userToken(1, "test@example.com")
+
+	req := jsonRequest("DELETE", "/me", map[string]string{"current_password": "wrong-password"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+	mockUserRepo.AssertNotCalled(t, "MarkDeleted", mock.Anything, mock.Anything, mock.Anything)
+
+	mockUserRepo.On("MarkDeleted", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil).Once()
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+
+	req = jsonRequest("DELETE", "/me", map[string]string{"current_password": "password1234"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	var body struct {
+		PurgeAt time.Time `json:"purge_at"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), body.PurgeAt, time.Minute)
+	assert.Contains(t, mailer.Next(t).Body, "log in before then")
+
+	// The token used for the deletion no longer works
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/me", token).Code)
+
+	mockUserRepo.AssertExpectations(t)
+	mockRevocationRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// TestLoginRestoresDeletedAccount tests that logging in during the grace period cancels the deletion
+func TestLoginRestoresDeletedAccount(t *testing.T) {
+	router, _, mockUserRepo, _, _, mockRefreshTokenRepo, mailer := setupAccountTestRouter()
+
+	user := profileUser()
+	deletedAt := time.Now().Add(-24 * time.Hour)
+	user.DeletedAt = &deletedAt
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+	mockUserRepo.On("Restore", mock.Anything, 1).Return(nil).Once()
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "test@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Your account deletion was cancelled", mailer.Next(t).Subject)
+
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestExportAccount tests the ZIP and JSON exports of the caller's data
+func TestExportAccount(t *testing.T) {
+	router, _, mockUserRepo, mockChatRepo, _, _, _ := setupAccountTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil)
+	mockChatRepo.On("GetConversationsByUserID", mock.Anything, 1).Return([]*domain.Conversation{
+		{ID: 3, User1ID: 1, User2ID: 2, LastMessage: "See you"},
+	}, nil)
+	mockChatRepo.On("GetMessagesByUserID", mock.Anything, 1).Return([]*domain.Message{
+		{ID: 10, SenderID: 1, ReceiverID: 2, Content: "Hello"},
+		{ID: 11, SenderID: 2, ReceiverID: 1, Content: "See you"},
+	}, nil)
+
+	token, _ := userToken(1, "test@example.com")
+
+	w := performAuthorized(router, "GET", "/me/export", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		assert.NoError(t, err)
+		files[file.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	assert.Len(t, files, 3)
+
+	var profile map[string]interface{}
+	json.Unmarshal(files["profile.json"], &profile)
+	assert.Equal(t, "test@example.com", profile["email"])
+	assert.NotContains(t, profile, "password")
+
+	var messages []domain.Message
+	json.Unmarshal(files["messages.json"], &messages)
+	assert.Len(t, messages, 2)
+
+	// The same data as one JSON document
+	w = performAuthorized(router, "GET", "/me/export?format=json", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var export domain.AccountExport
+	json.Unmarshal(w.Body.Bytes(), &export)
+	assert.Equal(t, 1, export.Profile.ID)
+	assert.Len(t, export.Conversations, 1)
+	assert.Len(t, export.Messages, 2)
+}
+
+// TestPurgeDeletedAccounts tests that only accounts still deleted after the grace period are purged
+func TestPurgeDeletedAccounts(t *testing.T) {
+	_, authUsecase, mockUserRepo, _, _, _, _ := setupAccountTestRouter()
+
+	beforeGrace := mock.MatchedBy(func(cutoff time.Time) bool {
+		return cutoff.Before(time.Now().Add(-7*24*time.Hour + time.Minute))
+	})
+	mockUserRepo.On("ListDeletedBefore", mock.Anything, beforeGrace).Return([]int{3, 4}, nil)
+	mockUserRepo.On("Purge", mock.Anything, 3, beforeGrace).Return(true, nil)
+	// Account 4 logged in again before it was reached
+	mockUserRepo.On("Purge", mock.Anything, 4, beforeGrace).Return(false, nil)
+
+	purged, err := authUsecase.PurgeDeletedAccounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	mockUserRepo.AssertExpectations(t)
+}
@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// adminUsersTest holds the router and mocks of the admin user management tests
+type adminUsersTest struct {
+	router           *gin.Engine
+	userRepo         *MockUserRepository
+	roleRepo         *MockRoleRepository
+	revocationRepo   *MockRevocationRepository
+	refreshTokenRepo *MockRefreshTokenRepository
+	userTokenRepo    *MockUserTokenRepository
+	sessionRepo      *MockSessionRepository
+	mailer           *RecordingMailer
+}
+
+// setupAdminUsersTestRouter creates a test router for the /admin/users endpoints
+func setupAdminUsersTestRouter() *adminUsersTest {
+	gin.SetMode(gin.TestMode)
+
+	test := &adminUsersTest{
+		router:           gin.Default(),
+		userRepo:         new(MockUserRepository),
+		roleRepo:         new(MockRoleRepository),
+		revocationRepo:   new(MockRevocationRepository),
+		refreshTokenRepo: new(MockRefreshTokenRepository),
+		userTokenRepo:    new(MockUserTokenRepository),
+		sessionRepo:      new(MockSessionRepository),
+		mailer:           NewRecordingMailer(),
+	}
+	revocationService := service.NewRevocationService(nil)
+
+	testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          test.userRepo,
+			RefreshTokenRepo:  test.refreshTokenRepo,
+			RevocationRepo:    test.revocationRepo,
+			RevocationService: revocationService,
+			UserTokenRepo:     test.userTokenRepo,
+			RoleRepo:          test.roleRepo,
+			SessionRepo:       test.sessionRepo,
+			Mailer:            test.mailer,
+		},
+		Config: &config.Config{AppBaseURL: "http://app.test"},
+	}.setupRoutes(test.router)
+
+	return test
+}
+
+// adminToken signs an access token for an administrator
+func adminToken(userID int) string {
+	return tokenWithPermissions(userID, []string{domain.RoleAdmin}, []string{domain.PermissionUsersManage})
+}
+
+// TestListUsers tests filtering, pagination limits and that regular users are refused
+func TestListUsers(t *testing.T) {
+	test := setupAdminUsersTestRouter()
+
+	test.userRepo.On("List", mock.Anything, domain.UserFilter{
+		Query:  "alice",
+		Status: domain.UserStatusDisabled,
+		Role:   domain.RoleOperator,
+		Limit:  domain.MaxUserPageSize,
+		Offset: 40,
+	}).Return([]*domain.User{{ID: 4, Name: "Alice", Email: "alice@example.com"}}, 41, nil).Once()
+
+	w := performAuthorized(test.router, "GET", "/admin/users?q=+alice+&status=disabled&role=operator&limit=500&offset=40", adminToken(1))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page domain.UserPage
+	json.Unmarshal(w.Body.Bytes(), &page)
+	assert.Equal(t, 41, page.Total)
+	assert.Equal(t, domain.MaxUserPageSize, page.Limit)
+	assert.Len(t, page.Users, 1)
+
+	assert.Equal(t, http.StatusBadRequest, performAuthorized(test.router, "GET", "/admin/users?status=sleeping", adminToken(1)).Code)
+
+	userToken, _ := userToken(2, "user@example.com")
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "GET", "/admin/users", userToken).Code)
+
+	test.userRepo.AssertExpectations(t)
+}
+
+// TestDisableUser tests that a disabled account is signed out and cannot log in until enabled again
+func TestDisableUser(t *testing.T) {
+	test := setupAdminUsersTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	user := &domain.User{ID: 2, Email: "user@example.com", Password: string(hashedPassword)}
+	test.userRepo.On("GetByID", mock.Anything, 2).Return(user, nil)
+	test.userRepo.On("SetDisabled", mock.Anything, 2, mock.MatchedBy(func(at *time.Time) bool { return at != nil })).Run(func(args mock.Arguments) {
+		user.DisabledAt = args.Get(2).(*time.Time)
+	}).Return(nil).Once()
+	test.revocationRepo.On("RevokeAllForUser", mock.Anything, 2, mock.AnythingOfType("time.Time")).Return(nil)
+	test.sessionRepo.On("RevokeAllForUser", mock.Anything, 2).Return(nil)
+	test.refreshTokenRepo.On("RevokeAllForUser", mock.Anything, 2).Return(nil)
+
+	// Administrators cannot lock themselves out
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "POST", "/admin/users/1/disable", adminToken(1)).Code)
+
+	userToken, _ := userToken(2, "user@example.com")
+	req := jsonRequest("POST", "/admin/users/2/disable", map[string]string{"reason": "spam"})
+	req.Header.Set("Authorization", "Bearer "+adminToken(1))
+	assert.Equal(t, http.StatusOK, serve(test.router, req).Code)
+
+	// Existing tokens are revoked and logging in is refused
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(test.router, "GET", "/protected", userToken).Code)
+
+	test.userRepo.On("GetByEmail", mock.Anything, "user@example.com").Return(user, nil)
+	login := jsonRequest("POST", "/login", map[string]string{"email": "user@example.com", "password": "password1234"})
+	login.RemoteAddr = "198.51.100.7:40000"
+	assert.Equal(t, http.StatusForbidden, serve(test.router, login).Code)
+
+	test.userRepo.On("SetDisabled", mock.Anything, 2, (*time.Time)(nil)).Return(nil).Once()
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "POST", "/admin/users/2/enable", adminToken(1)).Code)
+
+	test.userRepo.On("GetByID", mock.Anything, 99).Return(nil, nil)
+	assert.Equal(t, http.StatusNotFound, performAuthorized(test.router, "POST", "/admin/users/99/disable", adminToken(1)).Code)
+
+	test.userRepo.AssertExpectations(t)
+	test.sessionRepo.AssertExpectations(t)
+}
+
+// TestForcePasswordReset tests that the old password stops working and a reset link is emailed
+func TestForcePasswordReset(t *testing.T) {
+	test := setupAdminUsersTestRouter()
+
+	test.userRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{ID: 2, Name: "User", Email: "user@example.com"}, nil)
+	test.userRepo.On("UpdatePassword", mock.Anything, 2, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("password1234")) != nil
+	})).Return(nil).Once()
+	test.revocationRepo.On("RevokeAllForUser", mock.Anything, 2, mock.AnythingOfType("time.Time")).Return(nil)
+	test.sessionRepo.On("RevokeAllForUser", mock.Anything, 2).Return(nil)
+	test.refreshTokenRepo.On("RevokeAllForUser", mock.Anything, 2).Return(nil)
+	test.userTokenRepo.On("InvalidateForUser", mock.Anything, 2, domain.TokenPurposePasswordReset).Return(nil)
+
+	var storedHash string
+	test.userTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *domain.UserToken) bool {
+		return token.Purpose == domain.TokenPurposePasswordReset && token.UserID == 2
+	})).Run(func(args mock.Arguments) {
+		storedHash = args.Get(1).(*domain.UserToken).TokenHash
+	}).Return(nil)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "POST", "/admin/users/2/password-reset", adminToken(1)).Code)
+
+	email := test.mailer.Next(t)
+	assert.Equal(t, "user@example.com", email.To)
+	link := regexp.MustCompile(`/password/reset\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(email.Body)
+	assert.Len(t, link, 2)
+	assert.Equal(t, pkg.HashToken(link[1]), storedHash)
+
+	test.userRepo.AssertExpectations(t)
+	test.revocationRepo.AssertExpectations(t)
+}
+
+// TestRevokeUserSession tests that an administrator can sign a user out of a single device
+func TestRevokeUserSession(t *testing.T) {
+	test := setupAdminUsersTestRouter()
+
+	test.userRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{ID: 2, Email: "user@example.com"}, nil)
+	test.sessionRepo.On("Revoke", mock.Anything, "phone-session", 2).Return(true, nil).Once()
+	test.sessionRepo.On("Revoke", mock.Anything, "unknown-session", 2).Return(false, nil)
+	test.refreshTokenRepo.On("RevokeFamily", mock.Anything, "phone-session").Return(nil)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "DELETE", "/admin/users/2/sessions/phone-session", adminToken(1)).Code)
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(test.router, "GET", "/protected", sessionToken(2, "phone-session")).Code)
+	assert.Equal(t, http.StatusNotFound, performAuthorized(test.router, "DELETE", "/admin/users/2/sessions/unknown-session", adminToken(1)).Code)
+
+	test.sessionRepo.AssertExpectations(t)
+}
+
+// TestImpersonateUser tests that impersonation tokens are marked and cannot manage the account
+func TestImpersonateUser(t *testing.T) {
+	test := setupAdminUsersTestRouter()
+
+	test.userRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{ID: 2, Email: "user@example.com"}, nil)
+	test.userRepo.On("GetByID", mock.Anything, 3).Return(&domain.User{ID: 3, Email: "other-admin@example.com"}, nil)
+	test.roleRepo.On("GetUserAccess", mock.Anything, 2).Return(&domain.UserAccess{Roles: []string{domain.RoleUser}, Permissions: []string{}}, nil)
+	test.roleRepo.On("GetUserAccess", mock.Anything, 3).Return(&domain.UserAccess{Roles: []string{domain.RoleAdmin}, Permissions: []string{domain.PermissionUsersManage}}, nil)
+
+	w := performAuthorized(test.router, "POST", "/admin/users/2/impersonate", adminToken(1))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var token domain.ImpersonationToken
+	json.Unmarshal(w.Body.Bytes(), &token)
+	assert.Equal(t, 1, token.ImpersonatorID)
+
+	claims, err := pkg.ValidateJWT(token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, claims.UserID)
+	assert.Equal(t, 1, claims.Actor.UserID)
+	assert.Equal(t, "1", claims.Actor.Subject)
+	assert.Empty(t, claims.SessionID)
+
+	// The token acts as the user but cannot change their account
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "GET", "/protected", token.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "POST", "/logout-all", token.AccessToken).Code)
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "GET", "/me/export", token.AccessToken).Code)
+
+	// Other administrators and the caller themselves cannot be impersonated
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "POST", "/admin/users/3/impersonate", adminToken(1)).Code)
+	assert.Equal(t, http.StatusForbidden, performAuthorized(test.router, "POST", "/admin/users/1/impersonate", adminToken(1)).Code)
+
+	// Signing the administrator out everywhere ends the impersonation as well
+	test.revocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	test.sessionRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+	test.refreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "POST", "/logout-all", adminToken(1)).Code)
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(test.router, "GET", "/protected", token.AccessToken).Code)
+}
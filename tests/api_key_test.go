@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock API Key Repository
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	if key, ok := args.Get(0).(*domain.APIKey); ok {
+		return key, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListByUser(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if keys, ok := args.Get(0).([]*domain.APIKey); ok {
+		return keys, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(ctx context.Context, id, userID int) (bool, error) {
+	args := m.Called(ctx, id, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// setupAPIKeyTestRouter creates a test router with API key authentication
+func setupAPIKeyTestRouter() (*gin.Engine, *MockUserRepository, *MockRoleRepository, *MockAPIKeyRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	mockAPIKeyRepo := new(MockAPIKeyRepository)
+
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo, RoleRepo: mockRoleRepo, APIKeyRepo: mockAPIKeyRepo},
+		Config:      &config.Config{},
+		NATSHandler: delivery.NewNATSHandler(usecase.NewNATSUsecase(&pkg.NatsClient{}), nil),
+	}.newRouter()
+
+	return router, mockUserRepo, mockRoleRepo, mockAPIKeyRepo
+}
+
+// withAPIKey sends a request authenticated by an X-API-Key header
+func withAPIKey(router *gin.Engine, method, path, key string) int {
+	req := jsonRequest(method, path, nil)
+	req.Header.Set("X-API-Key", key)
+	return serve(router, req).Code
+}
+
+// TestCreateAPIKey tests that keys are shown once, stored hashed and limited to the owner's permissions
+func TestCreateAPIKey(t *testing.T) {
+	router, _, mockRoleRepo, mockAPIKeyRepo := setupAPIKeyTestRouter()
+
+	token, _ := userToken(1, "test@example.com")
+	mockRoleRepo.On("GetUserAccess", mock.Anything, 1).Return(&domain.UserAccess{
+		Roles:       []string{domain.RoleOperator},
+		Permissions: []string{domain.PermissionNATSRead},
+	}, nil)
+
+	var stored *domain.APIKey
+	mockAPIKeyRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.APIKey")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*domain.APIKey)
+		stored.ID = 5
+	}).Return(nil)
+
+	req := jsonRequest("POST", "/api-keys", map[string]interface{}{"name": "gateway", "scopes": []string{"chat", "nats:read", "chat"}, "expires_in_days": 30})
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		ID     int      `json:"id"`
+		Key    string   `json:"key"`
+		Prefix string   `json:"prefix"`
+		Scopes []string `json:"scopes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, 5, created.ID)
+	assert.True(t, strings.HasPrefix(created.Key, "gaa_"+created.Prefix+"_"))
+	assert.Len(t, created.Prefix, 16)
+	assert.Equal(t, []string{"chat", "nats:read"}, created.Scopes)
+	assert.Equal(t, pkg.HashToken(created.Key), stored.KeyHash)
+	assert.NotContains(t, w.Body.String(), stored.KeyHash)
+	assert.NotNil(t, stored.ExpiresAt)
+
+	// Scopes the user does not hold are refused
+	req = jsonRequest("POST", "/api-keys", map[string]interface{}{"name": "too much", "scopes": []string{"nats:publish"}})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusBadRequest, serve(router, req).Code)
+}
+
+// TestAPIKeysCannotAdminister tests that administrators cannot give keys their
+// admin permissions and that keys holding them are refused by the admin routes
+func TestAPIKeysCannotAdminister(t *testing.T) {
+	router, mockUserRepo, mockRoleRepo, mockAPIKeyRepo := setupAPIKeyTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "admin@example.com"}, nil)
+	mockRoleRepo.On("GetUserAccess", mock.Anything, 1).Return(&domain.UserAccess{
+		Roles:       []string{domain.RoleAdmin},
+		Permissions: []string{domain.PermissionUsersManage, domain.PermissionAuditRead},
+	}, nil)
+
+	for _, scope := range domain.AdminPermissions {
+		req := jsonRequest("POST", "/api-keys", map[string]interface{}{"name": "admin script", "scopes": []string{scope}})
+		req.Header.Set("Authorization", "Bearer "+adminToken(1))
+		assert.Equal(t, http.StatusBadRequest, serve(router, req).Code)
+	}
+
+	// Keys created before the restriction do not reach the admin routes either
+	rawKey := "gaa_33333333_legacy_admin_key"
+	mockAPIKeyRepo.On("TouchLastUsed", mock.Anything, 3).Return(nil)
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "33333333").Return(&domain.APIKey{
+		ID: 3, UserID: 1, KeyHash: pkg.HashToken(rawKey), Scopes: domain.AdminPermissions,
+	}, nil)
+	assert.Equal(t, http.StatusForbidden, withAPIKey(router, "GET", "/admin/users", rawKey))
+	assert.Equal(t, http.StatusForbidden, withAPIKey(router, "GET", "/admin/audit", rawKey))
+}
+
+// TestAPIKeyAuthentication tests the X-API-Key header, scope checks and revoked keys
+func TestAPIKeyAuthentication(t *testing.T) {
+	router, mockUserRepo, mockRoleRepo, mockAPIKeyRepo := setupAPIKeyTestRouter()
+
+	rawKey := "gaa_0a1b2c3d_c2VjcmV0X3dpdGhfdW5kZXJzY29yZXM"
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockRoleRepo.On("GetUserAccess", mock.Anything, 1).Return(&domain.UserAccess{
+		Roles:       []string{domain.RoleOperator},
+		Permissions: []string{domain.PermissionNATSRead, domain.PermissionNATSPublish},
+	}, nil)
+	mockAPIKeyRepo.On("TouchLastUsed", mock.Anything, 5).Return(nil)
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "0a1b2c3d").Return(&domain.APIKey{
+		ID:      5,
+		UserID:  1,
+		Prefix:  "0a1b2c3d",
+		KeyHash: pkg.HashToken(rawKey),
+		Scopes:  []string{domain.ScopeChat},
+	}, nil).Once()
+
+	// A chat-only key authenticates but does not carry the owner's NATS permissions
+	assert.Equal(t, http.StatusOK, withAPIKey(router, "GET", "/protected", rawKey))
+
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "0a1b2c3d").Return(&domain.APIKey{
+		ID:      5,
+		UserID:  1,
+		Prefix:  "0a1b2c3d",
+		KeyHash: pkg.HashToken(rawKey),
+		Scopes:  []string{domain.ScopeChat},
+	}, nil).Once()
+	assert.Equal(t, http.StatusForbidden, withAPIKey(router, "GET", "/nats/topics", rawKey))
+
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "0a1b2c3d").Return(&domain.APIKey{
+		ID:      5,
+		UserID:  1,
+		Prefix:  "0a1b2c3d",
+		KeyHash: pkg.HashToken(rawKey),
+		Scopes:  []string{domain.PermissionNATSRead},
+	}, nil)
+	assert.Equal(t, http.StatusOK, withAPIKey(router, "GET", "/nats/topics", rawKey))
+	assert.Equal(t, http.StatusForbidden, withAPIKey(router, "POST", "/nats/publish", rawKey))
+
+	// API keys cannot manage API keys
+	assert.Equal(t, http.StatusForbidden, withAPIKey(router, "GET", "/api-keys", rawKey))
+
+	// Wrong secret or malformed keys are rejected
+	assert.Equal(t, http.StatusUnauthorized, withAPIKey(router, "GET", "/protected", "gaa_0a1b2c3d_wrong"))
+	assert.Equal(t, http.StatusUnauthorized, withAPIKey(router, "GET", "/protected", "not-a-key"))
+}
+
+// TestRevokedAPIKeyIsRejected tests that revoked and expired keys stop working
+func TestRevokedAPIKeyIsRejected(t *testing.T) {
+	router, _, _, mockAPIKeyRepo := setupAPIKeyTestRouter()
+
+	revokedAt := time.Now().Add(-time.Minute)
+	expiredAt := time.Now().Add(-time.Hour)
+	revokedKey := "gaa_11111111_revoked"
+	expiredKey := "gaa_22222222_expired"
+
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "11111111").Return(&domain.APIKey{
+		ID: 1, UserID: 1, KeyHash: pkg.HashToken(revokedKey), Scopes: []string{domain.ScopeChat}, RevokedAt: &revokedAt,
+	}, nil)
+	mockAPIKeyRepo.On("GetByPrefix", mock.Anything, "22222222").Return(&domain.APIKey{
+		ID: 2, UserID: 1, KeyHash: pkg.HashToken(expiredKey), Scopes: []string{domain.ScopeChat}, ExpiresAt: &expiredAt,
+	}, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, withAPIKey(router, "GET", "/protected", revokedKey))
+	assert.Equal(t, http.StatusUnauthorized, withAPIKey(router, "GET", "/protected", expiredKey))
+
+	// Revoking goes through the owner's JWT
+	token, _ := userToken(1, "test@example.com")
+	mockAPIKeyRepo.On("Revoke", mock.Anything, 3, 1).Return(true, nil)
+	mockAPIKeyRepo.On("Revoke", mock.Anything, 4, 1).Return(false, nil)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "DELETE", "/api-keys/3", token).Code)
+	assert.Equal(t, http.StatusNotFound, performAuthorized(router, "DELETE", "/api-keys/4", token).Code)
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// MemoryAuditRepository keeps the audit chain in memory, linking entries like the Postgres repository
+type MemoryAuditRepository struct {
+	mu     sync.Mutex
+	Events []*domain.AuditEvent
+}
+
+func (r *MemoryAuditRepository) Append(ctx context.Context, event *domain.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.PrevHash = domain.AuditGenesisHash
+	if len(r.Events) > 0 {
+		event.PrevHash = r.Events[len(r.Events)-1].Hash
+	}
+	event.ID = int64(len(r.Events) + 1)
+	event.Hash = event.ComputeHash()
+
+	stored := *event
+	r.Events = append(r.Events, &stored)
+	return nil
+}
+
+func (r *MemoryAuditRepository) AppendBatch(ctx context.Context, events []*domain.AuditEvent) error {
+	for _, event := range events {
+		if err := r.Append(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryAuditRepository) Query(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []*domain.AuditEvent{}
+	for i := len(r.Events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		event := r.Events[i]
+		if (filter.From != nil && event.OccurredAt.Before(*filter.From)) ||
+			(filter.To != nil && !event.OccurredAt.Before(*filter.To)) ||
+			(filter.ActorID != 0 && event.ActorID != filter.ActorID) ||
+			(filter.TargetID != 0 && event.TargetUserID != filter.TargetID) ||
+			(filter.Action != "" && event.Action != filter.Action) ||
+			(filter.BeforeID != 0 && event.ID >= filter.BeforeID) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *MemoryAuditRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]*domain.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []*domain.AuditEvent{}
+	for _, event := range r.Events {
+		if event.ID > afterID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// setupAuditTestRouter creates a test router whose audit events are kept in memory
+func setupAuditTestRouter() (*gin.Engine, *MockUserRepository, *MockRefreshTokenRepository, *MemoryAuditRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	auditRepo := &MemoryAuditRepository{}
+	revocationService := service.NewRevocationService(nil)
+	auditService := service.NewAuditService(auditRepo, nil, "")
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationService: revocationService,
+			AuditService:      auditService,
+		},
+		Config:      &config.Config{},
+		NATSHandler: delivery.NewNATSHandler(usecase.NewNATSUsecase(&pkg.NatsClient{}), auditService),
+	}.newRouter()
+
+	return router, mockUserRepo, mockRefreshTokenRepo, auditRepo
+}
+
+// auditorToken signs an access token allowed to read the audit log
+func auditorToken(userID int) string {
+	return tokenWithPermissions(userID, []string{domain.RoleAdmin}, []string{domain.PermissionAuditRead})
+}
+
+// TestLoginIsAudited tests that successful and failed logins are recorded with the client
+func TestLoginIsAudited(t *testing.T) {
+	router, mockUserRepo, mockRefreshTokenRepo, auditRepo := setupAuditTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, assert.AnError)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(router, "203.0.113.9", "nobody@example.com", "password1234").Code)
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(router, "203.0.113.9", "test@example.com", "wrong-password").Code)
+	assert.Equal(t, http.StatusOK, loginFrom(router, "203.0.113.9", "test@example.com", "password1234").Code)
+
+	if assert.Len(t, auditRepo.Events, 3) {
+		unknown, badPassword, success := auditRepo.Events[0], auditRepo.Events[1], auditRepo.Events[2]
+
+		assert.Equal(t, domain.AuditLoginFailure, unknown.Action)
+		assert.Equal(t, domain.AuditOutcomeFailure, unknown.Outcome)
+		assert.Equal(t, 0, unknown.TargetUserID)
+		// Only a keyed digest of the address is kept, the log outlives account erasure
+		assert.NotContains(t, unknown.Details, "email")
+		assert.Len(t, unknown.Details["email_hmac"], 64)
+		assert.NotContains(t, unknown.Details["email_hmac"], "nobody")
+		assert.Equal(t, "203.0.113.9", unknown.IP)
+
+		assert.Equal(t, domain.AuditLoginFailure, badPassword.Action)
+		assert.Equal(t, 1, badPassword.TargetUserID)
+		assert.Equal(t, "incorrect_password", badPassword.Details["reason"])
+
+		assert.Equal(t, domain.AuditLoginSuccess, success.Action)
+		assert.Equal(t, domain.AuditOutcomeSuccess, success.Outcome)
+		assert.Equal(t, 1, success.ActorID)
+		assert.NotEmpty(t, success.Details["session_id"])
+		assert.Equal(t, badPassword.Hash, success.PrevHash)
+	}
+}
+
+// TestNATSPublishIsAudited tests that operator publishes are recorded, including failed ones
+func TestNATSPublishIsAudited(t *testing.T) {
+	router, _, _, auditRepo := setupAuditTestRouter()
+
+	operatorToken := tokenWithPermissions(2, []string{domain.RoleOperator}, []string{domain.PermissionNATSPublish})
+	req := jsonRequest("POST", "/nats/publish", map[string]string{"topic": "devices.reboot", "message": "now"})
+	req.Header.Set("Authorization", "Bearer "+operatorToken)
+
+	// There is no NATS server in tests, so the publish itself fails
+	assert.Equal(t, http.StatusInternalServerError, serve(router, req).Code)
+
+	if assert.Len(t, auditRepo.Events, 1) {
+		event := auditRepo.Events[0]
+		assert.Equal(t, domain.AuditNATSPublish, event.Action)
+		assert.Equal(t, domain.AuditOutcomeFailure, event.Outcome)
+		assert.Equal(t, 2, event.ActorID)
+		assert.Equal(t, "devices.reboot", event.Details["topic"])
+		assert.Equal(t, "3", event.Details["message_size"])
+	}
+}
+
+// TestQueryAuditEvents tests the filters of the admin endpoint and that it requires audit:read
+func TestQueryAuditEvents(t *testing.T) {
+	router, _, _, auditRepo := setupAuditTestRouter()
+
+	auditService := service.NewAuditService(auditRepo, nil, "")
+	ctx := context.Background()
+	auditService.Record(ctx, &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: 1})
+	auditService.Record(ctx, &domain.AuditEvent{Action: domain.AuditUserDisabled, ActorID: 9, TargetUserID: 1})
+	auditService.Record(ctx, &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: 2})
+	auditService.Record(ctx, &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: 1})
+
+	// Managing users does not grant access to the audit log
+	assert.Equal(t, http.StatusForbidden, performAuthorized(router, "GET", "/admin/audit", adminToken(9)).Code)
+
+	w := performAuthorized(router, "GET", "/admin/audit?actor_id=1&action=login.success", auditorToken(9))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Events       []*domain.AuditEvent `json:"events"`
+		NextBeforeID int64                `json:"next_before_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if assert.Len(t, response.Events, 2) {
+		assert.Equal(t, int64(4), response.Events[0].ID)
+		assert.Equal(t, int64(1), response.Events[1].ID)
+	}
+
+	w = performAuthorized(router, "GET", "/admin/audit?limit=1&before_id=4", auditorToken(9))
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if assert.Len(t, response.Events, 1) {
+		assert.Equal(t, int64(3), response.Events[0].ID)
+		assert.Equal(t, int64(3), response.NextBeforeID)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w = performAuthorized(router, "GET", "/admin/audit?from="+future, auditorToken(9))
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Empty(t, response.Events)
+
+	assert.Equal(t, http.StatusBadRequest, performAuthorized(router, "GET", "/admin/audit?from=yesterday", auditorToken(9)).Code)
+}
+
+// TestVerifyAuditLogDetectsTampering tests that editing or removing an entry breaks the chain
+func TestVerifyAuditLogDetectsTampering(t *testing.T) {
+	router, _, _, auditRepo := setupAuditTestRouter()
+
+	auditService := service.NewAuditService(auditRepo, nil, "")
+	for actorID := 1; actorID <= 4; actorID++ {
+		auditService.Record(context.Background(), &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: actorID})
+	}
+
+	var result domain.AuditVerification
+	w := performAuthorized(router, "GET", "/admin/audit/verify", auditorToken(9))
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 4, result.Checked)
+
+	// Rewriting who logged in is caught at the edited entry
+	auditRepo.Events[1].ActorID = 3
+	w = performAuthorized(router, "GET", "/admin/audit/verify", auditorToken(9))
+	result = domain.AuditVerification{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(2), result.BrokenAtID)
+
+	// So is removing an entry, even with its own hash intact
+	auditRepo.Events[1].ActorID = 2
+	auditRepo.Events = append(auditRepo.Events[:2], auditRepo.Events[3:]...)
+	w = performAuthorized(router, "GET", "/admin/audit/verify", auditorToken(9))
+	result = domain.AuditVerification{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	assert.False(t, result.Valid)
+	assert.Equal(t, int64(4), result.BrokenAtID)
+}
+
+// TestAuditWriterStoresQueuedEvents tests that events recorded through the background writer are all chained once it closes
+func TestAuditWriterStoresQueuedEvents(t *testing.T) {
+	auditRepo := &MemoryAuditRepository{}
+	auditService := service.NewAuditService(auditRepo, nil, "")
+	auditService.StartWriter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(actorID int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				assert.NoError(t, auditService.Record(context.Background(), &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: actorID}))
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+	auditService.Close()
+
+	assert.Len(t, auditRepo.Events, 1000)
+	result, err := auditService.Verify(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 1000, result.Checked)
+
+	// After closing, events are stored synchronously again
+	assert.NoError(t, auditService.Record(context.Background(), &domain.AuditEvent{Action: domain.AuditLoginSuccess, ActorID: 1}))
+	assert.Len(t, auditRepo.Events, 1001)
+}
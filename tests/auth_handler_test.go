@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go-auth-app/config"
 	"go-auth-app/internal/delivery"
 	"go-auth-app/internal/domain"
 	"go-auth-app/internal/routes"
@@ -16,6 +17,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // Mock repositories
@@ -44,6 +46,80 @@ func (m *MockUserRepository) GetByID(ctx context.Context, id int) (*domain.User,
 	return nil, args.Error(1)
 }
 
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	args := m.Called(ctx, id, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateProfile(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetPendingEmail(ctx context.Context, id int, email string) error {
+	args := m.Called(ctx, id, email)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ApplyPendingEmail(ctx context.Context, id int, email string) error {
+	args := m.Called(ctx, id, email)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) MarkDeleted(ctx context.Context, id int, deletedAt time.Time) error {
+	args := m.Called(ctx, id, deletedAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Restore(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ListDeletedBefore(ctx context.Context, before time.Time) ([]int, error) {
+	args := m.Called(ctx, before)
+	if ids, ok := args.Get(0).([]int); ok {
+		return ids, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserRepository) Purge(ctx context.Context, id int, deletedBefore time.Time) (bool, error) {
+	args := m.Called(ctx, id, deletedBefore)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) List(ctx context.Context, filter domain.UserFilter) ([]*domain.User, int, error) {
+	args := m.Called(ctx, filter)
+	if users, ok := args.Get(0).([]*domain.User); ok {
+		return users, args.Int(1), args.Error(2)
+	}
+	return nil, args.Int(1), args.Error(2)
+}
+
+func (m *MockUserRepository) SetDisabled(ctx context.Context, id int, disabledAt *time.Time) error {
+	args := m.Called(ctx, id, disabledAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) TouchLastSeen(ctx context.Context, ids []int, seenAt time.Time) error {
+	args := m.Called(ctx, ids, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetLastSeen(ctx context.Context, ids []int) (map[int]time.Time, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]time.Time), args.Error(1)
+}
+
 // Mock Chat Repository
 type MockChatRepository struct {
 	mock.Mock
@@ -83,6 +159,172 @@ func (m *MockChatRepository) UpdateConversation(ctx context.Context, conversatio
 	return args.Error(0)
 }
 
+func (m *MockChatRepository) GetMessagesByUserID(ctx context.Context, userID int) ([]*domain.Message, error) {
+	args := m.Called(ctx, userID)
+	if messages, ok := args.Get(0).([]*domain.Message); ok {
+		return messages, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) GetMessagesByConversationID(ctx context.Context, conversationID, userID int, limit, offset int) ([]*domain.Message, error) {
+	args := m.Called(ctx, conversationID, userID, limit, offset)
+	if messages, ok := args.Get(0).([]*domain.Message); ok {
+		return messages, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) GetConversation(ctx context.Context, id int) (*domain.Conversation, error) {
+	args := m.Called(ctx, id)
+	if conv, ok := args.Get(0).(*domain.Conversation); ok {
+		return conv, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) CreateGroup(ctx context.Context, conversation *domain.Conversation, memberIDs []int) error {
+	args := m.Called(ctx, conversation, memberIDs)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) RenameConversation(ctx context.Context, id int, name string) error {
+	args := m.Called(ctx, id, name)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) DeleteConversation(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	args := m.Called(ctx, conversationID, userID)
+	if member, ok := args.Get(0).(*domain.ConversationMember); ok {
+		return member, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) ListMembers(ctx context.Context, conversationID int) ([]*domain.ConversationMember, error) {
+	args := m.Called(ctx, conversationID)
+	if members, ok := args.Get(0).([]*domain.ConversationMember); ok {
+		return members, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) AddMembers(ctx context.Context, conversationID int, userIDs []int) ([]*domain.ConversationMember, error) {
+	args := m.Called(ctx, conversationID, userIDs)
+	if members, ok := args.Get(0).([]*domain.ConversationMember); ok {
+		return members, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) RemoveMember(ctx context.Context, conversationID, userID int) (bool, error) {
+	args := m.Called(ctx, conversationID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockChatRepository) SetMemberRole(ctx context.Context, conversationID, userID int, role string) (bool, error) {
+	args := m.Called(ctx, conversationID, userID, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockChatRepository) TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID int) error {
+	args := m.Called(ctx, conversationID, fromUserID, toUserID)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMessage(ctx context.Context, id int) (*domain.Message, error) {
+	args := m.Called(ctx, id)
+	if message, ok := args.Get(0).(*domain.Message); ok {
+		return message, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) GetLatestMessageID(ctx context.Context, conversationID int) (int, error) {
+	args := m.Called(ctx, conversationID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockChatRepository) MarkRead(ctx context.Context, conversationID, userID, messageID int) (bool, error) {
+	args := m.Called(ctx, conversationID, userID, messageID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockChatRepository) EditMessage(ctx context.Context, id int, content string, editedAt time.Time) (*domain.Message, error) {
+	args := m.Called(ctx, id, content, editedAt)
+	if message, ok := args.Get(0).(*domain.Message); ok {
+		return message, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) DeleteMessage(ctx context.Context, id int, deletedAt time.Time) (*domain.Message, error) {
+	args := m.Called(ctx, id, deletedAt)
+	if message, ok := args.Get(0).(*domain.Message); ok {
+		return message, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) HideMessage(ctx context.Context, id, userID int, hiddenAt time.Time) error {
+	args := m.Called(ctx, id, userID, hiddenAt)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMessageRevisions(ctx context.Context, messageID int) ([]*domain.MessageRevision, error) {
+	args := m.Called(ctx, messageID)
+	if revisions, ok := args.Get(0).([]*domain.MessageRevision); ok {
+		return revisions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockChatRepository) GetContactIDs(ctx context.Context, userID int) ([]int, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// Mock Refresh Token Repository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if token, ok := args.Get(0).(*domain.RefreshToken); ok {
+		return token, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) MarkUsed(ctx context.Context, id int) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
 // Mock NATS Service
 type MockNATSService struct {
 	mock.Mock
@@ -93,44 +335,75 @@ func (m *MockNATSService) PublishChatMessage(message *domain.Message) error {
 	return args.Error(0)
 }
 
-// Setup Test Router
-func setupTestRouter() (*gin.Engine, *MockUserRepository, *MockChatRepository, *MockNATSService) {
+// testApp is the fixture the test routers are built from: an AuthUsecase made
+// from Deps and Config, served with whichever chat, WebSocket and NATS
+// handlers a test needs. Dependencies left nil disable their features.
+type testApp struct {
+	Deps        usecase.AuthDependencies
+	Config      *config.Config
+	ChatUsecase *usecase.ChatUsecase
+	WSHandler   *delivery.WebSocketHandler
+	NATSHandler *delivery.NATSHandler
+}
+
+// newRouter creates a test router serving the app
+func (app testApp) newRouter() (*gin.Engine, *usecase.AuthUsecase) {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	return router, app.setupRoutes(router)
+}
+
+// setupRoutes serves the app on an existing router, such as one a test server
+// already listens with, and returns its AuthUsecase
+func (app testApp) setupRoutes(router *gin.Engine) *usecase.AuthUsecase {
+	authUsecase := usecase.NewAuthUsecase(app.Deps, app.Config)
+
+	var chatHandler *delivery.ChatHandler
+	if app.ChatUsecase != nil {
+		chatHandler = delivery.NewChatHandler(app.ChatUsecase)
+	}
+
+	routes.SetupRoutes(router, delivery.NewAuthHandler(authUsecase), chatHandler, app.WSHandler, app.NATSHandler)
+	return authUsecase
+}
 
+// Setup Test Router
+func setupTestRouter() (*gin.Engine, *MockUserRepository, *MockChatRepository, *MockNATSService, *MockRefreshTokenRepository) {
 	// Create mocks
 	mockUserRepo := new(MockUserRepository)
 	mockChatRepo := new(MockChatRepository)
 	mockNATSService := new(MockNATSService)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
 
-	// Create usecases
-	authUsecase := usecase.NewAuthUsecase(mockUserRepo)
-	chatUsecase := usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil)
-
-	// Create handlers
-	authHandler := delivery.NewAuthHandler(authUsecase)
-	chatHandler := delivery.NewChatHandler(chatUsecase)
+	// Signup sends a verification email, which is covered by its own tests
+	mockUserTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
 
-	// Setup routes
-	routes.SetupRoutes(router, authHandler, chatHandler, nil, nil)
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo, RefreshTokenRepo: mockRefreshTokenRepo, UserTokenRepo: mockUserTokenRepo},
+		Config:      &config.Config{},
+		ChatUsecase: usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil, nil),
+	}.newRouter()
 
-	return router, mockUserRepo, mockChatRepo, mockNATSService
+	return router, mockUserRepo, mockChatRepo, mockNATSService, mockRefreshTokenRepo
 }
 
 // Signup Test
 func TestSignup(t *testing.T) {
-	router, mockUserRepo, _, _ := setupTestRouter()
+	router, mockUserRepo, _, _, _ := setupTestRouter()
 
 	// Prepare test user
 	user := map[string]string{
 		"name":     "Test User",
-		"email":    "test@example.com",
+		"email":    "Test@Example.com",
 		"password": "securePassword123",
 	}
 
-	// Mock user repo expectations
+	// Mock user repo expectations, addresses are stored lowercased
 	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(nil, nil)
-	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.User")).Return(nil)
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+		return user.Email == "test@example.com"
+	})).Return(nil)
 
 	// Convert user to JSON
 	jsonUser, _ := json.Marshal(user)
@@ -150,7 +423,7 @@ func TestSignup(t *testing.T) {
 
 // Login Test
 func TestLogin(t *testing.T) {
-	router, mockUserRepo, _, _ := setupTestRouter()
+	router, mockUserRepo, _, _, mockRefreshTokenRepo := setupTestRouter()
 
 	// Prepare login credentials
 	loginCreds := map[string]string{
@@ -169,6 +442,7 @@ func TestLogin(t *testing.T) {
 
 	// Mock user repo expectations
 	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(mockUser, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
 
 	// Convert login creds to JSON
 	jsonCreds, _ := json.Marshal(loginCreds)
@@ -183,15 +457,90 @@ func TestLogin(t *testing.T) {
 
 	// Assert response
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response["token"])
+	assert.NotEmpty(t, response["refresh_token"])
+
 	mockUserRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// Refresh Token Rotation Test
+func TestRefreshToken(t *testing.T) {
+	router, mockUserRepo, _, _, mockRefreshTokenRepo := setupTestRouter()
+
+	storedToken := &domain.RefreshToken{
+		ID:        7,
+		UserID:    1,
+		FamilyID:  "family-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	mockRefreshTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("old-refresh-token")).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("MarkUsed", mock.Anything, 7).Return(true, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *domain.RefreshToken) bool {
+		return token.UserID == 1 && token.FamilyID == "family-1"
+	})).Return(nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+
+	jsonBody, _ := json.Marshal(map[string]string{"refresh_token": "old-refresh-token"})
+
+	req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response["token"])
+	assert.NotEqual(t, "old-refresh-token", response["refresh_token"])
+
+	mockUserRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// Refresh Token Reuse Test
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	router, _, _, _, mockRefreshTokenRepo := setupTestRouter()
+
+	usedAt := time.Now().Add(-time.Minute)
+	storedToken := &domain.RefreshToken{
+		ID:        7,
+		UserID:    1,
+		FamilyID:  "family-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+		UsedAt:    &usedAt,
+	}
+
+	mockRefreshTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("replayed-token")).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil)
+
+	jsonBody, _ := json.Marshal(map[string]string{"refresh_token": "replayed-token"})
+
+	req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockRefreshTokenRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
 }
 
 // Protected Route Test
 func TestProtectedRoute(t *testing.T) {
-	router, _, _, _ := setupTestRouter()
+	router, _, _, _, _ := setupTestRouter()
 
 	// Create a mock JWT token
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Create request with JWT token
 	req, _ := http.NewRequest("GET", "/protected", nil)
@@ -12,32 +12,20 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
-	"go-auth-app/internal/delivery"
 	"go-auth-app/internal/domain"
-	"go-auth-app/internal/routes"
 	"go-auth-app/internal/usecase"
-	"go-auth-app/pkg"
 )
 
 // setupChatTestRouter creates a test router specifically for chat tests
 func setupChatTestRouter() (*gin.Engine, *MockUserRepository, *MockChatRepository) {
-	gin.SetMode(gin.TestMode)
-	router := gin.Default()
-
 	// Create mocks
 	mockUserRepo := new(MockUserRepository)
 	mockChatRepo := new(MockChatRepository)
 
-	// Create usecases
-	authUsecase := usecase.NewAuthUsecase(mockUserRepo)
-	chatUsecase := usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil)
-
-	// Create handlers
-	authHandler := delivery.NewAuthHandler(authUsecase)
-	chatHandler := delivery.NewChatHandler(chatUsecase)
-
-	// Setup routes
-	routes.SetupRoutes(router, authHandler, chatHandler, nil, nil)
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo},
+		ChatUsecase: usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil, nil),
+	}.newRouter()
 
 	return router, mockUserRepo, mockChatRepo
 }
@@ -47,7 +35,7 @@ func TestSendMessage(t *testing.T) {
 	router, mockUserRepo, mockChatRepo := setupChatTestRouter()
 
 	// Create JWT token for authentication
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Mock user repository for receiver validation
 	mockUserRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{
@@ -139,7 +127,7 @@ func TestSendMessageInvalidRequest(t *testing.T) {
 	router, _, _ := setupChatTestRouter()
 
 	// Create JWT token for authentication
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Create invalid message request (missing required fields)
 	messageReq := map[string]interface{}{
@@ -168,7 +156,7 @@ func TestGetConversationMessages(t *testing.T) {
 	router, mockUserRepo, mockChatRepo := setupChatTestRouter()
 
 	// Create JWT token for authentication
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Mock user repository for user validation
 	mockUserRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{
@@ -230,7 +218,7 @@ func TestGetUserConversations(t *testing.T) {
 	router, _, mockChatRepo := setupChatTestRouter()
 
 	// Create JWT token for authentication
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Mock conversations retrieval
 	mockConversations := []*domain.Conversation{
@@ -284,7 +272,7 @@ func TestSendMessageReceiverNotFound(t *testing.T) {
 	router, mockUserRepo, _ := setupChatTestRouter()
 
 	// Create JWT token for authentication
-	token, _ := pkg.GenerateJWT(1, "test@example.com")
+	token, _ := userToken(1, "test@example.com")
 
 	// Mock user repository to return nil (user not found)
 	mockUserRepo.On("GetByID", mock.Anything, 999).Return(nil, nil)
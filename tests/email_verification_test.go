@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// setupVerificationTestRouter creates a test router with email verification enforced
+func setupVerificationTestRouter() (*gin.Engine, *MockUserRepository, *MockUserTokenRepository, *RecordingMailer) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockChatRepo := new(MockChatRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
+	mailer := NewRecordingMailer()
+
+	cfg := &config.Config{AppBaseURL: "http://app.test", RequireEmailVerification: true}
+
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo, UserTokenRepo: mockUserTokenRepo, Mailer: mailer},
+		Config:      cfg,
+		ChatUsecase: usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil, cfg),
+	}.newRouter()
+
+	return router, mockUserRepo, mockUserTokenRepo, mailer
+}
+
+// TestSignupSendsVerificationEmail tests that new accounts receive a verification link
+func TestSignupSendsVerificationEmail(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, mailer := setupVerificationTestRouter()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.User")).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 5
+	}).Return(nil)
+	mockUserTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *domain.UserToken) bool {
+		return token.UserID == 5 && token.Purpose == domain.TokenPurposeEmailVerification
+	})).Return(nil)
+
+	w := postJSON(router, "/signup", map[string]string{
+		"name":     "New User",
+		"email":    "new@example.com",
+		"password": "securePassword123",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	email := mailer.Next(t)
+	assert.Equal(t, "new@example.com", email.To)
+	assert.Contains(t, email.Body, "http://app.test/verify-email?token=")
+
+	mockUserTokenRepo.AssertExpectations(t)
+}
+
+// TestVerifyEmail tests confirming an address with the emailed token
+func TestVerifyEmail(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _ := setupVerificationTestRouter()
+
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("verify-token"), domain.TokenPurposeEmailVerification).Return(&domain.UserToken{
+		ID:        4,
+		UserID:    5,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 4).Return(true, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 5, domain.TokenPurposeEmailVerification).Return(nil)
+	mockUserRepo.On("MarkEmailVerified", mock.Anything, 5).Return(nil)
+
+	w := postJSON(router, "/verify-email", map[string]string{"token": "verify-token"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockUserRepo.AssertExpectations(t)
+	mockUserTokenRepo.AssertExpectations(t)
+}
+
+// TestResendVerificationThrottled tests that a resend inside the interval sends nothing
+func TestResendVerificationThrottled(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, mailer := setupVerificationTestRouter()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "new@example.com").Return(&domain.User{ID: 5, Email: "new@example.com"}, nil)
+	mockUserTokenRepo.On("GetLatestForUser", mock.Anything, 5, domain.TokenPurposeEmailVerification).Return(&domain.UserToken{
+		ID:        4,
+		UserID:    5,
+		CreatedAt: time.Now().Add(-10 * time.Second),
+	}, nil)
+
+	w := postJSON(router, "/verify-email/resend", map[string]string{"email": "new@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, mailer.Sent)
+	mockUserTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// TestUnverifiedUserCannotLoginOrChat tests the REQUIRE_EMAIL_VERIFICATION switch
+func TestUnverifiedUserCannotLoginOrChat(t *testing.T) {
+	router, mockUserRepo, _, _ := setupVerificationTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("securePassword123"), bcrypt.DefaultCost)
+	unverified := &domain.User{ID: 5, Email: "new@example.com", Password: string(hashedPassword)}
+	mockUserRepo.On("GetByEmail", mock.Anything, "new@example.com").Return(unverified, nil)
+	mockUserRepo.On("GetByID", mock.Anything, 5).Return(unverified, nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "new@example.com", "password": "securePassword123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A token obtained before the switch was enabled still cannot be used to chat
+	token, _ := userToken(5, "new@example.com")
+	req := jsonRequest("POST", "/chat/messages", map[string]interface{}{"receiver_id": 2, "content": "spam"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = serve(router, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,546 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+)
+
+// MemoryChatRepository keeps conversations, members and messages in memory
+type MemoryChatRepository struct {
+	mu            sync.Mutex
+	nextID        int
+	clock         time.Time
+	Conversations map[int]*domain.Conversation
+	Members       map[int]map[int]*domain.ConversationMember
+	Messages      []*domain.Message
+	Revisions     map[int][]*domain.MessageRevision
+	Hidden        map[int]map[int]bool
+}
+
+func NewMemoryChatRepository() *MemoryChatRepository {
+	return &MemoryChatRepository{
+		clock:         time.Now(),
+		Conversations: map[int]*domain.Conversation{},
+		Members:       map[int]map[int]*domain.ConversationMember{},
+		Revisions:     map[int][]*domain.MessageRevision{},
+		Hidden:        map[int]map[int]bool{},
+	}
+}
+
+// tick returns increasing times so members join in a known order
+func (r *MemoryChatRepository) tick() time.Time {
+	r.clock = r.clock.Add(time.Second)
+	return r.clock
+}
+
+func (r *MemoryChatRepository) addMembers(conversationID int, userIDs []int, role string) []*domain.ConversationMember {
+	if r.Members[conversationID] == nil {
+		r.Members[conversationID] = map[int]*domain.ConversationMember{}
+	}
+	added := []*domain.ConversationMember{}
+	for _, userID := range userIDs {
+		if _, ok := r.Members[conversationID][userID]; ok {
+			continue
+		}
+		member := &domain.ConversationMember{ConversationID: conversationID, UserID: userID, Role: role, JoinedAt: r.tick()}
+		r.Members[conversationID][userID] = member
+		added = append(added, member)
+	}
+	return added
+}
+
+func (r *MemoryChatRepository) SaveMessage(ctx context.Context, message *domain.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	message.ID = r.nextID
+	message.CreatedAt = r.tick()
+	stored := *message
+	r.Messages = append(r.Messages, &stored)
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMessagesByConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]*domain.Message, error) {
+	return []*domain.Message{}, nil
+}
+
+func (r *MemoryChatRepository) GetMessagesByConversationID(ctx context.Context, conversationID, userID int, limit, offset int) ([]*domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := []*domain.Message{}
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].ConversationID == conversationID && !r.Hidden[r.Messages[i].ID][userID] {
+			stored := *r.Messages[i]
+			messages = append(messages, &stored)
+		}
+	}
+	if offset > len(messages) {
+		offset = len(messages)
+	}
+	messages = messages[offset:]
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (r *MemoryChatRepository) GetOrCreateConversation(ctx context.Context, user1ID, user2ID int) (*domain.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, conversation := range r.Conversations {
+		if !conversation.IsGroup() && ((conversation.User1ID == user1ID && conversation.User2ID == user2ID) || (conversation.User1ID == user2ID && conversation.User2ID == user1ID)) {
+			return conversation, nil
+		}
+	}
+	r.nextID++
+	conversation := &domain.Conversation{ID: r.nextID, Type: domain.ConversationTypeDirect, User1ID: user1ID, User2ID: user2ID, UpdatedAt: r.tick()}
+	r.Conversations[conversation.ID] = conversation
+	r.addMembers(conversation.ID, []int{user1ID, user2ID}, domain.ConversationRoleMember)
+	return conversation, nil
+}
+
+func (r *MemoryChatRepository) GetConversation(ctx context.Context, id int) (*domain.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversation, ok := r.Conversations[id]
+	if !ok {
+		return nil, nil
+	}
+	stored := *conversation
+	return &stored, nil
+}
+
+func (r *MemoryChatRepository) GetConversationsByUserID(ctx context.Context, userID int) ([]*domain.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversations := []*domain.Conversation{}
+	for id, members := range r.Members {
+		member, ok := members[userID]
+		if !ok {
+			continue
+		}
+		conversation := *r.Conversations[id]
+		conversation.LastReadMessageID = member.LastReadMessageID
+		for _, message := range r.Messages {
+			if message.ConversationID == id && message.ID > member.LastReadMessageID && message.SenderID != userID && !message.IsDeleted() && !r.Hidden[message.ID][userID] {
+				conversation.UnreadCount++
+			}
+		}
+		conversations = append(conversations, &conversation)
+	}
+	return conversations, nil
+}
+
+func (r *MemoryChatRepository) UpdateConversation(ctx context.Context, conversationID int, lastMessage string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Conversations[conversationID].LastMessage = lastMessage
+	r.Conversations[conversationID].UpdatedAt = r.tick()
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMessagesByUserID(ctx context.Context, userID int) ([]*domain.Message, error) {
+	return []*domain.Message{}, nil
+}
+
+func (r *MemoryChatRepository) CreateGroup(ctx context.Context, conversation *domain.Conversation, memberIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	conversation.ID = r.nextID
+	conversation.Type = domain.ConversationTypeGroup
+	conversation.UpdatedAt = r.tick()
+	stored := *conversation
+	r.Conversations[conversation.ID] = &stored
+	r.addMembers(conversation.ID, []int{conversation.CreatedBy}, domain.ConversationRoleOwner)
+	r.addMembers(conversation.ID, memberIDs, domain.ConversationRoleMember)
+	return nil
+}
+
+func (r *MemoryChatRepository) RenameConversation(ctx context.Context, id int, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Conversations[id].Name = name
+	return nil
+}
+
+func (r *MemoryChatRepository) DeleteConversation(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.Conversations, id)
+	delete(r.Members, id)
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMember(ctx context.Context, conversationID, userID int) (*domain.ConversationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member, ok := r.Members[conversationID][userID]
+	if !ok {
+		return nil, nil
+	}
+	stored := *member
+	return &stored, nil
+}
+
+func (r *MemoryChatRepository) ListMembers(ctx context.Context, conversationID int) ([]*domain.ConversationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members := []*domain.ConversationMember{}
+	for _, member := range r.Members[conversationID] {
+		stored := *member
+		members = append(members, &stored)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].JoinedAt.Before(members[j].JoinedAt) })
+	return members, nil
+}
+
+func (r *MemoryChatRepository) AddMembers(ctx context.Context, conversationID int, userIDs []int) ([]*domain.ConversationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addMembers(conversationID, userIDs, domain.ConversationRoleMember), nil
+}
+
+func (r *MemoryChatRepository) RemoveMember(ctx context.Context, conversationID, userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.Members[conversationID][userID]
+	delete(r.Members[conversationID], userID)
+	return ok, nil
+}
+
+func (r *MemoryChatRepository) SetMemberRole(ctx context.Context, conversationID, userID int, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member, ok := r.Members[conversationID][userID]
+	if ok {
+		member.Role = role
+	}
+	return ok, nil
+}
+
+func (r *MemoryChatRepository) TransferOwnership(ctx context.Context, conversationID, fromUserID, toUserID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Members[conversationID][fromUserID].Role = domain.ConversationRoleAdmin
+	r.Members[conversationID][toUserID].Role = domain.ConversationRoleOwner
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMessage(ctx context.Context, id int) (*domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, message := range r.Messages {
+		if message.ID == id {
+			stored := *message
+			return &stored, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *MemoryChatRepository) GetLatestMessageID(ctx context.Context, conversationID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest := 0
+	for _, message := range r.Messages {
+		if message.ConversationID == conversationID && message.ID > latest {
+			latest = message.ID
+		}
+	}
+	return latest, nil
+}
+
+func (r *MemoryChatRepository) MarkRead(ctx context.Context, conversationID, userID, messageID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member, ok := r.Members[conversationID][userID]
+	if !ok || member.LastReadMessageID >= messageID {
+		return false, nil
+	}
+	member.LastReadMessageID = messageID
+	return true, nil
+}
+
+func (r *MemoryChatRepository) EditMessage(ctx context.Context, id int, content string, editedAt time.Time) (*domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message := r.message(id)
+	if message == nil || message.IsDeleted() {
+		return nil, nil
+	}
+	writtenAt := message.CreatedAt
+	if message.EditedAt != nil {
+		writtenAt = *message.EditedAt
+	}
+	r.nextID++
+	r.Revisions[id] = append(r.Revisions[id], &domain.MessageRevision{ID: r.nextID, MessageID: id, Content: message.Content, WrittenAt: writtenAt, ReplacedAt: editedAt})
+	message.Content = content
+	message.EditedAt = &editedAt
+	r.refreshLastMessage(message.ConversationID)
+	stored := *message
+	return &stored, nil
+}
+
+func (r *MemoryChatRepository) DeleteMessage(ctx context.Context, id int, deletedAt time.Time) (*domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message := r.message(id)
+	if message == nil || message.IsDeleted() {
+		return nil, nil
+	}
+	message.Content = ""
+	message.DeletedAt = &deletedAt
+	delete(r.Revisions, id)
+	r.refreshLastMessage(message.ConversationID)
+	stored := *message
+	return &stored, nil
+}
+
+func (r *MemoryChatRepository) HideMessage(ctx context.Context, id, userID int, hiddenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Hidden[id] == nil {
+		r.Hidden[id] = map[int]bool{}
+	}
+	r.Hidden[id][userID] = true
+	return nil
+}
+
+func (r *MemoryChatRepository) GetMessageRevisions(ctx context.Context, messageID int) ([]*domain.MessageRevision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*domain.MessageRevision{}, r.Revisions[messageID]...), nil
+}
+
+// message returns the stored message with the ID, the caller holds the lock
+func (r *MemoryChatRepository) message(id int) *domain.Message {
+	for _, message := range r.Messages {
+		if message.ID == id {
+			return message
+		}
+	}
+	return nil
+}
+
+// refreshLastMessage sets the preview of a conversation to its latest message that was not deleted, the caller holds the lock
+func (r *MemoryChatRepository) refreshLastMessage(conversationID int) {
+	r.Conversations[conversationID].LastMessage = ""
+	for _, message := range r.Messages {
+		if message.ConversationID == conversationID && !message.IsDeleted() {
+			r.Conversations[conversationID].LastMessage = message.Content
+		}
+	}
+}
+
+func (r *MemoryChatRepository) GetContactIDs(ctx context.Context, userID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := map[int]bool{}
+	ids := []int{}
+	for _, members := range r.Members {
+		if _, ok := members[userID]; !ok {
+			continue
+		}
+		for id := range members {
+			if id != userID && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// roles returns the role of every member of a conversation by user ID
+func (r *MemoryChatRepository) roles(conversationID int) map[int]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	roles := map[int]string{}
+	for userID, member := range r.Members[conversationID] {
+		roles[userID] = member.Role
+	}
+	return roles
+}
+
+// setupGroupChatTestRouter creates a test router where users 1 to 4 exist and user 99 does not
+func setupGroupChatTestRouter() (*gin.Engine, *MemoryChatRepository) {
+	return newGroupChatTestRouter(nil)
+}
+
+// newGroupChatTestRouter is setupGroupChatTestRouter with a chat usecase
+// adjusted by configure, such as to track typing or presence
+func newGroupChatTestRouter(configure func(chatUsecase *usecase.ChatUsecase)) (*gin.Engine, *MemoryChatRepository) {
+	mockUserRepo := new(MockUserRepository)
+	chatRepo := NewMemoryChatRepository()
+
+	for id := 1; id <= 4; id++ {
+		mockUserRepo.On("GetByID", mock.Anything, id).Return(&domain.User{ID: id, Name: "User " + strconv.Itoa(id)}, nil)
+	}
+	mockUserRepo.On("GetByID", mock.Anything, 99).Return(nil, nil)
+
+	chatUsecase := usecase.NewChatUsecase(chatRepo, mockUserRepo, nil, nil)
+	if configure != nil {
+		configure(chatUsecase)
+	}
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo},
+		ChatUsecase: chatUsecase,
+		WSHandler:   delivery.NewWebSocketHandler(chatUsecase, nil, nil),
+	}.newRouter()
+
+	return router, chatRepo
+}
+
+// dialChat opens the chat WebSocket of a test server as the given user
+func dialChat(t *testing.T, server *httptest.Server, userID int) *websocket.Conn {
+	header := http.Header{"Authorization": {"Bearer " + tokenWithPermissions(userID, nil, nil)}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chat/ws", header)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// chatRequest performs an authenticated chat request as the given user
+func chatRequest(router *gin.Engine, userID int, method, path string, body interface{}) (int, map[string]interface{}) {
+	req := jsonRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+tokenWithPermissions(userID, nil, nil))
+	w := serve(router, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// TestGroupMembershipManagement tests creating a group and the roles that may change it
+func TestGroupMembershipManagement(t *testing.T) {
+	router, chatRepo := setupGroupChatTestRouter()
+
+	// Unknown users cannot be added
+	code, _ := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2, 99}})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// The creator is the owner, duplicates are ignored
+	code, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "  Ops  ", "member_ids": []int{2, 3, 2, 1}})
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+	group := response["data"].(map[string]interface{})
+	assert.Equal(t, "Ops", group["name"])
+	assert.Equal(t, domain.ConversationTypeGroup, group["type"])
+	assert.Len(t, group["members"], 3)
+
+	id := int(group["id"].(float64))
+	base := "/chat/groups/" + strconv.Itoa(id)
+	assert.Equal(t, map[int]string{1: "owner", 2: "member", 3: "member"}, chatRepo.roles(id))
+
+	// Plain members cannot manage the group, outsiders cannot see it
+	code, _ = chatRequest(router, 2, "PATCH", base, map[string]string{"name": "Mine"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = chatRequest(router, 2, "POST", base+"/members", map[string][]int{"user_ids": {4}})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = chatRequest(router, 4, "GET", "/chat/conversations/"+strconv.Itoa(id), nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Only the owner hands out roles
+	code, _ = chatRequest(router, 2, "PUT", base+"/members/3/role", map[string]string{"role": "admin"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = chatRequest(router, 1, "PUT", base+"/members/2/role", map[string]string{"role": "admin"})
+	assert.Equal(t, http.StatusOK, code)
+
+	// Admins rename the group and add and remove members, but not the owner
+	code, _ = chatRequest(router, 2, "PATCH", base, map[string]string{"name": "Operations"})
+	assert.Equal(t, http.StatusOK, code)
+	code, response = chatRequest(router, 2, "POST", base+"/members", map[string][]int{"user_ids": {4, 3}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response["data"], 1)
+	code, _ = chatRequest(router, 2, "DELETE", base+"/members/1", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = chatRequest(router, 2, "DELETE", base+"/members/3", nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = chatRequest(router, 2, "DELETE", base+"/members/3", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, response = chatRequest(router, 4, "GET", "/chat/conversations/"+strconv.Itoa(id), nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Operations", response["data"].(map[string]interface{})["name"])
+
+	// The owner leaving hands the group to the admin rather than the older plain member
+	code, _ = chatRequest(router, 1, "POST", base+"/leave", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[int]string{2: "owner", 4: "member"}, chatRepo.roles(id))
+
+	// The group goes away with its last member
+	chatRequest(router, 4, "POST", base+"/leave", nil)
+	code, _ = chatRequest(router, 2, "POST", base+"/leave", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, chatRepo.Conversations, id)
+}
+
+// TestGroupMessages tests sending to a conversation ID and reading its messages
+func TestGroupMessages(t *testing.T) {
+	router, chatRepo := setupGroupChatTestRouter()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+	path := "/chat/conversations/" + strconv.Itoa(id) + "/messages"
+
+	code, response := chatRequest(router, 1, "POST", path, map[string]string{"content": "Deploy at noon"})
+	assert.Equal(t, http.StatusCreated, code)
+	message := response["data"].(map[string]interface{})
+	assert.Equal(t, float64(id), message["conversation_id"])
+	assert.NotContains(t, message, "receiver_id")
+
+	// The legacy endpoint takes a conversation ID instead of a receiver
+	code, _ = chatRequest(router, 2, "POST", "/chat/messages", map[string]interface{}{"conversation_id": id, "content": "Ack"})
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = chatRequest(router, 2, "POST", "/chat/messages", map[string]interface{}{"conversation_id": id, "receiver_id": 1, "content": "Both"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Outsiders can neither write nor read
+	code, _ = chatRequest(router, 3, "POST", path, map[string]string{"content": "Let me in"})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = chatRequest(router, 3, "GET", path, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, response = chatRequest(router, 2, "GET", path, nil)
+	assert.Equal(t, http.StatusOK, code)
+	if messages := response["data"].([]interface{}); assert.Len(t, messages, 2) {
+		assert.Equal(t, "Ack", messages[0].(map[string]interface{})["content"])
+	}
+	assert.Equal(t, "Ack", chatRepo.Conversations[id].LastMessage)
+
+	// A direct conversation can be written to by ID too, but not left
+	code, response = chatRequest(router, 1, "POST", "/chat/messages", map[string]interface{}{"receiver_id": 3, "content": "Hi"})
+	assert.Equal(t, http.StatusCreated, code)
+	direct := int(response["data"].(map[string]interface{})["conversation_id"].(float64))
+	code, response = chatRequest(router, 3, "POST", "/chat/conversations/"+strconv.Itoa(direct)+"/messages", map[string]string{"content": "Hello"})
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, float64(1), response["data"].(map[string]interface{})["receiver_id"])
+	code, _ = chatRequest(router, 3, "POST", "/chat/groups/"+strconv.Itoa(direct)+"/leave", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, response = chatRequest(router, 1, "GET", "/chat/conversations", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response["data"], 2)
+}
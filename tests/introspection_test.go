@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+)
+
+// introspect asks /oauth/introspect about a token as the given client
+func (test *oauthProviderTest) introspect(t *testing.T, client *domain.CreatedOAuthClient, token string) domain.TokenIntrospection {
+	w := postForm(test.router, "/oauth/introspect", url.Values{"token": {token}}, client.ID, client.ClientSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var introspection domain.TokenIntrospection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &introspection))
+	return introspection
+}
+
+// TestIntrospectAccessToken tests introspection of JWTs before and after they are revoked
+func TestIntrospectAccessToken(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, false)
+
+	claims := pkg.NewClaims(5, "ada@example.com", time.Hour)
+	claims.SessionID = "session-1"
+	claims.Permissions = []string{domain.PermissionNATSRead}
+	token, _ := pkg.SignClaims(claims)
+
+	introspection := test.introspect(t, client, token)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, domain.TokenTypeAccessToken, introspection.TokenType)
+	assert.Equal(t, "5", introspection.Subject)
+	assert.Equal(t, "ada@example.com", introspection.Username)
+	assert.Equal(t, claims.ExpiresAt, introspection.ExpiresAt)
+	assert.Equal(t, []string{domain.PermissionNATSRead}, introspection.Permissions)
+
+	test.revocationService.RevokeSession("session-1", 5, time.Now().Add(time.Hour))
+	introspection = test.introspect(t, client, token)
+	assert.False(t, introspection.Active)
+	assert.True(t, introspection.Revoked)
+	assert.Empty(t, introspection.Subject)
+
+	// Garbage and restricted tokens are inactive rather than errors
+	assert.False(t, test.introspect(t, client, "not-a-token").Active)
+	mfaClaims := pkg.NewClaims(5, "ada@example.com", time.Minute)
+	mfaClaims.Purpose = pkg.TokenPurposeMFA
+	mfaToken, _ := pkg.SignClaims(mfaClaims)
+	assert.False(t, test.introspect(t, client, mfaToken).Active)
+}
+
+// TestIntrospectAPIKey tests introspection of active and revoked API keys
+func TestIntrospectAPIKey(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, false)
+
+	activeKey := "gaa_0a1b2c3d_c2VjcmV0"
+	revokedKey := "gaa_11111111_revoked"
+	revokedAt := time.Now()
+	test.apiKeyRepo.On("GetByPrefix", mock.Anything, "0a1b2c3d").Return(&domain.APIKey{ID: 3, UserID: 5, KeyHash: pkg.HashToken(activeKey), Scopes: []string{domain.ScopeChat}, CreatedAt: time.Now()}, nil)
+	test.apiKeyRepo.On("GetByPrefix", mock.Anything, "11111111").Return(&domain.APIKey{ID: 4, UserID: 5, KeyHash: pkg.HashToken(revokedKey), RevokedAt: &revokedAt}, nil)
+	test.apiKeyRepo.On("TouchLastUsed", mock.Anything, 3).Return(nil)
+
+	introspection := test.introspect(t, client, activeKey)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, domain.TokenTypeAPIKey, introspection.TokenType)
+	assert.Equal(t, domain.ScopeChat, introspection.Scope)
+	assert.Equal(t, 5, introspection.UserID)
+
+	introspection = test.introspect(t, client, revokedKey)
+	assert.False(t, introspection.Active)
+	assert.True(t, introspection.Revoked)
+
+	assert.False(t, test.introspect(t, client, "gaa_0a1b2c3d_wrong").Active)
+}
+
+// TestIntrospectionRequiresConfidentialClient tests that only authenticated confidential clients may introspect
+func TestIntrospectionRequiresConfidentialClient(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, false)
+	publicClient := test.registerClient(t, true)
+	token, _ := userToken(5, "ada@example.com")
+
+	w := postForm(test.router, "/oauth/introspect", url.Values{"token": {token}}, "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), domain.OAuthErrInvalidClient)
+
+	w = postForm(test.router, "/oauth/introspect", url.Values{"token": {token}}, client.ID, "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postForm(test.router, "/oauth/introspect", url.Values{"token": {token}, "client_id": {publicClient.ID}}, "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Credentials may also be sent in the form
+	w = postForm(test.router, "/oauth/introspect", url.Values{"token": {token}, "client_id": {client.ID}, "client_secret": {client.ClientSecret}}, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"active":true`)
+}
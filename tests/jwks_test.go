@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-auth-app/pkg"
+)
+
+// TestAsymmetricSigningAndJWKS tests signing with RS256 and EdDSA keys and publishing them as a JWKS
+func TestAsymmetricSigningAndJWKS(t *testing.T) {
+	for _, algorithm := range []string{"RS256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			km, err := pkg.NewKeyManager(t.TempDir(), algorithm, time.Hour, time.Hour)
+			assert.NoError(t, err)
+
+			pkg.SetKeyManager(km)
+			defer pkg.SetKeyManager(nil)
+
+			token, err := userToken(1, "test@example.com")
+			assert.NoError(t, err)
+
+			claims, err := pkg.ValidateJWT(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "test@example.com", claims.Email)
+
+			// Tokens signed before a rotation stay valid while the old key is retained
+			assert.NoError(t, km.Rotate())
+			_, err = pkg.ValidateJWT(token)
+			assert.NoError(t, err)
+
+			router, _, _, _, _ := setupTestRouter()
+			req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var jwks pkg.JWKSet
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+			assert.Len(t, jwks.Keys, 2)
+			assert.Equal(t, algorithm, jwks.Keys[0].Algorithm)
+		})
+	}
+}
+
+// TestHMACTokenRejectedWithAsymmetricKeys tests that shared-secret tokens are refused once keys are configured
+func TestHMACTokenRejectedWithAsymmetricKeys(t *testing.T) {
+	hmacToken, _ := userToken(1, "test@example.com")
+
+	km, err := pkg.NewKeyManager(t.TempDir(), "RS256", time.Hour, time.Hour)
+	assert.NoError(t, err)
+
+	pkg.SetKeyManager(km)
+	defer pkg.SetKeyManager(nil)
+
+	_, err = pkg.ValidateJWT(hmacToken)
+	assert.Error(t, err)
+}
+
+// TestKeyCreationTimeComesFromKeyID tests that copying or touching key files does not change which key signs
+func TestKeyCreationTimeComesFromKeyID(t *testing.T) {
+	dir := t.TempDir()
+	km, err := pkg.NewKeyManager(dir, "EdDSA", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	first := km.ActiveKey()
+
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, km.Rotate())
+	second := km.ActiveKey()
+	assert.NotEqual(t, first.ID, second.ID)
+
+	// A newer modification time does not make the old key active again
+	future := time.Now().Add(24 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, first.ID+".pem"), future, future))
+	assert.NoError(t, km.Reload())
+	assert.Equal(t, second.ID, km.ActiveKey().ID)
+
+	// Kids with whole seconds still load; files without a creation time fall back to their modification time
+	data, err := os.ReadFile(filepath.Join(dir, first.ID+".pem"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "20200102T030405Z-legacy.pem"), data, 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "operator.pem"), data, 0600))
+	past := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "operator.pem"), past, past))
+	assert.NoError(t, km.Reload())
+
+	legacy, ok := km.VerificationKey("20200102T030405Z-legacy")
+	if assert.True(t, ok) {
+		assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), legacy.CreatedAt)
+	}
+	operator, ok := km.VerificationKey("operator")
+	if assert.True(t, ok) {
+		assert.True(t, past.Equal(operator.CreatedAt))
+	}
+	assert.Equal(t, second.ID, km.ActiveKey().ID)
+}
+
+// TestRetiredKeysKeptForRetentionAfterLateRotation tests that a key replaced
+// long after it was due, such as after downtime, stays verifiable for the
+// retention period while keys retired before that are removed
+func TestRetiredKeysKeptForRetentionAfterLateRotation(t *testing.T) {
+	source, err := pkg.NewKeyManager(t.TempDir(), "EdDSA", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(source.Dir, source.ActiveKey().ID+".pem"))
+	assert.NoError(t, err)
+
+	// Two keys from long ago: the older one was retired an hour after its creation
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "20200102T030405Z-older.pem"), data, 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "20200102T040405Z-stale.pem"), data, 0600))
+
+	km, err := pkg.NewKeyManager(dir, "EdDSA", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "20200102T040405Z-stale", km.ActiveKey().ID)
+
+	assert.NoError(t, km.Rotate())
+	assert.NotEqual(t, "20200102T040405Z-stale", km.ActiveKey().ID)
+
+	stale, ok := km.VerificationKey("20200102T040405Z-stale")
+	if assert.True(t, ok, "the key the late rotation replaced must stay verifiable") {
+		assert.Equal(t, km.ActiveKey().CreatedAt, stale.RetiredAt)
+	}
+	_, ok = km.VerificationKey("20200102T030405Z-older")
+	assert.False(t, ok)
+	_, err = os.Stat(filepath.Join(dir, "20200102T030405Z-older.pem"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-auth-app/config"
+	"go-auth-app/pkg"
+)
+
+// restoreJWTConfig resets the package level JWT settings to their defaults
+func restoreJWTConfig() {
+	pkg.ConfigureJWT(pkg.JWTConfig{Secret: []byte(os.Getenv("JWT_SECRET"))})
+}
+
+// TestJWTIssuerAndAudienceEnforced tests that tokens minted for one deployment are rejected by another
+func TestJWTIssuerAndAudienceEnforced(t *testing.T) {
+	defer restoreJWTConfig()
+
+	staging := pkg.JWTConfig{Secret: []byte("shared"), Issuer: "https://staging.example.com", Audience: []string{"chat"}}
+	production := pkg.JWTConfig{Secret: []byte("shared"), Issuer: "https://prod.example.com", Audience: []string{"chat"}}
+
+	pkg.ConfigureJWT(staging)
+	token, err := userToken(1, "test@example.com")
+	assert.NoError(t, err)
+
+	claims, err := pkg.ValidateJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.Subject)
+	assert.Equal(t, staging.Issuer, claims.Issuer)
+	assert.NotZero(t, claims.NotBefore)
+
+	pkg.ConfigureJWT(production)
+	_, err = pkg.ValidateJWT(token)
+	assert.Error(t, err)
+
+	production.Issuer = staging.Issuer
+	production.Audience = []string{"metrics"}
+	pkg.ConfigureJWT(production)
+	_, err = pkg.ValidateJWT(token)
+	assert.Error(t, err)
+}
+
+// TestJWTLifetimeAndLeeway tests the token lifetime and clock-skew tolerance
+func TestJWTLifetimeAndLeeway(t *testing.T) {
+	defer restoreJWTConfig()
+
+	pkg.ConfigureJWT(pkg.JWTConfig{Secret: []byte("shared")})
+
+	token, err := pkg.SignClaims(pkg.NewClaims(1, "test@example.com", 2*time.Hour))
+	assert.NoError(t, err)
+
+	claims, err := pkg.ValidateJWT(token)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(2*time.Hour).Unix(), claims.ExpiresAt, 2)
+
+	// A token that expired a few seconds ago is only accepted within the leeway
+	expired := pkg.NewClaims(1, "test@example.com", -5*time.Second)
+	token, err = pkg.SignClaims(expired)
+	assert.NoError(t, err)
+
+	_, err = pkg.ValidateJWT(token)
+	assert.Error(t, err)
+
+	pkg.ConfigureJWT(pkg.JWTConfig{Secret: []byte("shared"), Leeway: 30 * time.Second})
+	_, err = pkg.ValidateJWT(token)
+	assert.NoError(t, err)
+}
+
+// TestLegacyJWTExpirationHonoured tests that the deprecated JWT_EXPIRATION_HOURS
+// still sets the access token lifetime unless ACCESS_TOKEN_EXPIRATION_MINUTES is set
+func TestLegacyJWTExpirationHonoured(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_EXPIRATION_MINUTES", "")
+	os.Unsetenv("ACCESS_TOKEN_EXPIRATION_MINUTES")
+	t.Setenv("JWT_EXPIRATION_HOURS", "2")
+	assert.Equal(t, 120, config.LoadConfig().AccessTokenExpiration)
+
+	t.Setenv("ACCESS_TOKEN_EXPIRATION_MINUTES", "10")
+	assert.Equal(t, 10, config.LoadConfig().AccessTokenExpiration)
+}
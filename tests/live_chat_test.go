@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// natsStub is a minimal in-process NATS server. It speaks just enough of the
+// client protocol (CONNECT, PING, SUB, UNSUB, PUB) for messages to travel
+// between the app's subscriptions like they do in production.
+type natsStub struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	subs     map[*natsStubConn]map[string]string
+}
+
+// natsStubConn is one client connection of the stub
+type natsStubConn struct {
+	conn  net.Conn
+	mutex sync.Mutex
+}
+
+// startNATSStub listens on a free local port until the test ends
+func startNATSStub(t *testing.T) *natsStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	stub := &natsStub{listener: listener, subs: make(map[*natsStubConn]map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(&natsStubConn{conn: conn})
+		}
+	}()
+
+	return stub
+}
+
+// URL is the address clients connect to
+func (s *natsStub) URL() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+// serve handles the protocol of one client connection
+func (s *natsStub) serve(client *natsStubConn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.subs, client)
+		s.mutex.Unlock()
+		client.conn.Close()
+	}()
+
+	client.write(fmt.Sprintf("INFO {\"server_id\":\"stub\",\"version\":\"2.10.0\",\"proto\":1,\"max_payload\":1048576,\"port\":%d}\r\n", s.listener.Addr().(*net.TCPAddr).Port))
+
+	reader := bufio.NewReader(client.conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			client.write("PONG\r\n")
+		case "SUB":
+			s.mutex.Lock()
+			if s.subs[client] == nil {
+				s.subs[client] = make(map[string]string)
+			}
+			s.subs[client][fields[len(fields)-1]] = fields[1]
+			s.mutex.Unlock()
+		case "UNSUB":
+			s.mutex.Lock()
+			delete(s.subs[client], fields[1])
+			s.mutex.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.publish(fields[1], payload[:size])
+		}
+	}
+}
+
+// publish delivers a message to every matching subscription
+func (s *natsStub) publish(subject string, payload []byte) {
+	type delivery struct {
+		client *natsStubConn
+		sid    string
+	}
+
+	s.mutex.Lock()
+	var deliveries []delivery
+	for client, subs := range s.subs {
+		for sid, pattern := range subs {
+			if natsSubjectMatches(pattern, subject) {
+				deliveries = append(deliveries, delivery{client, sid})
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, d := range deliveries {
+		d.client.write(fmt.Sprintf("MSG %s %s %d\r\n%s\r\n", subject, d.sid, len(payload), payload))
+	}
+}
+
+// write sends a protocol line, keeping concurrent writes apart
+func (c *natsStubConn) write(data string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conn.Write([]byte(data))
+}
+
+// natsSubjectMatches applies the * and > wildcards of a subscription
+func natsSubjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// setupLiveChatTestRouter creates a chat router whose messages go through a
+// NATS stub, as between app instances
+func setupLiveChatTestRouter(t *testing.T) (*gin.Engine, *MemoryChatRepository) {
+	natsClient, err := pkg.NewNatsClient(startNATSStub(t).URL(), false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(natsClient.Close)
+	natsService := service.NewNATSService(natsClient)
+
+	mockUserRepo := new(MockUserRepository)
+	chatRepo := NewMemoryChatRepository()
+	for id := 1; id <= 4; id++ {
+		mockUserRepo.On("GetByID", mock.Anything, id).Return(&domain.User{ID: id, Name: "User " + strconv.Itoa(id)}, nil)
+	}
+
+	// Typing and presence stay local, only messages and their updates travel over NATS
+	chatUsecase := usecase.NewChatUsecase(chatRepo, mockUserRepo, nil, nil)
+	chatUsecase.NatsService = natsService
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo},
+		ChatUsecase: chatUsecase,
+		WSHandler:   delivery.NewWebSocketHandler(chatUsecase, natsService, nil),
+	}.newRouter()
+
+	return router, chatRepo
+}
+
+// readFrame waits for the next frame of the given type, skipping others
+func readFrame(t *testing.T, conn *websocket.Conn, frameType string) pkg.WebSocketMessage {
+	for {
+		var frame pkg.WebSocketMessage
+		if !assert.NoError(t, conn.ReadJSON(&frame)) {
+			t.FailNow()
+		}
+		if frame.Type == frameType {
+			return frame
+		}
+	}
+}
+
+// sendFrame writes a frame of the given type
+func sendFrame(t *testing.T, conn *websocket.Conn, frameType string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	assert.NoError(t, conn.WriteJSON(pkg.WebSocketMessage{Type: frameType, Data: data}))
+}
+
+// TestLiveDirectMessageCarriesID tests that the recipient of a live direct
+// message gets its ID and conversation, and can mark it read with them
+func TestLiveDirectMessageCarriesID(t *testing.T) {
+	router, chatRepo := setupLiveChatTestRouter(t)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	recipient := dialChat(t, server, 2)
+	defer recipient.Close()
+	sender := dialChat(t, server, 1)
+	defer sender.Close()
+
+	sendFrame(t, sender, pkg.TypeChat, domain.MessageRequest{ReceiverID: 2, Content: "Lunch?"})
+	var confirmed domain.Message
+	assert.NoError(t, json.Unmarshal(readFrame(t, sender, "chat_confirmed").Data, &confirmed))
+
+	var received domain.Message
+	assert.NoError(t, json.Unmarshal(readFrame(t, recipient, pkg.TypeChat).Data, &received))
+	assert.Equal(t, "Lunch?", received.Content)
+	assert.Equal(t, confirmed.ID, received.ID)
+	assert.Equal(t, confirmed.ConversationID, received.ConversationID)
+	assert.NotZero(t, received.ID)
+
+	sendFrame(t, recipient, pkg.TypeRead, domain.MarkReadRequest{ConversationID: received.ConversationID, MessageID: received.ID})
+	var receipt domain.ReadReceipt
+	assert.NoError(t, json.Unmarshal(readFrame(t, recipient, pkg.TypeRead).Data, &receipt))
+	assert.Equal(t, received.ID, receipt.MessageID)
+	assert.Equal(t, received.ID, chatRepo.Members[received.ConversationID][2].LastReadMessageID)
+}
+
+// TestLiveDirectMessageEditReachesRecipient tests that edits and deletions of
+// a live direct message reach the recipient under the ID it received
+func TestLiveDirectMessageEditReachesRecipient(t *testing.T) {
+	router, _ := setupLiveChatTestRouter(t)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	recipient := dialChat(t, server, 2)
+	defer recipient.Close()
+	sender := dialChat(t, server, 1)
+	defer sender.Close()
+
+	sendFrame(t, sender, pkg.TypeChat, domain.MessageRequest{ReceiverID: 2, Content: "See you at 5"})
+	var received domain.Message
+	assert.NoError(t, json.Unmarshal(readFrame(t, recipient, pkg.TypeChat).Data, &received))
+
+	sendFrame(t, sender, pkg.TypeEdit, domain.EditMessageRequest{ConversationID: received.ConversationID, MessageID: received.ID, Content: "See you at 6"})
+	var edited domain.Message
+	assert.NoError(t, json.Unmarshal(readFrame(t, recipient, pkg.TypeMessageEdited).Data, &edited))
+	assert.Equal(t, received.ID, edited.ID)
+	assert.Equal(t, received.ConversationID, edited.ConversationID)
+	assert.Equal(t, "See you at 6", edited.Content)
+
+	sendFrame(t, sender, pkg.TypeDelete, domain.DeleteMessageRequest{ConversationID: received.ConversationID, MessageID: received.ID, For: domain.DeleteForEveryone})
+	var deletion domain.MessageDeletion
+	assert.NoError(t, json.Unmarshal(readFrame(t, recipient, pkg.TypeMessageDeleted).Data, &deletion))
+	assert.Equal(t, received.ID, deletion.MessageID)
+	assert.Equal(t, received.ConversationID, deletion.ConversationID)
+}
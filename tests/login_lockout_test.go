@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+)
+
+// Mock Login Attempt Repository
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Get(ctx context.Context, scope, key string) (*domain.LoginAttempt, error) {
+	args := m.Called(ctx, scope, key)
+	if attempt, ok := args.Get(0).(*domain.LoginAttempt); ok {
+		return attempt, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) RecordFailure(ctx context.Context, scope, key string, windowStart time.Time) (*domain.LoginAttempt, error) {
+	args := m.Called(ctx, scope, key, windowStart)
+	if attempt, ok := args.Get(0).(*domain.LoginAttempt); ok {
+		return attempt, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	args := m.Called(ctx, scope, key, until)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) Reset(ctx context.Context, scope, key string) error {
+	args := m.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	args := m.Called(ctx, before)
+	return args.Error(0)
+}
+
+// setupLockoutTestRouter creates a test router with login throttling enabled
+func setupLockoutTestRouter() (*gin.Engine, *MockUserRepository, *MockLoginAttemptRepository, *MockRefreshTokenRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockLoginAttemptRepo := new(MockLoginAttemptRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+
+	cfg := &config.Config{
+		LoginMaxAttempts:     3,
+		LoginIPMaxAttempts:   10,
+		LoginLockoutDuration: 15,
+		LoginBackoffBase:     2,
+		LoginBackoffMax:      30,
+	}
+
+	router, _ := testApp{
+		Deps:   usecase.AuthDependencies{UserRepo: mockUserRepo, RefreshTokenRepo: mockRefreshTokenRepo, LoginAttemptRepo: mockLoginAttemptRepo},
+		Config: cfg,
+	}.newRouter()
+
+	return router, mockUserRepo, mockLoginAttemptRepo, mockRefreshTokenRepo
+}
+
+// lockedFor matches a lock time roughly d from now
+func lockedFor(d time.Duration) interface{} {
+	return mock.MatchedBy(func(until time.Time) bool {
+		remaining := time.Until(until)
+		return remaining > d-5*time.Second && remaining <= d
+	})
+}
+
+// loginFrom posts credentials to /login from the given client IP
+func loginFrom(router *gin.Engine, ip, email, password string) *httptest.ResponseRecorder {
+	req := jsonRequest("POST", "/login", map[string]string{"email": email, "password": password})
+	req.RemoteAddr = ip + ":40000"
+	return serve(router, req)
+}
+
+// TestFailedLoginBacksOffAndLocks tests exponential backoff and the lockout once the limit is reached
+func TestFailedLoginBacksOffAndLocks(t *testing.T) {
+	router, mockUserRepo, mockLoginAttemptRepo, _ := setupLockoutTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+	mockLoginAttemptRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	// Second failure on the account: 2s base doubled once
+	mockLoginAttemptRepo.On("RecordFailure", mock.Anything, domain.LoginScopeAccount, "test@example.com", mock.Anything).
+		Return(&domain.LoginAttempt{Failures: 2}, nil).Once()
+	mockLoginAttemptRepo.On("RecordFailure", mock.Anything, domain.LoginScopeIP, "203.0.113.7", mock.Anything).
+		Return(&domain.LoginAttempt{Failures: 1}, nil)
+	mockLoginAttemptRepo.On("Lock", mock.Anything, domain.LoginScopeAccount, "test@example.com", lockedFor(4*time.Second)).Return(nil).Once()
+	mockLoginAttemptRepo.On("Lock", mock.Anything, domain.LoginScopeIP, "203.0.113.7", lockedFor(2*time.Second)).Return(nil)
+
+	w := loginFrom(router, "203.0.113.7", "test@example.com", "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Third failure reaches the limit and locks the account for the lockout duration
+	mockLoginAttemptRepo.On("RecordFailure", mock.Anything, domain.LoginScopeAccount, "test@example.com", mock.Anything).
+		Return(&domain.LoginAttempt{Failures: 3}, nil).Once()
+	mockLoginAttemptRepo.On("Lock", mock.Anything, domain.LoginScopeAccount, "test@example.com", lockedFor(15*time.Minute)).Return(nil).Once()
+
+	// Unknown accounts are counted as well, and email case does not matter
+	mockUserRepo.On("GetByEmail", mock.Anything, "Test@Example.com ").Return(nil, errors.New("not found"))
+	w = loginFrom(router, "203.0.113.7", "Test@Example.com ", "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockLoginAttemptRepo.AssertExpectations(t)
+}
+
+// TestLockedAccountIsRejected tests that a locked account is refused before the password is checked
+func TestLockedAccountIsRejected(t *testing.T) {
+	router, mockUserRepo, mockLoginAttemptRepo, _ := setupLockoutTestRouter()
+
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	mockLoginAttemptRepo.On("Get", mock.Anything, domain.LoginScopeAccount, "test@example.com").
+		Return(&domain.LoginAttempt{Failures: 3, LockedUntil: &lockedUntil}, nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "test@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "600", w.Header().Get("Retry-After"))
+
+	mockUserRepo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+}
+
+// TestSuccessfulLoginResetsAccountCounter tests that only the account counter is cleared on success
+func TestSuccessfulLoginResetsAccountCounter(t *testing.T) {
+	router, mockUserRepo, mockLoginAttemptRepo, mockRefreshTokenRepo := setupLockoutTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	expired := time.Now().Add(-time.Second)
+	mockLoginAttemptRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(&domain.LoginAttempt{Failures: 2, LockedUntil: &expired}, nil)
+	mockLoginAttemptRepo.On("Reset", mock.Anything, domain.LoginScopeAccount, "test@example.com").Return(nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "test@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockLoginAttemptRepo.AssertExpectations(t)
+	mockLoginAttemptRepo.AssertNotCalled(t, "Reset", mock.Anything, domain.LoginScopeIP, mock.Anything)
+}
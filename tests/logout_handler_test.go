@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock Revocation Repository
+type MockRevocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockRevocationRepository) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, userID, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRevocationRepository) RevokeAllForUser(ctx context.Context, userID int, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
+func (m *MockRevocationRepository) GetRevokedTokens(ctx context.Context) ([]*domain.RevokedToken, error) {
+	args := m.Called(ctx)
+	if tokens, ok := args.Get(0).([]*domain.RevokedToken); ok {
+		return tokens, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRevocationRepository) GetUserRevocations(ctx context.Context, since time.Time) ([]*domain.UserRevocation, error) {
+	args := m.Called(ctx, since)
+	if revocations, ok := args.Get(0).([]*domain.UserRevocation); ok {
+		return revocations, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRevocationRepository) DeleteExpired(ctx context.Context, userRevocationCutoff time.Time) error {
+	args := m.Called(ctx, userRevocationCutoff)
+	return args.Error(0)
+}
+
+// setupLogoutTestRouter creates a test router with an in-memory revocation cache
+func setupLogoutTestRouter() (*gin.Engine, *MockRevocationRepository, *MockRefreshTokenRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+
+	// Revocations are only cached locally since there is no NATS connection
+	revocationService := service.NewRevocationService(nil)
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+		},
+		Config: &config.Config{},
+	}.newRouter()
+
+	return router, mockRevocationRepo, mockRefreshTokenRepo
+}
+
+// performAuthorized sends a request carrying the given bearer token
+func performAuthorized(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// userToken signs an hour-long access token for a user
+func userToken(userID int, email string) (string, error) {
+	return pkg.SignClaims(pkg.NewClaims(userID, email, time.Hour))
+}
+
+// TestLogoutRevokesToken tests that a token stops working after /logout
+func TestLogoutRevokesToken(t *testing.T) {
+	router, mockRevocationRepo, _ := setupLogoutTestRouter()
+
+	token, _ := userToken(1, "test@example.com")
+	otherToken, _ := userToken(1, "test@example.com")
+
+	mockRevocationRepo.On("RevokeToken", mock.Anything, mock.AnythingOfType("string"), 1, mock.AnythingOfType("time.Time")).Return(nil)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", token).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "POST", "/logout", token).Code)
+
+	// The logged out token is rejected, other sessions keep working
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", token).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", otherToken).Code)
+
+	mockRevocationRepo.AssertExpectations(t)
+}
+
+// TestLogoutAllRevokesEveryToken tests that /logout-all invalidates all tokens of the user
+func TestLogoutAllRevokesEveryToken(t *testing.T) {
+	router, mockRevocationRepo, mockRefreshTokenRepo := setupLogoutTestRouter()
+
+	token, _ := userToken(1, "test@example.com")
+	otherToken, _ := userToken(1, "test@example.com")
+	unrelatedToken, _ := userToken(2, "other@example.com")
+
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "POST", "/logout-all", token).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", token).Code)
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", otherToken).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", unrelatedToken).Code)
+
+	// Signing in again right away, usually within the same second, works
+	time.Sleep(2 * time.Millisecond)
+	newToken, _ := userToken(1, "test@example.com")
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", newToken).Code)
+
+	mockRevocationRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
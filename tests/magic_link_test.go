@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// TestMagicLinkLogin tests requesting a sign-in link and exchanging it for a token pair
+func TestMagicLinkLogin(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, mockRefreshTokenRepo, mailer := setupPasswordResetTestRouter()
+
+	user := &domain.User{ID: 1, Name: "Test User", Email: "tech@example.com"}
+	mockUserRepo.On("GetByEmail", mock.Anything, "tech@example.com").Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+	mockUserRepo.On("MarkEmailVerified", mock.Anything, 1).Return(nil).Once()
+	mockUserTokenRepo.On("GetLatestForUser", mock.Anything, 1, domain.TokenPurposeMagicLink).Return(nil, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposeMagicLink).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	var stored *domain.UserToken
+	mockUserTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.UserToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*domain.UserToken)
+		stored.ID = 9
+	}).Return(nil)
+
+	w := postJSON(router, "/login/magic", map[string]string{"email": "Tech@Example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	email := mailer.Next(t)
+	assert.Equal(t, "tech@example.com", email.To)
+	link := regexp.MustCompile(`http://app.test/login/magic/verify\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(email.Body)
+	if !assert.Len(t, link, 2) {
+		return
+	}
+	assert.Equal(t, pkg.HashToken(link[1]), stored.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), stored.ExpiresAt, time.Minute)
+
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken(link[1]), domain.TokenPurposeMagicLink).Return(stored, nil)
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 9).Return(true, nil).Once()
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 9).Return(false, nil)
+
+	// Opening the link only shows a page that posts the token
+	w = serve(router, httptest.NewRequest("GET", "/login/magic/verify?token="+link[1], nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `value="`+link[1]+`"`)
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	mockUserTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+
+	form := url.Values{"token": {link[1]}, "device_name": {"Field tablet"}}
+	req := httptest.NewRequest("POST", "/login/magic/verify", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result domain.LoginResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	if assert.NotNil(t, result.TokenPair) {
+		claims, err := pkg.ValidateJWT(result.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, claims.UserID)
+	}
+
+	// Opening the link proved the address, and the link works only once
+	assert.NotNil(t, user.EmailVerifiedAt)
+	w = postJSON(router, "/login/magic/verify", map[string]string{"token": link[1]})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestMagicLinkRequestIsSilent tests that unknown, disabled and throttled accounts get the same response and no email
+func TestMagicLinkRequestIsSilent(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, _, mailer := setupPasswordResetTestRouter()
+
+	disabledAt := time.Now()
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, assert.AnError)
+	mockUserRepo.On("GetByEmail", mock.Anything, "gone@example.com").Return(&domain.User{ID: 2, Email: "gone@example.com", DisabledAt: &disabledAt}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "tech@example.com").Return(&domain.User{ID: 1, Email: "tech@example.com"}, nil)
+	mockUserTokenRepo.On("GetLatestForUser", mock.Anything, 1, domain.TokenPurposeMagicLink).Return(&domain.UserToken{CreatedAt: time.Now()}, nil)
+
+	for _, address := range []string{"nobody@example.com", "gone@example.com", "tech@example.com"} {
+		w := postJSON(router, "/login/magic", map[string]string{"email": address})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "If an account exists")
+	}
+
+	assert.Empty(t, mailer.Sent)
+	mockUserTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	// Expired links are refused
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("expired"), domain.TokenPurposeMagicLink).Return(&domain.UserToken{ID: 4, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/login/magic/verify", map[string]string{"token": "expired"}).Code)
+}
+
+// TestMagicLinkUsesConfiguredPage tests that sign-in links open MAGIC_LINK_URL when it is set
+func TestMagicLinkUsesConfiguredPage(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
+	mailer := NewRecordingMailer()
+	authUsecase := usecase.NewAuthUsecase(usecase.AuthDependencies{UserRepo: mockUserRepo, UserTokenRepo: mockUserTokenRepo, Mailer: mailer}, &config.Config{
+		AppBaseURL:   "http://api.test",
+		MagicLinkURL: "https://chat.test/sign-in?source=email",
+	})
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "tech@example.com").Return(&domain.User{ID: 1, Name: "Test User", Email: "tech@example.com"}, nil)
+	mockUserTokenRepo.On("GetLatestForUser", mock.Anything, 1, domain.TokenPurposeMagicLink).Return(nil, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposeMagicLink).Return(nil)
+	mockUserTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.UserToken")).Return(nil)
+
+	assert.NoError(t, authUsecase.RequestMagicLink(context.Background(), "tech@example.com", domain.ClientInfo{}))
+
+	email := mailer.Next(t)
+	assert.Regexp(t, `https://chat\.test/sign-in\?source=email&token=[A-Za-z0-9_-]+\n`, email.Body)
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+)
+
+// messageContents returns the content of the messages a user sees in a conversation by message ID
+func messageContents(response map[string]interface{}) map[int]string {
+	contents := map[int]string{}
+	for _, item := range response["data"].([]interface{}) {
+		message := item.(map[string]interface{})
+		contents[int(message["id"].(float64))] = message["content"].(string)
+	}
+	return contents
+}
+
+// TestEditAndDeleteMessages tests editing, deleting for everyone and deleting for yourself
+func TestEditAndDeleteMessages(t *testing.T) {
+	router, chatRepo := setupGroupChatTestRouter()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2, 3}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+	base := "/chat/conversations/" + strconv.Itoa(id) + "/messages"
+
+	messageIDs := []int{}
+	for i, sender := range []int{1, 2, 1} {
+		_, response := chatRequest(router, sender, "POST", base, map[string]string{"content": "message " + strconv.Itoa(i)})
+		messageIDs = append(messageIDs, int(response["data"].(map[string]interface{})["id"].(float64)))
+	}
+	first, second, latest := base+"/"+strconv.Itoa(messageIDs[0]), base+"/"+strconv.Itoa(messageIDs[1]), base+"/"+strconv.Itoa(messageIDs[2])
+
+	// Only the sender edits, outsiders do not see the message
+	code, _ := chatRequest(router, 2, "PATCH", latest, map[string]string{"content": "mine"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = chatRequest(router, 4, "PATCH", latest, map[string]string{"content": "mine"})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = chatRequest(router, 1, "PATCH", latest, map[string]string{"content": ""})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	for _, content := range []string{"message 2, fixed", "message 2, final"} {
+		code, response = chatRequest(router, 1, "PATCH", latest, map[string]string{"content": content})
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotNil(t, response["data"].(map[string]interface{})["edited_at"])
+	}
+	assert.Equal(t, "message 2, final", chatRepo.Conversations[id].LastMessage)
+
+	code, response = chatRequest(router, 3, "GET", latest+"/revisions", nil)
+	assert.Equal(t, http.StatusOK, code)
+	revisions := response["data"].([]interface{})
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, "message 2", revisions[0].(map[string]interface{})["content"])
+		assert.Equal(t, "message 2, fixed", revisions[1].(map[string]interface{})["content"])
+	}
+	assert.Equal(t, 2, unreadCounts(t, router, 2)[id])
+
+	// Deleting the latest message for everyone leaves a tombstone and an older preview
+	code, _ = chatRequest(router, 2, "DELETE", latest+"?for=everyone", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, response = chatRequest(router, 1, "DELETE", latest+"?for=everyone", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, domain.DeleteForEveryone, response["data"].(map[string]interface{})["for"])
+	assert.Equal(t, "message 1", chatRepo.Conversations[id].LastMessage)
+	assert.Equal(t, 1, unreadCounts(t, router, 2)[id])
+
+	_, response = chatRequest(router, 2, "GET", base, nil)
+	tombstone := response["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "", tombstone["content"])
+	assert.NotNil(t, tombstone["deleted_at"])
+	_, response = chatRequest(router, 3, "GET", latest+"/revisions", nil)
+	assert.Empty(t, response["data"])
+
+	code, _ = chatRequest(router, 1, "PATCH", latest, map[string]string{"content": "back"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = chatRequest(router, 1, "DELETE", latest+"?for=everyone", nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// Deleting for yourself hides the message from you only
+	code, _ = chatRequest(router, 2, "DELETE", first+"?for=nobody", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, response = chatRequest(router, 2, "DELETE", first, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, domain.DeleteForMe, response["data"].(map[string]interface{})["for"])
+	assert.Equal(t, 0, unreadCounts(t, router, 2)[id])
+
+	_, response = chatRequest(router, 2, "GET", base, nil)
+	assert.NotContains(t, messageContents(response), messageIDs[0])
+	_, response = chatRequest(router, 3, "GET", base, nil)
+	assert.Equal(t, "message 0", messageContents(response)[messageIDs[0]])
+
+	// Members can hide messages of others, but not delete them for everyone
+	code, _ = chatRequest(router, 3, "DELETE", second, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "message 1", chatRepo.Conversations[id].LastMessage)
+}
+
+// TestMessageEditWebSocketFrames tests editing and deleting over the WebSocket
+func TestMessageEditWebSocketFrames(t *testing.T) {
+	router, _ := setupGroupChatTestRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+	_, response = chatRequest(router, 1, "POST", "/chat/conversations/"+strconv.Itoa(id)+"/messages", map[string]string{"content": "draft"})
+	messageID := int(response["data"].(map[string]interface{})["id"].(float64))
+
+	conn := dialChat(t, server, 1)
+	defer conn.Close()
+	send := func(frameType string, payload interface{}) pkg.WebSocketMessage {
+		data, _ := json.Marshal(payload)
+		assert.NoError(t, conn.WriteJSON(pkg.WebSocketMessage{Type: frameType, Data: data}))
+		var reply pkg.WebSocketMessage
+		assert.NoError(t, conn.ReadJSON(&reply))
+		return reply
+	}
+
+	reply := send(pkg.TypeEdit, domain.EditMessageRequest{ConversationID: id, MessageID: messageID, Content: "final"})
+	assert.Equal(t, pkg.TypeMessageEdited, reply.Type)
+	var message domain.Message
+	assert.NoError(t, json.Unmarshal(reply.Data, &message))
+	assert.Equal(t, "final", message.Content)
+	assert.NotNil(t, message.EditedAt)
+
+	reply = send(pkg.TypeDelete, domain.DeleteMessageRequest{ConversationID: id, MessageID: messageID + 100, For: domain.DeleteForEveryone})
+	assert.Equal(t, pkg.TypeError, reply.Type)
+
+	reply = send(pkg.TypeDelete, domain.DeleteMessageRequest{ConversationID: id, MessageID: messageID, For: domain.DeleteForEveryone})
+	assert.Equal(t, pkg.TypeMessageDeleted, reply.Type)
+	var deletion domain.MessageDeletion
+	assert.NoError(t, json.Unmarshal(reply.Data, &deletion))
+	assert.Equal(t, domain.MessageDeletion{ConversationID: id, MessageID: messageID, For: domain.DeleteForEveryone, DeletedAt: deletion.DeletedAt}, deletion)
+}
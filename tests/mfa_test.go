@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock MFA Repository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) GetByUserID(ctx context.Context, userID int) (*domain.UserMFA, error) {
+	args := m.Called(ctx, userID)
+	if mfa, ok := args.Get(0).(*domain.UserMFA); ok {
+		return mfa, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockMFARepository) SavePending(ctx context.Context, userID int, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Enable(ctx context.Context, userID int, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) RecordFailedAttempt(ctx context.Context, userID, maxAttempts int, lockUntil time.Time) (int, error) {
+	args := m.Called(ctx, userID, maxAttempts, lockUntil)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	args := m.Called(ctx, userID, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+// setupMFATestRouter creates a test router for two-factor enrollment and login
+func setupMFATestRouter() (*gin.Engine, *MockUserRepository, *MockMFARepository, *MockRevocationRepository, *MockRefreshTokenRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockMFARepo := new(MockMFARepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	revocationService := service.NewRevocationService(nil)
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+			MFARepo:           mockMFARepo,
+		},
+		Config: &config.Config{},
+	}.newRouter()
+
+	return router, mockUserRepo, mockMFARepo, mockRevocationRepo, mockRefreshTokenRepo
+}
+
+// TestTOTPMatchesRFC6238Vectors checks the SHA-1 test vectors from RFC 6238 appendix B
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// base32 of the ASCII seed "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := pkg.TOTPCode(secret, pkg.TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+
+	step, ok := pkg.ValidateTOTP(secret, "287082", time.Unix(59+pkg.TOTPPeriod, 0), 1)
+	assert.True(t, ok, "codes from the previous step are accepted")
+	assert.Equal(t, int64(1), step)
+
+	_, ok = pkg.ValidateTOTP(secret, "287082", time.Unix(59+3*pkg.TOTPPeriod, 0), 1)
+	assert.False(t, ok, "codes outside the skew window are rejected")
+}
+
+// TestTOTPEnrollment tests that a TOTP secret is only enabled after a valid code and returns recovery codes
+func TestTOTPEnrollment(t *testing.T) {
+	router, mockUserRepo, mockMFARepo, _, _ := setupMFATestRouter()
+
+	token, _ := userToken(1, "test@example.com")
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(nil, nil).Once()
+
+	var secret string
+	mockMFARepo.On("SavePending", mock.Anything, 1, mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		secret = args.String(2)
+	}).Return(nil)
+
+	req := jsonRequest("POST", "/mfa/totp/enroll", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var enrollment domain.TOTPEnrollment
+	json.Unmarshal(w.Body.Bytes(), &enrollment)
+	assert.Equal(t, secret, enrollment.Secret)
+	assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/go-auth-app:test@example.com?")
+
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret}, nil)
+
+	// A wrong code does not enable anything
+	req = jsonRequest("POST", "/mfa/totp/confirm", map[string]string{"code": "abcdef"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusBadRequest, serve(router, req).Code)
+
+	var hashes []string
+	mockMFARepo.On("ReplaceRecoveryCodes", mock.Anything, 1, mock.Anything).Run(func(args mock.Arguments) {
+		hashes = args.Get(2).([]string)
+	}).Return(nil)
+	mockMFARepo.On("Enable", mock.Anything, 1, mock.AnythingOfType("int64")).Return(nil)
+
+	code, _ := pkg.TOTPCode(secret, pkg.TOTPStep(time.Now()))
+	req = jsonRequest("POST", "/mfa/totp/confirm", map[string]string{"code": code})
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.RecoveryCodes, 10)
+	assert.Len(t, hashes, 10)
+	// Only hashes are stored
+	assert.NotContains(t, hashes, response.RecoveryCodes[0])
+
+	mockMFARepo.AssertExpectations(t)
+}
+
+// TestLoginWithTOTP tests the two-step login and that the pending token is not an access token
+func TestLoginWithTOTP(t *testing.T) {
+	router, mockUserRepo, mockMFARepo, mockRevocationRepo, mockRefreshTokenRepo := setupMFATestRouter()
+
+	secret, _ := pkg.GenerateTOTPSecret()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	user := &domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}
+	enabledAt := time.Now()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(user, nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret, EnabledAt: &enabledAt}, nil)
+	mockRevocationRepo.On("RevokeToken", mock.Anything, mock.AnythingOfType("string"), 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "test@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var login map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &login)
+	assert.Equal(t, true, login["mfa_required"])
+	assert.NotContains(t, login, "token")
+	mfaToken, _ := login["mfa_token"].(string)
+	assert.NotEmpty(t, mfaToken)
+
+	// The pending token must not grant API access
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", mfaToken).Code)
+
+	step := pkg.TOTPStep(time.Now())
+	code, _ := pkg.TOTPCode(secret, step)
+	mockMFARepo.On("UseStep", mock.Anything, 1, step).Return(true, nil).Once()
+
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": mfaToken, "code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens domain.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", tokens.AccessToken).Code)
+
+	// The pending token is single use
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": mfaToken, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockMFARepo.AssertExpectations(t)
+}
+
+// TestMFARejectsReplayAndAcceptsRecoveryCode tests replay protection and recovery code logins
+func TestMFARejectsReplayAndAcceptsRecoveryCode(t *testing.T) {
+	router, mockUserRepo, mockMFARepo, mockRevocationRepo, mockRefreshTokenRepo := setupMFATestRouter()
+
+	secret, _ := pkg.GenerateTOTPSecret()
+	enabledAt := time.Now()
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret, EnabledAt: &enabledAt}, nil)
+	mockRevocationRepo.On("RevokeToken", mock.Anything, mock.AnythingOfType("string"), 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	newMFAToken := func() string {
+		claims := pkg.NewClaims(1, "test@example.com", 5*time.Minute)
+		claims.Purpose = pkg.TokenPurposeMFA
+		token, _ := pkg.SignClaims(claims)
+		return token
+	}
+
+	// A code whose time step was already used is a replay
+	step := pkg.TOTPStep(time.Now())
+	code, _ := pkg.TOTPCode(secret, step)
+	mockMFARepo.On("UseStep", mock.Anything, 1, step).Return(false, nil)
+	mockMFARepo.On("RecordFailedAttempt", mock.Anything, 1, 5, mock.AnythingOfType("time.Time")).Return(1, nil)
+
+	w := postJSON(router, "/mfa/verify", map[string]string{"mfa_token": newMFAToken(), "code": code})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Recovery codes are matched by hash regardless of case and separators
+	mockMFARepo.On("UseRecoveryCode", mock.Anything, 1, pkg.HashToken("abcdefghij")).Return(true, nil)
+
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": newMFAToken(), "recovery_code": "ABCDE-fghij"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// An access token cannot stand in for the pending token
+	accessToken, _ := userToken(1, "test@example.com")
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": accessToken, "recovery_code": "abcde-fghij"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockMFARepo.AssertExpectations(t)
+}
+
+// TestMFALocksAfterRepeatedFailures tests that wrong codes lock the second factor for the user, not just the pending login
+func TestMFALocksAfterRepeatedFailures(t *testing.T) {
+	router, mockUserRepo, mockMFARepo, mockRevocationRepo, mockRefreshTokenRepo := setupMFATestRouter()
+
+	secret, _ := pkg.GenerateTOTPSecret()
+	enabledAt := time.Now()
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockRevocationRepo.On("RevokeToken", mock.Anything, mock.AnythingOfType("string"), 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	newMFAToken := func() string {
+		claims := pkg.NewClaims(1, "test@example.com", 5*time.Minute)
+		claims.Purpose = pkg.TokenPurposeMFA
+		token, _ := pkg.SignClaims(claims)
+		return token
+	}
+
+	// The wrong code that reaches the limit locks the factor and revokes the pending login
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret, EnabledAt: &enabledAt, FailedAttempts: 4}, nil).Once()
+	mockMFARepo.On("RecordFailedAttempt", mock.Anything, 1, 5, mock.AnythingOfType("time.Time")).Return(5, nil).Once()
+
+	w := postJSON(router, "/mfa/verify", map[string]string{"mfa_token": newMFAToken(), "code": "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	mockRevocationRepo.AssertCalled(t, "RevokeToken", mock.Anything, mock.AnythingOfType("string"), 1, mock.AnythingOfType("time.Time"))
+
+	// A fresh pending token from another password login does not unlock it, even with the right code
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret, EnabledAt: &enabledAt, FailedAttempts: 5, LockedUntil: &lockedUntil}, nil).Once()
+
+	code, _ := pkg.TOTPCode(secret, pkg.TOTPStep(time.Now()))
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": newMFAToken(), "code": code})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	mockMFARepo.AssertNotCalled(t, "UseStep", mock.Anything, 1, mock.Anything)
+
+	// Once the lockout has passed the right code is accepted again
+	expired := time.Now().Add(-time.Minute)
+	mockMFARepo.On("GetByUserID", mock.Anything, 1).Return(&domain.UserMFA{UserID: 1, TOTPSecret: secret, EnabledAt: &enabledAt, FailedAttempts: 5, LockedUntil: &expired}, nil).Once()
+	mockMFARepo.On("UseStep", mock.Anything, 1, pkg.TOTPStep(time.Now())).Return(true, nil).Once()
+
+	w = postJSON(router, "/mfa/verify", map[string]string{"mfa_token": newMFAToken(), "code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockMFARepo.AssertExpectations(t)
+}
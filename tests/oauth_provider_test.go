@@ -0,0 +1,400 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// MemoryOAuthRepository keeps OAuth clients, consents and codes in memory
+type MemoryOAuthRepository struct {
+	mu       sync.Mutex
+	Clients  map[string]*domain.OAuthClient
+	Consents map[string]*domain.OAuthConsent
+	Codes    map[string]*domain.AuthorizationCode
+}
+
+func NewMemoryOAuthRepository() *MemoryOAuthRepository {
+	return &MemoryOAuthRepository{
+		Clients:  map[string]*domain.OAuthClient{},
+		Consents: map[string]*domain.OAuthConsent{},
+		Codes:    map[string]*domain.AuthorizationCode{},
+	}
+}
+
+func consentKey(userID int, clientID string) string {
+	return fmt.Sprintf("%s/%d", clientID, userID)
+}
+
+func (r *MemoryOAuthRepository) CreateClient(ctx context.Context, client *domain.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.CreatedAt = time.Now()
+	r.Clients[client.ID] = client
+	return nil
+}
+
+func (r *MemoryOAuthRepository) GetClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Clients[id], nil
+}
+
+func (r *MemoryOAuthRepository) ListClients(ctx context.Context) ([]*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := []*domain.OAuthClient{}
+	for _, client := range r.Clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (r *MemoryOAuthRepository) DeleteClient(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.Clients[id]
+	delete(r.Clients, id)
+	for key, consent := range r.Consents {
+		if consent.ClientID == id {
+			delete(r.Consents, key)
+		}
+	}
+	return ok, nil
+}
+
+func (r *MemoryOAuthRepository) GetConsent(ctx context.Context, userID int, clientID string) (*domain.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Consents[consentKey(userID, clientID)], nil
+}
+
+func (r *MemoryOAuthRepository) SaveConsent(ctx context.Context, consent *domain.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent.GrantedAt = time.Now()
+	consent.ClientName = r.Clients[consent.ClientID].Name
+	r.Consents[consentKey(consent.UserID, consent.ClientID)] = consent
+	return nil
+}
+
+func (r *MemoryOAuthRepository) ListConsents(ctx context.Context, userID int) ([]*domain.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consents := []*domain.OAuthConsent{}
+	for _, consent := range r.Consents {
+		if consent.UserID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (r *MemoryOAuthRepository) DeleteConsent(ctx context.Context, userID int, clientID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := consentKey(userID, clientID)
+	_, ok := r.Consents[key]
+	delete(r.Consents, key)
+	return ok, nil
+}
+
+func (r *MemoryOAuthRepository) CreateCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Codes[code.CodeHash] = code
+	return nil
+}
+
+func (r *MemoryOAuthRepository) ConsumeCode(ctx context.Context, codeHash string) (*domain.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code := r.Codes[codeHash]
+	delete(r.Codes, codeHash)
+	return code, nil
+}
+
+func (r *MemoryOAuthRepository) DeleteExpiredCodes(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+// oauthProviderTest is this app running as an OpenID Connect provider on a local server
+type oauthProviderTest struct {
+	server            *httptest.Server
+	router            *gin.Engine
+	userRepo          *MockUserRepository
+	apiKeyRepo        *MockAPIKeyRepository
+	oauthRepo         *MemoryOAuthRepository
+	revocationService *service.RevocationService
+}
+
+// setupOAuthProviderTestRouter serves the app on a local server, whose URL is
+// the issuer, with RS256 keys to sign ID tokens
+func setupOAuthProviderTestRouter(t *testing.T) *oauthProviderTest {
+	gin.SetMode(gin.TestMode)
+
+	km, err := pkg.NewKeyManager(t.TempDir(), "RS256", time.Hour, time.Hour)
+	assert.NoError(t, err)
+	pkg.SetKeyManager(km)
+	t.Cleanup(func() { pkg.SetKeyManager(nil) })
+
+	test := &oauthProviderTest{
+		router:            gin.New(),
+		userRepo:          new(MockUserRepository),
+		apiKeyRepo:        new(MockAPIKeyRepository),
+		oauthRepo:         NewMemoryOAuthRepository(),
+		revocationService: service.NewRevocationService(nil),
+	}
+	test.server = httptest.NewServer(test.router)
+	t.Cleanup(test.server.Close)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	now := time.Now()
+	user := &domain.User{ID: 5, Name: "Ada Lovelace", Email: "ada@example.com", Password: string(hashedPassword), Timezone: "Europe/London", EmailVerifiedAt: &now}
+	test.userRepo.On("GetByEmail", mock.Anything, "ada@example.com").Return(user, nil)
+	test.userRepo.On("GetByEmail", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	test.userRepo.On("GetByID", mock.Anything, 5).Return(user, nil)
+
+	testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          test.userRepo,
+			RevocationService: test.revocationService,
+			APIKeyRepo:        test.apiKeyRepo,
+			OAuthRepo:         test.oauthRepo,
+		},
+		Config: &config.Config{OAuthIssuerURL: test.server.URL},
+	}.setupRoutes(test.router)
+
+	return test
+}
+
+// registerClient registers an OAuth client through the admin API
+func (test *oauthProviderTest) registerClient(t *testing.T, public bool) *domain.CreatedOAuthClient {
+	req := jsonRequest("POST", "/admin/oauth/clients", map[string]interface{}{
+		"name":          "Wiki",
+		"redirect_uris": []string{"https://wiki.example/callback"},
+		"public":        public,
+	})
+	req.Header.Set("Authorization", "Bearer "+adminToken(1))
+	w := serve(test.router, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		domain.OAuthClient
+		ClientSecret string `json:"client_secret"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	return &domain.CreatedOAuthClient{OAuthClient: &created.OAuthClient, ClientSecret: created.ClientSecret}
+}
+
+// postForm sends a form to the router, with client credentials when given
+func postForm(router *gin.Engine, path string, form url.Values, clientID, clientSecret string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientSecret != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+	return serve(router, req)
+}
+
+// authorizationForm is the submitted sign-in form of an authorization request
+func authorizationForm(clientID, password string, extra url.Values) url.Values {
+	form := url.Values{
+		"client_id":     {clientID},
+		"redirect_uri":  {"https://wiki.example/callback"},
+		"response_type": {"code"},
+		"scope":         {"openid email profile"},
+		"state":         {"xyz"},
+		"nonce":         {"n-0S6"},
+		"email":         {"ada@example.com"},
+		"password":      {password},
+		"decision":      {"allow"},
+	}
+	for key, values := range extra {
+		form[key] = values
+	}
+	return form
+}
+
+// redirectParams returns the query of a redirect back to the client
+func redirectParams(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "wiki.example", location.Host)
+	return location.Query()
+}
+
+// TestOAuthAuthorizationCodeFlow tests a confidential client signing a user in, verified with the app's own OIDC client
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, false)
+	assert.NotEmpty(t, client.ClientSecret)
+
+	query := url.Values{"client_id": {client.ID}, "redirect_uri": {"https://wiki.example/callback"}, "response_type": {"code"}, "scope": {"openid email profile"}, "state": {"xyz"}}
+	w := serve(test.router, jsonRequest("GET", "/oauth/authorize?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Sign in to Wiki")
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+
+	// A wrong password shows the form again
+	w = postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "wrong-password", nil), "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid email or password")
+
+	params := redirectParams(t, postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "password1234", nil), "", ""))
+	assert.Equal(t, "xyz", params.Get("state"))
+	code := params.Get("code")
+	assert.NotEmpty(t, code)
+
+	tokenForm := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"https://wiki.example/callback"}}
+	w = postForm(test.router, "/oauth/token", tokenForm, client.ID, "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), domain.OAuthErrInvalidClient)
+
+	w = postForm(test.router, "/oauth/token", tokenForm, client.ID, client.ClientSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var tokens domain.OAuthTokenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	assert.Equal(t, "openid profile email", tokens.Scope)
+
+	// The ID token verifies against the discovery document and JWKS the app publishes
+	provider := pkg.NewOIDCProvider("self", test.server.URL, client.ID, client.ClientSecret, "https://wiki.example/callback", nil)
+	claims, err := provider.VerifyIDToken(context.Background(), tokens.IDToken, "n-0S6")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "5", claims.Subject)
+		assert.Equal(t, "ada@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+		assert.Equal(t, "Ada Lovelace", claims.Name)
+		assert.NotZero(t, claims.AuthTime)
+	}
+
+	// Codes are single use
+	w = postForm(test.router, "/oauth/token", tokenForm, client.ID, client.ClientSecret)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), domain.OAuthErrInvalidGrant)
+
+	w = performAuthorized(test.router, "GET", "/oauth/userinfo", tokens.AccessToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var info domain.OAuthUserInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, "5", info.Subject)
+	assert.Equal(t, "Europe/London", info.Zoneinfo)
+
+	// Tokens issued to clients do not grant access to this app's API
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(test.router, "GET", "/protected", tokens.AccessToken).Code)
+
+	// Withdrawing consent cuts the client off
+	userToken := tokenWithPermissions(5, nil, nil)
+	w = performAuthorized(test.router, "GET", "/me/oauth/consents", userToken)
+	assert.Contains(t, w.Body.String(), `"client_name":"Wiki"`)
+	assert.Equal(t, http.StatusOK, performAuthorized(test.router, "DELETE", "/me/oauth/consents/"+client.ID, userToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(test.router, "GET", "/oauth/userinfo", tokens.AccessToken).Code)
+}
+
+// TestOAuthPublicClientRequiresPKCE tests that public clients must prove possession of the code verifier
+func TestOAuthPublicClientRequiresPKCE(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, true)
+	assert.Empty(t, client.ClientSecret)
+
+	params := redirectParams(t, postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "password1234", nil), "", ""))
+	assert.Equal(t, domain.OAuthErrInvalidRequest, params.Get("error"))
+	assert.Empty(t, params.Get("code"))
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	pkce := url.Values{"code_challenge": {pkg.PKCEChallenge(verifier)}, "code_challenge_method": {"S256"}}
+
+	authorize := func() string {
+		params := redirectParams(t, postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "password1234", pkce), "", ""))
+		return params.Get("code")
+	}
+
+	tokenForm := url.Values{"grant_type": {"authorization_code"}, "code": {authorize()}, "redirect_uri": {"https://wiki.example/callback"}, "client_id": {client.ID}, "code_verifier": {"not-the-verifier"}}
+	w := postForm(test.router, "/oauth/token", tokenForm, "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), domain.OAuthErrInvalidGrant)
+
+	tokenForm.Set("code", authorize())
+	tokenForm.Set("code_verifier", verifier)
+	w = postForm(test.router, "/oauth/token", tokenForm, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "id_token")
+}
+
+// TestOAuthAuthorizeRejectsUntrustedRequests tests that unknown clients and redirect URIs are never redirected to
+func TestOAuthAuthorizeRejectsUntrustedRequests(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+	client := test.registerClient(t, false)
+
+	query := url.Values{"client_id": {client.ID}, "redirect_uri": {"https://attacker.example/callback"}, "response_type": {"code"}, "scope": {"openid"}}
+	w := serve(test.router, jsonRequest("GET", "/oauth/authorize?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	query.Set("client_id", "unknown")
+	w = serve(test.router, jsonRequest("GET", "/oauth/authorize?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// Other problems are reported to the registered redirect URI
+	params := redirectParams(t, postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "password1234", url.Values{"scope": {"openid admin"}}), "", ""))
+	assert.Equal(t, domain.OAuthErrInvalidScope, params.Get("error"))
+
+	params = redirectParams(t, postForm(test.router, "/oauth/authorize", authorizationForm(client.ID, "", url.Values{"decision": {"deny"}}), "", ""))
+	assert.Equal(t, domain.OAuthErrAccessDenied, params.Get("error"))
+	assert.Equal(t, "xyz", params.Get("state"))
+
+	// Redirect URIs must use https outside of local development
+	req := jsonRequest("POST", "/admin/oauth/clients", map[string]interface{}{"name": "Wiki", "redirect_uris": []string{"http://wiki.example/callback"}})
+	req.Header.Set("Authorization", "Bearer "+adminToken(1))
+	assert.Equal(t, http.StatusBadRequest, serve(test.router, req).Code)
+}
+
+// TestOpenIDConfiguration tests the discovery document
+func TestOpenIDConfiguration(t *testing.T) {
+	test := setupOAuthProviderTestRouter(t)
+
+	w := serve(test.router, jsonRequest("GET", "/.well-known/openid-configuration", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var discovery pkg.OIDCDiscovery
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &discovery))
+	assert.Equal(t, test.server.URL, discovery.Issuer)
+	assert.Equal(t, test.server.URL+"/oauth/token", discovery.TokenEndpoint)
+	assert.Equal(t, []string{"RS256"}, discovery.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, discovery.CodeChallengeMethodsSupported)
+}
+
+// TestOAuthProviderNeedsAsymmetricKeys tests that the provider is not served
+// when tokens are signed with the shared HS256 secret
+func TestOAuthProviderNeedsAsymmetricKeys(t *testing.T) {
+	router, _ := testApp{
+		Deps:   usecase.AuthDependencies{UserRepo: new(MockUserRepository), OAuthRepo: NewMemoryOAuthRepository()},
+		Config: &config.Config{},
+	}.newRouter()
+
+	assert.Equal(t, http.StatusNotFound, serve(router, jsonRequest("GET", "/.well-known/openid-configuration", nil)).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, jsonRequest("GET", "/oauth/authorize", nil)).Code)
+	assert.Equal(t, http.StatusNotFound, postForm(router, "/oauth/token", url.Values{"grant_type": {"authorization_code"}}, "", "").Code)
+}
@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// MemoryOIDCRepository keeps login states and linked identities in memory
+type MemoryOIDCRepository struct {
+	mu         sync.Mutex
+	States     map[string]*domain.OIDCLoginState
+	Identities []*domain.UserIdentity
+}
+
+func NewMemoryOIDCRepository() *MemoryOIDCRepository {
+	return &MemoryOIDCRepository{States: map[string]*domain.OIDCLoginState{}}
+}
+
+func (r *MemoryOIDCRepository) CreateState(ctx context.Context, state *domain.OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.States[state.StateHash] = state
+	return nil
+}
+
+func (r *MemoryOIDCRepository) ConsumeState(ctx context.Context, stateHash string) (*domain.OIDCLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.States[stateHash]
+	delete(r.States, stateHash)
+	return state, nil
+}
+
+func (r *MemoryOIDCRepository) DeleteExpiredStates(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func (r *MemoryOIDCRepository) GetIdentity(ctx context.Context, provider, subject string) (*domain.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.Identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *MemoryOIDCRepository) CreateIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity.ID = len(r.Identities) + 1
+	r.Identities = append(r.Identities, identity)
+	return nil
+}
+
+func (r *MemoryOIDCRepository) TouchIdentity(ctx context.Context, id int, email string) error {
+	return nil
+}
+
+// mockIdentity is the user the mock provider signs in
+type mockIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// mockAuthorization is an authorization code issued by the mock provider
+type mockAuthorization struct {
+	challenge   string
+	redirectURI string
+	nonce       string
+	identity    mockIdentity
+}
+
+// mockOIDCProvider is a local OpenID Connect provider serving discovery, JWKS
+// and a token endpoint that enforces PKCE
+type mockOIDCProvider struct {
+	server       *httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+	// nonceOverride replaces the nonce of issued ID tokens when set
+	nonceOverride string
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	provider := &mockOIDCProvider{key: key, clientID: "go-auth-app", clientSecret: "s3cret", codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pkg.OIDCDiscovery{
+			Issuer:                provider.server.URL,
+			AuthorizationEndpoint: provider.server.URL + "/authorize",
+			TokenEndpoint:         provider.server.URL + "/token",
+			JWKSURI:               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pkg.JWKSet{Keys: []pkg.JWK{{
+			KeyType:   "RSA",
+			KeyID:     "mock-key",
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", provider.token)
+
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+// authorize plays the user signing in at the provider and returns the authorization code
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, identity mockIdentity) (code, state string) {
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, p.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+
+	query := parsed.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, p.clientID, query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Contains(t, query.Get("scope"), "openid")
+
+	code, _ = pkg.GenerateSecureToken(16)
+	p.mu.Lock()
+	p.codes[code] = mockAuthorization{
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		identity:    identity,
+	}
+	p.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+// token exchanges an authorization code, checking the client and the PKCE verifier
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != p.clientID || clientSecret != p.clientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.mu.Unlock()
+
+	if !ok || r.FormValue("grant_type") != "authorization_code" ||
+		r.FormValue("redirect_uri") != authorization.redirectURI ||
+		pkg.PKCEChallenge(r.FormValue("code_verifier")) != authorization.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	nonce := authorization.nonce
+	if p.nonceOverride != "" {
+		nonce = p.nonceOverride
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, &pkg.IDTokenClaims{
+		Issuer:        p.server.URL,
+		Subject:       authorization.identity.Subject,
+		Audience:      pkg.Audience{p.clientID},
+		ExpiresAt:     time.Now().Add(time.Hour).Unix(),
+		IssuedAt:      time.Now().Unix(),
+		Nonce:         nonce,
+		Email:         authorization.identity.Email,
+		EmailVerified: authorization.identity.EmailVerified,
+		Name:          authorization.identity.Name,
+	})
+	idToken.Header["kid"] = "mock-key"
+	signed, _ := idToken.SignedString(p.key)
+
+	json.NewEncoder(w).Encode(pkg.OIDCTokenResponse{AccessToken: "provider-access-token", TokenType: "Bearer", IDToken: signed, ExpiresIn: 3600})
+}
+
+// setupOIDCTestRouter creates a test router with the mock provider configured as "corp"
+func setupOIDCTestRouter(t *testing.T) (*gin.Engine, *mockOIDCProvider, *MockUserRepository, *MemoryOIDCRepository) {
+	provider := newMockOIDCProvider(t)
+
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	oidcRepo := NewMemoryOIDCRepository()
+	revocationService := service.NewRevocationService(nil)
+
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	cfg := &config.Config{OIDCProviders: []config.OIDCProviderConfig{{
+		Name:         "corp",
+		Issuer:       provider.server.URL,
+		ClientID:     provider.clientID,
+		ClientSecret: provider.clientSecret,
+		RedirectURL:  "http://app.test/auth/oidc/corp/callback",
+	}}}
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationService: revocationService,
+			OIDCRepo:          oidcRepo,
+		},
+		Config: cfg,
+	}.newRouter()
+
+	return router, provider, mockUserRepo, oidcRepo
+}
+
+// oidcLogin signs in through the mock provider and returns the callback response
+func oidcLogin(t *testing.T, router *gin.Engine, provider *mockOIDCProvider, identity mockIdentity) *httptest.ResponseRecorder {
+	w := serve(router, jsonRequest("GET", "/auth/oidc/corp/login", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	code, state := provider.authorize(t, w.Header().Get("Location"), identity)
+	return serve(router, jsonRequest("GET", "/auth/oidc/corp/callback?code="+url.QueryEscape(code)+"&state="+url.QueryEscape(state), nil))
+}
+
+// TestOIDCLoginCreatesAccount tests just-in-time account creation and signing in again with the same identity
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	router, provider, mockUserRepo, oidcRepo := setupOIDCTestRouter(t)
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "ada@corp.example").Return(nil, nil).Once()
+	mockUserRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+		return user.Email == "ada@corp.example" && user.Name == "Ada Lovelace"
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 42
+	}).Return(nil).Once()
+	mockUserRepo.On("MarkEmailVerified", mock.Anything, 42).Return(nil).Once()
+
+	identity := mockIdentity{Subject: "corp-123", Email: "Ada@Corp.example", EmailVerified: true, Name: "Ada Lovelace"}
+	w := oidcLogin(t, router, provider, identity)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens domain.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	claims, err := pkg.ValidateJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	if assert.Len(t, oidcRepo.Identities, 1) {
+		assert.Equal(t, 42, oidcRepo.Identities[0].UserID)
+		assert.Equal(t, "corp-123", oidcRepo.Identities[0].Subject)
+	}
+
+	// The linked identity is found by subject, even after the email changed at the provider
+	now := time.Now()
+	mockUserRepo.On("GetByID", mock.Anything, 42).Return(&domain.User{ID: 42, Email: "ada@corp.example", EmailVerifiedAt: &now}, nil).Once()
+	identity.Email = "ada.lovelace@corp.example"
+	assert.Equal(t, http.StatusOK, oidcLogin(t, router, provider, identity).Code)
+
+	assert.Len(t, oidcRepo.Identities, 1)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestOIDCLoginLinksByVerifiedEmail tests linking to existing accounts, which both sides must have verified
+func TestOIDCLoginLinksByVerifiedEmail(t *testing.T) {
+	router, provider, mockUserRepo, oidcRepo := setupOIDCTestRouter(t)
+
+	now := time.Now()
+	mockUserRepo.On("GetByEmail", mock.Anything, "grace@corp.example").Return(&domain.User{ID: 7, Email: "grace@corp.example", EmailVerifiedAt: &now}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "squatted@corp.example").Return(&domain.User{ID: 8, Email: "squatted@corp.example"}, nil)
+
+	// The provider does not vouch for the address
+	w := oidcLogin(t, router, provider, mockIdentity{Subject: "corp-7", Email: "grace@corp.example"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The local account never proved it owns the address
+	w = oidcLogin(t, router, provider, mockIdentity{Subject: "corp-8", Email: "squatted@corp.example", EmailVerified: true})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, oidcRepo.Identities)
+
+	// A failed lookup must not be mistaken for a missing account and create a second one
+	mockUserRepo.On("GetByEmail", mock.Anything, "outage@corp.example").Return(nil, assert.AnError)
+	w = oidcLogin(t, router, provider, mockIdentity{Subject: "corp-9", Email: "outage@corp.example", EmailVerified: true})
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Empty(t, oidcRepo.Identities)
+
+	w = oidcLogin(t, router, provider, mockIdentity{Subject: "corp-7", Email: "grace@corp.example", EmailVerified: true})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens domain.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	claims, _ := pkg.ValidateJWT(tokens.AccessToken)
+	assert.Equal(t, 7, claims.UserID)
+
+	if assert.Len(t, oidcRepo.Identities, 1) {
+		assert.Equal(t, 7, oidcRepo.Identities[0].UserID)
+	}
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// TestOIDCCallbackRejectsForgedLogins tests the state, nonce and provider checks of the callback
+func TestOIDCCallbackRejectsForgedLogins(t *testing.T) {
+	router, provider, mockUserRepo, _ := setupOIDCTestRouter(t)
+
+	now := time.Now()
+	mockUserRepo.On("GetByEmail", mock.Anything, "grace@corp.example").Return(&domain.User{ID: 7, Email: "grace@corp.example", EmailVerifiedAt: &now}, nil)
+	identity := mockIdentity{Subject: "corp-7", Email: "grace@corp.example", EmailVerified: true}
+
+	assert.Equal(t, http.StatusNotFound, serve(router, jsonRequest("GET", "/auth/oidc/unknown/login", nil)).Code)
+
+	// A callback for a login that was never started here
+	code, _ := provider.authorize(t, mustAuthURL(t, router), identity)
+	w := serve(router, jsonRequest("GET", "/auth/oidc/corp/callback?code="+code+"&state=forged", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A state can only be used once
+	code, state := provider.authorize(t, mustAuthURL(t, router), identity)
+	callback := "/auth/oidc/corp/callback?code=" + url.QueryEscape(code) + "&state=" + url.QueryEscape(state)
+	assert.Equal(t, http.StatusOK, serve(router, jsonRequest("GET", callback, nil)).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, jsonRequest("GET", callback, nil)).Code)
+
+	// An ID token minted for another login attempt
+	provider.nonceOverride = "someone-elses-nonce"
+	assert.Equal(t, http.StatusUnauthorized, oidcLogin(t, router, provider, identity).Code)
+
+	// The user cancelled at the provider
+	w = serve(router, jsonRequest("GET", "/auth/oidc/corp/callback?error=access_denied", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// mustAuthURL starts a login and returns the provider URL it redirects to
+func mustAuthURL(t *testing.T, router *gin.Engine) string {
+	w := serve(router, jsonRequest("GET", "/auth/oidc/corp/login", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	return w.Header().Get("Location")
+}
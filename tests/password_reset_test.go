@@ -0,0 +1,239 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock User Token Repository
+type MockUserTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserTokenRepository) Create(ctx context.Context, token *domain.UserToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockUserTokenRepository) GetByHash(ctx context.Context, tokenHash, purpose string) (*domain.UserToken, error) {
+	args := m.Called(ctx, tokenHash, purpose)
+	if token, ok := args.Get(0).(*domain.UserToken); ok {
+		return token, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserTokenRepository) MarkUsed(ctx context.Context, id int) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserTokenRepository) InvalidateForUser(ctx context.Context, userID int, purpose string) error {
+	args := m.Called(ctx, userID, purpose)
+	return args.Error(0)
+}
+
+func (m *MockUserTokenRepository) GetLatestForUser(ctx context.Context, userID int, purpose string) (*domain.UserToken, error) {
+	args := m.Called(ctx, userID, purpose)
+	if token, ok := args.Get(0).(*domain.UserToken); ok {
+		return token, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// RecordingMailer captures sent emails on a channel
+type RecordingMailer struct {
+	Sent chan pkg.Email
+}
+
+func NewRecordingMailer() *RecordingMailer {
+	return &RecordingMailer{Sent: make(chan pkg.Email, 10)}
+}
+
+func (m *RecordingMailer) Send(ctx context.Context, email pkg.Email) error {
+	m.Sent <- email
+	return nil
+}
+
+// Next waits for the next email to be sent
+func (m *RecordingMailer) Next(t *testing.T) pkg.Email {
+	select {
+	case email := <-m.Sent:
+		return email
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for email")
+		return pkg.Email{}
+	}
+}
+
+// setupPasswordResetTestRouter creates a test router for the password reset flow
+func setupPasswordResetTestRouter() (*gin.Engine, *MockUserRepository, *MockUserTokenRepository, *MockRevocationRepository, *MockRefreshTokenRepository, *RecordingMailer) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	mailer := NewRecordingMailer()
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:         mockUserRepo,
+			RefreshTokenRepo: mockRefreshTokenRepo,
+			RevocationRepo:   mockRevocationRepo,
+			UserTokenRepo:    mockUserTokenRepo,
+			Mailer:           mailer,
+		},
+		Config: &config.Config{AppBaseURL: "http://app.test"},
+	}.newRouter()
+
+	return router, mockUserRepo, mockUserTokenRepo, mockRevocationRepo, mockRefreshTokenRepo, mailer
+}
+
+// jsonRequest builds a request with a JSON body
+func jsonRequest(method, path string, body interface{}) *http.Request {
+	jsonBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// serve records the router's response to a request
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// postJSON sends a JSON request to the router
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	return serve(router, jsonRequest("POST", path, body))
+}
+
+// TestForgotPasswordSendsResetLink tests that a reset link is emailed and only its hash is stored
+func TestForgotPasswordSendsResetLink(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, _, mailer := setupPasswordResetTestRouter()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Name: "Test User", Email: "test@example.com"}, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposePasswordReset).Return(nil)
+
+	var storedHash string
+	mockUserTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.UserToken")).Run(func(args mock.Arguments) {
+		storedHash = args.Get(1).(*domain.UserToken).TokenHash
+	}).Return(nil)
+
+	w := postJSON(router, "/password/forgot", map[string]string{"email": "test@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	email := mailer.Next(t)
+	assert.Equal(t, "test@example.com", email.To)
+
+	token := regexp.MustCompile(`http://app.test/password/reset\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(email.Body)
+	assert.Len(t, token, 2)
+	assert.Equal(t, pkg.HashToken(token[1]), storedHash)
+
+	mockUserTokenRepo.AssertExpectations(t)
+}
+
+// TestForgotPasswordUnknownEmail tests that unknown accounts get the same response and no email
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, _, mailer := setupPasswordResetTestRouter()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, assert.AnError)
+
+	w := postJSON(router, "/password/forgot", map[string]string{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, mailer.Sent)
+	mockUserTokenRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// TestResetPassword tests that a valid token updates the password and revokes all sessions
+func TestResetPassword(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, mockRevocationRepo, mockRefreshTokenRepo, mailer := setupPasswordResetTestRouter()
+
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("reset-token"), domain.TokenPurposePasswordReset).Return(&domain.UserToken{
+		ID:        3,
+		UserID:    1,
+		Purpose:   domain.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 3).Return(true, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposePasswordReset).Return(nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Name: "Test User", Email: "test@example.com"}, nil)
+	mockUserRepo.On("UpdatePassword", mock.Anything, 1, mock.AnythingOfType("string")).Return(nil)
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+
+	w := postJSON(router, "/password/reset", map[string]string{"token": "reset-token", "password": "aBrandNewPassword1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test@example.com", mailer.Next(t).To)
+
+	mockUserRepo.AssertExpectations(t)
+	mockUserTokenRepo.AssertExpectations(t)
+	mockRevocationRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// TestResetPasswordPage tests that reset links open a page whose form sets the new password
+func TestResetPasswordPage(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, mockRevocationRepo, mockRefreshTokenRepo, _ := setupPasswordResetTestRouter()
+
+	w := serve(router, httptest.NewRequest("GET", "/password/reset?token=reset-token", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `value="reset-token"`)
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	mockUserTokenRepo.AssertNotCalled(t, "GetByHash", mock.Anything, mock.Anything, mock.Anything)
+
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("reset-token"), domain.TokenPurposePasswordReset).Return(&domain.UserToken{
+		ID:        3,
+		UserID:    1,
+		Purpose:   domain.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 3).Return(true, nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposePasswordReset).Return(nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(&domain.User{ID: 1, Name: "Test User", Email: "test@example.com"}, nil)
+	mockUserRepo.On("UpdatePassword", mock.Anything, 1, mock.AnythingOfType("string")).Return(nil)
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+
+	form := url.Values{"token": {"reset-token"}, "password": {"aBrandNewPassword1"}}
+	req := httptest.NewRequest("POST", "/password/reset", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestResetPasswordWithUsedToken tests that a reset token cannot be used twice
+func TestResetPasswordWithUsedToken(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, _, _ := setupPasswordResetTestRouter()
+
+	usedAt := time.Now().Add(-time.Minute)
+	mockUserTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("reset-token"), domain.TokenPurposePasswordReset).Return(&domain.UserToken{
+		ID:        3,
+		UserID:    1,
+		ExpiresAt: time.Now().Add(time.Minute),
+		UsedAt:    &usedAt,
+	}, nil)
+
+	w := postJSON(router, "/password/reset", map[string]string{"token": "reset-token", "password": "aBrandNewPassword1"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
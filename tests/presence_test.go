@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// nextPresence waits for the next presence change
+func nextPresence(t *testing.T, changes chan domain.Presence) domain.Presence {
+	select {
+	case presence := <-changes:
+		return presence
+	case <-time.After(2 * time.Second):
+		t.Fatal("no presence change was reported")
+		return domain.Presence{}
+	}
+}
+
+// readPresenceFrame reads the next frame of a connection, which must be a presence change
+func readPresenceFrame(t *testing.T, conn *websocket.Conn) domain.Presence {
+	var frame pkg.WebSocketMessage
+	if !assert.NoError(t, conn.ReadJSON(&frame)) || !assert.Equal(t, pkg.TypePresence, frame.Type) {
+		t.FailNow()
+	}
+
+	var presence domain.Presence
+	assert.NoError(t, json.Unmarshal(frame.Data, &presence))
+	return presence
+}
+
+// sendPresenceFrame sets the status of a connection
+func sendPresenceFrame(t *testing.T, conn *websocket.Conn, status string) {
+	data, _ := json.Marshal(domain.SetPresenceRequest{Status: status})
+	assert.NoError(t, conn.WriteJSON(pkg.WebSocketMessage{Type: pkg.TypePresence, Data: data}))
+}
+
+// TestPresenceAcrossInstances tests merging the users of this instance with the heartbeats of another
+func TestPresenceAcrossInstances(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	lastSeen := time.Now().Add(-time.Hour)
+	userRepo.On("TouchLastSeen", mock.Anything, []int{1}, mock.AnythingOfType("time.Time")).Return(nil).Once()
+	userRepo.On("GetLastSeen", mock.Anything, []int{1, 3}).Return(map[int]time.Time{1: lastSeen}, nil)
+
+	presence := service.NewPresenceService(userRepo, nil)
+	changes := make(chan domain.Presence, 10)
+	presence.OnChange(func(p domain.Presence) { changes <- p })
+
+	presence.SetLocal(1, domain.PresenceOnline)
+	assert.Equal(t, domain.Presence{UserID: 1, Status: domain.PresenceOnline}, nextPresence(t, changes))
+	presence.SetLocal(1, domain.PresenceAway)
+	assert.Equal(t, domain.PresenceAway, nextPresence(t, changes).Status)
+
+	// The most present connection wins, wherever it is
+	presence.Apply(service.PresenceHeartbeat{InstanceID: "other", Users: map[int]string{1: domain.PresenceOnline, 2: domain.PresenceAway}})
+	received := map[int]string{}
+	for i := 0; i < 2; i++ {
+		change := nextPresence(t, changes)
+		received[change.UserID] = change.Status
+	}
+	assert.Equal(t, map[int]string{1: domain.PresenceOnline, 2: domain.PresenceAway}, received)
+
+	// A full heartbeat replaces what the instance said before
+	presence.Apply(service.PresenceHeartbeat{InstanceID: "other", Full: true, Users: map[int]string{2: domain.PresenceAway}})
+	assert.Equal(t, domain.Presence{UserID: 1, Status: domain.PresenceAway}, nextPresence(t, changes))
+
+	// Leaving saves when the user was last seen
+	presence.SetLocal(1, domain.PresenceOffline)
+	change := nextPresence(t, changes)
+	assert.Equal(t, domain.PresenceOffline, change.Status)
+	assert.NotNil(t, change.LastSeenAt)
+
+	presences, err := presence.Get(context.Background(), []int{1, 2, 3})
+	assert.NoError(t, err)
+	if assert.Len(t, presences, 3) {
+		assert.Equal(t, &domain.Presence{UserID: 1, Status: domain.PresenceOffline, LastSeenAt: &lastSeen}, presences[0])
+		assert.Equal(t, &domain.Presence{UserID: 2, Status: domain.PresenceAway}, presences[1])
+		assert.Equal(t, &domain.Presence{UserID: 3, Status: domain.PresenceOffline}, presences[2])
+	}
+
+	// The users of an instance that stopped sending heartbeats go offline
+	presence.Interval = 20 * time.Millisecond
+	presence.TTL = 50 * time.Millisecond
+	assert.Error(t, presence.Start())
+	defer presence.Close()
+	change = nextPresence(t, changes)
+	assert.Equal(t, 2, change.UserID)
+	assert.Equal(t, domain.PresenceOffline, change.Status)
+
+	userRepo.AssertExpectations(t)
+}
+
+// TestPresenceEndpointsAndPush tests the presence endpoints and the frames sent to contacts
+func TestPresenceEndpointsAndPush(t *testing.T) {
+	userRepo := new(MockUserRepository)
+	userRepo.On("TouchLastSeen", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	userRepo.On("GetLastSeen", mock.Anything, mock.Anything).Return(map[int]time.Time{}, nil)
+
+	router, _ := newGroupChatTestRouter(func(chatUsecase *usecase.ChatUsecase) {
+		chatUsecase.Presence = service.NewPresenceService(userRepo, nil)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2}})
+
+	// The answer to an invalid status shows the connection is set up
+	contact := dialChat(t, server, 2)
+	defer contact.Close()
+	sendPresenceFrame(t, contact, "busy")
+	var reply pkg.WebSocketMessage
+	assert.NoError(t, contact.ReadJSON(&reply))
+	assert.Equal(t, pkg.TypeError, reply.Type)
+
+	user := dialChat(t, server, 1)
+	assert.Equal(t, domain.Presence{UserID: 1, Status: domain.PresenceOnline}, readPresenceFrame(t, contact))
+
+	code, response := chatRequest(router, 2, "GET", "/users/1/presence", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, domain.PresenceOnline, response["data"].(map[string]interface{})["status"])
+
+	// Only contacts see the presence of a user
+	code, _ = chatRequest(router, 3, "GET", "/users/1/presence", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	sendPresenceFrame(t, user, domain.PresenceAway)
+	assert.Equal(t, domain.Presence{UserID: 1, Status: domain.PresenceAway}, readPresenceFrame(t, contact))
+
+	code, response = chatRequest(router, 2, "POST", "/users/presence", map[string][]int{"user_ids": {1, 3, 2, 1}})
+	assert.Equal(t, http.StatusOK, code)
+	statuses := map[int]string{}
+	for _, item := range response["data"].([]interface{}) {
+		presence := item.(map[string]interface{})
+		statuses[int(presence["user_id"].(float64))] = presence["status"].(string)
+	}
+	assert.Equal(t, map[int]string{1: domain.PresenceAway, 2: domain.PresenceOnline}, statuses)
+
+	code, _ = chatRequest(router, 2, "POST", "/users/presence", map[string][]int{"user_ids": {}})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Closing the last connection takes the user offline
+	user.Close()
+	presence := readPresenceFrame(t, contact)
+	assert.Equal(t, domain.PresenceOffline, presence.Status)
+	assert.NotNil(t, presence.LastSeenAt)
+}
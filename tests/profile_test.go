@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// setupProfileTestRouter creates a test router for the /me endpoints
+func setupProfileTestRouter() (*gin.Engine, *MockUserRepository, *MockUserTokenRepository, *MockRevocationRepository, *MockRefreshTokenRepository, *RecordingMailer) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	revocationService := service.NewRevocationService(nil)
+	mailer := NewRecordingMailer()
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+			UserTokenRepo:     mockUserTokenRepo,
+			Mailer:            mailer,
+		},
+		Config: &config.Config{AppBaseURL: "http://app.test"},
+	}.newRouter()
+
+	return router, mockUserRepo, mockUserTokenRepo, mockRevocationRepo, mockRefreshTokenRepo, mailer
+}
+
+// profileUser returns a stored user whose password is password1234
+func profileUser() *domain.User {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	verifiedAt := time.Now().Add(-time.Hour)
+	return &domain.User{
+		ID:              1,
+		Name:            "Test User",
+		Email:           "test@example.com",
+		Password:        string(hashedPassword),
+		EmailVerifiedAt: &verifiedAt,
+	}
+}
+
+// TestGetProfileHidesPassword tests that the password hash is never part of a response
+func TestGetProfileHidesPassword(t *testing.T) {
+	router, mockUserRepo, _, _, _, _ := setupProfileTestRouter()
+
+	user := profileUser()
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(user, nil)
+
+	token, _ := userToken(1, "test@example.com")
+	w := performAuthorized(router, "GET", "/me", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	assert.Equal(t, "test@example.com", body["email"])
+	assert.NotContains(t, body, "password")
+	assert.NotContains(t, w.Body.String(), user.Password)
+}
+
+// TestUpdateProfile tests partial updates and validation of the profile fields
+func TestUpdateProfile(t *testing.T) {
+	router, mockUserRepo, _, _, _, _ := setupProfileTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil)
+	mockUserRepo.On("UpdateProfile", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+		return user.Name == "Test User" && user.Bio == "Hello" && user.Timezone == "Europe/Berlin" && user.AvatarURL == "https://cdn.example.com/a.png"
+	})).Return(nil).Once()
+
+	token, _ := userToken(1, "test@example.com")
+
+	req := jsonRequest("PATCH", "/me", map[string]string{"bio": "Hello", "timezone": "Europe/Berlin", "avatar_url": "https://cdn.example.com/a.png"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var user domain.User
+	json.Unmarshal(w.Body.Bytes(), &user)
+	assert.Equal(t, "Europe/Berlin", user.Timezone)
+
+	// Invalid values are rejected before anything is stored
+	for _, body := range []map[string]string{
+		{"timezone": "Mars/Olympus"},
+		{"avatar_url": "javascript:alert(1)"},
+		{"name": "   "},
+	} {
+		req = jsonRequest("PATCH", "/me", body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		assert.Equal(t, http.StatusBadRequest, serve(router, req).Code, "body %v", body)
+	}
+
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestChangePasswordRequiresCurrentPassword tests the current password check and that sessions are signed out
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	router, mockUserRepo, _, mockRevocationRepo, mockRefreshTokenRepo, mailer := setupProfileTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil)
+	token, _ := userToken(1, "test@example.com")
+
+	req := jsonRequest("PUT", "/me/password", map[string]string{"current_password": "wrong-password", "new_password": "aBrandNewPassword1"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+	mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+
+	mockUserRepo.On("UpdatePassword", mock.Anything, 1, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("aBrandNewPassword1")) == nil
+	})).Return(nil)
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 1, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRefreshTokenRepo.On("RevokeAllForUser", mock.Anything, 1).Return(nil)
+
+	req = jsonRequest("PUT", "/me/password", map[string]string{"current_password": "password1234", "new_password": "aBrandNewPassword1"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusOK, serve(router, req).Code)
+	assert.Equal(t, "test@example.com", mailer.Next(t).To)
+
+	// The token used for the change was signed out as well
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/me", token).Code)
+
+	mockUserRepo.AssertExpectations(t)
+	mockRevocationRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// TestChangeEmailRequiresVerification tests that the new address only takes effect once confirmed
+func TestChangeEmailRequiresVerification(t *testing.T) {
+	router, mockUserRepo, mockUserTokenRepo, _, _, mailer := setupProfileTestRouter()
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil).Once()
+	mockUserRepo.On("GetByEmail", mock.Anything, "taken@example.com").Return(&domain.User{ID: 2, Email: "taken@example.com"}, nil)
+	token, _ := userToken(1, "test@example.com")
+
+	// Addresses of other accounts cannot be claimed
+	req := jsonRequest("POST", "/me/email", map[string]string{"email": "taken@example.com", "current_password": "password1234"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusConflict, serve(router, req).Code)
+
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(profileUser(), nil).Once()
+	mockUserRepo.On("GetByEmail", mock.Anything, "new@example.com").Return(nil, assert.AnError)
+	mockUserRepo.On("SetPendingEmail", mock.Anything, 1, "new@example.com").Return(nil)
+	mockUserTokenRepo.On("InvalidateForUser", mock.Anything, 1, domain.TokenPurposeEmailChange).Return(nil)
+
+	var storedHash string
+	mockUserTokenRepo.On("Create", mock.Anything, mock.MatchedBy(func(token *domain.UserToken) bool {
+		return token.Purpose == domain.TokenPurposeEmailChange
+	})).Run(func(args mock.Arguments) {
+		storedHash = args.Get(1).(*domain.UserToken).TokenHash
+	}).Return(nil)
+
+	req = jsonRequest("POST", "/me/email", map[string]string{"email": "new@example.com", "current_password": "password1234"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusAccepted, serve(router, req).Code)
+	mockUserRepo.AssertNotCalled(t, "ApplyPendingEmail", mock.Anything, mock.Anything, mock.Anything)
+
+	// The link goes to the new address, the old one is notified
+	emails := map[string]pkg.Email{}
+	for i := 0; i < 2; i++ {
+		email := mailer.Next(t)
+		emails[email.To] = email
+	}
+	assert.Contains(t, emails, "test@example.com")
+	link := regexp.MustCompile(`/verify-email/change\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(emails["new@example.com"].Body)
+	assert.Len(t, link, 2)
+	assert.Equal(t, pkg.HashToken(link[1]), storedHash)
+
+	// Opening the link switches the address
+	pending := "new@example.com"
+	pendingUser := profileUser()
+	pendingUser.PendingEmail = &pending
+	mockUserTokenRepo.On("GetByHash", mock.Anything, storedHash, domain.TokenPurposeEmailChange).Return(&domain.UserToken{
+		ID:        9,
+		UserID:    1,
+		Purpose:   domain.TokenPurposeEmailChange,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	mockUserTokenRepo.On("MarkUsed", mock.Anything, 9).Return(true, nil)
+	mockUserRepo.On("GetByID", mock.Anything, 1).Return(pendingUser, nil).Once()
+	mockUserRepo.On("ApplyPendingEmail", mock.Anything, 1, "new@example.com").Return(nil)
+
+	w := serve(router, jsonRequest("GET", "/verify-email/change?token="+link[1], nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockUserRepo.AssertExpectations(t)
+	mockUserTokenRepo.AssertExpectations(t)
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock Role Repository
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) GetUserAccess(ctx context.Context, userID int) (*domain.UserAccess, error) {
+	args := m.Called(ctx, userID)
+	if access, ok := args.Get(0).(*domain.UserAccess); ok {
+		return access, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRoleRepository) AssignRole(ctx context.Context, userID int, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) RemoveRole(ctx context.Context, userID int, role string) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) GetRoles(ctx context.Context) ([]*domain.Role, error) {
+	args := m.Called(ctx)
+	if roles, ok := args.Get(0).([]*domain.Role); ok {
+		return roles, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// setupRBACTestRouter creates a test router with roles and the NATS routes
+func setupRBACTestRouter() (*gin.Engine, *MockUserRepository, *MockRoleRepository, *MockRevocationRepository, *MockRefreshTokenRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	mockUserTokenRepo := new(MockUserTokenRepository)
+	revocationService := service.NewRevocationService(nil)
+
+	// Signup stores an email verification token
+	mockUserTokenRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+			UserTokenRepo:     mockUserTokenRepo,
+			RoleRepo:          mockRoleRepo,
+		},
+		Config:      &config.Config{},
+		NATSHandler: delivery.NewNATSHandler(usecase.NewNATSUsecase(&pkg.NatsClient{}), nil),
+	}.newRouter()
+
+	return router, mockUserRepo, mockRoleRepo, mockRevocationRepo, mockRefreshTokenRepo
+}
+
+// tokenWithPermissions signs an access token carrying the given roles and permissions
+func tokenWithPermissions(userID int, roles, permissions []string) string {
+	claims := pkg.NewClaims(userID, "test@example.com", time.Hour)
+	claims.Roles = roles
+	claims.Permissions = permissions
+	token, _ := pkg.SignClaims(claims)
+	return token
+}
+
+// TestSignupAssignsUserRole tests that new accounts get the default role
+func TestSignupAssignsUserRole(t *testing.T) {
+	router, mockUserRepo, mockRoleRepo, _, _ := setupRBACTestRouter()
+
+	mockUserRepo.On("GetByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+	mockUserRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.User")).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 7
+	}).Return(nil)
+	mockRoleRepo.On("AssignRole", mock.Anything, 7, domain.RoleUser).Return(nil)
+
+	w := postJSON(router, "/signup", map[string]string{"name": "New User", "email": "new@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	mockRoleRepo.AssertExpectations(t)
+}
+
+// TestLoginEmbedsRolesInToken tests that roles and permissions are carried in the access token
+func TestLoginEmbedsRolesInToken(t *testing.T) {
+	router, mockUserRepo, mockRoleRepo, _, mockRefreshTokenRepo := setupRBACTestRouter()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+	mockRoleRepo.On("GetUserAccess", mock.Anything, 1).Return(&domain.UserAccess{
+		Roles:       []string{domain.RoleOperator},
+		Permissions: []string{domain.PermissionNATSPublish, domain.PermissionNATSRead},
+	}, nil)
+
+	w := postJSON(router, "/login", map[string]string{"email": "test@example.com", "password": "password1234"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens domain.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+
+	claims, err := pkg.ValidateJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Contains(t, claims.Roles, domain.RoleOperator)
+	assert.Contains(t, claims.Permissions, domain.PermissionNATSPublish)
+	assert.NotContains(t, claims.Permissions, domain.PermissionUsersManage)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/nats/topics", tokens.AccessToken).Code)
+}
+
+// TestNATSRoutesRequirePermission tests that the NATS and SNMP routes are no longer open
+func TestNATSRoutesRequirePermission(t *testing.T) {
+	router, _, _, _, _ := setupRBACTestRouter()
+
+	userToken := tokenWithPermissions(1, []string{domain.RoleUser}, nil)
+	operatorToken := tokenWithPermissions(2, []string{domain.RoleOperator}, []string{domain.PermissionNATSRead})
+
+	w := serve(router, jsonRequest("GET", "/nats/topics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, performAuthorized(router, "GET", "/nats/topics", userToken).Code)
+	assert.Equal(t, http.StatusForbidden, performAuthorized(router, "POST", "/nats/publish", userToken).Code)
+	assert.Equal(t, http.StatusForbidden, performAuthorized(router, "GET", "/snmp/metrics", userToken).Code)
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/nats/topics", operatorToken).Code)
+	// Reading does not imply publishing
+	assert.Equal(t, http.StatusForbidden, performAuthorized(router, "POST", "/nats/publish", operatorToken).Code)
+}
+
+// TestAssignRoleRevokesCurrentTokens tests that role changes take effect by invalidating access tokens
+func TestAssignRoleRevokesCurrentTokens(t *testing.T) {
+	router, mockUserRepo, mockRoleRepo, mockRevocationRepo, _ := setupRBACTestRouter()
+
+	adminToken := tokenWithPermissions(1, []string{domain.RoleAdmin}, []string{domain.PermissionUsersManage})
+	targetToken := tokenWithPermissions(2, []string{domain.RoleUser}, nil)
+
+	mockUserRepo.On("GetByID", mock.Anything, 2).Return(&domain.User{ID: 2, Email: "target@example.com"}, nil)
+	mockRoleRepo.On("AssignRole", mock.Anything, 2, domain.RoleOperator).Return(nil)
+	mockRoleRepo.On("AssignRole", mock.Anything, 2, "superuser").Return(domain.ErrUnknownRole)
+	mockRevocationRepo.On("RevokeAllForUser", mock.Anything, 2, mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Only holders of users:manage may assign roles
+	req := jsonRequest("POST", "/admin/users/2/roles", map[string]string{"role": domain.RoleOperator})
+	req.Header.Set("Authorization", "Bearer "+targetToken)
+	assert.Equal(t, http.StatusForbidden, serve(router, req).Code)
+
+	req = jsonRequest("POST", "/admin/users/2/roles", map[string]string{"role": "superuser"})
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	assert.Equal(t, http.StatusBadRequest, serve(router, req).Code)
+
+	req = jsonRequest("POST", "/admin/users/2/roles", map[string]string{"role": domain.RoleOperator})
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	assert.Equal(t, http.StatusOK, serve(router, req).Code)
+
+	// The target's old token no longer works, the admin's does
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", targetToken).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", adminToken).Code)
+
+	mockRoleRepo.AssertExpectations(t)
+	mockRevocationRepo.AssertExpectations(t)
+}
+
+// TestBootstrapAdminsSkipsUnverifiedAccounts tests that only verified, active accounts are promoted at startup
+func TestBootstrapAdminsSkipsUnverifiedAccounts(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockRoleRepo := new(MockRoleRepository)
+	authUsecase := usecase.NewAuthUsecase(usecase.AuthDependencies{UserRepo: mockUserRepo, RoleRepo: mockRoleRepo}, &config.Config{})
+
+	verifiedAt := time.Now().Add(-time.Hour)
+	mockUserRepo.On("GetByEmail", mock.Anything, "owner@example.com").Return(&domain.User{ID: 1, Email: "owner@example.com", EmailVerifiedAt: &verifiedAt}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "squatter@example.com").Return(&domain.User{ID: 2, Email: "squatter@example.com"}, nil)
+	mockUserRepo.On("GetByEmail", mock.Anything, "former@example.com").Return(&domain.User{ID: 3, Email: "former@example.com", EmailVerifiedAt: &verifiedAt, DisabledAt: &verifiedAt}, nil)
+	mockRoleRepo.On("AssignRole", mock.Anything, 1, domain.RoleAdmin).Return(nil).Once()
+
+	authUsecase.BootstrapAdmins(context.Background(), []string{"owner@example.com", "squatter@example.com", "former@example.com"})
+
+	mockRoleRepo.AssertExpectations(t)
+	mockRoleRepo.AssertNotCalled(t, "AssignRole", mock.Anything, 2, mock.Anything)
+	mockRoleRepo.AssertNotCalled(t, "AssignRole", mock.Anything, 3, mock.Anything)
+}
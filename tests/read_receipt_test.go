@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"go-auth-app/internal/domain"
+	"go-auth-app/pkg"
+)
+
+// unreadCounts returns the unread count of every conversation of a user by conversation ID
+func unreadCounts(t *testing.T, router *gin.Engine, userID int) map[int]int {
+	code, response := chatRequest(router, userID, "GET", "/chat/conversations", nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	counts := map[int]int{}
+	for _, item := range response["data"].([]interface{}) {
+		conversation := item.(map[string]interface{})
+		counts[int(conversation["id"].(float64))] = int(conversation["unread_count"].(float64))
+	}
+	return counts
+}
+
+// TestReadReceipts tests the read cursor and the unread counts of the conversation list
+func TestReadReceipts(t *testing.T) {
+	router, _ := setupGroupChatTestRouter()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2, 3}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+	base := "/chat/conversations/" + strconv.Itoa(id)
+
+	messageIDs := []int{}
+	for _, sender := range []int{1, 1, 2} {
+		_, response := chatRequest(router, sender, "POST", base+"/messages", map[string]string{"content": "update"})
+		messageIDs = append(messageIDs, int(response["data"].(map[string]interface{})["id"].(float64)))
+	}
+
+	// Your own messages are never unread
+	assert.Equal(t, 3, unreadCounts(t, router, 3)[id])
+	assert.Equal(t, 1, unreadCounts(t, router, 1)[id])
+
+	code, response := chatRequest(router, 3, "POST", base+"/read", map[string]int{"message_id": messageIDs[0]})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(messageIDs[0]), response["data"].(map[string]interface{})["message_id"])
+	assert.Equal(t, 2, unreadCounts(t, router, 3)[id])
+
+	// Without a message ID the conversation is read to the end
+	code, response = chatRequest(router, 3, "POST", base+"/read", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(messageIDs[2]), response["data"].(map[string]interface{})["message_id"])
+	assert.Equal(t, 0, unreadCounts(t, router, 3)[id])
+
+	// The cursor never moves back
+	code, response = chatRequest(router, 3, "POST", base+"/read", map[string]int{"message_id": messageIDs[1]})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(messageIDs[2]), response["data"].(map[string]interface{})["message_id"])
+
+	// Messages of other conversations and conversations of others are refused
+	_, response = chatRequest(router, 3, "POST", "/chat/messages", map[string]interface{}{"receiver_id": 4, "content": "Hi"})
+	direct := int(response["data"].(map[string]interface{})["id"].(float64))
+	code, _ = chatRequest(router, 3, "POST", base+"/read", map[string]int{"message_id": direct})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = chatRequest(router, 4, "POST", base+"/read", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, response = chatRequest(router, 1, "GET", base, nil)
+	assert.Equal(t, http.StatusOK, code)
+	for _, item := range response["data"].(map[string]interface{})["members"].([]interface{}) {
+		member := item.(map[string]interface{})
+		if member["user_id"] == float64(3) {
+			assert.Equal(t, float64(messageIDs[2]), member["last_read_message_id"])
+		}
+	}
+}
+
+// TestReadReceiptWebSocketFrame tests marking a conversation read over the WebSocket
+func TestReadReceiptWebSocketFrame(t *testing.T) {
+	router, _ := setupGroupChatTestRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+	_, response = chatRequest(router, 1, "POST", "/chat/conversations/"+strconv.Itoa(id)+"/messages", map[string]string{"content": "update"})
+	messageID := response["data"].(map[string]interface{})["id"].(float64)
+
+	conn := dialChat(t, server, 2)
+	defer conn.Close()
+
+	send := func(conversationID int) pkg.WebSocketMessage {
+		data, _ := json.Marshal(domain.MarkReadRequest{ConversationID: conversationID})
+		assert.NoError(t, conn.WriteJSON(pkg.WebSocketMessage{Type: pkg.TypeRead, Data: data}))
+
+		var reply pkg.WebSocketMessage
+		assert.NoError(t, conn.ReadJSON(&reply))
+		return reply
+	}
+
+	reply := send(id)
+	assert.Equal(t, pkg.TypeRead, reply.Type)
+	var receipt domain.ReadReceipt
+	assert.NoError(t, json.Unmarshal(reply.Data, &receipt))
+	assert.Equal(t, 2, receipt.UserID)
+	assert.Equal(t, int(messageID), receipt.MessageID)
+	assert.Equal(t, 0, unreadCounts(t, router, 2)[id])
+
+	assert.Equal(t, pkg.TypeError, send(id+100).Type)
+}
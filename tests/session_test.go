@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/delivery"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// Mock Session Repository
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	args := m.Called(ctx, id)
+	if session, ok := args.Get(0).(*domain.Session); ok {
+		return session, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSessionRepository) ListActiveByUser(ctx context.Context, userID int) ([]*domain.Session, error) {
+	args := m.Called(ctx, userID)
+	if sessions, ok := args.Get(0).([]*domain.Session); ok {
+		return sessions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSessionRepository) Touch(ctx context.Context, id, ip string) error {
+	args := m.Called(ctx, id, ip)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Revoke(ctx context.Context, id string, userID int) (bool, error) {
+	args := m.Called(ctx, id, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) ListRevokedSince(ctx context.Context, since time.Time) ([]*domain.Session, error) {
+	args := m.Called(ctx, since)
+	if sessions, ok := args.Get(0).([]*domain.Session); ok {
+		return sessions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// setupSessionTestRouter creates a test server with sessions, revocations and the WebSocket endpoint
+func setupSessionTestRouter() (*gin.Engine, *httptest.Server, *MockUserRepository, *MockSessionRepository, *MockRefreshTokenRepository) {
+	// Create mocks
+	mockUserRepo := new(MockUserRepository)
+	mockChatRepo := new(MockChatRepository)
+	mockSessionRepo := new(MockSessionRepository)
+	mockRefreshTokenRepo := new(MockRefreshTokenRepository)
+	mockRevocationRepo := new(MockRevocationRepository)
+	revocationService := service.NewRevocationService(nil)
+
+	chatUsecase := usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil, nil)
+	router, _ := testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          mockUserRepo,
+			RefreshTokenRepo:  mockRefreshTokenRepo,
+			RevocationRepo:    mockRevocationRepo,
+			RevocationService: revocationService,
+			SessionRepo:       mockSessionRepo,
+		},
+		Config:      &config.Config{},
+		ChatUsecase: chatUsecase,
+		WSHandler:   delivery.NewWebSocketHandler(chatUsecase, nil, revocationService),
+	}.newRouter()
+
+	server := httptest.NewServer(router)
+
+	return router, server, mockUserRepo, mockSessionRepo, mockRefreshTokenRepo
+}
+
+// sessionToken signs an access token belonging to the given session
+func sessionToken(userID int, sessionID string) string {
+	claims := pkg.NewClaims(userID, "test@example.com", time.Hour)
+	claims.SessionID = sessionID
+	token, _ := pkg.SignClaims(claims)
+	return token
+}
+
+// TestLoginCreatesSession tests that a login records the device and ties the tokens to the session
+func TestLoginCreatesSession(t *testing.T) {
+	router, server, mockUserRepo, mockSessionRepo, mockRefreshTokenRepo := setupSessionTestRouter()
+	defer server.Close()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+
+	var session *domain.Session
+	mockSessionRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Session")).Run(func(args mock.Arguments) {
+		session = args.Get(1).(*domain.Session)
+	}).Return(nil)
+
+	var refreshToken *domain.RefreshToken
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Run(func(args mock.Arguments) {
+		refreshToken = args.Get(1).(*domain.RefreshToken)
+	}).Return(nil)
+
+	req := jsonRequest("POST", "/login", map[string]string{"email": "test@example.com", "password": "password1234", "device_name": "Work laptop"})
+	req.RemoteAddr = "198.51.100.4:40000"
+	req.Header.Set("User-Agent", "TestBrowser/1.0")
+	w := serve(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 1, session.UserID)
+	assert.Equal(t, "Work laptop", session.DeviceName)
+	assert.Equal(t, "TestBrowser/1.0", session.UserAgent)
+	assert.Equal(t, "198.51.100.4", session.IP)
+	assert.Equal(t, refreshToken.FamilyID, session.ID)
+
+	var tokens domain.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	claims, err := pkg.ValidateJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, claims.SessionID)
+
+	// The listing marks the session the request came from
+	mockSessionRepo.On("ListActiveByUser", mock.Anything, 1).Return([]*domain.Session{
+		{ID: session.ID, UserID: 1, DeviceName: "Work laptop"},
+		{ID: "other-session", UserID: 1, DeviceName: "Phone"},
+	}, nil)
+
+	w = performAuthorized(router, "GET", "/sessions", tokens.AccessToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var listed struct {
+		Sessions []domain.Session `json:"sessions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.Len(t, listed.Sessions, 2)
+	assert.True(t, listed.Sessions[0].Current)
+	assert.False(t, listed.Sessions[1].Current)
+}
+
+// TestRevokeSession tests that revoking a session rejects its tokens and leaves other devices signed in
+func TestRevokeSession(t *testing.T) {
+	router, server, _, mockSessionRepo, mockRefreshTokenRepo := setupSessionTestRouter()
+	defer server.Close()
+
+	laptopToken := sessionToken(1, "laptop-session")
+	phoneToken := sessionToken(1, "phone-session")
+
+	mockSessionRepo.On("Revoke", mock.Anything, "phone-session", 1).Return(true, nil).Once()
+	mockSessionRepo.On("Revoke", mock.Anything, "phone-session", 1).Return(false, nil)
+	mockSessionRepo.On("Revoke", mock.Anything, "laptop-session", 2).Return(false, nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, "phone-session").Return(nil).Once()
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "DELETE", "/sessions/phone-session", laptopToken).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, performAuthorized(router, "GET", "/protected", phoneToken).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "GET", "/protected", laptopToken).Code)
+
+	// Already revoked sessions and other users' sessions are not found
+	assert.Equal(t, http.StatusNotFound, performAuthorized(router, "DELETE", "/sessions/phone-session", laptopToken).Code)
+	assert.Equal(t, http.StatusNotFound, performAuthorized(router, "DELETE", "/sessions/laptop-session", sessionToken(2, "intruder-session")).Code)
+
+	mockSessionRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+// TestRevokeSessionClosesWebSocket tests that open connections of a revoked session are terminated
+func TestRevokeSessionClosesWebSocket(t *testing.T) {
+	router, server, _, mockSessionRepo, mockRefreshTokenRepo := setupSessionTestRouter()
+	defer server.Close()
+
+	laptopToken := sessionToken(1, "laptop-session")
+	phoneToken := sessionToken(1, "phone-session")
+
+	mockSessionRepo.On("Revoke", mock.Anything, "phone-session", 1).Return(true, nil)
+	mockRefreshTokenRepo.On("RevokeFamily", mock.Anything, "phone-session").Return(nil)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/chat/ws"
+	dial := func(token string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer " + token}})
+		assert.NoError(t, err)
+		return conn
+	}
+
+	phoneConn := dial(phoneToken)
+	defer phoneConn.Close()
+	laptopConn := dial(laptopToken)
+	defer laptopConn.Close()
+
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "DELETE", "/sessions/phone-session", laptopToken).Code)
+
+	phoneConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := phoneConn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "expected the phone connection to be closed, got %v", err)
+
+	// The other device stays connected
+	laptopConn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = laptopConn.ReadMessage()
+	netErr, ok := err.(interface{ Timeout() bool })
+	assert.True(t, ok && netErr.Timeout(), "expected the laptop connection to stay open, got %v", err)
+}
+
+// TestSyncRevocationsCoversImpersonationTokens tests that revocations are kept
+// for as long as impersonation tokens issued before them remain valid
+func TestSyncRevocationsCoversImpersonationTokens(t *testing.T) {
+	mockRevocationRepo := new(MockRevocationRepository)
+	mockSessionRepo := new(MockSessionRepository)
+	revocationService := service.NewRevocationService(nil)
+	authUsecase := usecase.NewAuthUsecase(usecase.AuthDependencies{
+		RevocationRepo:    mockRevocationRepo,
+		RevocationService: revocationService,
+		SessionRepo:       mockSessionRepo,
+	}, &config.Config{AccessTokenExpiration: 15, ImpersonationTokenExpiration: 60})
+
+	now := time.Now()
+	revokedAt := now.Add(-30 * time.Minute)
+	coversImpersonation := mock.MatchedBy(func(cutoff time.Time) bool {
+		return cutoff.Before(now.Add(-59 * time.Minute))
+	})
+	mockRevocationRepo.On("DeleteExpired", mock.Anything, coversImpersonation).Return(nil)
+	mockRevocationRepo.On("GetRevokedTokens", mock.Anything).Return([]*domain.RevokedToken{}, nil)
+	mockRevocationRepo.On("GetUserRevocations", mock.Anything, coversImpersonation).Return([]*domain.UserRevocation{{UserID: 1, RevokedBefore: revokedAt}}, nil)
+	mockSessionRepo.On("ListRevokedSince", mock.Anything, coversImpersonation).Return([]*domain.Session{{ID: "impersonation-session", UserID: 2, RevokedAt: &revokedAt}}, nil)
+
+	assert.NoError(t, authUsecase.SyncRevocations(context.Background()))
+	mockRevocationRepo.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+
+	// An impersonation token issued 40 minutes ago is still valid, so both revocations still apply
+	issuedAt := now.Add(-40 * time.Minute)
+	assert.True(t, revocationService.IsRevoked("", "", 1, issuedAt))
+	assert.True(t, revocationService.IsRevoked("", "impersonation-session", 2, issuedAt))
+}
+
+// TestLongDeviceNameTruncatedByCharacter tests that a long device name is cut
+// to the column length without splitting a multi-byte character
+func TestLongDeviceNameTruncatedByCharacter(t *testing.T) {
+	router, server, mockUserRepo, mockSessionRepo, mockRefreshTokenRepo := setupSessionTestRouter()
+	defer server.Close()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.DefaultCost)
+	mockUserRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword)}, nil)
+	mockRefreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	var session *domain.Session
+	mockSessionRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.Session")).Run(func(args mock.Arguments) {
+		session = args.Get(1).(*domain.Session)
+	}).Return(nil)
+
+	deviceName := "a" + strings.Repeat("ноутбук ", 20)
+	req := jsonRequest("POST", "/login", map[string]string{"email": "test@example.com", "password": "password1234", "device_name": deviceName})
+	assert.Equal(t, http.StatusOK, serve(router, req).Code)
+
+	assert.True(t, utf8.ValidString(session.DeviceName))
+	assert.Equal(t, 100, utf8.RuneCountInString(session.DeviceName))
+	assert.Equal(t, string([]rune(deviceName)[:100]), session.DeviceName)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// RecordingTypingPublisher collects the typing events that would go to NATS
+type RecordingTypingPublisher struct {
+	Events chan domain.TypingEvent
+}
+
+func NewRecordingTypingPublisher() *RecordingTypingPublisher {
+	return &RecordingTypingPublisher{Events: make(chan domain.TypingEvent, 100)}
+}
+
+func (p *RecordingTypingPublisher) PublishTypingEvent(event *domain.TypingEvent, memberIDs []int) error {
+	p.Events <- *event
+	return nil
+}
+
+// Next waits for the next published event
+func (p *RecordingTypingPublisher) Next(t *testing.T) domain.TypingEvent {
+	select {
+	case event := <-p.Events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no typing event was published")
+		return domain.TypingEvent{}
+	}
+}
+
+// TestTypingServiceKeepAliveAndExpiry tests how often typing events are published
+func TestTypingServiceKeepAliveAndExpiry(t *testing.T) {
+	publisher := NewRecordingTypingPublisher()
+	typing := service.NewTypingService(publisher)
+	typing.Timeout = 200 * time.Millisecond
+	typing.KeepAlive = 100 * time.Millisecond
+
+	assert.NoError(t, typing.Start(1, 7, []int{1, 2}))
+	event := publisher.Next(t)
+	assert.True(t, event.Typing)
+	assert.Equal(t, 7, event.ConversationID)
+	if assert.NotNil(t, event.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().Add(typing.Timeout), *event.ExpiresAt, 100*time.Millisecond)
+	}
+
+	// Keystrokes within the keep-alive interval are not republished
+	assert.True(t, typing.Refresh(1, 7))
+	assert.NoError(t, typing.Start(1, 7, []int{1, 2}))
+	assert.Empty(t, publisher.Events)
+
+	time.Sleep(120 * time.Millisecond)
+	assert.True(t, typing.Refresh(1, 7))
+	assert.True(t, publisher.Next(t).Typing)
+
+	// A client that goes quiet is stopped by the server
+	event = publisher.Next(t)
+	assert.False(t, event.Typing)
+	assert.Nil(t, event.ExpiresAt)
+	assert.False(t, typing.Refresh(1, 7))
+
+	// Stopping twice publishes once
+	assert.NoError(t, typing.Start(1, 7, []int{1, 2}))
+	publisher.Next(t)
+	typing.Stop(1, 7)
+	typing.Stop(1, 7)
+	assert.False(t, publisher.Next(t).Typing)
+	time.Sleep(250 * time.Millisecond)
+	assert.Empty(t, publisher.Events)
+}
+
+// TestTypingServiceThrottle tests that a client cycling typing events is cut off
+func TestTypingServiceThrottle(t *testing.T) {
+	publisher := NewRecordingTypingPublisher()
+	typing := service.NewTypingService(publisher)
+	typing.MaxStarts = 3
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, typing.Start(1, 7, []int{1, 2}))
+		typing.Stop(1, 7)
+	}
+	assert.True(t, typing.Throttled(1))
+	assert.ErrorIs(t, typing.Start(1, 7, []int{1, 2}), domain.ErrTypingThrottled)
+	assert.Len(t, publisher.Events, 6)
+
+	// Other users are not affected
+	assert.False(t, typing.Throttled(2))
+	assert.NoError(t, typing.Start(2, 7, []int{1, 2}))
+}
+
+// TestTypingWebSocketFrames tests typing frames from a member and an outsider
+func TestTypingWebSocketFrames(t *testing.T) {
+	publisher := NewRecordingTypingPublisher()
+	router, _ := newGroupChatTestRouter(func(chatUsecase *usecase.ChatUsecase) {
+		chatUsecase.Typing = service.NewTypingService(publisher)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, response := chatRequest(router, 1, "POST", "/chat/groups", map[string]interface{}{"name": "Ops", "member_ids": []int{2}})
+	id := int(response["data"].(map[string]interface{})["id"].(float64))
+
+	frame := func(conn *websocket.Conn, frameType string) {
+		data, _ := json.Marshal(domain.TypingRequest{ConversationID: id})
+		assert.NoError(t, conn.WriteJSON(pkg.WebSocketMessage{Type: frameType, Data: data}))
+	}
+
+	member := dialChat(t, server, 2)
+	defer member.Close()
+	frame(member, pkg.TypeTypingStart)
+	event := publisher.Next(t)
+	assert.Equal(t, domain.TypingEvent{ConversationID: id, UserID: 2, Typing: true, ExpiresAt: event.ExpiresAt}, event)
+
+	// Sending the message ends the indicator
+	chatRequest(router, 2, "POST", "/chat/conversations/"+strconv.Itoa(id)+"/messages", map[string]string{"content": "done"})
+	assert.False(t, publisher.Next(t).Typing)
+
+	outsider := dialChat(t, server, 3)
+	defer outsider.Close()
+	frame(outsider, pkg.TypeTypingStart)
+	var reply pkg.WebSocketMessage
+	assert.NoError(t, outsider.ReadJSON(&reply))
+	assert.Equal(t, pkg.TypeError, reply.Type)
+	assert.Empty(t, publisher.Events)
+}
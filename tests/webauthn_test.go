@@ -0,0 +1,551 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-auth-app/config"
+	"go-auth-app/internal/domain"
+	"go-auth-app/internal/service"
+	"go-auth-app/internal/usecase"
+	"go-auth-app/pkg"
+)
+
+// MemoryWebAuthnRepository keeps passkeys and challenges in memory
+type MemoryWebAuthnRepository struct {
+	mu          sync.Mutex
+	nextID      int
+	Challenges  map[string]*domain.WebAuthnChallenge
+	Credentials []*domain.WebAuthnCredential
+}
+
+func NewMemoryWebAuthnRepository() *MemoryWebAuthnRepository {
+	return &MemoryWebAuthnRepository{Challenges: map[string]*domain.WebAuthnChallenge{}}
+}
+
+func (r *MemoryWebAuthnRepository) CreateChallenge(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Challenges[challenge.ChallengeHash] = challenge
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) ConsumeChallenge(ctx context.Context, challengeHash string) (*domain.WebAuthnChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge := r.Challenges[challengeHash]
+	delete(r.Challenges, challengeHash)
+	return challenge, nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteExpiredChallenges(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) CreateCredential(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	credential.ID = r.nextID
+	credential.CreatedAt = time.Now()
+	r.Credentials = append(r.Credentials, credential)
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) GetCredential(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, credential := range r.Credentials {
+		if bytes.Equal(credential.CredentialID, credentialID) {
+			stored := *credential
+			return &stored, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *MemoryWebAuthnRepository) ListCredentials(ctx context.Context, userID int) ([]*domain.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credentials := []*domain.WebAuthnCredential{}
+	for _, credential := range r.Credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *MemoryWebAuthnRepository) UpdateSignCount(ctx context.Context, id int, signCount uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, credential := range r.Credentials {
+		if credential.ID == id {
+			credential.SignCount = signCount
+			credential.LastUsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredentialsForUser(ctx context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := []*domain.WebAuthnCredential{}
+	for _, credential := range r.Credentials {
+		if credential.UserID != userID {
+			kept = append(kept, credential)
+		}
+	}
+	removed := len(r.Credentials) - len(kept)
+	r.Credentials = kept
+	return removed, nil
+}
+
+func (r *MemoryWebAuthnRepository) DeleteCredential(ctx context.Context, id, userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, credential := range r.Credentials {
+		if credential.ID == id && credential.UserID == userID {
+			r.Credentials = append(r.Credentials[:i], r.Credentials[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cborEntry is a map entry for cborEncode, which keeps entries in the given order
+type cborEntry struct {
+	key, value interface{}
+}
+
+// cborEncode writes the CBOR an authenticator would send: integers, byte and
+// text strings, and maps given as []cborEntry
+func cborEncode(value interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []cborEntry:
+		out := head(5, uint64(len(v)))
+		for _, entry := range v {
+			out = append(out, cborEncode(entry.key)...)
+			out = append(out, cborEncode(entry.value)...)
+		}
+		return out
+	default:
+		panic("cborEncode: unsupported type")
+	}
+}
+
+// softwareAuthenticator is a passkey authenticator with a P-256 key held in memory
+type softwareAuthenticator struct {
+	rpID         string
+	origin       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	// flags are the authenticator data flags it reports, user present and verified by default
+	flags byte
+}
+
+func newSoftwareAuthenticator(t *testing.T, rpID, origin string) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+
+	return &softwareAuthenticator{
+		rpID:         rpID,
+		origin:       origin,
+		key:          key,
+		credentialID: credentialID,
+		flags:        pkg.WebAuthnFlagUserPresent | pkg.WebAuthnFlagUserVerified,
+	}
+}
+
+// clientDataJSON is what the browser writes for a ceremony
+func (a *softwareAuthenticator) clientDataJSON(ceremony, challenge string) []byte {
+	clientData, _ := json.Marshal(map[string]interface{}{"type": ceremony, "challenge": challenge, "origin": a.origin, "crossOrigin": false})
+	return clientData
+}
+
+// authenticatorData builds the signed authenticator data, with the credential when attested
+func (a *softwareAuthenticator) authenticatorData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, cborEncode([]cborEntry{
+			{1, 2},
+			{3, pkg.COSEAlgES256},
+			{-1, 1},
+			{-2, a.key.X.FillBytes(make([]byte, 32))},
+			{-3, a.key.Y.FillBytes(make([]byte, 32))},
+		})...)
+	}
+	return data
+}
+
+// create answers navigator.credentials.create with the JSON a browser would post
+func (a *softwareAuthenticator) create(t *testing.T, options domain.PublicKeyCredentialCreationOptions) map[string]interface{} {
+	a.userHandle = options.User.ID
+
+	attestationObject := cborEncode([]cborEntry{
+		{"fmt", "none"},
+		{"attStmt", []cborEntry{}},
+		{"authData", a.authenticatorData(a.flags|pkg.WebAuthnFlagAttestedData, true)},
+	})
+
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientDataJSON(pkg.WebAuthnCeremonyCreate, options.Challenge)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+			"transports":        []string{"internal", "hybrid", "carrier-pigeon"},
+		},
+		"name": "Laptop",
+	}
+}
+
+// get answers navigator.credentials.get, counting the signature
+func (a *softwareAuthenticator) get(t *testing.T, options domain.PublicKeyCredentialRequestOptions) map[string]interface{} {
+	assert.Equal(t, a.rpID, options.RPID)
+	a.signCount++
+
+	clientData := a.clientDataJSON(pkg.WebAuthnCeremonyGet, options.Challenge)
+	authData := a.authenticatorData(a.flags, false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	assert.NoError(t, err)
+
+	return map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+		},
+		"device_name": "Laptop",
+	}
+}
+
+// webauthnTest holds the router and repositories of the passkey tests
+type webauthnTest struct {
+	router           *gin.Engine
+	userRepo         *MockUserRepository
+	webauthnRepo     *MemoryWebAuthnRepository
+	mfaRepo          *MockMFARepository
+	userTokenRepo    *MockUserTokenRepository
+	revocationRepo   *MockRevocationRepository
+	refreshTokenRepo *MockRefreshTokenRepository
+	mailer           *RecordingMailer
+}
+
+// setupWebAuthnTestRouter creates a test router for the relying party app.example
+// with user 5, whose password is password1234 and who has no second factor
+func setupWebAuthnTestRouter() *webauthnTest {
+	gin.SetMode(gin.TestMode)
+
+	test := &webauthnTest{
+		router:           gin.New(),
+		userRepo:         new(MockUserRepository),
+		webauthnRepo:     NewMemoryWebAuthnRepository(),
+		mfaRepo:          new(MockMFARepository),
+		userTokenRepo:    new(MockUserTokenRepository),
+		revocationRepo:   new(MockRevocationRepository),
+		refreshTokenRepo: new(MockRefreshTokenRepository),
+		mailer:           NewRecordingMailer(),
+	}
+	revocationService := service.NewRevocationService(nil)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.MinCost)
+	test.refreshTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+	test.userRepo.On("GetByID", mock.Anything, 5).Return(&domain.User{ID: 5, Name: "Ada Lovelace", Email: "ada@example.com", Password: string(hashedPassword)}, nil)
+	test.mfaRepo.On("GetByUserID", mock.Anything, 5).Return(nil, nil)
+
+	testApp{
+		Deps: usecase.AuthDependencies{
+			UserRepo:          test.userRepo,
+			RefreshTokenRepo:  test.refreshTokenRepo,
+			RevocationRepo:    test.revocationRepo,
+			RevocationService: revocationService,
+			UserTokenRepo:     test.userTokenRepo,
+			MFARepo:           test.mfaRepo,
+			WebAuthnRepo:      test.webauthnRepo,
+			Mailer:            test.mailer,
+		},
+		Config: &config.Config{WebAuthnRPID: "app.example", WebAuthnRPName: "App", WebAuthnOrigins: []string{"https://app.example"}},
+	}.setupRoutes(test.router)
+
+	return test
+}
+
+// beginPasskeyRegistration asks for registration options, re-authenticating with the given body
+func beginPasskeyRegistration(router *gin.Engine, token string, body interface{}) *httptest.ResponseRecorder {
+	req := jsonRequest("POST", "/webauthn/register/begin", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return serve(router, req)
+}
+
+// registerPasskey runs the registration ceremony for user 5 and returns the response to the finish request
+func registerPasskey(t *testing.T, router *gin.Engine, authenticator *softwareAuthenticator) *httptest.ResponseRecorder {
+	token := tokenWithPermissions(5, nil, nil)
+
+	w := beginPasskeyRegistration(router, token, map[string]string{"current_password": "password1234"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var begin struct {
+		PublicKey domain.PublicKeyCredentialCreationOptions `json:"publicKey"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &begin))
+
+	req := jsonRequest("POST", "/webauthn/register/finish", authenticator.create(t, begin.PublicKey))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return serve(router, req)
+}
+
+// loginWithPasskey runs the login ceremony and returns the response to the finish request
+func loginWithPasskey(t *testing.T, router *gin.Engine, authenticator *softwareAuthenticator) *httptest.ResponseRecorder {
+	w := postJSON(router, "/webauthn/login/begin", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var begin struct {
+		PublicKey domain.PublicKeyCredentialRequestOptions `json:"publicKey"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &begin))
+
+	return postJSON(router, "/webauthn/login/finish", authenticator.get(t, begin.PublicKey))
+}
+
+// TestPasskeyRegistrationAndLogin tests adding a passkey and signing in with it alone
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	test := setupWebAuthnTestRouter()
+	router, webauthnRepo := test.router, test.webauthnRepo
+	authenticator := newSoftwareAuthenticator(t, "app.example", "https://app.example")
+
+	w := registerPasskey(t, router, authenticator)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []byte("5"), authenticator.userHandle)
+
+	// The owner hears about every passkey added to the account
+	email := test.mailer.Next(t)
+	assert.Equal(t, "ada@example.com", email.To)
+	assert.Contains(t, email.Subject, "passkey")
+
+	if assert.Len(t, webauthnRepo.Credentials, 1) {
+		credential := webauthnRepo.Credentials[0]
+		assert.Equal(t, 5, credential.UserID)
+		assert.Equal(t, "Laptop", credential.Name)
+		assert.Equal(t, []string{"internal", "hybrid"}, credential.Transports)
+	}
+
+	// Registering the same authenticator twice is refused
+	assert.Equal(t, http.StatusConflict, registerPasskey(t, router, authenticator).Code)
+
+	w = loginWithPasskey(t, router, authenticator)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result domain.LoginResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	if assert.NotNil(t, result.TokenPair) {
+		claims, err := pkg.ValidateJWT(result.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, claims.UserID)
+		assert.NotEmpty(t, claims.SessionID)
+		assert.NotEmpty(t, result.RefreshToken)
+	}
+	assert.Equal(t, uint32(1), webauthnRepo.Credentials[0].SignCount)
+
+	// The session token works like one from /login
+	w = performAuthorized(router, "GET", "/webauthn/credentials", result.AccessToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"Laptop"`)
+	assert.NotContains(t, w.Body.String(), "public_key")
+}
+
+// TestPasskeyLoginRejectsForgedAssertions tests the origin, signature, replay and clone checks
+func TestPasskeyLoginRejectsForgedAssertions(t *testing.T) {
+	test := setupWebAuthnTestRouter()
+	router, webauthnRepo := test.router, test.webauthnRepo
+	authenticator := newSoftwareAuthenticator(t, "app.example", "https://app.example")
+	assert.Equal(t, http.StatusCreated, registerPasskey(t, router, authenticator).Code)
+
+	// A phishing page on another origin cannot use the passkey
+	phished := *authenticator
+	phished.origin = "https://app-example.attacker"
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, router, &phished).Code)
+
+	// Someone else's key cannot sign for the credential
+	impostor := newSoftwareAuthenticator(t, "app.example", "https://app.example")
+	impostor.credentialID = authenticator.credentialID
+	impostor.userHandle = authenticator.userHandle
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, router, impostor).Code)
+
+	// Without user verification the passkey is only one factor
+	unverified := *authenticator
+	unverified.flags = pkg.WebAuthnFlagUserPresent
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, router, &unverified).Code)
+
+	// A replayed assertion answers a challenge that was already used
+	w := postJSON(router, "/webauthn/login/begin", nil)
+	var begin struct {
+		PublicKey domain.PublicKeyCredentialRequestOptions `json:"publicKey"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	assertion := authenticator.get(t, begin.PublicKey)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/webauthn/login/finish", assertion).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/webauthn/login/finish", assertion).Code)
+
+	// A clone of the authenticator falls behind the stored sign count
+	clone := *authenticator
+	clone.signCount = 0
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, router, &clone).Code)
+	assert.Equal(t, http.StatusOK, loginWithPasskey(t, router, authenticator).Code)
+
+	// A deleted passkey no longer signs in
+	token := tokenWithPermissions(5, nil, nil)
+	id := webauthnRepo.Credentials[0].ID
+	assert.Equal(t, http.StatusNotFound, performAuthorized(router, "DELETE", "/webauthn/credentials/999", token).Code)
+	assert.Equal(t, http.StatusOK, performAuthorized(router, "DELETE", "/webauthn/credentials/"+strconv.Itoa(id), token).Code)
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, router, authenticator).Code)
+}
+
+// TestPasskeyRegistrationChecksRelyingParty tests that credentials for another site or origin are refused
+func TestPasskeyRegistrationChecksRelyingParty(t *testing.T) {
+	test := setupWebAuthnTestRouter()
+	router, webauthnRepo := test.router, test.webauthnRepo
+
+	w := registerPasskey(t, router, newSoftwareAuthenticator(t, "app.example", "https://attacker.example"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = registerPasskey(t, router, newSoftwareAuthenticator(t, "attacker.example", "https://app.example"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Empty(t, webauthnRepo.Credentials)
+
+	// Passkeys are added by a signed-in user only
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/webauthn/register/begin", nil).Code)
+
+	// The algorithms offered are the ones the app verifies
+	w = beginPasskeyRegistration(router, tokenWithPermissions(5, nil, nil), map[string]string{"current_password": "password1234"})
+	var begin struct {
+		PublicKey domain.PublicKeyCredentialCreationOptions `json:"publicKey"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	algs := []int{}
+	for _, param := range begin.PublicKey.PubKeyCredParams {
+		algs = append(algs, param.Alg)
+	}
+	sort.Ints(algs)
+	assert.Equal(t, []int{pkg.COSEAlgRS256, pkg.COSEAlgEdDSA, pkg.COSEAlgES256}, algs)
+	assert.Equal(t, "required", begin.PublicKey.AuthenticatorSelection.UserVerification)
+}
+
+// TestPasskeyRegistrationRequiresReauthentication tests that an access token
+// alone cannot add a passkey: the password and, when enabled, a TOTP code are asked for
+func TestPasskeyRegistrationRequiresReauthentication(t *testing.T) {
+	test := setupWebAuthnTestRouter()
+	token := tokenWithPermissions(5, nil, nil)
+
+	assert.Equal(t, http.StatusBadRequest, beginPasskeyRegistration(test.router, token, map[string]string{}).Code)
+	assert.Equal(t, http.StatusForbidden, beginPasskeyRegistration(test.router, token, map[string]string{"current_password": "wrong-password"}).Code)
+	assert.Empty(t, test.webauthnRepo.Challenges, "no ceremony starts without the password")
+
+	// User 6 has two-factor authentication enabled
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password1234"), bcrypt.MinCost)
+	secret, _ := pkg.GenerateTOTPSecret()
+	enabledAt := time.Now()
+	test.userRepo.On("GetByID", mock.Anything, 6).Return(&domain.User{ID: 6, Email: "grace@example.com", Password: string(hashedPassword)}, nil)
+	test.mfaRepo.On("GetByUserID", mock.Anything, 6).Return(&domain.UserMFA{UserID: 6, TOTPSecret: secret, EnabledAt: &enabledAt}, nil)
+	token = tokenWithPermissions(6, nil, nil)
+
+	w := beginPasskeyRegistration(test.router, token, map[string]string{"current_password": "password1234"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), domain.ErrMFACodeRequired.Error())
+
+	step := pkg.TOTPStep(time.Now())
+	code, _ := pkg.TOTPCode(secret, step)
+	test.mfaRepo.On("UseStep", mock.Anything, 6, step).Return(true, nil).Once()
+
+	w = beginPasskeyRegistration(test.router, token, map[string]string{"current_password": "password1234", "code": code})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, test.webauthnRepo.Challenges, 1)
+}
+
+// TestPasswordResetRemovesPasskeys tests that taking an account back with a
+// password reset also removes passkeys someone else may have added
+func TestPasswordResetRemovesPasskeys(t *testing.T) {
+	test := setupWebAuthnTestRouter()
+	authenticator := newSoftwareAuthenticator(t, "app.example", "https://app.example")
+	assert.Equal(t, http.StatusCreated, registerPasskey(t, test.router, authenticator).Code)
+	test.mailer.Next(t)
+
+	test.userTokenRepo.On("GetByHash", mock.Anything, pkg.HashToken("reset-token"), domain.TokenPurposePasswordReset).Return(&domain.UserToken{
+		ID:        3,
+		UserID:    5,
+		Purpose:   domain.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	test.userTokenRepo.On("MarkUsed", mock.Anything, 3).Return(true, nil)
+	test.userTokenRepo.On("InvalidateForUser", mock.Anything, 5, domain.TokenPurposePasswordReset).Return(nil)
+	test.userRepo.On("UpdatePassword", mock.Anything, 5, mock.AnythingOfType("string")).Return(nil)
+	test.revocationRepo.On("RevokeAllForUser", mock.Anything, 5, mock.AnythingOfType("time.Time")).Return(nil)
+	test.refreshTokenRepo.On("RevokeAllForUser", mock.Anything, 5).Return(nil)
+
+	w := postJSON(test.router, "/password/reset", map[string]string{"token": "reset-token", "password": "aBrandNewPassword1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, test.webauthnRepo.Credentials)
+	assert.Contains(t, test.mailer.Next(t).Body, "passkeys were removed")
+
+	assert.Equal(t, http.StatusUnauthorized, loginWithPasskey(t, test.router, authenticator).Code)
+}
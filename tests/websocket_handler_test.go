@@ -5,7 +5,6 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"go-auth-app/internal/delivery"
-	"go-auth-app/internal/routes"
 	"go-auth-app/internal/usecase"
 	"net/http/httptest"
 	"strings"
@@ -14,24 +13,16 @@ import (
 
 // setupWebSocketTestRouter creates a test router specifically for WebSocket tests
 func setupWebSocketTestRouter() (*gin.Engine, *MockUserRepository, *MockChatRepository, *httptest.Server) {
-	gin.SetMode(gin.TestMode)
-	router := gin.Default()
-
 	// Create mocks
 	mockUserRepo := new(MockUserRepository)
 	mockChatRepo := new(MockChatRepository)
 
-	// Create usecases
-	authUsecase := usecase.NewAuthUsecase(mockUserRepo)
-	chatUsecase := usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil)
-
-	// Create handlers
-	authHandler := delivery.NewAuthHandler(authUsecase)
-	chatHandler := delivery.NewChatHandler(chatUsecase)
-	wsHandler := delivery.NewWebSocketHandler(chatUsecase, nil)
-
-	// Setup routes
-	routes.SetupRoutes(router, authHandler, chatHandler, wsHandler, nil)
+	chatUsecase := usecase.NewChatUsecase(mockChatRepo, mockUserRepo, nil, nil)
+	router, _ := testApp{
+		Deps:        usecase.AuthDependencies{UserRepo: mockUserRepo},
+		ChatUsecase: chatUsecase,
+		WSHandler:   delivery.NewWebSocketHandler(chatUsecase, nil, nil),
+	}.newRouter()
 
 	// Create test server
 	server := httptest.NewServer(router)